@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"transaction_project/models"
+	"transaction_project/views"
+)
+
+type AccountStatement struct {
+	transService *models.TransactionService
+}
+
+// NewAccountStatementController create a new AccountStatement controller using the provided TransactionService
+func NewAccountStatementController(transService *models.TransactionService) *AccountStatement {
+	return &AccountStatement{
+		transService: transService,
+	}
+}
+
+// Render build the statement of the account for the period from from, inclusive, to to, exclusive, and
+// write it to w in the format. It returns the statement so the caller can name the file.
+func (sC *AccountStatement) Render(w io.Writer, format, account string, from, to time.Time) (*models.AccountStatement, error) {
+	if !views.Supported(format) {
+		return nil, fmt.Errorf("%w: %q", views.ErrUnknownFormat, format)
+	}
+	statement, err := sC.transService.Statement(account, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return statement, views.RenderStatement(w, format, statement)
+}
+
+// NewHandler create a new http.Handler that download the statement of the account parameter of the query
+// string, the caller's own account when it is missing, for the period of ParseStatementPeriod in the
+// format of the format parameter, pdf when it is missing (owner of the account, auditor or admin)
+func (sC *AccountStatement) NewHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		account := query.Get("account")
+		if account == "" {
+			if user := currentUser(r.Context()); user != nil {
+				account = user.Email
+			}
+		}
+		if err := requireOwnerOrRole(r.Context(), account, models.RoleAuditor, models.RoleAdmin); err != nil {
+			writeAuthError(w, err)
+			return
+		}
+		format := strings.ToLower(query.Get("format"))
+		if format == "" {
+			format = views.FormatPDF
+		}
+		if !views.Supported(format) {
+			http.Error(w, fmt.Sprintf("%v: %q", views.ErrUnknownFormat, format), http.StatusBadRequest)
+			return
+		}
+		from, to, err := ParseStatementPeriod(query, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		statement, err := sC.transService.Statement(account, from, to)
+		if err != nil {
+			log.Printf("statement of %s: %v", account, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		disposition := "attachment"
+		if format == views.FormatHTML {
+			disposition = "inline"
+		}
+		w.Header().Set("Content-Type", views.ContentType(format))
+		w.Header().Set("Content-Disposition", disposition+`; filename="`+views.StatementFilename(statement, format)+`"`)
+		if err := views.RenderStatement(w, format, statement); err != nil {
+			// The status was already sent, abort the response so the client does not keep a truncated file
+			log.Printf("statement of %s: %v", account, err)
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// ParseStatementPeriod decode the period of a statement from URL parameters: month as YYYY-MM, or from
+// and to as YYYY-MM-DD, to being exclusive. The month before now is used when none is provided.
+func ParseStatementPeriod(values url.Values, now time.Time) (from, to time.Time, err error) {
+	if month := values.Get("month"); month != "" {
+		from, err = time.Parse("2006-01", month)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid month %q, expected YYYY-MM", month)
+		}
+		return from, from.AddDate(0, 1, 0), nil
+	}
+
+	rawFrom, rawTo := values.Get("from"), values.Get("to")
+	if rawFrom == "" && rawTo == "" {
+		to = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return to.AddDate(0, -1, 0), to, nil
+	}
+	if rawFrom == "" || rawTo == "" {
+		return from, to, fmt.Errorf("both from and to are needed")
+	}
+	if from, err = time.Parse("2006-01-02", rawFrom); err != nil {
+		return from, to, fmt.Errorf("invalid from %q, expected YYYY-MM-DD", rawFrom)
+	}
+	if to, err = time.Parse("2006-01-02", rawTo); err != nil {
+		return from, to, fmt.Errorf("invalid to %q, expected YYYY-MM-DD", rawTo)
+	}
+	if !to.After(from) {
+		return from, to, fmt.Errorf("to must be after from")
+	}
+	return from, to, nil
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"strings"
+	"transaction_project/models"
+)
+
+type Approval struct {
+	approvalService *models.ApprovalService
+}
+
+// ApprovalPolicyInput hold the fields needed to create a new models.ApprovalPolicy
+// Every field but Name is optional, a nil condition is met by every transaction, a nil RequiredApprovals
+// asks for a single approval and a policy is enabled unless Enabled is false
+type ApprovalPolicyInput struct {
+	Name              string
+	MinValue          *float64
+	Currency          *string
+	Counterparty      *string
+	CreatorRoles      []string
+	ApproverRole      *string
+	RequiredApprovals *int
+	Enabled           *bool
+}
+
+// ApprovalPolicyPatch hold the fields of a models.ApprovalPolicy that can be updated
+// Every field is optional, a nil field keeps the value already stored in the database. ClearMinValue
+// removes the value threshold.
+type ApprovalPolicyPatch struct {
+	Name              *string
+	MinValue          *float64
+	ClearMinValue     bool
+	Currency          *string
+	Counterparty      *string
+	CreatorRoles      []string
+	ApproverRole      *string
+	RequiredApprovals *int
+	Enabled           *bool
+}
+
+// NewApprovalController create a new Approval controller using the provided ApprovalService
+func NewApprovalController(approvalService *models.ApprovalService) *Approval {
+	return &Approval{
+		approvalService: approvalService,
+	}
+}
+
+// WithActor return a copy of the controller deciding on approvals as the provided actor
+func (aC *Approval) WithActor(actor string) *Approval {
+	return &Approval{
+		approvalService: aC.approvalService.WithActor(actor),
+	}
+}
+
+// NewPolicy create a new models.ApprovalPolicy using the models.ApprovalService
+func (aC *Approval) NewPolicy(input ApprovalPolicyInput) (*models.ApprovalPolicy, error) {
+	policy := &models.ApprovalPolicy{
+		Name:         input.Name,
+		MinValue:     input.MinValue,
+		CreatorRoles: strings.Join(input.CreatorRoles, ","),
+		Enabled:      true,
+	}
+	if input.Currency != nil {
+		policy.Currency = *input.Currency
+	}
+	if input.Counterparty != nil {
+		policy.Counterparty = *input.Counterparty
+	}
+	if input.ApproverRole != nil {
+		policy.ApproverRole = *input.ApproverRole
+	}
+	if input.RequiredApprovals != nil {
+		policy.RequiredApprovals = *input.RequiredApprovals
+	}
+	if input.Enabled != nil {
+		policy.Enabled = *input.Enabled
+	}
+	return policy, aC.approvalService.SavePolicy(policy)
+}
+
+// UpdatePolicy update an existing models.ApprovalPolicy using the models.ApprovalService
+func (aC *Approval) UpdatePolicy(id uint, patch ApprovalPolicyPatch) (*models.ApprovalPolicy, error) {
+	policy, err := aC.approvalService.ReadPolicy(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.Name != nil {
+		policy.Name = *patch.Name
+	}
+	if patch.MinValue != nil {
+		policy.MinValue = patch.MinValue
+	}
+	if patch.ClearMinValue {
+		policy.MinValue = nil
+	}
+	if patch.Currency != nil {
+		policy.Currency = *patch.Currency
+	}
+	if patch.Counterparty != nil {
+		policy.Counterparty = *patch.Counterparty
+	}
+	if patch.CreatorRoles != nil {
+		policy.CreatorRoles = strings.Join(patch.CreatorRoles, ",")
+	}
+	if patch.ApproverRole != nil {
+		policy.ApproverRole = *patch.ApproverRole
+	}
+	if patch.RequiredApprovals != nil {
+		policy.RequiredApprovals = *patch.RequiredApprovals
+	}
+	if patch.Enabled != nil {
+		policy.Enabled = *patch.Enabled
+	}
+
+	return policy, aC.approvalService.SavePolicy(policy)
+}
+
+// Approve approve a transaction awaiting approval using the models.ApprovalService
+func (aC *Approval) Approve(id uint, comment string) (*models.TransactionApproval, error) {
+	return aC.approvalService.Approve(id, comment)
+}
+
+// Reject reject a transaction awaiting approval using the models.ApprovalService
+func (aC *Approval) Reject(id uint, comment string) (*models.TransactionApproval, error) {
+	return aC.approvalService.Reject(id, comment)
+}
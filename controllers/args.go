@@ -0,0 +1,824 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"time"
+	"transaction_project/models"
+)
+
+// argError is returned when a GraphQL argument is missing or has an unexpected type
+func argError(name string) error {
+	return fmt.Errorf("GraphQL: missing or invalid %s", name)
+}
+
+// objectArg read a required input object from a GraphQL argument map
+func objectArg(args map[string]interface{}, name string) (map[string]interface{}, error) {
+	object, isOK := args[name].(map[string]interface{})
+	if !isOK {
+		return nil, argError(name)
+	}
+	return object, nil
+}
+
+// stringArg read a required string from a GraphQL argument map
+func stringArg(args map[string]interface{}, name string) (string, error) {
+	value, isOK := args[name].(string)
+	if !isOK {
+		return "", argError(name)
+	}
+	return value, nil
+}
+
+// optionalStringArg read an optional string from a GraphQL argument map, nil is returned if it is not provided
+func optionalStringArg(args map[string]interface{}, name string) (*string, error) {
+	raw, isSet := args[name]
+	if !isSet || raw == nil {
+		return nil, nil
+	}
+	value, isOK := raw.(string)
+	if !isOK {
+		return nil, argError(name)
+	}
+	return &value, nil
+}
+
+// floatArg read a required float from a GraphQL argument map
+func floatArg(args map[string]interface{}, name string) (float64, error) {
+	value, isOK := args[name].(float64)
+	if !isOK {
+		return 0, argError(name)
+	}
+	return value, nil
+}
+
+// optionalFloatArg read an optional float from a GraphQL argument map, nil is returned if it is not provided
+func optionalFloatArg(args map[string]interface{}, name string) (*float64, error) {
+	raw, isSet := args[name]
+	if !isSet || raw == nil {
+		return nil, nil
+	}
+	value, isOK := raw.(float64)
+	if !isOK {
+		return nil, argError(name)
+	}
+	return &value, nil
+}
+
+// optionalVersionArg read an optional version from a GraphQL argument map, nil is returned if it is not provided
+func optionalVersionArg(args map[string]interface{}, name string) (*uint, error) {
+	raw, isSet := args[name]
+	if !isSet || raw == nil {
+		return nil, nil
+	}
+	value, isOK := raw.(int)
+	if !isOK || value < 0 {
+		return nil, argError(name)
+	}
+	version := uint(value)
+	return &version, nil
+}
+
+// optionalTimeArg read an optional DateTime from a GraphQL argument map, nil is returned if it is not provided
+func optionalTimeArg(args map[string]interface{}, name string) (*time.Time, error) {
+	raw, isSet := args[name]
+	if !isSet || raw == nil {
+		return nil, nil
+	}
+	value, isOK := raw.(time.Time)
+	if !isOK {
+		return nil, argError(name)
+	}
+	return &value, nil
+}
+
+// transactionInputArg decode a TransactionInput GraphQL input object into a TransactionInput
+func transactionInputArg(args map[string]interface{}, name string) (input TransactionInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.Value, err = floatArg(object, "Value"); err != nil {
+		return input, err
+	}
+	if input.Note, err = optionalStringArg(object, "Note"); err != nil {
+		return input, err
+	}
+	if input.Sender, err = stringArg(object, "Sender"); err != nil {
+		return input, err
+	}
+	if input.Receiver, err = stringArg(object, "Receiver"); err != nil {
+		return input, err
+	}
+	if input.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return input, err
+	}
+	if input.ReceivedCurrency, err = optionalStringArg(object, "ReceivedCurrency"); err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// transactionPatchArg decode a TransactionPatch GraphQL input object into an UpdateTransactionInput
+func transactionPatchArg(args map[string]interface{}, name string) (input UpdateTransactionInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.Value, err = optionalFloatArg(object, "Value"); err != nil {
+		return input, err
+	}
+	if input.Note, err = optionalStringArg(object, "Note"); err != nil {
+		return input, err
+	}
+	if input.Sender, err = optionalStringArg(object, "Sender"); err != nil {
+		return input, err
+	}
+	if input.Receiver, err = optionalStringArg(object, "Receiver"); err != nil {
+		return input, err
+	}
+	if input.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return input, err
+	}
+	if input.ReceivedCurrency, err = optionalStringArg(object, "ReceivedCurrency"); err != nil {
+		return input, err
+	}
+	if input.Version, err = optionalVersionArg(object, "Version"); err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// userInputArg decode a UserInput GraphQL input object into a UserInput
+func userInputArg(args map[string]interface{}, name string) (input UserInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.Email, err = stringArg(object, "Email"); err != nil {
+		return input, err
+	}
+	if input.Password, err = stringArg(object, "Password"); err != nil {
+		return input, err
+	}
+	if input.Last, err = stringArg(object, "Last"); err != nil {
+		return input, err
+	}
+	if input.Middle, err = optionalStringArg(object, "Middle"); err != nil {
+		return input, err
+	}
+	if input.First, err = optionalStringArg(object, "First"); err != nil {
+		return input, err
+	}
+	if input.Phone, err = optionalStringArg(object, "Phone"); err != nil {
+		return input, err
+	}
+	if input.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// userPatchArg decode a UserPatch GraphQL input object into a UserPatch
+func userPatchArg(args map[string]interface{}, name string) (patch UserPatch, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return patch, err
+	}
+	if patch.Email, err = optionalStringArg(object, "Email"); err != nil {
+		return patch, err
+	}
+	if patch.Password, err = optionalStringArg(object, "Password"); err != nil {
+		return patch, err
+	}
+	if patch.Last, err = optionalStringArg(object, "Last"); err != nil {
+		return patch, err
+	}
+	if patch.Middle, err = optionalStringArg(object, "Middle"); err != nil {
+		return patch, err
+	}
+	if patch.First, err = optionalStringArg(object, "First"); err != nil {
+		return patch, err
+	}
+	if patch.Phone, err = optionalStringArg(object, "Phone"); err != nil {
+		return patch, err
+	}
+	if patch.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return patch, err
+	}
+	if patch.Version, err = optionalVersionArg(object, "Version"); err != nil {
+		return patch, err
+	}
+	return patch, nil
+}
+
+// timeArg read a required DateTime from a GraphQL argument map
+func timeArg(args map[string]interface{}, name string) (time.Time, error) {
+	value, isOK := args[name].(time.Time)
+	if !isOK {
+		return time.Time{}, argError(name)
+	}
+	return value, nil
+}
+
+// optionalIntArg read an optional int from a GraphQL argument map, nil is returned if it is not provided
+func optionalIntArg(args map[string]interface{}, name string) (*int, error) {
+	raw, isSet := args[name]
+	if !isSet || raw == nil {
+		return nil, nil
+	}
+	value, isOK := raw.(int)
+	if !isOK {
+		return nil, argError(name)
+	}
+	return &value, nil
+}
+
+// optionalIDArg read an optional ID from a GraphQL argument map, nil is returned if it is not provided
+func optionalIDArg(args map[string]interface{}, name string) (*uint, error) {
+	value, err := optionalIntArg(args, name)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	if *value <= 0 {
+		return nil, argError(name)
+	}
+	id := uint(*value)
+	return &id, nil
+}
+
+// optionalBoolArg read an optional Boolean from a GraphQL argument map, nil is returned if it is not provided
+func optionalBoolArg(args map[string]interface{}, name string) (*bool, error) {
+	raw, isSet := args[name]
+	if !isSet || raw == nil {
+		return nil, nil
+	}
+	value, isOK := raw.(bool)
+	if !isOK {
+		return nil, argError(name)
+	}
+	return &value, nil
+}
+
+// stringListArg read a required list of strings from a GraphQL argument map
+func stringListArg(args map[string]interface{}, name string) ([]string, error) {
+	raw, isOK := args[name].([]interface{})
+	if !isOK {
+		return nil, argError(name)
+	}
+	values := make([]string, 0, len(raw))
+	for _, item := range raw {
+		value, isOK := item.(string)
+		if !isOK {
+			return nil, argError(name)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// scheduleInputArg decode a ScheduledTransactionInput GraphQL input object into a ScheduleInput
+func scheduleInputArg(args map[string]interface{}, name string) (input ScheduleInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.Value, err = floatArg(object, "Value"); err != nil {
+		return input, err
+	}
+	if input.Note, err = optionalStringArg(object, "Note"); err != nil {
+		return input, err
+	}
+	if input.Sender, err = stringArg(object, "Sender"); err != nil {
+		return input, err
+	}
+	if input.Receiver, err = stringArg(object, "Receiver"); err != nil {
+		return input, err
+	}
+	if input.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return input, err
+	}
+	if input.ReceivedCurrency, err = optionalStringArg(object, "ReceivedCurrency"); err != nil {
+		return input, err
+	}
+	if input.Rule, err = optionalStringArg(object, "Rule"); err != nil {
+		return input, err
+	}
+	if input.StartAt, err = timeArg(object, "StartAt"); err != nil {
+		return input, err
+	}
+	if input.EndAt, err = optionalTimeArg(object, "EndAt"); err != nil {
+		return input, err
+	}
+	if input.Count, err = optionalIntArg(object, "Count"); err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// schedulePatchArg decode a ScheduledTransactionPatch GraphQL input object into a SchedulePatch
+func schedulePatchArg(args map[string]interface{}, name string) (patch SchedulePatch, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return patch, err
+	}
+	if patch.Value, err = optionalFloatArg(object, "Value"); err != nil {
+		return patch, err
+	}
+	if patch.Note, err = optionalStringArg(object, "Note"); err != nil {
+		return patch, err
+	}
+	if patch.Sender, err = optionalStringArg(object, "Sender"); err != nil {
+		return patch, err
+	}
+	if patch.Receiver, err = optionalStringArg(object, "Receiver"); err != nil {
+		return patch, err
+	}
+	if patch.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return patch, err
+	}
+	if patch.ReceivedCurrency, err = optionalStringArg(object, "ReceivedCurrency"); err != nil {
+		return patch, err
+	}
+	if patch.Rule, err = optionalStringArg(object, "Rule"); err != nil {
+		return patch, err
+	}
+	if patch.StartAt, err = optionalTimeArg(object, "StartAt"); err != nil {
+		return patch, err
+	}
+	if patch.EndAt, err = optionalTimeArg(object, "EndAt"); err != nil {
+		return patch, err
+	}
+	if patch.Count, err = optionalIntArg(object, "Count"); err != nil {
+		return patch, err
+	}
+	return patch, nil
+}
+
+// transactionFilterArgs is the arguments of the queries that list transactions, see transactionFilterArg
+var transactionFilterArgs = graphql.FieldConfigArgument{
+	"Sender": &graphql.ArgumentConfig{
+		Type: graphql.String,
+	},
+	"Receiver": &graphql.ArgumentConfig{
+		Type: graphql.String,
+	},
+	"Account": &graphql.ArgumentConfig{
+		Type:        graphql.String,
+		Description: "Match transactions sent or received by the account",
+	},
+	"Currency": &graphql.ArgumentConfig{
+		Type: graphql.String,
+	},
+	"From": &graphql.ArgumentConfig{
+		Type:        graphql.DateTime,
+		Description: "Match transactions created at or after From",
+	},
+	"To": &graphql.ArgumentConfig{
+		Type:        graphql.DateTime,
+		Description: "Match transactions created before To",
+	},
+	"MinValue": &graphql.ArgumentConfig{
+		Type: graphql.Float,
+	},
+	"MaxValue": &graphql.ArgumentConfig{
+		Type: graphql.Float,
+	},
+	"CategoryID": &graphql.ArgumentConfig{
+		Type: graphql.Int,
+	},
+	"Tag": &graphql.ArgumentConfig{
+		Type: graphql.String,
+	},
+	"SplitID": &graphql.ArgumentConfig{
+		Type:        graphql.Int,
+		Description: "Match the legs of a split transaction",
+	},
+}
+
+// filterArgsWith return the transactionFilterArgs along with the extra arguments of a query
+func filterArgsWith(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args := make(graphql.FieldConfigArgument, len(transactionFilterArgs)+len(extra))
+	for name, arg := range transactionFilterArgs {
+		args[name] = arg
+	}
+	for name, arg := range extra {
+		args[name] = arg
+	}
+	return args
+}
+
+// transactionFilterArg decode the transactionFilterArgs of a GraphQL argument map into a models.TransactionFilter
+func transactionFilterArg(args map[string]interface{}) (filter models.TransactionFilter, err error) {
+	filter.Sender, _ = args["Sender"].(string)
+	filter.Receiver, _ = args["Receiver"].(string)
+	filter.Account, _ = args["Account"].(string)
+	if currency, _ := args["Currency"].(string); currency != "" {
+		if filter.Currency, err = models.NormalizeCurrency(currency); err != nil {
+			return filter, err
+		}
+	}
+	if filter.From, err = optionalTimeArg(args, "From"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = optionalTimeArg(args, "To"); err != nil {
+		return filter, err
+	}
+	if filter.MinValue, err = optionalFloatArg(args, "MinValue"); err != nil {
+		return filter, err
+	}
+	if filter.MaxValue, err = optionalFloatArg(args, "MaxValue"); err != nil {
+		return filter, err
+	}
+	if filter.CategoryID, err = optionalIDArg(args, "CategoryID"); err != nil {
+		return filter, err
+	}
+	filter.Tag, _ = args["Tag"].(string)
+	if filter.SplitID, err = optionalIDArg(args, "SplitID"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// matchOptionsArgs is the arguments of the mutations that propose reconciliation matches, see matchOptionsArg
+var matchOptionsArgs = graphql.FieldConfigArgument{
+	"DateWindow": &graphql.ArgumentConfig{
+		Type:         graphql.Int,
+		Description:  "Days between the statement line and its transaction",
+		DefaultValue: models.DefaultMatchDateWindow,
+	},
+	"AmountTolerance": &graphql.ArgumentConfig{
+		Type:         graphql.Float,
+		Description:  "Largest difference between the amounts of the statement line and its transaction",
+		DefaultValue: models.DefaultMatchAmountTolerance,
+	},
+	"MinScore": &graphql.ArgumentConfig{
+		Type:         graphql.Float,
+		Description:  "Lowest confidence score, between 0 and 1, of a proposed match",
+		DefaultValue: models.DefaultMatchMinScore,
+	},
+}
+
+// matchOptionsArg decode the matchOptionsArgs of a GraphQL argument map into a models.MatchOptions
+func matchOptionsArg(args map[string]interface{}) models.MatchOptions {
+	var options models.MatchOptions
+	options.DateWindow, _ = args["DateWindow"].(int)
+	options.AmountTolerance, _ = args["AmountTolerance"].(float64)
+	options.MinScore, _ = args["MinScore"].(float64)
+	return options
+}
+
+// categoryInputArg decode a CategoryInput GraphQL input object into a CategoryInput
+func categoryInputArg(args map[string]interface{}, name string) (input CategoryInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.Name, err = stringArg(object, "Name"); err != nil {
+		return input, err
+	}
+	if input.Description, err = optionalStringArg(object, "Description"); err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// categoryRuleInputArg decode a CategoryRuleInput GraphQL input object into a CategoryRuleInput
+func categoryRuleInputArg(args map[string]interface{}, name string) (input CategoryRuleInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	categoryID, err := optionalIDArg(object, "CategoryID")
+	if err != nil {
+		return input, err
+	}
+	if categoryID == nil {
+		return input, argError("CategoryID")
+	}
+	input.CategoryID = *categoryID
+	if input.Name, err = optionalStringArg(object, "Name"); err != nil {
+		return input, err
+	}
+	if input.Priority, err = optionalIntArg(object, "Priority"); err != nil {
+		return input, err
+	}
+	if input.Counterparty, err = optionalStringArg(object, "Counterparty"); err != nil {
+		return input, err
+	}
+	if input.NotePattern, err = optionalStringArg(object, "NotePattern"); err != nil {
+		return input, err
+	}
+	if input.MinValue, err = optionalFloatArg(object, "MinValue"); err != nil {
+		return input, err
+	}
+	if input.MaxValue, err = optionalFloatArg(object, "MaxValue"); err != nil {
+		return input, err
+	}
+	if input.Enabled, err = optionalBoolArg(object, "Enabled"); err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// categoryRulePatchArg decode a CategoryRulePatch GraphQL input object into a CategoryRulePatch
+func categoryRulePatchArg(args map[string]interface{}, name string) (patch CategoryRulePatch, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return patch, err
+	}
+	if patch.Name, err = optionalStringArg(object, "Name"); err != nil {
+		return patch, err
+	}
+	if patch.Priority, err = optionalIntArg(object, "Priority"); err != nil {
+		return patch, err
+	}
+	if patch.CategoryID, err = optionalIDArg(object, "CategoryID"); err != nil {
+		return patch, err
+	}
+	if patch.Counterparty, err = optionalStringArg(object, "Counterparty"); err != nil {
+		return patch, err
+	}
+	if patch.NotePattern, err = optionalStringArg(object, "NotePattern"); err != nil {
+		return patch, err
+	}
+	if patch.MinValue, err = optionalFloatArg(object, "MinValue"); err != nil {
+		return patch, err
+	}
+	if patch.MaxValue, err = optionalFloatArg(object, "MaxValue"); err != nil {
+		return patch, err
+	}
+	patch.ClearMinValue, _ = object["ClearMinValue"].(bool)
+	patch.ClearMaxValue, _ = object["ClearMaxValue"].(bool)
+	if patch.Enabled, err = optionalBoolArg(object, "Enabled"); err != nil {
+		return patch, err
+	}
+	return patch, nil
+}
+
+// splitInputArg decode a SplitTransactionInput GraphQL input object into a SplitInput
+func splitInputArg(args map[string]interface{}, name string) (input SplitInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.Sender, err = stringArg(object, "Sender"); err != nil {
+		return input, err
+	}
+	if input.Total, err = floatArg(object, "Total"); err != nil {
+		return input, err
+	}
+	if input.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return input, err
+	}
+	if input.Note, err = optionalStringArg(object, "Note"); err != nil {
+		return input, err
+	}
+	legs, isOK := object["Legs"].([]interface{})
+	if !isOK {
+		return input, argError("Legs")
+	}
+	for _, raw := range legs {
+		legObject, isOK := raw.(map[string]interface{})
+		if !isOK {
+			return input, argError("Legs")
+		}
+		var leg SplitLegInput
+		if leg.Receiver, err = stringArg(legObject, "Receiver"); err != nil {
+			return input, err
+		}
+		if leg.Amount, err = optionalFloatArg(legObject, "Amount"); err != nil {
+			return input, err
+		}
+		if leg.Percentage, err = optionalFloatArg(legObject, "Percentage"); err != nil {
+			return input, err
+		}
+		if leg.Note, err = optionalStringArg(legObject, "Note"); err != nil {
+			return input, err
+		}
+		input.Legs = append(input.Legs, leg)
+	}
+	return input, nil
+}
+
+// groupInputArg decode an ExpenseGroupInput GraphQL input object into a GroupInput
+func groupInputArg(args map[string]interface{}, name string) (input GroupInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.Name, err = stringArg(object, "Name"); err != nil {
+		return input, err
+	}
+	if input.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return input, err
+	}
+	if object["Members"] != nil {
+		if input.Members, err = stringListArg(object, "Members"); err != nil {
+			return input, err
+		}
+	}
+	return input, nil
+}
+
+// groupExpenseInputArg decode a GroupExpenseInput GraphQL input object into a GroupExpenseInput
+func groupExpenseInputArg(args map[string]interface{}, name string) (input GroupExpenseInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.PaidBy, err = stringArg(object, "PaidBy"); err != nil {
+		return input, err
+	}
+	if input.Amount, err = floatArg(object, "Amount"); err != nil {
+		return input, err
+	}
+	if input.Description, err = optionalStringArg(object, "Description"); err != nil {
+		return input, err
+	}
+	if input.Method, err = optionalStringArg(object, "Method"); err != nil {
+		return input, err
+	}
+	participants, _ := object["Participants"].([]interface{})
+	for _, raw := range participants {
+		participantObject, isOK := raw.(map[string]interface{})
+		if !isOK {
+			return input, argError("Participants")
+		}
+		var participant ExpenseParticipantInput
+		if participant.Member, err = stringArg(participantObject, "Member"); err != nil {
+			return input, err
+		}
+		if participant.Value, err = optionalFloatArg(participantObject, "Value"); err != nil {
+			return input, err
+		}
+		input.Participants = append(input.Participants, participant)
+	}
+	return input, nil
+}
+
+// paymentRequestInputArg decode a PaymentRequestInput GraphQL input object into a PaymentRequestInput
+func paymentRequestInputArg(args map[string]interface{}, name string) (input PaymentRequestInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.Payer, err = stringArg(object, "Payer"); err != nil {
+		return input, err
+	}
+	if input.Amount, err = floatArg(object, "Amount"); err != nil {
+		return input, err
+	}
+	if input.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return input, err
+	}
+	if input.Note, err = optionalStringArg(object, "Note"); err != nil {
+		return input, err
+	}
+	if input.ExpiresAt, err = optionalTimeArg(object, "ExpiresAt"); err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// holdInputArg decode a HoldInput GraphQL input object into a HoldInput
+func holdInputArg(args map[string]interface{}, name string) (input HoldInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.Sender, err = stringArg(object, "Sender"); err != nil {
+		return input, err
+	}
+	if input.Receiver, err = stringArg(object, "Receiver"); err != nil {
+		return input, err
+	}
+	if input.Amount, err = floatArg(object, "Amount"); err != nil {
+		return input, err
+	}
+	if input.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return input, err
+	}
+	if input.Note, err = optionalStringArg(object, "Note"); err != nil {
+		return input, err
+	}
+	if input.ExpiresAt, err = optionalTimeArg(object, "ExpiresAt"); err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// approvalPolicyInputArg decode an ApprovalPolicyInput GraphQL input object into an ApprovalPolicyInput
+func approvalPolicyInputArg(args map[string]interface{}, name string) (input ApprovalPolicyInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.Name, err = stringArg(object, "Name"); err != nil {
+		return input, err
+	}
+	if input.MinValue, err = optionalFloatArg(object, "MinValue"); err != nil {
+		return input, err
+	}
+	if input.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return input, err
+	}
+	if input.Counterparty, err = optionalStringArg(object, "Counterparty"); err != nil {
+		return input, err
+	}
+	if object["CreatorRoles"] != nil {
+		if input.CreatorRoles, err = stringListArg(object, "CreatorRoles"); err != nil {
+			return input, err
+		}
+	}
+	if input.ApproverRole, err = optionalStringArg(object, "ApproverRole"); err != nil {
+		return input, err
+	}
+	if input.RequiredApprovals, err = optionalIntArg(object, "RequiredApprovals"); err != nil {
+		return input, err
+	}
+	if input.Enabled, err = optionalBoolArg(object, "Enabled"); err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// approvalPolicyPatchArg decode an ApprovalPolicyPatch GraphQL input object into an ApprovalPolicyPatch
+func approvalPolicyPatchArg(args map[string]interface{}, name string) (patch ApprovalPolicyPatch, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return patch, err
+	}
+	if patch.Name, err = optionalStringArg(object, "Name"); err != nil {
+		return patch, err
+	}
+	if patch.MinValue, err = optionalFloatArg(object, "MinValue"); err != nil {
+		return patch, err
+	}
+	patch.ClearMinValue, _ = object["ClearMinValue"].(bool)
+	if patch.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return patch, err
+	}
+	if patch.Counterparty, err = optionalStringArg(object, "Counterparty"); err != nil {
+		return patch, err
+	}
+	if object["CreatorRoles"] != nil {
+		if patch.CreatorRoles, err = stringListArg(object, "CreatorRoles"); err != nil {
+			return patch, err
+		}
+	}
+	if patch.ApproverRole, err = optionalStringArg(object, "ApproverRole"); err != nil {
+		return patch, err
+	}
+	if patch.RequiredApprovals, err = optionalIntArg(object, "RequiredApprovals"); err != nil {
+		return patch, err
+	}
+	if patch.Enabled, err = optionalBoolArg(object, "Enabled"); err != nil {
+		return patch, err
+	}
+	return patch, nil
+}
+
+// limitInputArg decode a TransactionLimitInput GraphQL input object into a LimitInput
+func limitInputArg(args map[string]interface{}, name string) (input LimitInput, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return input, err
+	}
+	if input.Currency, err = optionalStringArg(object, "Currency"); err != nil {
+		return input, err
+	}
+	if input.MaxPerTransaction, err = optionalFloatArg(object, "MaxPerTransaction"); err != nil {
+		return input, err
+	}
+	if input.MaxDaily, err = optionalFloatArg(object, "MaxDaily"); err != nil {
+		return input, err
+	}
+	if input.MaxMonthly, err = optionalFloatArg(object, "MaxMonthly"); err != nil {
+		return input, err
+	}
+	if input.MaxPerHour, err = optionalIntArg(object, "MaxPerHour"); err != nil {
+		return input, err
+	}
+	return input, nil
+}
+
+// riskSettingsPatchArg decode a RiskSettingsPatch GraphQL input object into a RiskSettingsPatch
+func riskSettingsPatchArg(args map[string]interface{}, name string) (patch RiskSettingsPatch, err error) {
+	object, err := objectArg(args, name)
+	if err != nil {
+		return patch, err
+	}
+	if patch.HoldScore, err = optionalIntArg(object, "HoldScore"); err != nil {
+		return patch, err
+	}
+	if patch.BlockScore, err = optionalIntArg(object, "BlockScore"); err != nil {
+		return patch, err
+	}
+	if object["DisabledRules"] != nil {
+		if patch.DisabledRules, err = stringListArg(object, "DisabledRules"); err != nil {
+			return patch, err
+		}
+	}
+	return patch, nil
+}
@@ -0,0 +1,19 @@
+package controllers
+
+import "transaction_project/models"
+
+type Audit struct {
+	auditService *models.AuditService
+}
+
+// NewAuditController create a new Audit controller using the provided AuditService
+func NewAuditController(auditService *models.AuditService) *Audit {
+	return &Audit{
+		auditService: auditService,
+	}
+}
+
+// History return every recorded change of the entity with the provided type and ID, oldest first
+func (aC *Audit) History(entityType string, id uint) ([]models.AuditEntry, error) {
+	return aC.auditService.ReadByEntity(entityType, id)
+}
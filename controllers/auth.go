@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"transaction_project/models"
+)
+
+var (
+	// errUnauthenticated is returned when an operation needs an authenticated caller
+	errUnauthenticated = errors.New("GraphQL: authentication required")
+
+	// errForbidden is returned when the caller does not have a role allowed to do an operation
+	errForbidden = errors.New("GraphQL: permission denied")
+)
+
+type contextKey string
+
+const currentUserKey contextKey = "currentUser"
+
+// anonymousActor is recorded as the actor of changes made by a caller that did not authenticate
+const anonymousActor = "anonymous"
+
+// Authenticate is a middleware that identify the caller of the wrapped handler from HTTP Basic credentials.
+// Requests without credentials are passed through anonymously, requests with wrong credentials are rejected.
+func (uC *User) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email, password, hasAuth := r.BasicAuth()
+		if !hasAuth {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := uC.userService.Authenticate(email, password)
+		if err == models.ErrInvalidCredentials {
+			w.Header().Set("WWW-Authenticate", `Basic realm="transaction_project"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), currentUserKey, user)))
+	})
+}
+
+// currentUser return the authenticated user of the request the context belong to, nil if the caller is anonymous
+func currentUser(ctx context.Context) *models.User {
+	if ctx == nil {
+		return nil
+	}
+	user, _ := ctx.Value(currentUserKey).(*models.User)
+	return user
+}
+
+// actorFrom return the name recorded in the audit log for the caller of the request the context belong to
+func actorFrom(ctx context.Context) string {
+	if user := currentUser(ctx); user != nil {
+		return user.Email
+	}
+	return anonymousActor
+}
+
+// requireRole check that the caller of the request the context belong to has one of the provided roles
+func requireRole(ctx context.Context, roles ...string) error {
+	user := currentUser(ctx)
+	if user == nil {
+		return errUnauthenticated
+	}
+	for _, role := range roles {
+		if user.Role == role {
+			return nil
+		}
+	}
+	return errForbidden
+}
+
+// requireOwnerOrRole return nil if the caller owns the account, the account being its email, or has one
+// of the provided roles, see requireRole
+func requireOwnerOrRole(ctx context.Context, account string, roles ...string) error {
+	if user := currentUser(ctx); user != nil && user.Email == account {
+		return nil
+	}
+	return requireRole(ctx, roles...)
+}
+
+// requireSelfOrRole return nil if the caller is the user with the provided ID or has one of the provided
+// roles, see requireRole
+func requireSelfOrRole(ctx context.Context, id uint, roles ...string) error {
+	if user := currentUser(ctx); user != nil && user.ID == id {
+		return nil
+	}
+	return requireRole(ctx, roles...)
+}
+
+// requireParty check that the caller sent or received the transaction with the provided ID, or is an admin
+func (gql *GraphQL) requireParty(ctx context.Context, id uint) error {
+	if currentUser(ctx) == nil {
+		return errUnauthenticated
+	}
+	if requireRole(ctx, models.RoleAdmin) == nil {
+		return nil
+	}
+	transaction, err := gql.tranController.transService.ReadByID(id)
+	if err != nil {
+		return err
+	}
+	if err := requireOwnerOrRole(ctx, transaction.Sender); err == nil {
+		return nil
+	}
+	return requireOwnerOrRole(ctx, transaction.Receiver, models.RoleAdmin)
+}
+
+// requireSender check that the caller sent the transaction with the provided ID, or is an admin
+func (gql *GraphQL) requireSender(ctx context.Context, id uint) error {
+	if currentUser(ctx) == nil {
+		return errUnauthenticated
+	}
+	if requireRole(ctx, models.RoleAdmin) == nil {
+		return nil
+	}
+	transaction, err := gql.tranController.transService.ReadByID(id)
+	if err != nil {
+		return err
+	}
+	return requireOwnerOrRole(ctx, transaction.Sender, models.RoleAdmin)
+}
+
+// requirePatchAllowed check that the caller can apply the patch to the transaction with the provided ID.
+// The receiver can only change the note. The value, the currencies and the parties can only be changed by
+// the sender, who must stay the sender, or by an admin.
+func (gql *GraphQL) requirePatchAllowed(ctx context.Context, id uint, patch UpdateTransactionInput) error {
+	if err := gql.requireParty(ctx, id); err != nil {
+		return err
+	}
+	if requireRole(ctx, models.RoleAdmin) == nil {
+		return nil
+	}
+	if patch.Value == nil && patch.Sender == nil && patch.Receiver == nil && patch.Currency == nil && patch.ReceivedCurrency == nil {
+		return nil
+	}
+	if err := gql.requireSender(ctx, id); err != nil {
+		return err
+	}
+	if patch.Sender != nil {
+		return requireOwnerOrRole(ctx, *patch.Sender, models.RoleAdmin)
+	}
+	return nil
+}
+
+// writeAuthError reply to an HTTP request whose caller failed requireRole or requireOwnerOrRole, asking
+// anonymous callers to authenticate
+func writeAuthError(w http.ResponseWriter, err error) {
+	status := http.StatusForbidden
+	if err == errUnauthenticated {
+		w.Header().Set("WWW-Authenticate", `Basic realm="transaction_project"`)
+		status = http.StatusUnauthorized
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import "transaction_project/models"
+
+type Category struct {
+	categoryService *models.CategoryService
+}
+
+// CategoryInput hold the fields of a models.Category
+// Description is optional, a nil Description is left empty when creating and unchanged when updating
+type CategoryInput struct {
+	Name        string
+	Description *string
+}
+
+// CategoryRuleInput hold the fields needed to create a new models.CategoryRule
+// Every field but CategoryID is optional, a nil condition is met by every transaction and a rule is
+// enabled unless Enabled is false
+type CategoryRuleInput struct {
+	Name         *string
+	Priority     *int
+	CategoryID   uint
+	Counterparty *string
+	NotePattern  *string
+	MinValue     *float64
+	MaxValue     *float64
+	Enabled      *bool
+}
+
+// CategoryRulePatch hold the fields of a models.CategoryRule that can be updated
+// Every field is optional, a nil field keeps the value already stored in the database. ClearMinValue and
+// ClearMaxValue remove the bounds of the value range.
+type CategoryRulePatch struct {
+	Name          *string
+	Priority      *int
+	CategoryID    *uint
+	Counterparty  *string
+	NotePattern   *string
+	MinValue      *float64
+	MaxValue      *float64
+	ClearMinValue bool
+	ClearMaxValue bool
+	Enabled       *bool
+}
+
+// NewCategoryController create a new Category controller using the provided CategoryService
+func NewCategoryController(categoryService *models.CategoryService) *Category {
+	return &Category{
+		categoryService: categoryService,
+	}
+}
+
+// WithActor return a copy of the controller whose category and tag assignments are recorded in the audit
+// log as made by the provided actor
+func (cC *Category) WithActor(actor string) *Category {
+	return &Category{
+		categoryService: cC.categoryService.WithActor(actor),
+	}
+}
+
+// NewCategory create a new models.Category using the models.CategoryService
+func (cC *Category) NewCategory(input CategoryInput) (*models.Category, error) {
+	category := &models.Category{Name: input.Name}
+	if input.Description != nil {
+		category.Description = *input.Description
+	}
+	return category, cC.categoryService.SaveCategory(category)
+}
+
+// UpdateCategory rename an existing models.Category and change its description using the models.CategoryService
+func (cC *Category) UpdateCategory(id uint, input CategoryInput) (*models.Category, error) {
+	category, err := cC.categoryService.ReadCategory(id)
+	if err != nil {
+		return nil, err
+	}
+	category.Name = input.Name
+	if input.Description != nil {
+		category.Description = *input.Description
+	}
+	return category, cC.categoryService.SaveCategory(category)
+}
+
+// NewRule create a new models.CategoryRule using the models.CategoryService, it only categorizes the
+// transactions created from now on until the rules are applied again
+func (cC *Category) NewRule(input CategoryRuleInput) (*models.CategoryRule, error) {
+	rule := &models.CategoryRule{
+		CategoryID: input.CategoryID,
+		MinValue:   input.MinValue,
+		MaxValue:   input.MaxValue,
+		Enabled:    true,
+	}
+	if input.Name != nil {
+		rule.Name = *input.Name
+	}
+	if input.Priority != nil {
+		rule.Priority = *input.Priority
+	}
+	if input.Counterparty != nil {
+		rule.Counterparty = *input.Counterparty
+	}
+	if input.NotePattern != nil {
+		rule.NotePattern = *input.NotePattern
+	}
+	if input.Enabled != nil {
+		rule.Enabled = *input.Enabled
+	}
+	return rule, cC.categoryService.SaveRule(rule)
+}
+
+// UpdateRule update an existing models.CategoryRule using the models.CategoryService
+func (cC *Category) UpdateRule(id uint, patch CategoryRulePatch) (*models.CategoryRule, error) {
+	rule, err := cC.categoryService.ReadRule(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.Name != nil {
+		rule.Name = *patch.Name
+	}
+	if patch.Priority != nil {
+		rule.Priority = *patch.Priority
+	}
+	if patch.CategoryID != nil {
+		rule.CategoryID = *patch.CategoryID
+	}
+	if patch.Counterparty != nil {
+		rule.Counterparty = *patch.Counterparty
+	}
+	if patch.NotePattern != nil {
+		rule.NotePattern = *patch.NotePattern
+	}
+	if patch.MinValue != nil {
+		rule.MinValue = patch.MinValue
+	}
+	if patch.ClearMinValue {
+		rule.MinValue = nil
+	}
+	if patch.MaxValue != nil {
+		rule.MaxValue = patch.MaxValue
+	}
+	if patch.ClearMaxValue {
+		rule.MaxValue = nil
+	}
+	if patch.Enabled != nil {
+		rule.Enabled = *patch.Enabled
+	}
+
+	return rule, cC.categoryService.SaveRule(rule)
+}
+
+// SetCategory assign a category to the transaction, or remove its category when categoryID is nil
+func (cC *Category) SetCategory(transactionID uint, categoryID *uint) (*models.Transaction, error) {
+	return cC.categoryService.SetCategory(transactionID, categoryID)
+}
+
+// SetTags replace the tags of the transaction
+func (cC *Category) SetTags(transactionID uint, tags []string) ([]string, error) {
+	return cC.categoryService.SetTags(transactionID, tags)
+}
+
+// ApplyRules categorize again the transactions matching the filter, see models.CategoryService.ApplyRules
+func (cC *Category) ApplyRules(filter models.TransactionFilter, overwrite bool) (int, error) {
+	return cC.categoryService.ApplyRules(filter, overwrite)
+}
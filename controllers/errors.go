@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"errors"
+	"transaction_project/models"
+)
+
+// conflictError expose a models.ConflictError to GraphQL clients with the CONFLICT code and the current state
+type conflictError struct {
+	*models.ConflictError
+}
+
+// Extensions is added to the GraphQL error so clients can retry from the current state
+func (err conflictError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":    "CONFLICT",
+		"current": err.Current,
+	}
+}
+
+// limitExceededError expose a models.LimitExceededError to GraphQL clients with the LIMIT_EXCEEDED code and
+// the remaining allowance
+type limitExceededError struct {
+	*models.LimitExceededError
+}
+
+// Extensions is added to the GraphQL error so clients can tell which limit was hit and what is left of it
+func (err limitExceededError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":      "LIMIT_EXCEEDED",
+		"limit":     err.Limit,
+		"max":       err.Max,
+		"remaining": err.Remaining,
+		"currency":  err.Currency,
+	}
+}
+
+// gqlError convert errors of the models package into errors GraphQL clients can act on
+func gqlError(err error) error {
+	var conflict *models.ConflictError
+	if errors.As(err, &conflict) {
+		return conflictError{conflict}
+	}
+	var limit *models.LimitExceededError
+	if errors.As(err, &limit) {
+		return limitExceededError{limit}
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"transaction_project/exports"
+	"transaction_project/models"
+)
+
+type Export struct {
+	transService *models.TransactionService
+}
+
+// NewExportController create a new Export controller using the provided TransactionService
+func NewExportController(transService *models.TransactionService) *Export {
+	return &Export{
+		transService: transService,
+	}
+}
+
+// Export write every transaction matching the filter to w in the format, reading them one at a time
+// from the database. It returns the number of transactions written.
+func (eC *Export) Export(w io.Writer, format string, filter models.TransactionFilter) (int, error) {
+	writer, err := exports.NewWriter(w, format)
+	if err != nil {
+		return 0, err
+	}
+	written := 0
+	err = eC.transService.Each(filter, func(transaction *models.Transaction) error {
+		written++
+		return writer.Write(transaction)
+	})
+	if err != nil {
+		return written, err
+	}
+	return written, writer.Close()
+}
+
+// NewHandler create a new http.Handler that download the transactions matching the filters of the query
+// string, see ParseTransactionFilter, in the format of the format parameter, csv when it is missing.
+// Callers must be authenticated, users other than auditors and admins only export the transactions of
+// their account.
+func (eC *Export) NewHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		user := currentUser(r.Context())
+		if user == nil {
+			writeAuthError(w, errUnauthenticated)
+			return
+		}
+		query := r.URL.Query()
+		format := strings.ToLower(query.Get("format"))
+		if format == "" {
+			format = exports.FormatCSV
+		}
+		filter, err := ParseTransactionFilter(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if requireRole(r.Context(), models.RoleAuditor, models.RoleAdmin) != nil {
+			filter.Sender, filter.Receiver = "", ""
+			filter.Account = user.Email
+		}
+		if !exports.Supported(format) {
+			http.Error(w, fmt.Sprintf("%v: %q", exports.ErrUnknownFormat, format), http.StatusBadRequest)
+			return
+		}
+
+		filename := fmt.Sprintf("transactions-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+		w.Header().Set("Content-Type", exports.ContentType(format))
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if _, err := eC.Export(w, format, filter); err != nil {
+			// The status was already sent, abort the response so the client does not keep a truncated file
+			log.Printf("export: %v", err)
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// ParseTransactionFilter decode the filters of the transaction list from URL parameters: sender,
+// receiver, account, currency, from and to as RFC 3339 or YYYY-MM-DD, min_value and max_value
+func ParseTransactionFilter(values url.Values) (filter models.TransactionFilter, err error) {
+	filter.Sender = values.Get("sender")
+	filter.Receiver = values.Get("receiver")
+	filter.Account = values.Get("account")
+	if currency := values.Get("currency"); currency != "" {
+		if filter.Currency, err = models.NormalizeCurrency(currency); err != nil {
+			return filter, err
+		}
+	}
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		raw := values.Get(name)
+		if raw == "" {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			if date, err = time.Parse("2006-01-02", raw); err != nil {
+				return filter, fmt.Errorf("invalid %s %q, expected RFC 3339 or YYYY-MM-DD", name, raw)
+			}
+		}
+		*dst = &date
+	}
+	for name, dst := range map[string]**float64{"min_value": &filter.MinValue, "max_value": &filter.MaxValue} {
+		raw := values.Get(name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid %s %q", name, raw)
+		}
+		*dst = &value
+	}
+	return filter, nil
+}
@@ -4,18 +4,74 @@ import (
 	"errors"
 	"github.com/graphql-go/graphql"
 	"github.com/graphql-go/handler"
+	"net/http"
+	"transaction_project/models"
 )
 
 type GraphQL struct {
-	tranController *Transaction
-	userController *User
+	tranController           *Transaction
+	userController           *User
+	auditController          *Audit
+	ledgerController         *Ledger
+	rateController           *Rate
+	reportController         *Report
+	scheduleController       *Schedule
+	importController         *Import
+	paymentController        *Payment
+	reconciliationController *Reconciliation
+	categoryController       *Category
+	searchController         *Search
+	groupController          *Group
+	requestController        *PaymentRequest
+	holdController           *Hold
+	approvalController       *Approval
+	limitController          *Limit
+	riskController           *Risk
+}
+
+// Controllers hold every controller the GraphQL schema resolves with
+type Controllers struct {
+	Transaction    *Transaction
+	User           *User
+	Audit          *Audit
+	Ledger         *Ledger
+	Rate           *Rate
+	Report         *Report
+	Schedule       *Schedule
+	Import         *Import
+	Payment        *Payment
+	Reconciliation *Reconciliation
+	Category       *Category
+	Search         *Search
+	Group          *Group
+	PaymentRequest *PaymentRequest
+	Hold           *Hold
+	Approval       *Approval
+	Limit          *Limit
+	Risk           *Risk
 }
 
 // NewGraphQL create a new GraphQL controller
-func NewGraphQL(tranController *Transaction, userController *User) *GraphQL {
+func NewGraphQL(controllers Controllers) *GraphQL {
 	return &GraphQL{
-		tranController: tranController,
-		userController: userController,
+		tranController:           controllers.Transaction,
+		userController:           controllers.User,
+		auditController:          controllers.Audit,
+		ledgerController:         controllers.Ledger,
+		rateController:           controllers.Rate,
+		reportController:         controllers.Report,
+		scheduleController:       controllers.Schedule,
+		importController:         controllers.Import,
+		paymentController:        controllers.Payment,
+		reconciliationController: controllers.Reconciliation,
+		categoryController:       controllers.Category,
+		searchController:         controllers.Search,
+		groupController:          controllers.Group,
+		requestController:        controllers.PaymentRequest,
+		holdController:           controllers.Hold,
+		approvalController:       controllers.Approval,
+		limitController:          controllers.Limit,
+		riskController:           controllers.Risk,
 	}
 }
 
@@ -38,6 +94,72 @@ var transactionType = graphql.NewObject(graphql.ObjectConfig{
 		"Receiver": &graphql.Field{
 			Type: graphql.String,
 		},
+		"Hash": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Hash of the ledger entry appended when the transaction was last committed",
+		},
+		"Version": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Incremented on every update, pass it back to UpdateTransaction to detect conflicts",
+		},
+		"Currency": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Currency of Value",
+		},
+		"ReceivedValue": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Amount credited to the receiver, in ReceivedCurrency",
+		},
+		"ReceivedCurrency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Rate": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Exchange rate applied to convert Value into ReceivedValue",
+		},
+		"CategoryID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"SplitID": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Split transaction the transaction is a leg of",
+		},
+		"Approval": &graphql.Field{
+			Type:        transactionApprovalType,
+			Description: "Set by AddTransaction instead of the ID when the transaction awaits approval before it is created",
+		},
+		"Risk": &graphql.Field{
+			Type:        riskAssessmentType,
+			Description: "Set by the mutations creating or updating the transaction to its risk assessment, AddTransaction does not create it yet when it is held for review",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.LedgerReport
+var ledgerReportType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "LedgerReport",
+	Fields: graphql.Fields{
+		"OK": &graphql.Field{
+			Type: graphql.Boolean,
+		},
+		"Entries": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Transactions": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Checkpoints": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"BrokenEntryID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"BrokenTransactionID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Reason": &graphql.Field{
+			Type: graphql.String,
+		},
 	},
 })
 
@@ -51,9 +173,6 @@ var userType = graphql.NewObject(graphql.ObjectConfig{
 		"Email": &graphql.Field{
 			Type: graphql.String,
 		},
-		"Password": &graphql.Field{
-			Type: graphql.String,
-		},
 		"Last": &graphql.Field{
 			Type: graphql.String,
 		},
@@ -66,6 +185,210 @@ var userType = graphql.NewObject(graphql.ObjectConfig{
 		"Phone": &graphql.Field{
 			Type: graphql.String,
 		},
+		"Role": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Version": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Incremented on every update, pass it back to UpdateUser to detect conflicts",
+		},
+		"Currency": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Currency of the account of the user",
+		},
+	},
+})
+
+// GraphQL Enum for the entity types recorded in the audit log
+var auditEntityEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "AuditEntityType",
+	Values: graphql.EnumValueConfigMap{
+		"Transaction": &graphql.EnumValueConfig{
+			Value: models.AuditEntityTransaction,
+		},
+		"User": &graphql.EnumValueConfig{
+			Value: models.AuditEntityUser,
+		},
+		"ReconciliationMatch": &graphql.EnumValueConfig{
+			Value: models.AuditEntityReconciliation,
+		},
+		"SplitTransaction": &graphql.EnumValueConfig{
+			Value: models.AuditEntitySplit,
+		},
+		"ExpenseGroup": &graphql.EnumValueConfig{
+			Value: models.AuditEntityGroup,
+		},
+		"GroupExpense": &graphql.EnumValueConfig{
+			Value: models.AuditEntityGroupExpense,
+		},
+		"PaymentRequest": &graphql.EnumValueConfig{
+			Value: models.AuditEntityPaymentRequest,
+		},
+		"Hold": &graphql.EnumValueConfig{
+			Value: models.AuditEntityHold,
+		},
+		"TransactionApproval": &graphql.EnumValueConfig{
+			Value: models.AuditEntityApproval,
+		},
+		"TransactionLimit": &graphql.EnumValueConfig{
+			Value: models.AuditEntityLimit,
+		},
+		"RiskAssessment": &graphql.EnumValueConfig{
+			Value: models.AuditEntityRisk,
+		},
+		"RiskSettings": &graphql.EnumValueConfig{
+			Value: models.AuditEntityRiskSettings,
+		},
+		"ScheduledTransaction": &graphql.EnumValueConfig{
+			Value: models.AuditEntitySchedule,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.AuditEntry
+var auditEntryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AuditEntry",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Actor": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Operation": &graphql.Field{
+			Type: graphql.String,
+		},
+		"EntityType": &graphql.Field{
+			Type: auditEntityEnum,
+		},
+		"EntityID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Before": &graphql.Field{
+			Type:        graphql.String,
+			Description: "JSON snapshot of the entity before the change",
+		},
+		"After": &graphql.Field{
+			Type:        graphql.String,
+			Description: "JSON snapshot of the entity after the change",
+		},
+	},
+})
+
+// GraphQL InputObject for creating a models.Transaction, see TransactionInput
+var transactionInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "TransactionInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Value": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.Float),
+		},
+		"Note": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Sender": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Receiver": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"ReceivedCurrency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+	},
+})
+
+// GraphQL InputObject for updating a models.Transaction, see UpdateTransactionInput
+var transactionPatchType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "TransactionPatch",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Value": &graphql.InputObjectFieldConfig{
+			Type: graphql.Float,
+		},
+		"Note": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Sender": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Receiver": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"ReceivedCurrency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Version": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Int,
+			Description: "Version the update is based on, the update fails with a CONFLICT error if it changed",
+		},
+	},
+})
+
+// GraphQL InputObject for creating a models.User, see UserInput
+var userInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "UserInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Email": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Password": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Last": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Middle": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"First": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Phone": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+	},
+})
+
+// GraphQL InputObject for updating a models.User, see UserPatch
+var userPatchType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "UserPatch",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Email": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Password": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Last": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Middle": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"First": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Phone": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Version": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Int,
+			Description: "Version the update is based on, the update fails with a CONFLICT error if it changed",
+		},
 	},
 })
 
@@ -82,241 +405,372 @@ func (gql *GraphQL) newSchemaConfig() graphql.SchemaConfig {
 	userService := gql.userController.userService
 
 	// Root query for the SchemaConfig
-	rootQuery := graphql.NewObject(graphql.ObjectConfig{
-		Name: "RootQuery",
-		Fields: graphql.Fields{
-			// Read a single transaction
-			"Transaction": &graphql.Field{
-				Type:        transactionType,
-				Description: "Get a single transaction",
-				Args:        IDFieldArgument,
-				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-					id, isOK := params.Args["ID"].(int)
-					if isOK {
-						return transactionService.ReadByID(uint(id))
+	queryFields := graphql.Fields{
+		// Read a single transaction
+		"Transaction": &graphql.Field{
+			Type:        transactionType,
+			Description: "Get a single transaction (sender, receiver, auditor or admin)",
+			Args:        IDFieldArgument,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if isOK {
+					if currentUser(params.Context) == nil {
+						return nil, errUnauthenticated
+					}
+					transaction, err := transactionService.ReadByID(uint(id))
+					if err != nil {
+						return nil, err
 					}
+					if err := requireOwnerOrRole(params.Context, transaction.Sender); err == nil {
+						return transaction, nil
+					}
+					if err := requireOwnerOrRole(params.Context, transaction.Receiver, models.RoleAuditor, models.RoleAdmin); err != nil {
+						return nil, err
+					}
+					return transaction, nil
+				}
 
-					return nil, errors.New("GraphQL: missing ID")
-				},
+				return nil, errors.New("GraphQL: missing ID")
+			},
+		},
+
+		// Read all transactions
+		"AllTransaction": &graphql.Field{
+			Type:        graphql.NewList(transactionType),
+			Description: "Get all transactions, or only those matching the filters. Users other than auditors and admins only get the transactions of their account.",
+			Args:        transactionFilterArgs,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				user := currentUser(params.Context)
+				if user == nil {
+					return nil, errUnauthenticated
+				}
+				filter, err := transactionFilterArg(params.Args)
+				if err != nil {
+					return nil, err
+				}
+				if requireRole(params.Context, models.RoleAuditor, models.RoleAdmin) != nil {
+					filter.Account = user.Email
+				}
+				return transactionService.ReadFiltered(filter)
 			},
+		},
 
-			// Read all transactions
-			"AllTransaction": &graphql.Field{
-				Type:        graphql.NewList(transactionType),
-				Description: "Get all transactions",
-				Resolve: func(_ graphql.ResolveParams) (interface{}, error) {
-					return transactionService.ReadAll()
+		// Read the audit log of an entity
+		"History": &graphql.Field{
+			Type:        graphql.NewList(auditEntryType),
+			Description: "Get every recorded change of a transaction or a user, oldest first",
+			Args: graphql.FieldConfigArgument{
+				"EntityType": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(auditEntityEnum),
+				},
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
 				},
 			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				entityType, OK1 := params.Args["EntityType"].(string)
+				id, OK2 := params.Args["ID"].(int)
+				if OK1 && OK2 {
+					return gql.auditController.History(entityType, uint(id))
+				}
+				return nil, errors.New("GraphQL: missing EntityType or ID")
+			},
+		},
+
+		// Verify the hash chain over the transaction log
+		"VerifyLedger": &graphql.Field{
+			Type:        ledgerReportType,
+			Description: "Walk the hash chain over the transaction log and report the first broken link",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return gql.ledgerController.Verify()
+			},
+		},
 
-			// Read a single user
-			"User": &graphql.Field{
-				Type:        userType,
-				Description: "Get a single user",
-				Args:        IDFieldArgument,
-				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-					id, isOK := params.Args["ID"].(int)
-					if isOK {
-						return userService.ReadByID(uint(id))
+		// Read the soft deleted transactions
+		"DeletedTransactions": &graphql.Field{
+			Type:        graphql.NewList(transactionType),
+			Description: "Get every deleted transaction that has not been purged yet (admin only)",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return transactionService.ReadDeleted()
+			},
+		},
+
+		// Read a single user
+		"User": &graphql.Field{
+			Type:        userType,
+			Description: "Get a single user (the user itself or admin)",
+			Args:        IDFieldArgument,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if isOK {
+					if err := requireSelfOrRole(params.Context, uint(id), models.RoleAdmin); err != nil {
+						return nil, err
 					}
+					return userService.ReadByID(uint(id))
+				}
 
-					return nil, errors.New("GraphQL: missing ID")
-				},
+				return nil, errors.New("GraphQL: missing ID")
 			},
+		},
 
-			// Read all users
-			"AllUser": &graphql.Field{
-				Type:        graphql.NewList(userType),
-				Description: "Get all users",
-				Resolve: func(_ graphql.ResolveParams) (interface{}, error) {
+		// Read all users
+		"AllUser": &graphql.Field{
+			Type:        graphql.NewList(userType),
+			Description: "Get all users, or only yourself unless you are an admin",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				user := currentUser(params.Context)
+				if user == nil {
+					return nil, errUnauthenticated
+				}
+				if requireRole(params.Context, models.RoleAdmin) == nil {
 					return userService.ReadAll()
-				},
+				}
+				self, err := userService.ReadByID(user.ID)
+				if err != nil {
+					return nil, err
+				}
+				return []models.User{*self}, nil
 			},
 		},
+
+		// Read the soft deleted users
+		"DeletedUsers": &graphql.Field{
+			Type:        graphql.NewList(userType),
+			Description: "Get every deleted user that has not been purged yet (admin only)",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return userService.ReadDeleted()
+			},
+		},
+	}
+	addFields(queryFields, gql.currencyQueries())
+	addFields(queryFields, gql.scheduleQueries())
+	addFields(queryFields, gql.importQueries())
+	addFields(queryFields, gql.paymentQueries())
+	addFields(queryFields, gql.reconciliationQueries())
+	addFields(queryFields, gql.analyticsQueries())
+	addFields(queryFields, gql.categoryQueries())
+	addFields(queryFields, gql.searchQueries())
+	addFields(queryFields, gql.splitQueries())
+	addFields(queryFields, gql.groupQueries())
+	addFields(queryFields, gql.paymentRequestQueries())
+	addFields(queryFields, gql.holdQueries())
+	addFields(queryFields, gql.approvalQueries())
+	addFields(queryFields, gql.limitQueries())
+	addFields(queryFields, gql.riskQueries())
+	rootQuery := graphql.NewObject(graphql.ObjectConfig{
+		Name:   "RootQuery",
+		Fields: queryFields,
 	})
 
 	// Root mutation for the SchemaConfig
-	rootMutation := graphql.NewObject(graphql.ObjectConfig{
-		Name: "RootMutation",
-		Fields: graphql.Fields{
-			// Create a transaction
-			"AddTransaction": &graphql.Field{
-				Type:        transactionType,
-				Description: "Create a new transaction",
-				Args: graphql.FieldConfigArgument{
-					"Value": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.Float),
-					},
-					"Note": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-					"Sender": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
-					},
-					"Receiver": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
-					},
-				},
-				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-					value, OK1 := params.Args["Value"].(float64)
-					sender, OK2 := params.Args["Sender"].(string)
-					receiver, OK3 := params.Args["Receiver"].(string)
-					note := params.Args["Note"]
-
-					if OK1 && OK2 && OK3 {
-						return gql.tranController.NewModel(value, note, sender, receiver)
-					}
-					return nil, errors.New("GraphQL: missing Value, Sender, or Receiver")
+	mutationFields := graphql.Fields{
+		// Create a transaction
+		"AddTransaction": &graphql.Field{
+			Type:        transactionType,
+			Description: "Create a new transaction, or hold it for approval when it meets an approval policy (sender or admin)",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(transactionInputType),
 				},
 			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				input, err := transactionInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, input.Sender, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				transaction, err := gql.tranController.WithActor(actorFrom(params.Context)).NewModel(input)
+				if err != nil {
+					return nil, gqlError(err)
+				}
+				return transaction, nil
+			},
+		},
 
-			// Update a transaction
-			"UpdateTransaction": &graphql.Field{
-				Type:        transactionType,
-				Description: "Update a transaction",
-				Args: graphql.FieldConfigArgument{
-					"ID": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.Int),
-					},
-					"Value": &graphql.ArgumentConfig{
-						Type: graphql.Float,
-					},
-					"Note": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-					"Sender": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-					"Receiver": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
+		// Update a transaction
+		"UpdateTransaction": &graphql.Field{
+			Type:        transactionType,
+			Description: "Update a transaction. The receiver can only change the note, the sender, who must stay the sender, or an admin can change every field. Raising its value or changing its parties or currency is refused when it would meet approval policies.",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
 				},
-				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-					id, OK := params.Args["ID"].(int)
-					value := params.Args["Value"]
-					note := params.Args["Note"]
-					sender := params.Args["Sender"]
-					receiver := params.Args["Receiver"]
-
-					if OK {
-						return gql.tranController.UpdateModel(uint(id), value, note, sender, receiver)
-					}
-					return nil, errors.New("GraphQL: missing ID")
+				"Patch": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(transactionPatchType),
 				},
 			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, OK := params.Args["ID"].(int)
+				if !OK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				patch, err := transactionPatchArg(params.Args, "Patch")
+				if err != nil {
+					return nil, err
+				}
+				if err := gql.requirePatchAllowed(params.Context, uint(id), patch); err != nil {
+					return nil, err
+				}
+				transaction, err := gql.tranController.WithActor(actorFrom(params.Context)).UpdateModel(uint(id), patch)
+				if err != nil {
+					return nil, gqlError(err)
+				}
+				return transaction, nil
+			},
+		},
 
-			// Delete a transaction
-			"DeleteTransaction": &graphql.Field{
-				Type:        graphql.Int,
-				Description: "Delete a transaction",
-				Args:        IDFieldArgument,
-				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-					id, isOK := params.Args["ID"].(int)
-					if isOK {
-						return id, transactionService.Delete(uint(id))
+		// Delete a transaction
+		"DeleteTransaction": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Delete a transaction (sender or admin)",
+			Args:        IDFieldArgument,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if isOK {
+					if err := gql.requireSender(params.Context, uint(id)); err != nil {
+						return 0, err
 					}
+					return id, transactionService.WithActor(actorFrom(params.Context)).Delete(uint(id))
+				}
 
-					return 0, errors.New("GraphQL: missing ID")
-				},
+				return 0, errors.New("GraphQL: missing ID")
 			},
+		},
 
-			// Create a user
-			"AddUser": &graphql.Field{
-				Type:        userType,
-				Description: "Create a new user",
-				Args: graphql.FieldConfigArgument{
-					"Email": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
-					},
-					"Password": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
-					},
-					"Last": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.String),
-					},
-					"Middle": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-					"First": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-					"Phone": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-				},
-				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-					email, OK1 := params.Args["Email"].(string)
-					password, OK2 := params.Args["Password"].(string)
-					last, OK3 := params.Args["Last"].(string)
-					middle := params.Args["Middle"]
-					first := params.Args["First"]
-					phone := params.Args["Phone"]
-
-					if OK1 && OK2 && OK3 {
-						return gql.userController.NewModel(email, password, last, middle, first, phone)
-					}
-					return nil, errors.New("GraphQL: missing Email, Password, or Last")
+		// Restore a deleted transaction
+		"RestoreTransaction": &graphql.Field{
+			Type:        transactionType,
+			Description: "Restore a deleted transaction that has not been purged yet (admin only)",
+			Args:        IDFieldArgument,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if isOK {
+					return transactionService.WithActor(actorFrom(params.Context)).Restore(uint(id))
+				}
+
+				return nil, errors.New("GraphQL: missing ID")
+			},
+		},
+
+		// Create a user
+		"AddUser": &graphql.Field{
+			Type:        userType,
+			Description: "Create a new user",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(userInputType),
 				},
 			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				input, err := userInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				return gql.userController.WithActor(actorFrom(params.Context)).NewModel(input)
+			},
+		},
 
-			// Update a user
-			"UpdateUser": &graphql.Field{
-				Type:        userType,
-				Description: "Update a user",
-				Args: graphql.FieldConfigArgument{
-					"ID": &graphql.ArgumentConfig{
-						Type: graphql.NewNonNull(graphql.Int),
-					},
-					"Email": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-					"Password": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-					"Last": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-					"Middle": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-					"First": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
-					"Phone": &graphql.ArgumentConfig{
-						Type: graphql.String,
-					},
+		// Update a user
+		"UpdateUser": &graphql.Field{
+			Type:        userType,
+			Description: "Update a user (the user itself or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
 				},
-				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-					id, OK := params.Args["ID"].(int)
-					email := params.Args["Email"]
-					password := params.Args["Password"]
-					last := params.Args["Last"]
-					middle := params.Args["Middle"]
-					first := params.Args["First"]
-					phone := params.Args["Phone"]
-
-					if OK {
-						return gql.userController.UpdateModel(uint(id), email, password, last, middle, first, phone)
-					}
-					return nil, errors.New("GraphQL: missing ID")
+				"Patch": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(userPatchType),
 				},
 			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, OK := params.Args["ID"].(int)
+				if !OK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				if err := requireSelfOrRole(params.Context, uint(id), models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				patch, err := userPatchArg(params.Args, "Patch")
+				if err != nil {
+					return nil, err
+				}
+				user, err := gql.userController.WithActor(actorFrom(params.Context)).UpdateModel(uint(id), patch)
+				if err != nil {
+					return nil, gqlError(err)
+				}
+				return user, nil
+			},
+		},
 
-			// Delete a user
-			"DeleteUser": &graphql.Field{
-				Type:        graphql.Int,
-				Description: "Delete a user",
-				Args:        IDFieldArgument,
-				Resolve: func(params graphql.ResolveParams) (interface{}, error) {
-					id, isOK := params.Args["ID"].(int)
-					if isOK {
-						return id, userService.Delete(uint(id))
+		// Delete a user
+		"DeleteUser": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Delete a user (the user itself or admin)",
+			Args:        IDFieldArgument,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if isOK {
+					if err := requireSelfOrRole(params.Context, uint(id), models.RoleAdmin); err != nil {
+						return 0, err
 					}
+					return id, userService.WithActor(actorFrom(params.Context)).Delete(uint(id))
+				}
 
-					return 0, errors.New("GraphQL: missing ID")
-				},
+				return 0, errors.New("GraphQL: missing ID")
+			},
+		},
+
+		// Restore a deleted user
+		"RestoreUser": &graphql.Field{
+			Type:        userType,
+			Description: "Restore a deleted user that has not been purged yet (admin only)",
+			Args:        IDFieldArgument,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if isOK {
+					return userService.WithActor(actorFrom(params.Context)).Restore(uint(id))
+				}
+
+				return nil, errors.New("GraphQL: missing ID")
 			},
 		},
+	}
+	addFields(mutationFields, gql.currencyMutations())
+	addFields(mutationFields, gql.scheduleMutations())
+	addFields(mutationFields, gql.importMutations())
+	addFields(mutationFields, gql.paymentMutations())
+	addFields(mutationFields, gql.reconciliationMutations())
+	addFields(mutationFields, gql.categoryMutations())
+	addFields(mutationFields, gql.splitMutations())
+	addFields(mutationFields, gql.groupMutations())
+	addFields(mutationFields, gql.paymentRequestMutations())
+	addFields(mutationFields, gql.holdMutations())
+	addFields(mutationFields, gql.approvalMutations())
+	addFields(mutationFields, gql.limitMutations())
+	addFields(mutationFields, gql.riskMutations())
+	rootMutation := graphql.NewObject(graphql.ObjectConfig{
+		Name:   "RootMutation",
+		Fields: mutationFields,
 	})
 
 	return graphql.SchemaConfig{
@@ -325,12 +779,23 @@ func (gql *GraphQL) newSchemaConfig() graphql.SchemaConfig {
 	}
 }
 
-// NewHandler create a new *handler.Handler and return it.
-func (gql *GraphQL) NewHandler() *handler.Handler {
+// addFields add every field of src to dst, it is used to assemble the root objects from the fields of each feature
+func addFields(dst, src graphql.Fields) {
+	for name, field := range src {
+		dst[name] = field
+	}
+}
+
+// NewHandler create a new http.Handler serving the schema and return it. Besides the requests the
+// graphql-go handler accepts, it serves multipart requests that upload files.
+func (gql *GraphQL) NewHandler() http.Handler {
 	schema, _ := graphql.NewSchema(gql.newSchemaConfig())
-	return handler.New(&handler.Config{
-		Schema:   &schema,
-		Pretty:   true,
-		GraphiQL: true,
-	})
+	return &graphQLHandler{
+		schema: schema,
+		handler: handler.New(&handler.Config{
+			Schema:   &schema,
+			Pretty:   true,
+			GraphiQL: true,
+		}),
+	}
 }
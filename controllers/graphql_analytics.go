@@ -0,0 +1,289 @@
+package controllers
+
+import (
+	"context"
+	"github.com/graphql-go/graphql"
+	"transaction_project/models"
+)
+
+// defaultTopCounterparties is the number of counterparties TopCounterparties return when no Limit is provided
+const defaultTopCounterparties = 10
+
+// GraphQL Enum for the dimensions of models.TransactionService.Aggregate
+var groupByEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "AnalyticsGroupBy",
+	Values: graphql.EnumValueConfigMap{
+		"DAY": &graphql.EnumValueConfig{
+			Value: models.GroupByDay,
+		},
+		"WEEK": &graphql.EnumValueConfig{
+			Value:       models.GroupByWeek,
+			Description: "Weeks start on Monday",
+		},
+		"MONTH": &graphql.EnumValueConfig{
+			Value: models.GroupByMonth,
+		},
+		"SENDER": &graphql.EnumValueConfig{
+			Value: models.GroupBySender,
+		},
+		"RECEIVER": &graphql.EnumValueConfig{
+			Value: models.GroupByReceiver,
+		},
+		"CURRENCY": &graphql.EnumValueConfig{
+			Value: models.GroupByCurrency,
+		},
+		"CATEGORY": &graphql.EnumValueConfig{
+			Value:       models.GroupByCategory,
+			Description: "Keyed by the name of the category, empty for uncategorized transactions",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.AggregateBucket
+var aggregateBucketType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AggregateBucket",
+	Fields: graphql.Fields{
+		"Key": &graphql.Field{
+			Type:        graphql.String,
+			Description: "First day of the period as YYYY-MM-DD, YYYY-MM for months, or the sender, receiver, currency or category",
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Total": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Count": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Average": &graphql.Field{
+			Type: graphql.Float,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.CounterpartyTotal
+var counterpartyTotalType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CounterpartyTotal",
+	Fields: graphql.Fields{
+		"Counterparty": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Sent": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Total sent to the counterparty",
+		},
+		"Received": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Total received from the counterparty",
+		},
+		"Count": &graphql.Field{
+			Type: graphql.Int,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.CashFlow
+var cashFlowType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CashFlow",
+	Fields: graphql.Fields{
+		"Account": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Incoming": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Outgoing": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Net": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Incoming minus Outgoing",
+		},
+		"Count": &graphql.Field{
+			Type: graphql.Int,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.Percentile
+var percentileType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Percentile",
+	Fields: graphql.Fields{
+		"Rank": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Between 0 and 1, 0.5 being the median",
+		},
+		"Value": &graphql.Field{
+			Type: graphql.Float,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.ValueDistribution
+var valueDistributionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ValueDistribution",
+	Fields: graphql.Fields{
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Count": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Min": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Max": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Average": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Percentiles": &graphql.Field{
+			Type: graphql.NewList(percentileType),
+		},
+	},
+})
+
+// scopeFilter restrict a filter to the transactions the caller may analyze: every transaction for
+// auditors and admins, the transactions of their own account for other users
+func scopeFilter(ctx context.Context, filter models.TransactionFilter) (models.TransactionFilter, error) {
+	if requireRole(ctx, models.RoleAuditor, models.RoleAdmin) == nil {
+		return filter, nil
+	}
+	user := currentUser(ctx)
+	if user == nil {
+		return filter, errUnauthenticated
+	}
+	if filter.Account != "" && filter.Account != user.Email {
+		return filter, errForbidden
+	}
+	filter.Account = user.Email
+	return filter, nil
+}
+
+// scopedFilterArg decode the transactionFilterArgs of a GraphQL argument map and restrict them with scopeFilter
+func scopedFilterArg(params graphql.ResolveParams) (models.TransactionFilter, error) {
+	filter, err := transactionFilterArg(params.Args)
+	if err != nil {
+		return filter, err
+	}
+	return scopeFilter(params.Context, filter)
+}
+
+// analyticsQueries return the root query fields of the analytics over transactions. Users who are
+// neither auditors nor admins only analyze the transactions of their own account.
+func (gql *GraphQL) analyticsQueries() graphql.Fields {
+	transService := gql.reportController.transService
+
+	return graphql.Fields{
+		// Group the transactions by period, by party, by currency or by category
+		"AggregateTransactions": &graphql.Field{
+			Type:        graphql.NewList(aggregateBucketType),
+			Description: "Sum and count the transactions matching the filters grouped by GroupBy and by currency",
+			Args: filterArgsWith(graphql.FieldConfigArgument{
+				"GroupBy": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(groupByEnum),
+				},
+			}),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				filter, err := scopedFilterArg(params)
+				if err != nil {
+					return nil, err
+				}
+				groupBy, err := stringArg(params.Args, "GroupBy")
+				if err != nil {
+					return nil, err
+				}
+				return transService.Aggregate(filter, groupBy)
+			},
+		},
+
+		// Rank the counterparties of an account
+		"TopCounterparties": &graphql.Field{
+			Type:        graphql.NewList(counterpartyTotalType),
+			Description: "Get the counterparties an account exchanged the most transactions with (owner of the account, auditor or admin)",
+			Args: graphql.FieldConfigArgument{
+				"Account": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"Currency": &graphql.ArgumentConfig{
+					Type: graphql.String,
+				},
+				"From": &graphql.ArgumentConfig{
+					Type: graphql.DateTime,
+				},
+				"To": &graphql.ArgumentConfig{
+					Type: graphql.DateTime,
+				},
+				"Limit": &graphql.ArgumentConfig{
+					Type:         graphql.Int,
+					DefaultValue: defaultTopCounterparties,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := stringArg(params.Args, "Account")
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, account, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				filter, err := transactionFilterArg(params.Args)
+				if err != nil {
+					return nil, err
+				}
+				limit, _ := params.Args["Limit"].(int)
+				return transService.TopCounterparties(account, filter, limit)
+			},
+		},
+
+		// Compute what went in and out of the accounts
+		"CashFlows": &graphql.Field{
+			Type:        graphql.NewList(cashFlowType),
+			Description: "Compute the net cash flow of every user, or only of Account, through the transactions matching the filters",
+			Args:        transactionFilterArgs,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				filter, err := scopedFilterArg(params)
+				if err != nil {
+					return nil, err
+				}
+				return transService.CashFlows(filter)
+			},
+		},
+
+		// Describe the distribution of the transaction values
+		"ValuePercentiles": &graphql.Field{
+			Type:        graphql.NewList(valueDistributionType),
+			Description: "Get the percentiles of the values of the transactions matching the filters in each currency",
+			Args: filterArgsWith(graphql.FieldConfigArgument{
+				"Ranks": &graphql.ArgumentConfig{
+					Type:        graphql.NewList(graphql.NewNonNull(graphql.Float)),
+					Description: "Ranks between 0 and 1 of the percentiles, 0.5, 0.9, 0.95 and 0.99 when missing",
+				},
+			}),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				filter, err := scopedFilterArg(params)
+				if err != nil {
+					return nil, err
+				}
+				var ranks []float64
+				rawRanks, _ := params.Args["Ranks"].([]interface{})
+				for _, raw := range rawRanks {
+					rank, isOK := raw.(float64)
+					if !isOK {
+						return nil, argError("Ranks")
+					}
+					ranks = append(ranks, rank)
+				}
+				return transService.ValuePercentiles(filter, ranks)
+			},
+		},
+	}
+}
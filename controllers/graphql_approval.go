@@ -0,0 +1,423 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/graphql-go/graphql"
+	"strings"
+	"transaction_project/models"
+)
+
+// GraphQL Enum for the statuses of a models.TransactionApproval
+var approvalStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "ApprovalStatus",
+	Values: graphql.EnumValueConfigMap{
+		"PENDING": &graphql.EnumValueConfig{
+			Value: models.ApprovalPending,
+		},
+		"APPROVED": &graphql.EnumValueConfig{
+			Value: models.ApprovalApproved,
+		},
+		"REJECTED": &graphql.EnumValueConfig{
+			Value: models.ApprovalRejected,
+		},
+	},
+})
+
+// GraphQL Enum for the decisions of an approver
+var approvalDecisionEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "ApprovalDecisionKind",
+	Values: graphql.EnumValueConfigMap{
+		"APPROVE": &graphql.EnumValueConfig{
+			Value: models.DecisionApprove,
+		},
+		"REJECT": &graphql.EnumValueConfig{
+			Value: models.DecisionReject,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.ApprovalPolicy
+var approvalPolicyType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ApprovalPolicy",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Name": &graphql.Field{
+			Type: graphql.String,
+		},
+		"MinValue": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Transactions of at least this value need approval, any value when missing",
+		},
+		"Currency": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Currency of MinValue, other currencies are converted, values are compared as they are when empty",
+		},
+		"Counterparty": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Sender or receiver of the transactions, any when empty",
+		},
+		"CreatorRoles": &graphql.Field{
+			Type:        graphql.NewList(graphql.String),
+			Description: "Roles of the creators whose transactions need approval, any creator when empty",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				policy, isOK := params.Source.(*models.ApprovalPolicy)
+				if !isOK {
+					if value, isValue := params.Source.(models.ApprovalPolicy); isValue {
+						policy, isOK = &value, true
+					}
+				}
+				if !isOK || policy.CreatorRoles == "" {
+					return []string{}, nil
+				}
+				return strings.Split(policy.CreatorRoles, ","), nil
+			},
+		},
+		"ApproverRole": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Role the approvers must have, any role when empty",
+		},
+		"RequiredApprovals": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Enabled": &graphql.Field{
+			Type: graphql.Boolean,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.ApprovalDecision
+var approvalDecisionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ApprovalDecision",
+	Fields: graphql.Fields{
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Approver": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Decision": &graphql.Field{
+			Type: approvalDecisionEnum,
+		},
+		"Comment": &graphql.Field{
+			Type: graphql.String,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.TransactionApproval
+var transactionApprovalType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TransactionApproval",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"CreatedBy": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Status": &graphql.Field{
+			Type: approvalStatusEnum,
+		},
+		"Policies": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Names of the approval policies the transaction met",
+		},
+		"ApproverRole": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Role the approvers must have besides admins, any role when empty",
+		},
+		"RequiredApprovals": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Approvals": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Value": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Note": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Sender": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Receiver": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"ReceivedCurrency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"ResolvedAt": &graphql.Field{
+			Type:        graphql.DateTime,
+			Description: "When the transaction was approved or rejected",
+		},
+		"TransactionID": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Transaction created once approved",
+		},
+		"Decisions": &graphql.Field{
+			Type:        graphql.NewList(approvalDecisionType),
+			Description: "Only loaded when a single approval is read or decided on",
+		},
+	},
+})
+
+// GraphQL InputObject for creating a models.ApprovalPolicy, see ApprovalPolicyInput
+var approvalPolicyInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ApprovalPolicyInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Name": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"MinValue": &graphql.InputObjectFieldConfig{
+			Type: graphql.Float,
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Counterparty": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"CreatorRoles": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewList(graphql.NewNonNull(graphql.String)),
+		},
+		"ApproverRole": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"RequiredApprovals": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Int,
+			Description: "1 when missing",
+		},
+		"Enabled": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Boolean,
+			Description: "true when missing",
+		},
+	},
+})
+
+// GraphQL InputObject for updating a models.ApprovalPolicy, see ApprovalPolicyPatch
+var approvalPolicyPatchType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ApprovalPolicyPatch",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Name": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"MinValue": &graphql.InputObjectFieldConfig{
+			Type: graphql.Float,
+		},
+		"ClearMinValue": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Boolean,
+			Description: "Remove the value threshold",
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Counterparty": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"CreatorRoles": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewList(graphql.NewNonNull(graphql.String)),
+		},
+		"ApproverRole": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"RequiredApprovals": &graphql.InputObjectFieldConfig{
+			Type: graphql.Int,
+		},
+		"Enabled": &graphql.InputObjectFieldConfig{
+			Type: graphql.Boolean,
+		},
+	},
+})
+
+// approvalQueries return the root query fields of approval policies and transactions awaiting approval
+func (gql *GraphQL) approvalQueries() graphql.Fields {
+	approvalService := gql.approvalController.approvalService
+
+	return graphql.Fields{
+		// Read the approval policies
+		"ApprovalPolicies": &graphql.Field{
+			Type:        graphql.NewList(approvalPolicyType),
+			Description: "Get every approval policy (admin only)",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return approvalService.ReadPolicies()
+			},
+		},
+
+		// Read a single approval
+		"TransactionApproval": &graphql.Field{
+			Type:        transactionApprovalType,
+			Description: "Get a single transaction awaiting or past approval with its decisions (creator or any authenticated user)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if currentUser(params.Context) == nil {
+					return nil, errUnauthenticated
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				return approvalService.ReadByID(uint(id))
+			},
+		},
+
+		// Read the approval queue
+		"TransactionApprovals": &graphql.Field{
+			Type:        graphql.NewList(transactionApprovalType),
+			Description: "Get the transactions awaiting or past approval, oldest first, without their decisions",
+			Args: graphql.FieldConfigArgument{
+				"Status": &graphql.ArgumentConfig{
+					Type:         approvalStatusEnum,
+					Description:  "Every status when null",
+					DefaultValue: models.ApprovalPending,
+				},
+				"CreatedBy": &graphql.ArgumentConfig{
+					Type:        graphql.String,
+					Description: "Only the transactions of this creator, every creator when missing",
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if currentUser(params.Context) == nil {
+					return nil, errUnauthenticated
+				}
+				status, _ := params.Args["Status"].(string)
+				creator, _ := params.Args["CreatedBy"].(string)
+				return approvalService.ReadApprovals(status, creator)
+			},
+		},
+	}
+}
+
+// approvalMutations return the root mutation fields of approval policies and decisions
+func (gql *GraphQL) approvalMutations() graphql.Fields {
+	// decideField return a field recording a decision with decide
+	decideField := func(description string, decide func(*Approval, uint, string) (*models.TransactionApproval, error)) *graphql.Field {
+		return &graphql.Field{
+			Type:        transactionApprovalType,
+			Description: description,
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"Comment": &graphql.ArgumentConfig{
+					Type: graphql.String,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				user := currentUser(params.Context)
+				if user == nil {
+					return nil, errUnauthenticated
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				comment, _ := params.Args["Comment"].(string)
+				approval, err := decide(gql.approvalController.WithActor(user.Email), uint(id), comment)
+				if err != nil {
+					return nil, gqlError(err)
+				}
+				return approval, nil
+			},
+		}
+	}
+
+	return graphql.Fields{
+		// Create an approval policy
+		"AddApprovalPolicy": &graphql.Field{
+			Type:        approvalPolicyType,
+			Description: "Create a new approval policy, it applies to the transactions created from now on (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(approvalPolicyInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				input, err := approvalPolicyInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				return gql.approvalController.NewPolicy(input)
+			},
+		},
+
+		// Update an approval policy
+		"UpdateApprovalPolicy": &graphql.Field{
+			Type:        approvalPolicyType,
+			Description: "Update an approval policy, transactions already awaiting approval are not affected (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"Patch": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(approvalPolicyPatchType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				patch, err := approvalPolicyPatchArg(params.Args, "Patch")
+				if err != nil {
+					return nil, err
+				}
+				return gql.approvalController.UpdatePolicy(uint(id), patch)
+			},
+		},
+
+		// Delete an approval policy
+		"DeleteApprovalPolicy": &graphql.Field{
+			Type:        graphql.Boolean,
+			Description: "Delete an approval policy, transactions already awaiting approval still need it (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				if err := gql.approvalController.approvalService.DeletePolicy(uint(id)); err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		},
+
+		// Approve a transaction
+		"ApproveTransaction": decideField(
+			"Approve a transaction awaiting approval, it is created once enough approvers approved it. Its creator can not approve it.",
+			(*Approval).Approve),
+
+		// Reject a transaction
+		"RejectTransaction": decideField(
+			"Reject a transaction awaiting approval, it is never created. Its creator can not reject it.",
+			(*Approval).Reject),
+	}
+}
@@ -0,0 +1,430 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/graphql-go/graphql"
+	"transaction_project/models"
+)
+
+// GraphQL ObjectTypes for Golang struct models.Category
+var categoryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Category",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Name": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Description": &graphql.Field{
+			Type: graphql.String,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.CategoryRule
+var categoryRuleType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CategoryRule",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Name": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Priority": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Rules are tried from the lowest priority, the first matching one wins",
+		},
+		"CategoryID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Counterparty": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Sender or receiver of the transactions, any when empty",
+		},
+		"NotePattern": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Regular expression searched in the note of the transactions, any when empty",
+		},
+		"MinValue": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"MaxValue": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Enabled": &graphql.Field{
+			Type: graphql.Boolean,
+		},
+	},
+})
+
+// GraphQL InputObject for creating or updating a models.Category, see CategoryInput
+var categoryInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CategoryInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Name": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Description": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+	},
+})
+
+// GraphQL InputObject for creating a models.CategoryRule, see CategoryRuleInput
+var categoryRuleInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CategoryRuleInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Name": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Priority": &graphql.InputObjectFieldConfig{
+			Type: graphql.Int,
+		},
+		"CategoryID": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+		"Counterparty": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"NotePattern": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "Go regular expression, prefix it with (?i) to ignore case",
+		},
+		"MinValue": &graphql.InputObjectFieldConfig{
+			Type: graphql.Float,
+		},
+		"MaxValue": &graphql.InputObjectFieldConfig{
+			Type: graphql.Float,
+		},
+		"Enabled": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Boolean,
+			Description: "True when missing",
+		},
+	},
+})
+
+// GraphQL InputObject for updating a models.CategoryRule, see CategoryRulePatch
+var categoryRulePatchType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CategoryRulePatch",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Name": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Priority": &graphql.InputObjectFieldConfig{
+			Type: graphql.Int,
+		},
+		"CategoryID": &graphql.InputObjectFieldConfig{
+			Type: graphql.Int,
+		},
+		"Counterparty": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"NotePattern": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"MinValue": &graphql.InputObjectFieldConfig{
+			Type: graphql.Float,
+		},
+		"MaxValue": &graphql.InputObjectFieldConfig{
+			Type: graphql.Float,
+		},
+		"ClearMinValue": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Boolean,
+			Description: "Remove the lower bound of the value range",
+		},
+		"ClearMaxValue": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Boolean,
+			Description: "Remove the upper bound of the value range",
+		},
+		"Enabled": &graphql.InputObjectFieldConfig{
+			Type: graphql.Boolean,
+		},
+	},
+})
+
+// transactionSource return the transaction a field of the Transaction type is resolved on
+func transactionSource(source interface{}) (*models.Transaction, error) {
+	switch transaction := source.(type) {
+	case *models.Transaction:
+		return transaction, nil
+	case models.Transaction:
+		return &transaction, nil
+	}
+	return nil, errors.New("GraphQL: not a transaction")
+}
+
+// categoryQueries return the root query fields of categories and categorization rules. The category and
+// the tags of a transaction are also added to the Transaction type here since they are resolved with the
+// models.CategoryService.
+func (gql *GraphQL) categoryQueries() graphql.Fields {
+	categoryService := gql.categoryController.categoryService
+
+	transactionType.AddFieldConfig("Category", &graphql.Field{
+		Type: categoryType,
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			transaction, err := transactionSource(params.Source)
+			if err != nil || transaction.CategoryID == nil {
+				return nil, err
+			}
+			return categoryService.ReadCategory(*transaction.CategoryID)
+		},
+	})
+	transactionType.AddFieldConfig("Tags", &graphql.Field{
+		Type: graphql.NewList(graphql.String),
+		Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+			transaction, err := transactionSource(params.Source)
+			if err != nil {
+				return nil, err
+			}
+			return categoryService.ReadTags(transaction.ID)
+		},
+	})
+
+	return graphql.Fields{
+		// Read every category
+		"Categories": &graphql.Field{
+			Type:        graphql.NewList(categoryType),
+			Description: "Get every category ordered by name",
+			Resolve: func(_ graphql.ResolveParams) (interface{}, error) {
+				return categoryService.ReadCategories()
+			},
+		},
+
+		// Read every categorization rule
+		"CategoryRules": &graphql.Field{
+			Type:        graphql.NewList(categoryRuleType),
+			Description: "Get every categorization rule in the order they are tried (admin only)",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return categoryService.ReadRules()
+			},
+		},
+	}
+}
+
+// categoryMutations return the root mutation fields of categories, tags and categorization rules
+func (gql *GraphQL) categoryMutations() graphql.Fields {
+	categoryService := gql.categoryController.categoryService
+	IDFieldArgument := graphql.FieldConfigArgument{
+		"ID": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+	}
+
+	return graphql.Fields{
+		// Create a category
+		"AddCategory": &graphql.Field{
+			Type:        categoryType,
+			Description: "Create a new category (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(categoryInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				input, err := categoryInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				return gql.categoryController.NewCategory(input)
+			},
+		},
+
+		// Rename a category
+		"UpdateCategory": &graphql.Field{
+			Type:        categoryType,
+			Description: "Rename a category and change its description (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(categoryInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				input, err := categoryInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				return gql.categoryController.UpdateCategory(uint(id), input)
+			},
+		},
+
+		// Delete a category
+		"DeleteCategory": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Delete a category no rule uses, its transactions become uncategorized (admin only)",
+			Args:        IDFieldArgument,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if isOK {
+					return id, categoryService.DeleteCategory(uint(id))
+				}
+				return 0, errors.New("GraphQL: missing ID")
+			},
+		},
+
+		// Categorize a transaction by hand
+		"SetTransactionCategory": &graphql.Field{
+			Type:        transactionType,
+			Description: "Assign a category to a transaction, or remove its category when CategoryID is missing (sender, receiver or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"CategoryID": &graphql.ArgumentConfig{
+					Type: graphql.Int,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				if err := gql.requireParty(params.Context, uint(id)); err != nil {
+					return nil, err
+				}
+				categoryID, err := optionalIDArg(params.Args, "CategoryID")
+				if err != nil {
+					return nil, err
+				}
+				return gql.categoryController.WithActor(actorFrom(params.Context)).SetCategory(uint(id), categoryID)
+			},
+		},
+
+		// Tag a transaction
+		"SetTransactionTags": &graphql.Field{
+			Type:        graphql.NewList(graphql.String),
+			Description: "Replace the tags of a transaction, tags are lowercased (sender, receiver or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"Tags": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				if err := gql.requireParty(params.Context, uint(id)); err != nil {
+					return nil, err
+				}
+				tags, err := stringListArg(params.Args, "Tags")
+				if err != nil {
+					return nil, err
+				}
+				return gql.categoryController.WithActor(actorFrom(params.Context)).SetTags(uint(id), tags)
+			},
+		},
+
+		// Create a categorization rule
+		"AddCategoryRule": &graphql.Field{
+			Type:        categoryRuleType,
+			Description: "Create a rule categorizing the new transactions meeting its conditions (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(categoryRuleInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				input, err := categoryRuleInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				return gql.categoryController.NewRule(input)
+			},
+		},
+
+		// Update a categorization rule
+		"UpdateCategoryRule": &graphql.Field{
+			Type:        categoryRuleType,
+			Description: "Update a categorization rule, transactions already created keep their category (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"Patch": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(categoryRulePatchType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				patch, err := categoryRulePatchArg(params.Args, "Patch")
+				if err != nil {
+					return nil, err
+				}
+				return gql.categoryController.UpdateRule(uint(id), patch)
+			},
+		},
+
+		// Delete a categorization rule
+		"DeleteCategoryRule": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Delete a categorization rule, the transactions it categorized keep their category (admin only)",
+			Args:        IDFieldArgument,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if isOK {
+					return id, categoryService.DeleteRule(uint(id))
+				}
+				return 0, errors.New("GraphQL: missing ID")
+			},
+		},
+
+		// Categorize historical transactions
+		"ApplyCategoryRules": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Categorize the transactions matching the filters with the enabled rules and return how many changed, categorized transactions are only changed with Overwrite (admin only)",
+			Args: filterArgsWith(graphql.FieldConfigArgument{
+				"Overwrite": &graphql.ArgumentConfig{
+					Type:         graphql.Boolean,
+					DefaultValue: false,
+				},
+			}),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				filter, err := transactionFilterArg(params.Args)
+				if err != nil {
+					return nil, err
+				}
+				overwrite, _ := params.Args["Overwrite"].(bool)
+				return gql.categoryController.WithActor(actorFrom(params.Context)).ApplyRules(filter, overwrite)
+			},
+		},
+	}
+}
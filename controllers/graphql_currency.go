@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/graphql-go/graphql"
+	"transaction_project/models"
+)
+
+// GraphQL ObjectTypes for Golang struct models.ExchangeRate
+var exchangeRateType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ExchangeRate",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Base": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Quote": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Rate": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Price of one unit of Base in Quote",
+		},
+		"EffectiveAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.ConvertedTotal
+var convertedTotalType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CurrencyTotal",
+	Fields: graphql.Fields{
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Total": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Total in Currency",
+		},
+		"Count": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Rate": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Converted": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Total converted into the base currency",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.ConvertedTotals
+var convertedTotalsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ConvertedTotals",
+	Fields: graphql.Fields{
+		"Base": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Total": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Sum of every total converted into Base",
+		},
+		"Count": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"ByCurrency": &graphql.Field{
+			Type: graphql.NewList(convertedTotalType),
+		},
+	},
+})
+
+// currencyQueries return the root query fields of exchange rates and converted reports
+func (gql *GraphQL) currencyQueries() graphql.Fields {
+	rateService := gql.rateController.rateService
+
+	return graphql.Fields{
+		// Read the exchange rates
+		"ExchangeRates": &graphql.Field{
+			Type:        graphql.NewList(exchangeRateType),
+			Description: "Get every exchange rate, optionally only those of a currency",
+			Args: graphql.FieldConfigArgument{
+				"Currency": &graphql.ArgumentConfig{
+					Type: graphql.String,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				currency, _ := params.Args["Currency"].(string)
+				if currency != "" {
+					var err error
+					if currency, err = models.NormalizeCurrency(currency); err != nil {
+						return nil, err
+					}
+				}
+				return rateService.ReadAll(currency)
+			},
+		},
+
+		// Sum the transactions of a period in a base currency
+		"Totals": &graphql.Field{
+			Type:        convertedTotalsType,
+			Description: "Sum the transactions created between From and To, converted into the Base currency",
+			Args: graphql.FieldConfigArgument{
+				"Base": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"From": &graphql.ArgumentConfig{
+					Type: graphql.DateTime,
+				},
+				"To": &graphql.ArgumentConfig{
+					Type: graphql.DateTime,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				base, err := stringArg(params.Args, "Base")
+				if err != nil {
+					return nil, err
+				}
+				from, err := optionalTimeArg(params.Args, "From")
+				if err != nil {
+					return nil, err
+				}
+				to, err := optionalTimeArg(params.Args, "To")
+				if err != nil {
+					return nil, err
+				}
+				return gql.reportController.Totals(base, from, to)
+			},
+		},
+
+		// Compute the balance of an account in a base currency
+		"Balance": &graphql.Field{
+			Type:        convertedTotalsType,
+			Description: "Compute the balance of an account at a date, converted into the Base currency",
+			Args: graphql.FieldConfigArgument{
+				"Account": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"Base": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"At": &graphql.ArgumentConfig{
+					Type: graphql.DateTime,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, OK1 := params.Args["Account"].(string)
+				base, OK2 := params.Args["Base"].(string)
+				if !OK1 || !OK2 {
+					return nil, errors.New("GraphQL: missing Account or Base")
+				}
+				at, err := optionalTimeArg(params.Args, "At")
+				if err != nil {
+					return nil, err
+				}
+				return gql.reportController.Balance(account, base, at)
+			},
+		},
+	}
+}
+
+// currencyMutations return the root mutation fields of exchange rates
+func (gql *GraphQL) currencyMutations() graphql.Fields {
+	return graphql.Fields{
+		// Set an exchange rate
+		"SetExchangeRate": &graphql.Field{
+			Type:        exchangeRateType,
+			Description: "Set the rate of a currency pair from a date, now if EffectiveAt is missing (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"Base": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"Quote": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"Rate": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Float),
+				},
+				"EffectiveAt": &graphql.ArgumentConfig{
+					Type: graphql.DateTime,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				var input RateInput
+				var err error
+				if input.Base, err = stringArg(params.Args, "Base"); err != nil {
+					return nil, err
+				}
+				if input.Quote, err = stringArg(params.Args, "Quote"); err != nil {
+					return nil, err
+				}
+				if input.Rate, err = floatArg(params.Args, "Rate"); err != nil {
+					return nil, err
+				}
+				if input.EffectiveAt, err = optionalTimeArg(params.Args, "EffectiveAt"); err != nil {
+					return nil, err
+				}
+				return gql.rateController.NewModel(input)
+			},
+		},
+	}
+}
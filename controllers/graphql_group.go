@@ -0,0 +1,480 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/graphql-go/graphql"
+	"transaction_project/models"
+)
+
+// GraphQL Enum for the methods splitting a models.GroupExpense
+var splitMethodEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "SplitMethod",
+	Values: graphql.EnumValueConfigMap{
+		"EQUAL": &graphql.EnumValueConfig{
+			Value:       models.SplitEqual,
+			Description: "Every participant owes the same amount, the value of the participants is ignored",
+		},
+		"EXACT": &graphql.EnumValueConfig{
+			Value:       models.SplitExact,
+			Description: "The value of a participant is the amount it owes, the values must add up to the amount",
+		},
+		"SHARES": &graphql.EnumValueConfig{
+			Value:       models.SplitShares,
+			Description: "The value of a participant is its number of shares of the amount",
+		},
+		"PERCENTAGES": &graphql.EnumValueConfig{
+			Value:       models.SplitPercentages,
+			Description: "The value of a participant is its percentage of the amount, the values must add up to 100",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.GroupMember
+var groupMemberType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "GroupMember",
+	Fields: graphql.Fields{
+		"Email": &graphql.Field{
+			Type: graphql.String,
+		},
+		"CreatedAt": &graphql.Field{
+			Type:        graphql.DateTime,
+			Description: "When the user joined the group",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.ExpenseGroup
+var expenseGroupType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ExpenseGroup",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Name": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Currency": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Currency of every expense of the group",
+		},
+		"CreatedBy": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Members": &graphql.Field{
+			Type:        graphql.NewList(groupMemberType),
+			Description: "Only loaded when a single group is read",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.ExpenseShare
+var expenseShareType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ExpenseShare",
+	Fields: graphql.Fields{
+		"Member": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Amount": &graphql.Field{
+			Type: graphql.Float,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.GroupExpense
+var groupExpenseType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "GroupExpense",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"GroupID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"PaidBy": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Amount": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Description": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Method": &graphql.Field{
+			Type: splitMethodEnum,
+		},
+		"Shares": &graphql.Field{
+			Type:        graphql.NewList(expenseShareType),
+			Description: "What each participant owes, the shares add up to the amount",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.MemberBalance
+var memberBalanceType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "MemberBalance",
+	Fields: graphql.Fields{
+		"Member": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Paid": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Sum of the expenses the member paid",
+		},
+		"Owed": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Sum of the shares of the member",
+		},
+		"Settled": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Settle-up transfers the member sent minus those it received",
+		},
+		"Net": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Positive when the member is owed money, negative when it owes money",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.DebtTransfer
+var debtTransferType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "DebtTransfer",
+	Fields: graphql.Fields{
+		"From": &graphql.Field{
+			Type: graphql.String,
+		},
+		"To": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Amount": &graphql.Field{
+			Type: graphql.Float,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.GroupSettlement
+var groupSettlementType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "GroupSettlement",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"From": &graphql.Field{
+			Type: graphql.String,
+		},
+		"To": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Amount": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"TransactionID": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Transaction recording the transfer",
+		},
+	},
+})
+
+// GraphQL InputObject for creating a models.ExpenseGroup, see GroupInput
+var expenseGroupInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ExpenseGroupInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Name": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "Currency of your account when missing",
+		},
+		"Members": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+			Description: "Emails of the other members, you are always a member",
+		},
+	},
+})
+
+// GraphQL InputObject for a participant of a models.GroupExpense, see ExpenseParticipantInput
+var expenseParticipantInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ExpenseParticipantInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Member": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Value": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Float,
+			Description: "Amount, number of shares or percentage depending on the method, ignored by EQUAL",
+		},
+	},
+})
+
+// GraphQL InputObject for adding a models.GroupExpense, see GroupExpenseInput
+var groupExpenseInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "GroupExpenseInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"PaidBy": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Amount": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewNonNull(graphql.Float),
+			Description: "Amount in the currency of the group",
+		},
+		"Description": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Method": &graphql.InputObjectFieldConfig{
+			Type:         splitMethodEnum,
+			DefaultValue: models.SplitEqual,
+		},
+		"Participants": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(expenseParticipantInputType)),
+			Description: "Members sharing the expense, every member of the group for an EQUAL split when missing",
+		},
+	},
+})
+
+// requireMember check that the caller is a member of the group with the provided ID or has one of the roles
+func (gql *GraphQL) requireMember(ctx context.Context, groupID uint, roles ...string) error {
+	user := currentUser(ctx)
+	if user == nil {
+		return errUnauthenticated
+	}
+	if requireRole(ctx, roles...) == nil {
+		return nil
+	}
+	isMember, err := gql.groupController.groupService.IsMember(groupID, user.Email)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return errForbidden
+	}
+	return nil
+}
+
+// groupIDArg read the required GroupID argument of the expense group fields
+func groupIDArg(args map[string]interface{}) (uint, error) {
+	id, isOK := args["GroupID"].(int)
+	if !isOK {
+		return 0, errors.New("GraphQL: missing GroupID")
+	}
+	return uint(id), nil
+}
+
+// groupIDArgs return the arguments of the expense group fields, the GroupID and the extra arguments
+func groupIDArgs(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{
+		"GroupID": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+	}
+	for name, arg := range extra {
+		args[name] = arg
+	}
+	return args
+}
+
+// groupQueries return the root query fields of expense groups. Members of a group, auditors and admins can
+// read it.
+func (gql *GraphQL) groupQueries() graphql.Fields {
+	groupService := gql.groupController.groupService
+
+	// groupField return a field reading something of the group whose ID is the GroupID argument
+	groupField := func(fieldType graphql.Output, description string, read func(uint) (interface{}, error)) *graphql.Field {
+		return &graphql.Field{
+			Type:        fieldType,
+			Description: description,
+			Args:        groupIDArgs(nil),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				groupID, err := groupIDArg(params.Args)
+				if err != nil {
+					return nil, err
+				}
+				if err := gql.requireMember(params.Context, groupID, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return read(groupID)
+			},
+		}
+	}
+
+	return graphql.Fields{
+		// Read a single expense group
+		"ExpenseGroup": groupField(expenseGroupType, "Get a single expense group with its members",
+			func(id uint) (interface{}, error) {
+				return groupService.ReadByID(id)
+			}),
+
+		// Read the expense groups of the caller
+		"MyExpenseGroups": &graphql.Field{
+			Type:        graphql.NewList(expenseGroupType),
+			Description: "Get the expense groups you are a member of, without their members",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				user := currentUser(params.Context)
+				if user == nil {
+					return nil, errUnauthenticated
+				}
+				return groupService.ReadForMember(user.Email)
+			},
+		},
+
+		// Read the expenses of a group
+		"GroupExpenses": groupField(graphql.NewList(groupExpenseType), "Get the expenses of a group with their shares, newest first",
+			func(id uint) (interface{}, error) {
+				return groupService.ReadExpenses(id)
+			}),
+
+		// Read the balance sheet of a group
+		"GroupBalances": groupField(graphql.NewList(memberBalanceType), "Get what every member of a group paid, owes and settled, and its net balance",
+			func(id uint) (interface{}, error) {
+				return groupService.Balances(id)
+			}),
+
+		// Read who owes whom in a group
+		"GroupDebts": groupField(graphql.NewList(debtTransferType), "Get the fewest transfers settling every balance of a group, what SettleUp would record",
+			func(id uint) (interface{}, error) {
+				return groupService.SimplifyDebts(id)
+			}),
+
+		// Read the settle-up transfers of a group
+		"GroupSettlements": groupField(graphql.NewList(groupSettlementType), "Get the transfers recorded by SettleUp, newest first",
+			func(id uint) (interface{}, error) {
+				return groupService.ReadSettlements(id)
+			}),
+	}
+}
+
+// groupMutations return the root mutation fields of expense groups. Members of a group and admins can
+// change it.
+func (gql *GraphQL) groupMutations() graphql.Fields {
+	return graphql.Fields{
+		// Create an expense group
+		"AddExpenseGroup": &graphql.Field{
+			Type:        expenseGroupType,
+			Description: "Create an expense group you are a member of",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(expenseGroupInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				user := currentUser(params.Context)
+				if user == nil {
+					return nil, errUnauthenticated
+				}
+				input, err := groupInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				return gql.groupController.WithActor(user.Email).NewGroup(user.Email, input)
+			},
+		},
+
+		// Add a member to an expense group
+		"AddExpenseGroupMember": &graphql.Field{
+			Type:        groupMemberType,
+			Description: "Add a user to an expense group (member or admin)",
+			Args: groupIDArgs(graphql.FieldConfigArgument{
+				"Email": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			}),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				groupID, err := groupIDArg(params.Args)
+				if err != nil {
+					return nil, err
+				}
+				if err := gql.requireMember(params.Context, groupID, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				email, err := stringArg(params.Args, "Email")
+				if err != nil {
+					return nil, err
+				}
+				return gql.groupController.WithActor(actorFrom(params.Context)).AddMember(groupID, email)
+			},
+		},
+
+		// Remove a member from an expense group
+		"RemoveExpenseGroupMember": &graphql.Field{
+			Type:        graphql.Boolean,
+			Description: "Remove a user whose balance is settled from an expense group (member or admin)",
+			Args: groupIDArgs(graphql.FieldConfigArgument{
+				"Email": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			}),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				groupID, err := groupIDArg(params.Args)
+				if err != nil {
+					return nil, err
+				}
+				if err := gql.requireMember(params.Context, groupID, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				email, err := stringArg(params.Args, "Email")
+				if err != nil {
+					return nil, err
+				}
+				if err := gql.groupController.WithActor(actorFrom(params.Context)).RemoveMember(groupID, email); err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		},
+
+		// Add an expense to a group
+		"AddGroupExpense": &graphql.Field{
+			Type:        groupExpenseType,
+			Description: "Record an expense paid by a member and split between members (member or admin)",
+			Args: groupIDArgs(graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(groupExpenseInputType),
+				},
+			}),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				groupID, err := groupIDArg(params.Args)
+				if err != nil {
+					return nil, err
+				}
+				if err := gql.requireMember(params.Context, groupID, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				input, err := groupExpenseInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				return gql.groupController.WithActor(actorFrom(params.Context)).NewExpense(groupID, input)
+			},
+		},
+
+		// Settle the debts of a group
+		"SettleUp": &graphql.Field{
+			Type:        graphql.NewList(groupSettlementType),
+			Description: "Record a transaction for each of the fewest transfers settling every balance of a group (member or admin)",
+			Args:        groupIDArgs(nil),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				groupID, err := groupIDArg(params.Args)
+				if err != nil {
+					return nil, err
+				}
+				if err := gql.requireMember(params.Context, groupID, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				settlements, err := gql.groupController.WithActor(actorFrom(params.Context)).SettleUp(groupID)
+				if err != nil {
+					return nil, gqlError(err)
+				}
+				return settlements, nil
+			},
+		},
+	}
+}
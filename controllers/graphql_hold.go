@@ -0,0 +1,294 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/graphql-go/graphql"
+	"transaction_project/models"
+)
+
+// GraphQL Enum for the statuses of a models.Hold
+var holdStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "HoldStatus",
+	Values: graphql.EnumValueConfigMap{
+		"ACTIVE": &graphql.EnumValueConfig{
+			Value: models.HoldActive,
+		},
+		"CAPTURED": &graphql.EnumValueConfig{
+			Value: models.HoldCaptured,
+		},
+		"RELEASED": &graphql.EnumValueConfig{
+			Value: models.HoldReleased,
+		},
+		"EXPIRED": &graphql.EnumValueConfig{
+			Value: models.HoldExpired,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.Hold
+var holdType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Hold",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Sender": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Account whose funds are reserved",
+		},
+		"Receiver": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Account the funds are reserved for, who captures or releases the hold",
+		},
+		"Amount": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Note": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Status": &graphql.Field{
+			Type: holdStatusEnum,
+		},
+		"ExpiresAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"CapturedAmount": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Amount of the transaction once the hold is captured, at most Amount",
+		},
+		"ResolvedAt": &graphql.Field{
+			Type:        graphql.DateTime,
+			Description: "When the hold was captured, released or expired",
+		},
+		"TransactionID": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Transaction created by the capture",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.AccountBalance
+var accountBalanceType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "AccountBalance",
+	Fields: graphql.Fields{
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Ledger": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Net amount of the transactions of the account",
+		},
+		"Held": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Sum of the active holds on the account",
+		},
+		"Available": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Ledger balance minus the held amount, what new holds can reserve and transactions can send while holds are active",
+		},
+	},
+})
+
+// GraphQL InputObject for placing a models.Hold, see HoldInput
+var holdInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "HoldInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Sender": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Receiver": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Amount": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.Float),
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "Currency of the sender account when missing",
+		},
+		"Note": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "Note of the transaction created by the capture",
+		},
+		"ExpiresAt": &graphql.InputObjectFieldConfig{
+			Type:        graphql.DateTime,
+			Description: "A week from now when missing",
+		},
+	},
+})
+
+// holdQueries return the root query fields of holds and available balances
+func (gql *GraphQL) holdQueries() graphql.Fields {
+	holdService := gql.holdController.holdService
+
+	return graphql.Fields{
+		// Read a single hold
+		"Hold": &graphql.Field{
+			Type:        holdType,
+			Description: "Get a single hold (sender, receiver, auditor or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				hold, err := holdService.ReadByID(uint(id))
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, hold.Sender); err == nil {
+					return hold, nil
+				}
+				if err := requireOwnerOrRole(params.Context, hold.Receiver, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return hold, nil
+			},
+		},
+
+		// Read the holds of an account
+		"Holds": &graphql.Field{
+			Type:        graphql.NewList(holdType),
+			Description: "Get the holds placed on or for an account, newest first",
+			Args: graphql.FieldConfigArgument{
+				"Status": &graphql.ArgumentConfig{
+					Type:        holdStatusEnum,
+					Description: "Every status when missing",
+				},
+				"Account": &graphql.ArgumentConfig{
+					Type:        graphql.String,
+					Description: "Your account when missing (auditor or admin for another account)",
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := scopedAccount(params.Context, params.Args)
+				if err != nil {
+					return nil, err
+				}
+				status, _ := params.Args["Status"].(string)
+				return holdService.ReadHolds(account, status)
+			},
+		},
+
+		// Read the available and ledger balances of an account
+		"AccountBalances": &graphql.Field{
+			Type:        graphql.NewList(accountBalanceType),
+			Description: "Get the ledger, held and available balances of an account in each of its currencies",
+			Args: graphql.FieldConfigArgument{
+				"Account": &graphql.ArgumentConfig{
+					Type:        graphql.String,
+					Description: "Your account when missing (auditor or admin for another account)",
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := scopedAccount(params.Context, params.Args)
+				if err != nil {
+					return nil, err
+				}
+				return holdService.Balances(account)
+			},
+		},
+	}
+}
+
+// requireHoldReceiver check that the caller receives the hold with the provided ID or is an admin
+func (gql *GraphQL) requireHoldReceiver(ctx context.Context, id uint) error {
+	if currentUser(ctx) == nil {
+		return errUnauthenticated
+	}
+	hold, err := gql.holdController.holdService.ReadByID(id)
+	if err != nil {
+		return err
+	}
+	return requireOwnerOrRole(ctx, hold.Receiver, models.RoleAdmin)
+}
+
+// holdMutations return the root mutation fields of holds
+func (gql *GraphQL) holdMutations() graphql.Fields {
+	return graphql.Fields{
+		// Place a hold
+		"PlaceHold": &graphql.Field{
+			Type:        holdType,
+			Description: "Reserve an amount of the available balance of the sender for the receiver (sender or admin)",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(holdInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				input, err := holdInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, input.Sender, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return gql.holdController.WithActor(actorFrom(params.Context)).NewModel(input)
+			},
+		},
+
+		// Capture a hold
+		"CaptureHold": &graphql.Field{
+			Type:        holdType,
+			Description: "Pay Amount of an active hold, the whole hold when missing, and free the rest (receiver or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"Amount": &graphql.ArgumentConfig{
+					Type: graphql.Float,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				if err := gql.requireHoldReceiver(params.Context, uint(id)); err != nil {
+					return nil, err
+				}
+				amount, err := optionalFloatArg(params.Args, "Amount")
+				if err != nil {
+					return nil, err
+				}
+				hold, err := gql.holdController.WithActor(actorFrom(params.Context)).Capture(uint(id), amount)
+				if err != nil {
+					return nil, gqlError(err)
+				}
+				return hold, nil
+			},
+		},
+
+		// Release a hold
+		"ReleaseHold": &graphql.Field{
+			Type:        holdType,
+			Description: "Free an active hold without paying it (receiver or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				if err := gql.requireHoldReceiver(params.Context, uint(id)); err != nil {
+					return nil, err
+				}
+				return gql.holdController.WithActor(actorFrom(params.Context)).Release(uint(id))
+			},
+		},
+	}
+}
@@ -0,0 +1,368 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/graphql-go/graphql"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"transaction_project/imports"
+	"transaction_project/models"
+)
+
+// GraphQL Enum for the formats of the files that can be imported
+var importFormatEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "ImportFormat",
+	Values: graphql.EnumValueConfigMap{
+		"CSV": &graphql.EnumValueConfig{
+			Value: imports.FormatCSV,
+		},
+		"JSONL": &graphql.EnumValueConfig{
+			Value:       imports.FormatJSONL,
+			Description: "JSON Lines, one object per line",
+		},
+	},
+})
+
+// GraphQL InputObject mapping a field of a transaction to the column it is read from
+var columnMappingInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ColumnMappingInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Field": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "One of " + strings.Join(imports.Fields, ", "),
+		},
+		"Column": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "Header of the CSV column or key of the JSON object",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct ImportError
+var importErrorType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ImportError",
+	Fields: graphql.Fields{
+		"Line": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Message": &graphql.Field{
+			Type: graphql.String,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct ImportReport
+var importReportType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ImportReport",
+	Fields: graphql.Fields{
+		"Total": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Valid": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Invalid": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Created": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"DryRun": &graphql.Field{
+			Type: graphql.Boolean,
+		},
+		"Errors": &graphql.Field{
+			Type: graphql.NewList(importErrorType),
+		},
+	},
+})
+
+// GraphQL Enum for the formats of the bank statements that can be imported
+var statementFormatEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "StatementFormat",
+	Values: graphql.EnumValueConfigMap{
+		"OFX": &graphql.EnumValueConfig{
+			Value:       imports.FormatOFX,
+			Description: "OFX 1.x (SGML) or 2.x (XML)",
+		},
+		"QFX": &graphql.EnumValueConfig{
+			Value: imports.FormatQFX,
+		},
+		"QIF": &graphql.EnumValueConfig{
+			Value: imports.FormatQIF,
+		},
+		"CAMT053": &graphql.EnumValueConfig{
+			Value:       imports.FormatCAMT053,
+			Description: "ISO 20022 camt.053 BankToCustomerStatement",
+		},
+		"MT940": &graphql.EnumValueConfig{
+			Value:       imports.FormatMT940,
+			Description: "SWIFT MT940 customer statement",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct StatementEntryReport
+var statementEntryReportType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "StatementEntryReport",
+	Fields: graphql.Fields{
+		"Line": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"FITID": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Reference": &graphql.Field{
+			Type: graphql.String,
+		},
+		"PostedAt": &graphql.Field{
+			Type:        graphql.DateTime,
+			Description: "Booking date",
+		},
+		"ValueAt": &graphql.Field{
+			Type:        graphql.DateTime,
+			Description: "Value date, when the statement has one",
+		},
+		"Amount": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Positive when credited to the account",
+		},
+		"Payee": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Status": &graphql.Field{
+			Type:        graphql.String,
+			Description: "created, skipped, conflicted or failed",
+		},
+		"TransactionID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"DuplicateOfID": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Transaction the skipped or conflicted entry matched",
+		},
+		"Message": &graphql.Field{
+			Type: graphql.String,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct StatementReport
+var statementReportType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "StatementReport",
+	Fields: graphql.Fields{
+		"Account": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Total": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Created": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Skipped": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Conflicted": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Failed": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"DryRun": &graphql.Field{
+			Type: graphql.Boolean,
+		},
+		"Entries": &graphql.Field{
+			Type: graphql.NewList(statementEntryReportType),
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.ImportedEntry
+var importedEntryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ImportedEntry",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type:        graphql.DateTime,
+			Description: "When the statement was imported",
+		},
+		"Account": &graphql.Field{
+			Type: graphql.String,
+		},
+		"FITID": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Identifier the bank gave the entry",
+		},
+		"TransactionID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Reference": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Counterparty": &graphql.Field{
+			Type: graphql.String,
+		},
+		"BookedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"ValueAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+	},
+})
+
+// importQueries return the root query fields of imported statements
+func (gql *GraphQL) importQueries() graphql.Fields {
+	return graphql.Fields{
+		// Read the statement entry a transaction was imported from
+		"ImportedEntry": &graphql.Field{
+			Type:        importedEntryType,
+			Description: "Get the bank statement entry a transaction was imported from",
+			Args: graphql.FieldConfigArgument{
+				"TransactionID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["TransactionID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing TransactionID")
+				}
+				return gql.importController.statementService.ReadByTransaction(uint(id))
+			},
+		},
+	}
+}
+
+// importMutations return the root mutation fields of bulk imports
+func (gql *GraphQL) importMutations() graphql.Fields {
+	return graphql.Fields{
+		// Import transactions from a file
+		"ImportTransactions": &graphql.Field{
+			Type:        importReportType,
+			Description: "Create the transactions of an uploaded CSV or JSON Lines file, or only validate them with DryRun (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"File": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(uploadScalar),
+				},
+				"Format": &graphql.ArgumentConfig{
+					Type:        importFormatEnum,
+					Description: "Inferred from the extension of the file when missing",
+				},
+				"Mapping": &graphql.ArgumentConfig{
+					Type: graphql.NewList(graphql.NewNonNull(columnMappingInputType)),
+				},
+				"DateFormat": &graphql.ArgumentConfig{
+					Type:        graphql.String,
+					Description: "Go layout of the date column, RFC 3339 or YYYY-MM-DD when missing",
+				},
+				"DryRun": &graphql.ArgumentConfig{
+					Type:         graphql.Boolean,
+					DefaultValue: false,
+				},
+				"BatchSize": &graphql.ArgumentConfig{
+					Type:         graphql.Int,
+					Description:  "Number of rows committed in each database transaction",
+					DefaultValue: defaultImportBatchSize,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				fileHeader, isOK := params.Args["File"].(*multipart.FileHeader)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing File")
+				}
+
+				var options ImportOptions
+				options.Format, _ = params.Args["Format"].(string)
+				if options.Format == "" {
+					options.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+				}
+				options.DateFormat, _ = params.Args["DateFormat"].(string)
+				options.DryRun, _ = params.Args["DryRun"].(bool)
+				options.BatchSize, _ = params.Args["BatchSize"].(int)
+				mappings, _ := params.Args["Mapping"].([]interface{})
+				options.Mapping = make(imports.Mapping)
+				for _, raw := range mappings {
+					mapping, _ := raw.(map[string]interface{})
+					field, err := stringArg(mapping, "Field")
+					if err != nil {
+						return nil, err
+					}
+					column, err := stringArg(mapping, "Column")
+					if err != nil {
+						return nil, err
+					}
+					options.Mapping[strings.ToLower(field)] = column
+				}
+
+				file, err := fileHeader.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer file.Close()
+				return gql.importController.WithActor(actorFrom(params.Context)).Import(file, options)
+			},
+		},
+
+		// Import a bank statement
+		"ImportStatement": &graphql.Field{
+			Type:        statementReportType,
+			Description: "Create the transactions of an uploaded OFX, QFX, QIF, camt.053 or MT940 bank statement of an account, skipping the entries already imported (admin or owner of the account)",
+			Args: graphql.FieldConfigArgument{
+				"File": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(uploadScalar),
+				},
+				"Account": &graphql.ArgumentConfig{
+					Type:        graphql.NewNonNull(graphql.String),
+					Description: "Email of the user the statement belongs to",
+				},
+				"Format": &graphql.ArgumentConfig{
+					Type:        statementFormatEnum,
+					Description: "Inferred from the extension of the file when missing",
+				},
+				"DateFormat": &graphql.ArgumentConfig{
+					Type:        graphql.String,
+					Description: "Go layout of the dates of a QIF file, US dates when missing",
+				},
+				"DryRun": &graphql.ArgumentConfig{
+					Type:         graphql.Boolean,
+					DefaultValue: false,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := stringArg(params.Args, "Account")
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, account, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				fileHeader, isOK := params.Args["File"].(*multipart.FileHeader)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing File")
+				}
+
+				var options StatementOptions
+				options.Format, _ = params.Args["Format"].(string)
+				if options.Format == "" {
+					options.Format = imports.StatementFormat(fileHeader.Filename)
+				}
+				options.DateFormat, _ = params.Args["DateFormat"].(string)
+				options.DryRun, _ = params.Args["DryRun"].(bool)
+
+				file, err := fileHeader.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer file.Close()
+				return gql.importController.WithActor(actorFrom(params.Context)).ImportStatement(file, account, options)
+			},
+		},
+	}
+}
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/graphql-go/graphql"
+	"transaction_project/models"
+)
+
+// GraphQL ObjectTypes for Golang struct models.TransactionLimit
+var transactionLimitType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "TransactionLimit",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"UpdatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Email": &graphql.Field{
+			Type:        graphql.String,
+			Description: "User the limit applies to, empty for a role limit",
+		},
+		"Role": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Role the limit applies to, empty for a user limit",
+		},
+		"Currency": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Currency of the maximums, empty when values are added as they are",
+		},
+		"MaxPerTransaction": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"MaxDaily": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Maximum sent since midnight UTC",
+		},
+		"MaxMonthly": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Maximum sent since the first of the month UTC",
+		},
+		"MaxPerHour": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Maximum number of transactions sent in the last 60 minutes",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.LimitUsage
+var limitUsageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "LimitUsage",
+	Fields: graphql.Fields{
+		"Email": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Limit": &graphql.Field{
+			Type:        transactionLimitType,
+			Description: "Limit applying to the user, null when it is not limited",
+		},
+		"SentToday": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"SentThisMonth": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"CountLastHour": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"RemainingDaily": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Null when the daily total is not limited",
+		},
+		"RemainingMonthly": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Null when the monthly total is not limited",
+		},
+		"RemainingPerHour": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Null when the hourly count is not limited",
+		},
+	},
+})
+
+// GraphQL InputObject for setting a models.TransactionLimit, see LimitInput
+var transactionLimitInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "TransactionLimitInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "Currency of the maximums, values of every currency are added as they are when missing",
+		},
+		"MaxPerTransaction": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Float,
+			Description: "Not limited when missing",
+		},
+		"MaxDaily": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Float,
+			Description: "Not limited when missing",
+		},
+		"MaxMonthly": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Float,
+			Description: "Not limited when missing",
+		},
+		"MaxPerHour": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Int,
+			Description: "Not limited when missing",
+		},
+	},
+})
+
+// limitQueries return the root query fields of transaction limits
+func (gql *GraphQL) limitQueries() graphql.Fields {
+	limitService := gql.limitController.limitService
+
+	return graphql.Fields{
+		// Read every limit
+		"TransactionLimits": &graphql.Field{
+			Type:        graphql.NewList(transactionLimitType),
+			Description: "Get every user and role limit (admin only)",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return limitService.ReadLimits()
+			},
+		},
+
+		// Read the usage of the limit of a user
+		"LimitUsage": &graphql.Field{
+			Type:        limitUsageType,
+			Description: "Get the limit applying to a user and what is left of it",
+			Args: graphql.FieldConfigArgument{
+				"Account": &graphql.ArgumentConfig{
+					Type:        graphql.String,
+					Description: "Your account when missing (auditor or admin for another account)",
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := scopedAccount(params.Context, params.Args)
+				if err != nil {
+					return nil, err
+				}
+				return limitService.Usage(account)
+			},
+		},
+	}
+}
+
+// limitMutations return the root mutation fields of transaction limits, every one is admin only
+func (gql *GraphQL) limitMutations() graphql.Fields {
+	return graphql.Fields{
+		// Set the limit of a user
+		"SetUserLimit": &graphql.Field{
+			Type:        transactionLimitType,
+			Description: "Set the limit of a user, replacing the limit of its role (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"Email": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(transactionLimitInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				email, err := stringArg(params.Args, "Email")
+				if err != nil {
+					return nil, err
+				}
+				input, err := limitInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				return gql.limitController.WithActor(actorFrom(params.Context)).SetUserLimit(email, input)
+			},
+		},
+
+		// Set the limit of a role
+		"SetRoleLimit": &graphql.Field{
+			Type:        transactionLimitType,
+			Description: "Set the limit of the users with a role and no limit of their own (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"Role": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(transactionLimitInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				role, err := stringArg(params.Args, "Role")
+				if err != nil {
+					return nil, err
+				}
+				input, err := limitInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				return gql.limitController.WithActor(actorFrom(params.Context)).SetRoleLimit(role, input)
+			},
+		},
+
+		// Delete a limit
+		"DeleteTransactionLimit": &graphql.Field{
+			Type:        graphql.Boolean,
+			Description: "Delete a user or role limit, a user falls back to the limit of its role (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				if err := gql.limitController.WithActor(actorFrom(params.Context)).DeleteLimit(uint(id)); err != nil {
+					return false, err
+				}
+				return true, nil
+			},
+		},
+	}
+}
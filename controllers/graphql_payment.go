@@ -0,0 +1,281 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/graphql-go/graphql"
+	"transaction_project/models"
+	"transaction_project/payments"
+)
+
+// GraphQL ObjectTypes for Golang struct models.BankAccount
+var bankAccountType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "BankAccount",
+	Fields: graphql.Fields{
+		"Account": &graphql.Field{
+			Type: graphql.String,
+		},
+		"HolderName": &graphql.Field{
+			Type: graphql.String,
+		},
+		"IBAN": &graphql.Field{
+			Type: graphql.String,
+		},
+		"BIC": &graphql.Field{
+			Type: graphql.String,
+		},
+		"RoutingNumber": &graphql.Field{
+			Type: graphql.String,
+		},
+		"AccountNumber": &graphql.Field{
+			Type: graphql.String,
+		},
+		"AccountType": &graphql.Field{
+			Type: graphql.String,
+		},
+	},
+})
+
+// GraphQL InputObject for setting a models.BankAccount, see BankAccountInput
+var bankAccountInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "BankAccountInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Account": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "Email of the user paid to this bank account",
+		},
+		"HolderName": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"IBAN": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"BIC": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"RoutingNumber": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "ABA routing number of the bank, for ACH payments",
+		},
+		"AccountNumber": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"AccountType": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "checking (default) or savings",
+		},
+	},
+})
+
+// GraphQL Enum for the formats of the payment files
+var paymentFormatEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "PaymentFormat",
+	Values: graphql.EnumValueConfigMap{
+		"PAIN001": &graphql.EnumValueConfig{
+			Value:       payments.FormatPain001,
+			Description: "ISO 20022 pain.001.001.03 credit transfer initiation",
+		},
+		"NACHA": &graphql.EnumValueConfig{
+			Value:       payments.FormatNACHA,
+			Description: "NACHA ACH file, USD only",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.PaymentBatch
+var paymentBatchType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PaymentBatch",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Format": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"ExecutionDate": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"MessageID": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Filename": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Count": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Total": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Content": &graphql.Field{
+			Type:        graphql.String,
+			Description: "The payment file, also downloadable from /payment-batch?id=ID",
+		},
+	},
+})
+
+// paymentQueries return the root query fields of bank accounts and payment batches
+func (gql *GraphQL) paymentQueries() graphql.Fields {
+	paymentService := gql.paymentController.paymentService
+
+	return graphql.Fields{
+		// Read the bank account of an account
+		"BankAccount": &graphql.Field{
+			Type:        bankAccountType,
+			Description: "Get the bank account an account is paid to (admin or owner of the account)",
+			Args: graphql.FieldConfigArgument{
+				"Account": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := stringArg(params.Args, "Account")
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, account, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return paymentService.ReadBankAccount(account)
+			},
+		},
+
+		// Read every payment batch
+		"PaymentBatches": &graphql.Field{
+			Type:        graphql.NewList(paymentBatchType),
+			Description: "Get every payment batch, newest first, without their file (admin only)",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return paymentService.ReadBatches()
+			},
+		},
+
+		// Read a single payment batch
+		"PaymentBatch": &graphql.Field{
+			Type:        paymentBatchType,
+			Description: "Get a single payment batch and its file (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				return paymentService.ReadBatch(uint(id))
+			},
+		},
+	}
+}
+
+// paymentMutations return the root mutation fields of bank accounts and payment batches
+func (gql *GraphQL) paymentMutations() graphql.Fields {
+	return graphql.Fields{
+		// Set the bank account of an account
+		"SetBankAccount": &graphql.Field{
+			Type:        bankAccountType,
+			Description: "Set the bank account an account is paid to (admin or owner of the account)",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(bankAccountInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				object, err := objectArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				var input BankAccountInput
+				if input.Account, err = stringArg(object, "Account"); err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, input.Account, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				if input.HolderName, err = stringArg(object, "HolderName"); err != nil {
+					return nil, err
+				}
+				if input.IBAN, err = optionalStringArg(object, "IBAN"); err != nil {
+					return nil, err
+				}
+				if input.BIC, err = optionalStringArg(object, "BIC"); err != nil {
+					return nil, err
+				}
+				if input.RoutingNumber, err = optionalStringArg(object, "RoutingNumber"); err != nil {
+					return nil, err
+				}
+				if input.AccountNumber, err = optionalStringArg(object, "AccountNumber"); err != nil {
+					return nil, err
+				}
+				if input.AccountType, err = optionalStringArg(object, "AccountType"); err != nil {
+					return nil, err
+				}
+				return gql.paymentController.SetBankAccount(input)
+			},
+		},
+
+		// Generate the payment file of pending transactions
+		"CreatePaymentBatch": &graphql.Field{
+			Type:        paymentBatchType,
+			Description: "Generate the payment file of pending transactions received in Currency and mark them as submitted (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"Format": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(paymentFormatEnum),
+				},
+				"Currency": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"TransactionIDs": &graphql.ArgumentConfig{
+					Type:        graphql.NewList(graphql.NewNonNull(graphql.Int)),
+					Description: "Transactions to pay, the oldest pending ones are paid when missing",
+				},
+				"Limit": &graphql.ArgumentConfig{
+					Type:        graphql.Int,
+					Description: "Maximum number of transactions paid when TransactionIDs is missing",
+				},
+				"ExecutionDate": &graphql.ArgumentConfig{
+					Type:        graphql.DateTime,
+					Description: "Date the bank should pay, today when missing",
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				var input PaymentBatchInput
+				var err error
+				if input.Format, err = stringArg(params.Args, "Format"); err != nil {
+					return nil, err
+				}
+				if input.Currency, err = stringArg(params.Args, "Currency"); err != nil {
+					return nil, err
+				}
+				ids, _ := params.Args["TransactionIDs"].([]interface{})
+				for _, raw := range ids {
+					id, isOK := raw.(int)
+					if !isOK || id <= 0 {
+						return nil, argError("TransactionIDs")
+					}
+					input.TransactionIDs = append(input.TransactionIDs, uint(id))
+				}
+				input.Limit, _ = params.Args["Limit"].(int)
+				if input.ExecutionDate, err = optionalTimeArg(params.Args, "ExecutionDate"); err != nil {
+					return nil, err
+				}
+				return gql.paymentController.NewBatch(input)
+			},
+		},
+	}
+}
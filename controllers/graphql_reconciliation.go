@@ -0,0 +1,398 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/graphql-go/graphql"
+	"mime/multipart"
+	"transaction_project/imports"
+	"transaction_project/models"
+)
+
+// GraphQL ObjectTypes for Golang struct models.StatementLine
+var statementLineType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "StatementLine",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type:        graphql.DateTime,
+			Description: "When the statement was loaded",
+		},
+		"Account": &graphql.Field{
+			Type: graphql.String,
+		},
+		"FITID": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Identifier the bank gave the entry",
+		},
+		"Reference": &graphql.Field{
+			Type: graphql.String,
+		},
+		"BookedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"ValueAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Amount": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Positive when credited to the account",
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Payee": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Memo": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Status": &graphql.Field{
+			Type:        graphql.String,
+			Description: "unmatched or matched",
+		},
+		"TransactionID": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Transaction the line is matched to",
+		},
+	},
+})
+
+// GraphQL Enum for the statuses of a reconciliation match
+var matchStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "MatchStatus",
+	Values: graphql.EnumValueConfigMap{
+		"PROPOSED": &graphql.EnumValueConfig{
+			Value: models.MatchProposed,
+		},
+		"CONFIRMED": &graphql.EnumValueConfig{
+			Value: models.MatchConfirmed,
+		},
+		"REJECTED": &graphql.EnumValueConfig{
+			Value: models.MatchRejected,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.ReconciliationMatch
+var reconciliationMatchType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ReconciliationMatch",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"StatementLineID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"TransactionID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Score": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Confidence between 0 and 1 that the transaction is the one of the statement line",
+		},
+		"Reasons": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Criteria that contributed to the score",
+		},
+		"Status": &graphql.Field{
+			Type: matchStatusEnum,
+		},
+		"Manual": &graphql.Field{
+			Type:        graphql.Boolean,
+			Description: "True when an operator paired the statement line and the transaction by hand",
+		},
+		"Note": &graphql.Field{
+			Type: graphql.String,
+		},
+		"ResolvedBy": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Operator who confirmed, rejected or paired the match",
+		},
+		"ResolvedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct ReconciliationReport
+var reconciliationReportType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ReconciliationReport",
+	Fields: graphql.Fields{
+		"Account": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Total": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Loaded": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Skipped": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Entries whose FITID was already loaded",
+		},
+		"Failed": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Errors": &graphql.Field{
+			Type: graphql.NewList(importErrorType),
+		},
+		"Lines": &graphql.Field{
+			Type: graphql.NewList(statementLineType),
+		},
+		"Matches": &graphql.Field{
+			Type:        graphql.NewList(reconciliationMatchType),
+			Description: "Matches proposed for every unmatched statement line of the account",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct UnmatchedItems
+var unmatchedItemsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "UnmatchedItems",
+	Fields: graphql.Fields{
+		"Account": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Lines": &graphql.Field{
+			Type:        graphql.NewList(statementLineType),
+			Description: "Statement lines without a transaction",
+		},
+		"Transactions": &graphql.Field{
+			Type:        graphql.NewList(transactionType),
+			Description: "Transactions without a statement line",
+		},
+	},
+})
+
+// reconciliationQueries return the root query fields of bank reconciliation
+func (gql *GraphQL) reconciliationQueries() graphql.Fields {
+	return graphql.Fields{
+		// Read what is left to reconcile
+		"UnmatchedItems": &graphql.Field{
+			Type:        unmatchedItemsType,
+			Description: "Get the statement lines and the transactions of an account that are not matched yet (owner of the account, auditor or admin)",
+			Args: graphql.FieldConfigArgument{
+				"Account": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"From": &graphql.ArgumentConfig{
+					Type:        graphql.DateTime,
+					Description: "Start of the transactions, the first statement line when missing",
+				},
+				"To": &graphql.ArgumentConfig{
+					Type:        graphql.DateTime,
+					Description: "End of the transactions, the day after the last statement line when missing",
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := stringArg(params.Args, "Account")
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, account, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				from, err := optionalTimeArg(params.Args, "From")
+				if err != nil {
+					return nil, err
+				}
+				to, err := optionalTimeArg(params.Args, "To")
+				if err != nil {
+					return nil, err
+				}
+				return gql.reconciliationController.Unmatched(account, from, to)
+			},
+		},
+
+		// Read the reconciliation matches of an account
+		"ReconciliationMatches": &graphql.Field{
+			Type:        graphql.NewList(reconciliationMatchType),
+			Description: "Get the matches of the statement lines of an account, highest score first (owner of the account, auditor or admin)",
+			Args: graphql.FieldConfigArgument{
+				"Account": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"Status": &graphql.ArgumentConfig{
+					Type:        matchStatusEnum,
+					Description: "Every match when missing",
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := stringArg(params.Args, "Account")
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, account, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				status, _ := params.Args["Status"].(string)
+				return gql.reconciliationController.reconService.ReadMatches(account, status)
+			},
+		},
+	}
+}
+
+// reconciliationMutations return the root mutation fields of bank reconciliation
+func (gql *GraphQL) reconciliationMutations() graphql.Fields {
+	reconcileArgs := graphql.FieldConfigArgument{
+		"File": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(uploadScalar),
+		},
+		"Account": &graphql.ArgumentConfig{
+			Type:        graphql.NewNonNull(graphql.String),
+			Description: "Email of the user the statement belongs to",
+		},
+		"Format": &graphql.ArgumentConfig{
+			Type:        statementFormatEnum,
+			Description: "Inferred from the extension of the file when missing",
+		},
+		"DateFormat": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "Go layout of the dates of a QIF file, US dates when missing",
+		},
+	}
+	matchArgs := graphql.FieldConfigArgument{
+		"Account": &graphql.ArgumentConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+	}
+	for name, arg := range matchOptionsArgs {
+		reconcileArgs[name] = arg
+		matchArgs[name] = arg
+	}
+
+	return graphql.Fields{
+		// Load a bank statement to reconcile
+		"ReconcileStatement": &graphql.Field{
+			Type:        reconciliationReportType,
+			Description: "Load the entries of an uploaded bank statement of an account without creating transactions and propose the transaction matching every unmatched line (admin or owner of the account)",
+			Args:        reconcileArgs,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := stringArg(params.Args, "Account")
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, account, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				fileHeader, isOK := params.Args["File"].(*multipart.FileHeader)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing File")
+				}
+
+				var statement StatementOptions
+				statement.Format, _ = params.Args["Format"].(string)
+				if statement.Format == "" {
+					statement.Format = imports.StatementFormat(fileHeader.Filename)
+				}
+				statement.DateFormat, _ = params.Args["DateFormat"].(string)
+
+				file, err := fileHeader.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer file.Close()
+				return gql.reconciliationController.LoadStatement(file, account, statement, matchOptionsArg(params.Args))
+			},
+		},
+
+		// Propose matches again
+		"MatchStatementLines": &graphql.Field{
+			Type:        graphql.NewList(reconciliationMatchType),
+			Description: "Replace the proposed matches of the unmatched statement lines of an account (admin or owner of the account)",
+			Args:        matchArgs,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := stringArg(params.Args, "Account")
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, account, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return gql.reconciliationController.Match(account, matchOptionsArg(params.Args))
+			},
+		},
+
+		// Confirm a proposed match
+		"ConfirmMatch": &graphql.Field{
+			Type:        reconciliationMatchType,
+			Description: "Accept a proposed match, its statement line is matched to its transaction (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				return gql.reconciliationController.WithActor(actorFrom(params.Context)).Confirm(uint(id))
+			},
+		},
+
+		// Reject a proposed match
+		"RejectMatch": &graphql.Field{
+			Type:        reconciliationMatchType,
+			Description: "Refuse a proposed match, it is not proposed again (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"Note": &graphql.ArgumentConfig{
+					Type:        graphql.String,
+					Description: "Why the match is rejected",
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				note, _ := params.Args["Note"].(string)
+				return gql.reconciliationController.WithActor(actorFrom(params.Context)).Reject(uint(id), note)
+			},
+		},
+
+		// Pair a statement line with a transaction by hand
+		"PairStatementLine": &graphql.Field{
+			Type:        reconciliationMatchType,
+			Description: "Match a statement line with a transaction of its account by hand, the matches proposed for either are rejected (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"StatementLineID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"TransactionID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"Note": &graphql.ArgumentConfig{
+					Type: graphql.String,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				lineID, OK1 := params.Args["StatementLineID"].(int)
+				transactionID, OK2 := params.Args["TransactionID"].(int)
+				if !OK1 || !OK2 {
+					return nil, errors.New("GraphQL: missing StatementLineID or TransactionID")
+				}
+				note, _ := params.Args["Note"].(string)
+				return gql.reconciliationController.WithActor(actorFrom(params.Context)).Pair(uint(lineID), uint(transactionID), note)
+			},
+		},
+	}
+}
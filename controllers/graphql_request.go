@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/graphql-go/graphql"
+	"transaction_project/models"
+)
+
+// GraphQL Enum for the statuses of a models.PaymentRequest
+var paymentRequestStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "PaymentRequestStatus",
+	Values: graphql.EnumValueConfigMap{
+		"PENDING": &graphql.EnumValueConfig{
+			Value: models.RequestPending,
+		},
+		"ACCEPTED": &graphql.EnumValueConfig{
+			Value: models.RequestAccepted,
+		},
+		"DECLINED": &graphql.EnumValueConfig{
+			Value: models.RequestDeclined,
+		},
+		"EXPIRED": &graphql.EnumValueConfig{
+			Value: models.RequestExpired,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.PaymentRequest
+var paymentRequestType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PaymentRequest",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Requester": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Account asking to be paid, the receiver of the transaction",
+		},
+		"Payer": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Account asked to pay, the sender of the transaction",
+		},
+		"Amount": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Note": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Status": &graphql.Field{
+			Type: paymentRequestStatusEnum,
+		},
+		"ExpiresAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Reason": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Why the payer declined the request",
+		},
+		"ResolvedAt": &graphql.Field{
+			Type:        graphql.DateTime,
+			Description: "When the request was accepted, declined or expired",
+		},
+		"TransactionID": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Transaction paying the request once it is accepted",
+		},
+	},
+})
+
+// GraphQL InputObject for creating a models.PaymentRequest, see PaymentRequestInput
+var paymentRequestInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "PaymentRequestInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Payer": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Amount": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.Float),
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "Currency of your account when missing",
+		},
+		"Note": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "Note of the transaction paying the request",
+		},
+		"ExpiresAt": &graphql.InputObjectFieldConfig{
+			Type:        graphql.DateTime,
+			Description: "A week from now when missing",
+		},
+	},
+})
+
+// scopedAccount return the account whose records are read, the Account argument for auditors and admins
+// and the caller otherwise
+func scopedAccount(ctx context.Context, args map[string]interface{}) (string, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return "", errUnauthenticated
+	}
+	account, _ := args["Account"].(string)
+	if account == "" || account == user.Email {
+		return user.Email, nil
+	}
+	if err := requireRole(ctx, models.RoleAuditor, models.RoleAdmin); err != nil {
+		return "", err
+	}
+	return account, nil
+}
+
+// paymentRequestQueries return the root query fields of payment requests
+func (gql *GraphQL) paymentRequestQueries() graphql.Fields {
+	requestService := gql.requestController.requestService
+
+	listArgs := graphql.FieldConfigArgument{
+		"Status": &graphql.ArgumentConfig{
+			Type:        paymentRequestStatusEnum,
+			Description: "Every status when missing",
+		},
+		"Account": &graphql.ArgumentConfig{
+			Type:        graphql.String,
+			Description: "Account whose requests are read, yours when missing (auditor or admin for another account)",
+		},
+	}
+
+	return graphql.Fields{
+		// Read a single payment request
+		"PaymentRequest": &graphql.Field{
+			Type:        paymentRequestType,
+			Description: "Get a single payment request (requester, payer, auditor or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				request, err := requestService.ReadByID(uint(id))
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, request.Requester); err == nil {
+					return request, nil
+				}
+				if err := requireOwnerOrRole(params.Context, request.Payer, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return request, nil
+			},
+		},
+
+		// Read the payment requests to pay
+		"IncomingPaymentRequests": &graphql.Field{
+			Type:        graphql.NewList(paymentRequestType),
+			Description: "Get the payment requests you are asked to pay, newest first",
+			Args:        listArgs,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := scopedAccount(params.Context, params.Args)
+				if err != nil {
+					return nil, err
+				}
+				status, _ := params.Args["Status"].(string)
+				return requestService.ReadIncoming(account, status)
+			},
+		},
+
+		// Read the payment requests made
+		"OutgoingPaymentRequests": &graphql.Field{
+			Type:        graphql.NewList(paymentRequestType),
+			Description: "Get the payment requests you made, newest first",
+			Args:        listArgs,
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := scopedAccount(params.Context, params.Args)
+				if err != nil {
+					return nil, err
+				}
+				status, _ := params.Args["Status"].(string)
+				return requestService.ReadOutgoing(account, status)
+			},
+		},
+	}
+}
+
+// paymentRequestMutations return the root mutation fields of payment requests
+func (gql *GraphQL) paymentRequestMutations() graphql.Fields {
+	requestService := gql.requestController.requestService
+
+	// requirePayer check that the caller is asked to pay the payment request with the ID argument
+	requirePayer := func(params graphql.ResolveParams) (uint, error) {
+		id, isOK := params.Args["ID"].(int)
+		if !isOK {
+			return 0, errors.New("GraphQL: missing ID")
+		}
+		if currentUser(params.Context) == nil {
+			return 0, errUnauthenticated
+		}
+		request, err := requestService.ReadByID(uint(id))
+		if err != nil {
+			return 0, err
+		}
+		return uint(id), requireOwnerOrRole(params.Context, request.Payer)
+	}
+
+	return graphql.Fields{
+		// Request a payment
+		"RequestPayment": &graphql.Field{
+			Type:        paymentRequestType,
+			Description: "Ask another user to pay you an amount",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(paymentRequestInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				user := currentUser(params.Context)
+				if user == nil {
+					return nil, errUnauthenticated
+				}
+				input, err := paymentRequestInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				return gql.requestController.WithActor(user.Email).NewModel(user.Email, input)
+			},
+		},
+
+		// Accept a payment request
+		"AcceptPaymentRequest": &graphql.Field{
+			Type:        paymentRequestType,
+			Description: "Pay a pending payment request you were sent, creating the transaction to the requester",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, err := requirePayer(params)
+				if err != nil {
+					return nil, err
+				}
+				request, err := gql.requestController.WithActor(actorFrom(params.Context)).Accept(id)
+				if err != nil {
+					return nil, gqlError(err)
+				}
+				return request, nil
+			},
+		},
+
+		// Decline a payment request
+		"DeclinePaymentRequest": &graphql.Field{
+			Type:        paymentRequestType,
+			Description: "Refuse a pending payment request you were sent",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"Reason": &graphql.ArgumentConfig{
+					Type: graphql.String,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, err := requirePayer(params)
+				if err != nil {
+					return nil, err
+				}
+				reason, _ := params.Args["Reason"].(string)
+				return gql.requestController.WithActor(actorFrom(params.Context)).Decline(id, reason)
+			},
+		},
+	}
+}
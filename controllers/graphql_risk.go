@@ -0,0 +1,336 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/graphql-go/graphql"
+	"strings"
+	"transaction_project/models"
+)
+
+// GraphQL Enum for the statuses of a models.RiskAssessment
+var riskStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "RiskStatus",
+	Values: graphql.EnumValueConfigMap{
+		"ALLOWED": &graphql.EnumValueConfig{
+			Value:       models.RiskAllowed,
+			Description: "The transaction was created, or held for approval",
+		},
+		"HELD": &graphql.EnumValueConfig{
+			Value:       models.RiskHeld,
+			Description: "The transaction awaits review",
+		},
+		"BLOCKED": &graphql.EnumValueConfig{
+			Value:       models.RiskBlocked,
+			Description: "The transaction was refused right away",
+		},
+		"RELEASED": &graphql.EnumValueConfig{
+			Value:       models.RiskReleased,
+			Description: "A reviewer cleared the held transaction",
+		},
+		"REJECTED": &graphql.EnumValueConfig{
+			Value:       models.RiskRejected,
+			Description: "A reviewer refused the held transaction",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.RiskSignal
+var riskSignalType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "RiskSignal",
+	Fields: graphql.Fields{
+		"Rule": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Score": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Points the rule added to the risk score",
+		},
+		"Reason": &graphql.Field{
+			Type: graphql.String,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.RiskAssessment
+var riskAssessmentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "RiskAssessment",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"CreatedBy": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Score": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "From 0 to 100, the sum of the points of the triggered rules",
+		},
+		"Status": &graphql.Field{
+			Type: riskStatusEnum,
+		},
+		"Signals": &graphql.Field{
+			Type:        graphql.NewList(riskSignalType),
+			Description: "Rules the transaction triggered and why (auditor or admin, null for other users)",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				assessment, isOK := params.Source.(*models.RiskAssessment)
+				if !isOK {
+					if value, isValue := params.Source.(models.RiskAssessment); isValue {
+						assessment, isOK = &value, true
+					}
+				}
+				if !isOK || requireRole(params.Context, models.RoleAuditor, models.RoleAdmin) != nil {
+					return nil, nil
+				}
+				return assessment.Signals, nil
+			},
+		},
+		"Value": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Note": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Sender": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Receiver": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"ReceivedCurrency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"TransactionID": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Transaction created once the assessment allowed it or a reviewer released it",
+		},
+		"ApprovalID": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Approval the transaction awaits when it met approval policies",
+		},
+		"ReviewedBy": &graphql.Field{
+			Type: graphql.String,
+		},
+		"ReviewComment": &graphql.Field{
+			Type: graphql.String,
+		},
+		"ReviewedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.RiskSettings
+var riskSettingsType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "RiskSettings",
+	Fields: graphql.Fields{
+		"HoldScore": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Transactions scoring at least this are held for review, null when none is",
+		},
+		"BlockScore": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Transactions scoring at least this are blocked, null when none is",
+		},
+		"DisabledRules": &graphql.Field{
+			Type: graphql.NewList(graphql.String),
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				settings, isOK := params.Source.(*models.RiskSettings)
+				if !isOK || settings.DisabledRules == "" {
+					return []string{}, nil
+				}
+				return strings.Split(settings.DisabledRules, ","), nil
+			},
+		},
+		"Rules": &graphql.Field{
+			Type:        graphql.NewList(graphql.String),
+			Description: "Every registered rule in the order they are evaluated",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				return models.RiskRuleNames(), nil
+			},
+		},
+	},
+})
+
+// GraphQL InputObject for updating the models.RiskSettings, see RiskSettingsPatch
+var riskSettingsPatchType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "RiskSettingsPatch",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"HoldScore": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Int,
+			Description: "0 to never hold transactions",
+		},
+		"BlockScore": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Int,
+			Description: "0 to never block transactions",
+		},
+		"DisabledRules": &graphql.InputObjectFieldConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+			Description: "Replace the rules that are not evaluated, an empty list enables every rule",
+		},
+	},
+})
+
+// riskQueries return the root query fields of the risk settings and assessments, auditors and admins can
+// read assessments and only admins the settings
+func (gql *GraphQL) riskQueries() graphql.Fields {
+	riskService := gql.riskController.riskService
+
+	return graphql.Fields{
+		// Read the settings
+		"RiskSettings": &graphql.Field{
+			Type:        riskSettingsType,
+			Description: "Get the thresholds and disabled rules of the risk engine (admin only)",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return riskService.ReadSettings()
+			},
+		},
+
+		// Read the review queue
+		"RiskReviewQueue": &graphql.Field{
+			Type:        graphql.NewList(riskAssessmentType),
+			Description: "Get the risk assessments of scored transactions, riskiest first (auditor or admin)",
+			Args: graphql.FieldConfigArgument{
+				"Status": &graphql.ArgumentConfig{
+					Type:         riskStatusEnum,
+					DefaultValue: models.RiskHeld,
+					Description:  "The transactions awaiting review when missing",
+				},
+				"MinScore": &graphql.ArgumentConfig{
+					Type:         graphql.Int,
+					DefaultValue: 0,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				status, _ := params.Args["Status"].(string)
+				minScore, _ := params.Args["MinScore"].(int)
+				return riskService.ReadAssessments(status, minScore)
+			},
+		},
+
+		// Read a single assessment
+		"RiskAssessment": &graphql.Field{
+			Type:        riskAssessmentType,
+			Description: "Get a single risk assessment (auditor or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				return riskService.ReadByID(uint(id))
+			},
+		},
+
+		// Read the assessment of a transaction
+		"TransactionRisk": &graphql.Field{
+			Type:        riskAssessmentType,
+			Description: "Get the latest risk assessment of a transaction, null when it was never scored (auditor or admin)",
+			Args: graphql.FieldConfigArgument{
+				"TransactionID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["TransactionID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing TransactionID")
+				}
+				assessment, err := riskService.ReadByTransaction(uint(id))
+				if err == models.ErrNotFound {
+					return nil, nil
+				}
+				return assessment, err
+			},
+		},
+	}
+}
+
+// riskMutations return the root mutation fields of the risk settings and reviews, every one is admin only
+func (gql *GraphQL) riskMutations() graphql.Fields {
+	// reviewField return a field resolving a held transaction with review
+	reviewField := func(description string, review func(*Risk, uint, string) (*models.RiskAssessment, error)) *graphql.Field {
+		return &graphql.Field{
+			Type:        riskAssessmentType,
+			Description: description,
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type:        graphql.NewNonNull(graphql.Int),
+					Description: "ID of the risk assessment",
+				},
+				"Comment": &graphql.ArgumentConfig{
+					Type: graphql.String,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				comment, _ := params.Args["Comment"].(string)
+				assessment, err := review(gql.riskController.WithActor(actorFrom(params.Context)), uint(id), comment)
+				if err != nil {
+					return nil, gqlError(err)
+				}
+				return assessment, nil
+			},
+		}
+	}
+
+	return graphql.Fields{
+		// Update the settings
+		"UpdateRiskSettings": &graphql.Field{
+			Type:        riskSettingsType,
+			Description: "Update the thresholds and disabled rules of the risk engine, they apply to the transactions scored from now on (admin only)",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(riskSettingsPatchType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				if err := requireRole(params.Context, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				patch, err := riskSettingsPatchArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				return gql.riskController.WithActor(actorFrom(params.Context)).UpdateSettings(patch)
+			},
+		},
+
+		// Release a held transaction
+		"ReleaseHeldTransaction": reviewField(
+			"Clear a transaction held for review, it is created or held for approval when it meets approval policies. Its creator can not review it.",
+			(*Risk).Release),
+
+		// Reject a held transaction
+		"RejectHeldTransaction": reviewField(
+			"Refuse a transaction held for review, it is never created. Its creator can not review it.",
+			(*Risk).Reject),
+	}
+}
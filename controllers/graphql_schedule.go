@@ -0,0 +1,365 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/graphql-go/graphql"
+	"transaction_project/models"
+)
+
+// GraphQL ObjectTypes for Golang struct models.ScheduledTransaction
+var scheduledTransactionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "ScheduledTransaction",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"Value": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Note": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Sender": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Receiver": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"ReceivedCurrency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Rule": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Recurrence rule like FREQ=MONTHLY;BYMONTHDAY=1, empty for a one-off",
+		},
+		"StartAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"EndAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Count": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Maximum number of transactions to create, 0 for no limit",
+		},
+		"Occurrences": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Number of transactions created so far",
+		},
+		"LastRunAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"NextRunAt": &graphql.Field{
+			Type:        graphql.DateTime,
+			Description: "Next occurrence, null once the schedule is finished or paused",
+		},
+		"Failures": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Occurrences that failed in a row, they are skipped",
+		},
+		"LastError": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Why the last failed occurrence could not be created",
+		},
+		"LastFailedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"PausedAt": &graphql.Field{
+			Type:        graphql.DateTime,
+			Description: "When the schedule was paused after too many failures, updating the schedule resumes it",
+		},
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.UpcomingTransaction
+var upcomingTransactionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "UpcomingTransaction",
+	Fields: graphql.Fields{
+		"ScheduleID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"OccursAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Value": &graphql.Field{
+			Type: graphql.Float,
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Note": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Sender": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Receiver": &graphql.Field{
+			Type: graphql.String,
+		},
+	},
+})
+
+// GraphQL InputObject for creating a models.ScheduledTransaction, see ScheduleInput
+var scheduleInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ScheduledTransactionInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Value": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.Float),
+		},
+		"Note": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Sender": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Receiver": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"ReceivedCurrency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Rule": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "Empty for a one-off, daily, weekly, monthly, yearly, or an RRULE like FREQ=WEEKLY;INTERVAL=2;BYDAY=MO",
+		},
+		"StartAt": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.DateTime),
+		},
+		"EndAt": &graphql.InputObjectFieldConfig{
+			Type: graphql.DateTime,
+		},
+		"Count": &graphql.InputObjectFieldConfig{
+			Type: graphql.Int,
+		},
+	},
+})
+
+// GraphQL InputObject for updating a models.ScheduledTransaction, see SchedulePatch
+var schedulePatchType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "ScheduledTransactionPatch",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Value": &graphql.InputObjectFieldConfig{
+			Type: graphql.Float,
+		},
+		"Note": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Sender": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Receiver": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"ReceivedCurrency": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Rule": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"StartAt": &graphql.InputObjectFieldConfig{
+			Type: graphql.DateTime,
+		},
+		"EndAt": &graphql.InputObjectFieldConfig{
+			Type: graphql.DateTime,
+		},
+		"Count": &graphql.InputObjectFieldConfig{
+			Type: graphql.Int,
+		},
+	},
+})
+
+// scheduleQueries return the root query fields of scheduled transactions
+func (gql *GraphQL) scheduleQueries() graphql.Fields {
+	scheduleService := gql.scheduleController.scheduleService
+
+	return graphql.Fields{
+		// Read a single scheduled transaction
+		"ScheduledTransaction": &graphql.Field{
+			Type:        scheduledTransactionType,
+			Description: "Get a single scheduled transaction (sender, receiver, auditor or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				schedule, err := scheduleService.ReadByID(uint(id))
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, schedule.Sender); err == nil {
+					return schedule, nil
+				}
+				if err := requireOwnerOrRole(params.Context, schedule.Receiver, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return schedule, nil
+			},
+		},
+
+		// Read all scheduled transactions
+		"AllScheduledTransaction": &graphql.Field{
+			Type:        graphql.NewList(scheduledTransactionType),
+			Description: "Get all scheduled transactions. Users other than auditors and admins only get the schedules of their account.",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := scheduleScope(params.Context)
+				if err != nil {
+					return nil, err
+				}
+				return scheduleService.ReadAll(account)
+			},
+		},
+
+		// Preview the occurrences of the scheduled transactions
+		"UpcomingTransactions": &graphql.Field{
+			Type:        graphql.NewList(upcomingTransactionType),
+			Description: "Get every occurrence of the scheduled transactions between From and To, nothing is created. Users other than auditors and admins only get the occurrences of their account.",
+			Args: graphql.FieldConfigArgument{
+				"From": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.DateTime),
+				},
+				"To": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.DateTime),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				account, err := scheduleScope(params.Context)
+				if err != nil {
+					return nil, err
+				}
+				from, err := timeArg(params.Args, "From")
+				if err != nil {
+					return nil, err
+				}
+				to, err := timeArg(params.Args, "To")
+				if err != nil {
+					return nil, err
+				}
+				return scheduleService.Upcoming(account, from, to)
+			},
+		},
+	}
+}
+
+// scheduleScope return the account whose schedules the caller can read, empty for auditors and admins who
+// can read every schedule
+func scheduleScope(ctx context.Context) (string, error) {
+	user := currentUser(ctx)
+	if user == nil {
+		return "", errUnauthenticated
+	}
+	if requireRole(ctx, models.RoleAuditor, models.RoleAdmin) != nil {
+		return user.Email, nil
+	}
+	return "", nil
+}
+
+// requireScheduleSender check that the caller sends the scheduled transaction with the provided ID or is
+// an admin
+func (gql *GraphQL) requireScheduleSender(ctx context.Context, id uint) error {
+	if currentUser(ctx) == nil {
+		return errUnauthenticated
+	}
+	schedule, err := gql.scheduleController.scheduleService.ReadByID(id)
+	if err != nil {
+		return err
+	}
+	return requireOwnerOrRole(ctx, schedule.Sender, models.RoleAdmin)
+}
+
+// scheduleMutations return the root mutation fields of scheduled transactions
+func (gql *GraphQL) scheduleMutations() graphql.Fields {
+	scheduleService := gql.scheduleController.scheduleService
+
+	return graphql.Fields{
+		// Create a scheduled transaction
+		"AddScheduledTransaction": &graphql.Field{
+			Type:        scheduledTransactionType,
+			Description: "Create a new one-off or recurring scheduled transaction (sender or admin)",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(scheduleInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				input, err := scheduleInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, input.Sender, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return gql.scheduleController.WithActor(actorFrom(params.Context)).NewModel(input)
+			},
+		},
+
+		// Update a scheduled transaction
+		"UpdateScheduledTransaction": &graphql.Field{
+			Type:        scheduledTransactionType,
+			Description: "Update a scheduled transaction, occurrences already created or skipped are not repeated and a paused schedule resumes (sender or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+				"Patch": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(schedulePatchType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, OK := params.Args["ID"].(int)
+				if !OK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				patch, err := schedulePatchArg(params.Args, "Patch")
+				if err != nil {
+					return nil, err
+				}
+				if err := gql.requireScheduleSender(params.Context, uint(id)); err != nil {
+					return nil, err
+				}
+				if patch.Sender != nil {
+					if err := requireOwnerOrRole(params.Context, *patch.Sender, models.RoleAdmin); err != nil {
+						return nil, err
+					}
+				}
+				return gql.scheduleController.WithActor(actorFrom(params.Context)).UpdateModel(uint(id), patch)
+			},
+		},
+
+		// Delete a scheduled transaction
+		"DeleteScheduledTransaction": &graphql.Field{
+			Type:        graphql.Int,
+			Description: "Delete a scheduled transaction, no more transactions are created from it (sender or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if isOK {
+					if err := gql.requireScheduleSender(params.Context, uint(id)); err != nil {
+						return 0, err
+					}
+					return id, scheduleService.WithActor(actorFrom(params.Context)).Delete(uint(id))
+				}
+
+				return 0, errors.New("GraphQL: missing ID")
+			},
+		},
+	}
+}
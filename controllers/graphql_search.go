@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"github.com/graphql-go/graphql"
+	"transaction_project/models"
+)
+
+// GraphQL Enum for the kinds of search results
+var searchKindEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "SearchKind",
+	Values: graphql.EnumValueConfigMap{
+		"TRANSACTION": &graphql.EnumValueConfig{
+			Value: models.SearchTransaction,
+		},
+		"USER": &graphql.EnumValueConfig{
+			Value: models.SearchUser,
+		},
+	},
+})
+
+// GraphQL Union of the entities a search returns
+var searchResultUnion = graphql.NewUnion(graphql.UnionConfig{
+	Name:  "SearchResult",
+	Types: []*graphql.Object{transactionType, userType},
+	ResolveType: func(params graphql.ResolveTypeParams) *graphql.Object {
+		if _, isOK := params.Value.(*models.User); isOK {
+			return userType
+		}
+		return transactionType
+	},
+})
+
+// GraphQL ObjectTypes for Golang struct models.SearchHit
+var searchHitType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "SearchHit",
+	Fields: graphql.Fields{
+		"Kind": &graphql.Field{
+			Type: searchKindEnum,
+		},
+		"Rank": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Relevance of the result, higher for better matches",
+		},
+		"Result": &graphql.Field{
+			Type:        searchResultUnion,
+			Description: "The transaction or the user found, select its fields with ... on Transaction or ... on User",
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				hit, isOK := params.Source.(models.SearchHit)
+				if !isOK {
+					return nil, nil
+				}
+				if hit.User != nil {
+					return hit.User, nil
+				}
+				return hit.Transaction, nil
+			},
+		},
+	},
+})
+
+// searchQueries return the root query fields of the full-text search
+func (gql *GraphQL) searchQueries() graphql.Fields {
+	return graphql.Fields{
+		// Search transactions and users
+		"Search": &graphql.Field{
+			Type:        graphql.NewList(searchHitType),
+			Description: "Find the transactions whose sender, receiver or note, and the users whose email or names, have words starting with every word of Query, best matches first. Users who are neither auditors nor admins only find their own transactions.",
+			Args: graphql.FieldConfigArgument{
+				"Query": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.String),
+				},
+				"Kinds": &graphql.ArgumentConfig{
+					Type:        graphql.NewList(graphql.NewNonNull(searchKindEnum)),
+					Description: "Kinds of results to search, every kind when missing",
+				},
+				"Limit": &graphql.ArgumentConfig{
+					Type:         graphql.Int,
+					DefaultValue: models.DefaultSearchLimit,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				user := currentUser(params.Context)
+				if user == nil {
+					return nil, errUnauthenticated
+				}
+				query, err := stringArg(params.Args, "Query")
+				if err != nil {
+					return nil, err
+				}
+				var options models.SearchOptions
+				if _, isSet := params.Args["Kinds"]; isSet {
+					if options.Kinds, err = stringListArg(params.Args, "Kinds"); err != nil {
+						return nil, err
+					}
+				}
+				options.Limit, _ = params.Args["Limit"].(int)
+				if requireRole(params.Context, models.RoleAuditor, models.RoleAdmin) != nil {
+					options.Account = user.Email
+				}
+				return gql.searchController.Search(query, options)
+			},
+		},
+	}
+}
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/graphql-go/graphql"
+	"transaction_project/models"
+)
+
+// GraphQL ObjectTypes for Golang struct models.SplitTransaction
+var splitTransactionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "SplitTransaction",
+	Fields: graphql.Fields{
+		"ID": &graphql.Field{
+			Type: graphql.Int,
+		},
+		"CreatedAt": &graphql.Field{
+			Type: graphql.DateTime,
+		},
+		"Sender": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Total": &graphql.Field{
+			Type:        graphql.Float,
+			Description: "Sum of the values of the legs",
+		},
+		"Currency": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Note": &graphql.Field{
+			Type: graphql.String,
+		},
+		"Legs": &graphql.Field{
+			Type:        graphql.NewList(transactionType),
+			Description: "Transaction of each receiver, only loaded when a single split transaction is read",
+		},
+	},
+})
+
+// GraphQL InputObject for a leg of a models.SplitTransaction, see SplitLegInput
+var splitLegInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "SplitLegInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Receiver": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Amount": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Float,
+			Description: "Amount in the currency of the split, set either Amount or Percentage",
+		},
+		"Percentage": &graphql.InputObjectFieldConfig{
+			Type:        graphql.Float,
+			Description: "Percentage of the total, between 0 and 100",
+		},
+		"Note": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "Note of this leg, the note of the split when missing",
+		},
+	},
+})
+
+// GraphQL InputObject for creating a models.SplitTransaction, see SplitInput
+var splitInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "SplitTransactionInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"Sender": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"Total": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.Float),
+		},
+		"Currency": &graphql.InputObjectFieldConfig{
+			Type:        graphql.String,
+			Description: "Currency of the sender account when missing",
+		},
+		"Note": &graphql.InputObjectFieldConfig{
+			Type: graphql.String,
+		},
+		"Legs": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(splitLegInputType))),
+		},
+	},
+})
+
+// splitQueries return the root query fields of split transactions
+func (gql *GraphQL) splitQueries() graphql.Fields {
+	transService := gql.tranController.transService
+
+	return graphql.Fields{
+		// Read a single split transaction
+		"SplitTransaction": &graphql.Field{
+			Type:        splitTransactionType,
+			Description: "Get a single split transaction with its legs (sender, auditor or admin)",
+			Args: graphql.FieldConfigArgument{
+				"ID": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(graphql.Int),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				id, isOK := params.Args["ID"].(int)
+				if !isOK {
+					return nil, errors.New("GraphQL: missing ID")
+				}
+				split, err := transService.ReadSplit(uint(id))
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, split.Sender, models.RoleAuditor, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				return split, nil
+			},
+		},
+
+		// Read all split transactions
+		"AllSplitTransaction": &graphql.Field{
+			Type:        graphql.NewList(splitTransactionType),
+			Description: "Get every split transaction, or only those of a sender, newest first and without their legs. Users other than auditors and admins only get their own split transactions.",
+			Args: graphql.FieldConfigArgument{
+				"Sender": &graphql.ArgumentConfig{
+					Type: graphql.String,
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				user := currentUser(params.Context)
+				if user == nil {
+					return nil, errUnauthenticated
+				}
+				sender, _ := params.Args["Sender"].(string)
+				if sender != "" {
+					if err := requireOwnerOrRole(params.Context, sender, models.RoleAuditor, models.RoleAdmin); err != nil {
+						return nil, err
+					}
+				} else if requireRole(params.Context, models.RoleAuditor, models.RoleAdmin) != nil {
+					sender = user.Email
+				}
+				return transService.ReadSplits(sender)
+			},
+		},
+	}
+}
+
+// splitMutations return the root mutation fields of split transactions
+func (gql *GraphQL) splitMutations() graphql.Fields {
+	return graphql.Fields{
+		// Create a split transaction
+		"AddSplitTransaction": &graphql.Field{
+			Type:        splitTransactionType,
+			Description: "Create a payment fanned out to several receivers, with a transaction for each leg, all at once (sender or admin)",
+			Args: graphql.FieldConfigArgument{
+				"Input": &graphql.ArgumentConfig{
+					Type: graphql.NewNonNull(splitInputType),
+				},
+			},
+			Resolve: func(params graphql.ResolveParams) (interface{}, error) {
+				input, err := splitInputArg(params.Args, "Input")
+				if err != nil {
+					return nil, err
+				}
+				if err := requireOwnerOrRole(params.Context, input.Sender, models.RoleAdmin); err != nil {
+					return nil, err
+				}
+				split, err := gql.tranController.WithActor(actorFrom(params.Context)).NewSplit(input)
+				if err != nil {
+					return nil, gqlError(err)
+				}
+				return split, nil
+			},
+		},
+	}
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import "transaction_project/models"
+
+type Group struct {
+	groupService *models.GroupService
+}
+
+// GroupInput hold the fields needed to create a new models.ExpenseGroup
+// Currency is optional, a nil Currency is replaced by the currency of the creator account. The creator is
+// always a member, Members lists the other users to add.
+type GroupInput struct {
+	Name     string
+	Currency *string
+	Members  []string
+}
+
+// GroupExpenseInput hold the fields needed to add a new models.GroupExpense
+// Method is optional and defaults to an equal split, with no participants an equal split is shared by
+// every member of the group
+type GroupExpenseInput struct {
+	PaidBy       string
+	Amount       float64
+	Description  *string
+	Method       *string
+	Participants []ExpenseParticipantInput
+}
+
+// ExpenseParticipantInput hold a member taking part in a group expense, Value is ignored by equal splits
+type ExpenseParticipantInput struct {
+	Member string
+	Value  *float64
+}
+
+// NewGroupController create a new Group controller using the provided GroupService
+func NewGroupController(groupService *models.GroupService) *Group {
+	return &Group{
+		groupService: groupService,
+	}
+}
+
+// WithActor return a copy of the controller whose changes are recorded in the audit log as made by the
+// provided actor
+func (gC *Group) WithActor(actor string) *Group {
+	return &Group{
+		groupService: gC.groupService.WithActor(actor),
+	}
+}
+
+// NewGroup create a new models.ExpenseGroup created by the provided user using the models.GroupService
+func (gC *Group) NewGroup(creator string, input GroupInput) (*models.ExpenseGroup, error) {
+	group := &models.ExpenseGroup{
+		Name:      input.Name,
+		CreatedBy: creator,
+	}
+	if input.Currency != nil {
+		group.Currency = *input.Currency
+	}
+	return group, gC.groupService.Create(group, input.Members)
+}
+
+// NewExpense add a new models.GroupExpense to a group using the models.GroupService
+func (gC *Group) NewExpense(groupID uint, input GroupExpenseInput) (*models.GroupExpense, error) {
+	expense := &models.GroupExpense{
+		GroupID: groupID,
+		PaidBy:  input.PaidBy,
+		Amount:  input.Amount,
+	}
+	if input.Description != nil {
+		expense.Description = *input.Description
+	}
+	if input.Method != nil {
+		expense.Method = *input.Method
+	}
+	participants := make([]models.ExpenseParticipant, len(input.Participants))
+	for i, participant := range input.Participants {
+		participants[i].Member = participant.Member
+		if participant.Value != nil {
+			participants[i].Value = *participant.Value
+		}
+	}
+	return expense, gC.groupService.AddExpense(expense, participants)
+}
+
+// SettleUp record the transactions settling every debt of a group using the models.GroupService
+func (gC *Group) SettleUp(groupID uint) ([]models.GroupSettlement, error) {
+	return gC.groupService.SettleUp(groupID)
+}
+
+// AddMember add a user to a group using the models.GroupService
+func (gC *Group) AddMember(groupID uint, email string) (*models.GroupMember, error) {
+	return gC.groupService.AddMember(groupID, email)
+}
+
+// RemoveMember remove a user who has settled up from a group using the models.GroupService
+func (gC *Group) RemoveMember(groupID uint, email string) error {
+	return gC.groupService.RemoveMember(groupID, email)
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"time"
+	"transaction_project/models"
+)
+
+type Hold struct {
+	holdService *models.HoldService
+}
+
+// HoldInput hold the fields needed to place a new models.Hold
+// Currency, Note and ExpiresAt are optional, a nil Currency is replaced by the currency of the sender
+// account and a nil ExpiresAt by models.DefaultHoldExpiry from now
+type HoldInput struct {
+	Sender    string
+	Receiver  string
+	Amount    float64
+	Currency  *string
+	Note      *string
+	ExpiresAt *time.Time
+}
+
+// NewHoldController create a new Hold controller using the provided HoldService
+func NewHoldController(holdService *models.HoldService) *Hold {
+	return &Hold{
+		holdService: holdService,
+	}
+}
+
+// WithActor return a copy of the controller whose changes are recorded in the audit log as made by the
+// provided actor
+func (hC *Hold) WithActor(actor string) *Hold {
+	return &Hold{
+		holdService: hC.holdService.WithActor(actor),
+	}
+}
+
+// NewModel place a new models.Hold using the models.HoldService
+func (hC *Hold) NewModel(input HoldInput) (*models.Hold, error) {
+	hold := &models.Hold{
+		Sender:   input.Sender,
+		Receiver: input.Receiver,
+		Amount:   input.Amount,
+	}
+	if input.Currency != nil {
+		hold.Currency = *input.Currency
+	}
+	if input.Note != nil {
+		hold.Note = *input.Note
+	}
+	if input.ExpiresAt != nil {
+		hold.ExpiresAt = *input.ExpiresAt
+	}
+	return hold, hC.holdService.Create(hold)
+}
+
+// Capture turn an active models.Hold into a transaction of amount, the whole hold if it is nil, using the
+// models.HoldService
+func (hC *Hold) Capture(id uint, amount *float64) (*models.Hold, error) {
+	return hC.holdService.Capture(id, amount)
+}
+
+// Release free an active models.Hold using the models.HoldService
+func (hC *Hold) Release(id uint) (*models.Hold, error) {
+	return hC.holdService.Release(id)
+}
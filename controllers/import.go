@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"io"
+	"sort"
+	"time"
+	"transaction_project/imports"
+	"transaction_project/models"
+)
+
+// defaultImportBatchSize is the number of rows committed in a single database transaction when
+// ImportOptions.BatchSize is not set
+const defaultImportBatchSize = 500
+
+type Import struct {
+	transService     *models.TransactionService
+	statementService *models.StatementService
+}
+
+// ImportOptions describe how a file is read and committed
+// A DryRun validates every row and reports the errors without creating anything
+type ImportOptions struct {
+	imports.Options
+	DryRun    bool
+	BatchSize int
+}
+
+// ImportError is the error of a single row of an imported file
+type ImportError struct {
+	Line    int
+	Message string
+}
+
+// ImportReport summarize an import, Created is always 0 for a dry run
+type ImportReport struct {
+	Total   int
+	Valid   int
+	Invalid int
+	Created int
+	DryRun  bool
+	Errors  []ImportError
+}
+
+// StatementOptions describe how a bank statement is read and imported
+// A DryRun reports what would become of every entry without creating anything
+type StatementOptions struct {
+	Format     string
+	DateFormat string
+	DryRun     bool
+}
+
+// StatementEntryReport is what became of an entry of an imported bank statement, see models.StatementOutcome
+type StatementEntryReport struct {
+	Line          int
+	FITID         string
+	Reference     string
+	PostedAt      *time.Time
+	ValueAt       *time.Time
+	Amount        float64
+	Payee         string
+	Status        string
+	TransactionID uint
+	DuplicateOfID uint
+	Message       string
+}
+
+// StatementReport summarize the import of a bank statement
+type StatementReport struct {
+	Account    string
+	Total      int
+	Created    int
+	Skipped    int
+	Conflicted int
+	Failed     int
+	DryRun     bool
+	Entries    []StatementEntryReport
+}
+
+// NewImportController create a new Import controller using the provided services
+func NewImportController(transService *models.TransactionService, statementService *models.StatementService) *Import {
+	return &Import{
+		transService:     transService,
+		statementService: statementService,
+	}
+}
+
+// WithActor return a copy of the controller whose changes are recorded in the audit log under the provided actor
+func (iC *Import) WithActor(actor string) *Import {
+	return &Import{
+		transService:     iC.transService.WithActor(actor),
+		statementService: iC.statementService.WithActor(actor),
+	}
+}
+
+// Import read every row of the file and create the valid ones in batches, each batch in its own
+// database transaction. A batch that fails is reported as an error on each of its rows and the
+// import goes on with the next batch.
+func (iC *Import) Import(r io.Reader, options ImportOptions) (*ImportReport, error) {
+	rows, err := imports.Parse(r, options.Options)
+	if err != nil {
+		return nil, err
+	}
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	report := &ImportReport{Total: len(rows), DryRun: options.DryRun}
+	var valid []imports.Row
+	for _, row := range rows {
+		if row.Err != nil {
+			report.Errors = append(report.Errors, ImportError{Line: row.Line, Message: row.Err.Error()})
+			continue
+		}
+		valid = append(valid, row)
+	}
+	report.Valid = len(valid)
+	report.Invalid = report.Total - report.Valid
+	if options.DryRun {
+		return report, nil
+	}
+
+	for start := 0; start < len(valid); start += batchSize {
+		end := start + batchSize
+		if end > len(valid) {
+			end = len(valid)
+		}
+		batch := make([]models.Transaction, 0, end-start)
+		for _, row := range valid[start:end] {
+			batch = append(batch, row.Transaction)
+		}
+		if err := iC.transService.CreateBatch(batch); err != nil {
+			for _, row := range valid[start:end] {
+				report.Errors = append(report.Errors, ImportError{Line: row.Line, Message: "batch not created: " + err.Error()})
+			}
+			continue
+		}
+		report.Created += len(batch)
+	}
+	return report, nil
+}
+
+// ImportStatement read every entry of the bank statement of the account and create a transaction for
+// each of them that was not imported before, see models.StatementService.Import
+func (iC *Import) ImportStatement(r io.Reader, account string, options StatementOptions) (*StatementReport, error) {
+	rows, err := imports.ParseStatement(r, imports.Options{Format: options.Format, DateFormat: options.DateFormat})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StatementReport{Account: account, Total: len(rows), DryRun: options.DryRun}
+	var entries []models.StatementEntry
+	var lines []int
+	for _, row := range rows {
+		if row.Err != nil {
+			report.Failed++
+			report.Entries = append(report.Entries, StatementEntryReport{
+				Line:    row.Line,
+				FITID:   row.Entry.FITID,
+				Status:  models.StatementFailed,
+				Message: row.Err.Error(),
+			})
+			continue
+		}
+		entries = append(entries, row.Entry)
+		lines = append(lines, row.Line)
+	}
+	outcomes, err := iC.statementService.Import(account, entries, options.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, outcome := range outcomes {
+		entry := entries[i]
+		entryReport := StatementEntryReport{
+			Line:          lines[i],
+			FITID:         entry.FITID,
+			Reference:     entry.Reference,
+			PostedAt:      &entry.PostedAt,
+			ValueAt:       entry.ValueAt,
+			Amount:        entry.Amount,
+			Payee:         entry.Payee,
+			Status:        outcome.Status,
+			TransactionID: outcome.TransactionID,
+			DuplicateOfID: outcome.DuplicateOfID,
+		}
+		if outcome.Err != nil {
+			entryReport.Message = outcome.Err.Error()
+		}
+		switch outcome.Status {
+		case models.StatementCreated:
+			report.Created++
+		case models.StatementSkipped:
+			report.Skipped++
+		case models.StatementConflicted:
+			report.Conflicted++
+		default:
+			report.Failed++
+		}
+		report.Entries = append(report.Entries, entryReport)
+	}
+	sort.SliceStable(report.Entries, func(i, j int) bool { return report.Entries[i].Line < report.Entries[j].Line })
+	return report, nil
+}
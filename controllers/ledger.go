@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"crypto/ed25519"
+	"transaction_project/models"
+)
+
+type Ledger struct {
+	ledgerService *models.LedgerService
+	publicKey     ed25519.PublicKey
+}
+
+// NewLedgerController create a new Ledger controller using the provided LedgerService
+// Checkpoints are only trusted if they were signed by publicKey, unless it is nil
+func NewLedgerController(ledgerService *models.LedgerService, publicKey ed25519.PublicKey) *Ledger {
+	return &Ledger{
+		ledgerService: ledgerService,
+		publicKey:     publicKey,
+	}
+}
+
+// Verify walk the hash chain over the transaction log and report the first broken link
+func (lC *Ledger) Verify() (*models.LedgerReport, error) {
+	return lC.ledgerService.Verify(lC.publicKey)
+}
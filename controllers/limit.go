@@ -0,0 +1,66 @@
+package controllers
+
+import "transaction_project/models"
+
+type Limit struct {
+	limitService *models.LimitService
+}
+
+// LimitInput hold the maximums of a models.TransactionLimit
+// Every field is optional, a nil maximum is not limited and a nil Currency adds up the values of every
+// currency as they are
+type LimitInput struct {
+	Currency          *string
+	MaxPerTransaction *float64
+	MaxDaily          *float64
+	MaxMonthly        *float64
+	MaxPerHour        *int
+}
+
+// NewLimitController create a new Limit controller using the provided LimitService
+func NewLimitController(limitService *models.LimitService) *Limit {
+	return &Limit{
+		limitService: limitService,
+	}
+}
+
+// WithActor return a copy of the controller whose changes are recorded in the audit log as made by the
+// provided actor
+func (lC *Limit) WithActor(actor string) *Limit {
+	return &Limit{
+		limitService: lC.limitService.WithActor(actor),
+	}
+}
+
+// SetUserLimit set the limit of the user with the provided email, overriding the limit of its role, using
+// the models.LimitService
+func (lC *Limit) SetUserLimit(email string, input LimitInput) (*models.TransactionLimit, error) {
+	return lC.setLimit(&models.TransactionLimit{Email: email}, input)
+}
+
+// SetRoleLimit set the limit of the users with the provided role using the models.LimitService
+func (lC *Limit) SetRoleLimit(role string, input LimitInput) (*models.TransactionLimit, error) {
+	return lC.setLimit(&models.TransactionLimit{Role: role}, input)
+}
+
+// setLimit fill the limit with the maximums of the input and store it
+func (lC *Limit) setLimit(limit *models.TransactionLimit, input LimitInput) (*models.TransactionLimit, error) {
+	if input.Currency != nil {
+		limit.Currency = *input.Currency
+	}
+	limit.MaxPerTransaction = input.MaxPerTransaction
+	limit.MaxDaily = input.MaxDaily
+	limit.MaxMonthly = input.MaxMonthly
+	limit.MaxPerHour = input.MaxPerHour
+	return limit, lC.limitService.SetLimit(limit)
+}
+
+// DeleteLimit remove a models.TransactionLimit using the models.LimitService
+func (lC *Limit) DeleteLimit(id uint) error {
+	return lC.limitService.DeleteLimit(id)
+}
+
+// Usage return the limit applying to a user and what is left of it using the models.LimitService
+func (lC *Limit) Usage(email string) (*models.LimitUsage, error) {
+	return lC.limitService.Usage(email)
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"transaction_project/models"
+	"transaction_project/payments"
+)
+
+type Payment struct {
+	paymentService *models.PaymentService
+	originator     payments.Originator
+}
+
+// BankAccountInput hold the fields needed to set the models.BankAccount of an account
+// IBAN and BIC are used by pain.001 files, RoutingNumber, AccountNumber and AccountType by NACHA files
+type BankAccountInput struct {
+	Account       string
+	HolderName    string
+	IBAN          *string
+	BIC           *string
+	RoutingNumber *string
+	AccountNumber *string
+	AccountType   *string
+}
+
+// PaymentBatchInput hold the fields needed to create a new models.PaymentBatch
+// TransactionIDs selects the transactions to pay, the oldest pending ones, up to Limit, are paid when it is empty
+// ExecutionDate defaults to today when nil
+type PaymentBatchInput struct {
+	Format         string
+	Currency       string
+	TransactionIDs []uint
+	Limit          int
+	ExecutionDate  *time.Time
+}
+
+// NewPaymentController create a new Payment controller using the provided PaymentService, the batches are
+// paid out from the bank account of the originator
+func NewPaymentController(paymentService *models.PaymentService, originator payments.Originator) *Payment {
+	return &Payment{
+		paymentService: paymentService,
+		originator:     originator,
+	}
+}
+
+// SetBankAccount set the bank account of an account using the models.PaymentService
+func (pC *Payment) SetBankAccount(input BankAccountInput) (*models.BankAccount, error) {
+	bankAccount := &models.BankAccount{
+		Account:    input.Account,
+		HolderName: input.HolderName,
+	}
+	if input.IBAN != nil {
+		bankAccount.IBAN = *input.IBAN
+	}
+	if input.BIC != nil {
+		bankAccount.BIC = *input.BIC
+	}
+	if input.RoutingNumber != nil {
+		bankAccount.RoutingNumber = *input.RoutingNumber
+	}
+	if input.AccountNumber != nil {
+		bankAccount.AccountNumber = *input.AccountNumber
+	}
+	if input.AccountType != nil {
+		bankAccount.AccountType = *input.AccountType
+	}
+	return bankAccount, pC.paymentService.SetBankAccount(bankAccount)
+}
+
+// NewBatch generate the payment file of a new batch of pending transactions in the format of the input
+// and mark its transactions as submitted using the models.PaymentService
+func (pC *Payment) NewBatch(input PaymentBatchInput) (*models.PaymentBatch, error) {
+	format := strings.ToLower(input.Format)
+	if !payments.Supported(format) {
+		return nil, fmt.Errorf("%w: %q", payments.ErrUnknownFormat, input.Format)
+	}
+	executionDate := time.Now().UTC().Truncate(24 * time.Hour)
+	if input.ExecutionDate != nil {
+		executionDate = *input.ExecutionDate
+	}
+
+	batch := &models.PaymentBatch{
+		Format:        format,
+		Currency:      input.Currency,
+		ExecutionDate: executionDate,
+	}
+	err := pC.paymentService.CreateBatch(batch, input.TransactionIDs, input.Limit, func(batch *models.PaymentBatch, pending []models.PendingPayment) error {
+		file := payments.Batch{
+			ID:            batch.ID,
+			MessageID:     fmt.Sprintf("TP%s%06d", batch.CreatedAt.UTC().Format("20060102"), batch.ID),
+			CreatedAt:     batch.CreatedAt,
+			ExecutionDate: batch.ExecutionDate,
+			Currency:      batch.Currency,
+			Originator:    pC.originator,
+		}
+		for _, payment := range pending {
+			remittance := payment.Transaction.Note
+			if remittance == "" {
+				remittance = fmt.Sprintf("Transaction %d", payment.Transaction.ID)
+			}
+			file.Payments = append(file.Payments, payments.Payment{
+				EndToEndID:    fmt.Sprintf("TX%d", payment.Transaction.ID),
+				Amount:        payment.Amount,
+				Name:          payment.BankAccount.HolderName,
+				IBAN:          payment.BankAccount.IBAN,
+				BIC:           payment.BankAccount.BIC,
+				RoutingNumber: payment.BankAccount.RoutingNumber,
+				AccountNumber: payment.BankAccount.AccountNumber,
+				Savings:       payment.BankAccount.AccountType == models.AccountSavings,
+				Remittance:    remittance,
+			})
+		}
+
+		filename, content, err := payments.Generate(format, file)
+		if err != nil {
+			return err
+		}
+		batch.MessageID = file.MessageID
+		batch.Filename = filename
+		batch.Content = string(content)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// NewDownloadHandler create a new http.Handler that download again the file of the payment batch whose
+// ID is the id parameter of the query string (admin only)
+func (pC *Payment) NewDownloadHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := requireRole(r.Context(), models.RoleAdmin); err != nil {
+			writeAuthError(w, err)
+			return
+		}
+		id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+		if err != nil || id == 0 {
+			http.Error(w, "missing or invalid id", http.StatusBadRequest)
+			return
+		}
+		batch, err := pC.paymentService.ReadBatch(uint(id))
+		if errors.Is(err, models.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("payment batch %d: %v", id, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", payments.ContentType(batch.Format))
+		w.Header().Set("Content-Disposition", `attachment; filename="`+batch.Filename+`"`)
+		_, _ = w.Write([]byte(batch.Content))
+	})
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"time"
+	"transaction_project/models"
+)
+
+type Rate struct {
+	rateService *models.RateService
+}
+
+// RateInput hold the fields needed to set a models.ExchangeRate
+// EffectiveAt defaults to now when nil
+type RateInput struct {
+	Base        string
+	Quote       string
+	Rate        float64
+	EffectiveAt *time.Time
+}
+
+// NewRateController create a new Rate controller using the provided RateService
+func NewRateController(rateService *models.RateService) *Rate {
+	return &Rate{
+		rateService: rateService,
+	}
+}
+
+// NewModel set the exchange rate of a currency pair using the models.RateService
+func (rC *Rate) NewModel(input RateInput) (*models.ExchangeRate, error) {
+	rate := &models.ExchangeRate{
+		Base:        input.Base,
+		Quote:       input.Quote,
+		Rate:        input.Rate,
+		EffectiveAt: time.Now(),
+	}
+	if input.EffectiveAt != nil {
+		rate.EffectiveAt = *input.EffectiveAt
+	}
+	return rate, rC.rateService.Set(rate)
+}
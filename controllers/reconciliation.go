@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"io"
+	"time"
+	"transaction_project/imports"
+	"transaction_project/models"
+)
+
+type Reconciliation struct {
+	reconService *models.ReconciliationService
+}
+
+// ReconciliationReport is the result of loading a bank statement for reconciliation. Lines hold the
+// statement lines loaded and Matches the matches proposed for every unmatched line of the account.
+type ReconciliationReport struct {
+	Account string
+	Total   int
+	Loaded  int
+	Skipped int
+	Failed  int
+	Errors  []ImportError
+	Lines   []models.StatementLine
+	Matches []models.ReconciliationMatch
+}
+
+// UnmatchedItems hold what is left to reconcile on both sides, the statement lines without a transaction
+// and the transactions without a statement line
+type UnmatchedItems struct {
+	Account      string
+	Lines        []models.StatementLine
+	Transactions []models.Transaction
+}
+
+// NewReconciliationController create a new Reconciliation controller using the provided ReconciliationService
+func NewReconciliationController(reconService *models.ReconciliationService) *Reconciliation {
+	return &Reconciliation{
+		reconService: reconService,
+	}
+}
+
+// WithActor return a copy of the controller whose confirmations, rejections and manual pairs are recorded
+// in the audit log as made by the provided actor
+func (rC *Reconciliation) WithActor(actor string) *Reconciliation {
+	return &Reconciliation{
+		reconService: rC.reconService.WithActor(actor),
+	}
+}
+
+// LoadStatement parse the bank statement of the account read from r, load its entries as statement lines
+// and propose matches for every unmatched line of the account using the models.ReconciliationService
+func (rC *Reconciliation) LoadStatement(r io.Reader, account string, statement StatementOptions, options models.MatchOptions) (*ReconciliationReport, error) {
+	rows, err := imports.ParseStatement(r, imports.Options{Format: statement.Format, DateFormat: statement.DateFormat})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReconciliationReport{Account: account, Total: len(rows)}
+	var entries []models.StatementEntry
+	for _, row := range rows {
+		if row.Err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, ImportError{Line: row.Line, Message: row.Err.Error()})
+			continue
+		}
+		entries = append(entries, row.Entry)
+	}
+	if report.Lines, report.Skipped, err = rC.reconService.AddLines(account, entries); err != nil {
+		return nil, err
+	}
+	report.Loaded = len(report.Lines)
+	if report.Matches, err = rC.reconService.Match(account, options); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Match propose again a transaction for every unmatched statement line of the account
+func (rC *Reconciliation) Match(account string, options models.MatchOptions) ([]models.ReconciliationMatch, error) {
+	return rC.reconService.Match(account, options)
+}
+
+// Unmatched return the statement lines and the transactions of the account left to reconcile, the
+// transactions are restricted to the period covered by the statement lines when from or to is nil
+func (rC *Reconciliation) Unmatched(account string, from, to *time.Time) (*UnmatchedItems, error) {
+	lines, err := rC.reconService.UnmatchedLines(account)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := rC.reconService.UnmatchedTransactions(account, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &UnmatchedItems{
+		Account:      account,
+		Lines:        lines,
+		Transactions: transactions,
+	}, nil
+}
+
+// Confirm accept the proposed match with the provided ID
+func (rC *Reconciliation) Confirm(id uint) (*models.ReconciliationMatch, error) {
+	return rC.reconService.Confirm(id)
+}
+
+// Reject refuse the proposed match with the provided ID
+func (rC *Reconciliation) Reject(id uint, note string) (*models.ReconciliationMatch, error) {
+	return rC.reconService.Reject(id, note)
+}
+
+// Pair match a statement line with a transaction by hand
+func (rC *Reconciliation) Pair(lineID, transactionID uint, note string) (*models.ReconciliationMatch, error) {
+	return rC.reconService.Pair(lineID, transactionID, note)
+}
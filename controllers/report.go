@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"time"
+	"transaction_project/models"
+)
+
+type Report struct {
+	transService *models.TransactionService
+	rateService  *models.RateService
+}
+
+// NewReportController create a new Report controller using the provided services
+func NewReportController(transService *models.TransactionService, rateService *models.RateService) *Report {
+	return &Report{
+		transService: transService,
+		rateService:  rateService,
+	}
+}
+
+// Totals sum the transactions created between from and to, either of which may be nil, converted into
+// the base currency with the rates effective at the end of the period
+func (rC *Report) Totals(base string, from, to *time.Time) (*models.ConvertedTotals, error) {
+	totals, err := rC.transService.TotalsByCurrency(from, to)
+	if err != nil {
+		return nil, err
+	}
+	at := time.Now()
+	if to != nil {
+		at = *to
+	}
+	return rC.rateService.ConvertTotals(totals, base, at)
+}
+
+// Balance compute the balance of the account at the provided date, or now if it is nil, converted into
+// the base currency with the rates effective at that date
+func (rC *Report) Balance(account, base string, at *time.Time) (*models.ConvertedTotals, error) {
+	balances, err := rC.transService.BalanceByCurrency(account, at)
+	if err != nil {
+		return nil, err
+	}
+	rateDate := time.Now()
+	if at != nil {
+		rateDate = *at
+	}
+	return rC.rateService.ConvertTotals(balances, base, rateDate)
+}
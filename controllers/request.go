@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"time"
+	"transaction_project/models"
+)
+
+type PaymentRequest struct {
+	requestService *models.PaymentRequestService
+}
+
+// PaymentRequestInput hold the fields needed to create a new models.PaymentRequest
+// Currency, Note and ExpiresAt are optional, a nil Currency is replaced by the currency of the requester
+// account and a nil ExpiresAt by models.DefaultRequestExpiry from now
+type PaymentRequestInput struct {
+	Payer     string
+	Amount    float64
+	Currency  *string
+	Note      *string
+	ExpiresAt *time.Time
+}
+
+// NewPaymentRequestController create a new PaymentRequest controller using the provided PaymentRequestService
+func NewPaymentRequestController(requestService *models.PaymentRequestService) *PaymentRequest {
+	return &PaymentRequest{
+		requestService: requestService,
+	}
+}
+
+// WithActor return a copy of the controller whose changes are recorded in the audit log as made by the
+// provided actor
+func (rC *PaymentRequest) WithActor(actor string) *PaymentRequest {
+	return &PaymentRequest{
+		requestService: rC.requestService.WithActor(actor),
+	}
+}
+
+// NewModel create a new models.PaymentRequest from the requester using the models.PaymentRequestService
+func (rC *PaymentRequest) NewModel(requester string, input PaymentRequestInput) (*models.PaymentRequest, error) {
+	request := &models.PaymentRequest{
+		Requester: requester,
+		Payer:     input.Payer,
+		Amount:    input.Amount,
+	}
+	if input.Currency != nil {
+		request.Currency = *input.Currency
+	}
+	if input.Note != nil {
+		request.Note = *input.Note
+	}
+	if input.ExpiresAt != nil {
+		request.ExpiresAt = *input.ExpiresAt
+	}
+	return request, rC.requestService.Create(request)
+}
+
+// Accept pay a pending models.PaymentRequest using the models.PaymentRequestService
+func (rC *PaymentRequest) Accept(id uint) (*models.PaymentRequest, error) {
+	return rC.requestService.Accept(id)
+}
+
+// Decline refuse a pending models.PaymentRequest using the models.PaymentRequestService
+func (rC *PaymentRequest) Decline(id uint, reason string) (*models.PaymentRequest, error) {
+	return rC.requestService.Decline(id, reason)
+}
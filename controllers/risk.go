@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"strings"
+	"transaction_project/models"
+)
+
+type Risk struct {
+	riskService *models.RiskService
+}
+
+// RiskSettingsPatch hold the fields of the models.RiskSettings that can be updated
+// Every field is optional, a nil field keeps the value already stored in the database. A threshold of 0
+// never applies.
+type RiskSettingsPatch struct {
+	HoldScore     *int
+	BlockScore    *int
+	DisabledRules []string
+}
+
+// NewRiskController create a new Risk controller using the provided RiskService
+func NewRiskController(riskService *models.RiskService) *Risk {
+	return &Risk{
+		riskService: riskService,
+	}
+}
+
+// WithActor return a copy of the controller reviewing transactions as the provided actor
+func (rC *Risk) WithActor(actor string) *Risk {
+	return &Risk{
+		riskService: rC.riskService.WithActor(actor),
+	}
+}
+
+// UpdateSettings update the models.RiskSettings using the models.RiskService
+func (rC *Risk) UpdateSettings(patch RiskSettingsPatch) (*models.RiskSettings, error) {
+	settings, err := rC.riskService.ReadSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.HoldScore != nil {
+		settings.HoldScore = threshold(*patch.HoldScore)
+	}
+	if patch.BlockScore != nil {
+		settings.BlockScore = threshold(*patch.BlockScore)
+	}
+	if patch.DisabledRules != nil {
+		settings.DisabledRules = strings.Join(patch.DisabledRules, ",")
+	}
+
+	return settings, rC.riskService.SaveSettings(settings)
+}
+
+// threshold return the threshold of the models.RiskSettings for a score, nil for 0
+func threshold(score int) *int {
+	if score == 0 {
+		return nil
+	}
+	return &score
+}
+
+// Release create a transaction held for review using the models.RiskService
+func (rC *Risk) Release(id uint, comment string) (*models.RiskAssessment, error) {
+	return rC.riskService.Release(id, comment)
+}
+
+// Reject refuse a transaction held for review using the models.RiskService
+func (rC *Risk) Reject(id uint, comment string) (*models.RiskAssessment, error) {
+	return rC.riskService.Reject(id, comment)
+}
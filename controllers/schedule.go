@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"time"
+	"transaction_project/models"
+)
+
+type Schedule struct {
+	scheduleService *models.ScheduleService
+}
+
+// ScheduleInput hold the fields needed to create a new models.ScheduledTransaction
+// Rule is either empty for a one-off, a shorthand like monthly, or an RRULE like FREQ=WEEKLY;BYDAY=MO,FR
+// EndAt and Count are optional, the schedule never ends when both are nil
+type ScheduleInput struct {
+	Value            float64
+	Note             *string
+	Sender           string
+	Receiver         string
+	Currency         *string
+	ReceivedCurrency *string
+	Rule             *string
+	StartAt          time.Time
+	EndAt            *time.Time
+	Count            *int
+}
+
+// SchedulePatch hold the fields of a models.ScheduledTransaction that can be updated
+// Every field is optional, a nil field keeps the value already stored in the database
+type SchedulePatch struct {
+	Value            *float64
+	Note             *string
+	Sender           *string
+	Receiver         *string
+	Currency         *string
+	ReceivedCurrency *string
+	Rule             *string
+	StartAt          *time.Time
+	EndAt            *time.Time
+	Count            *int
+}
+
+// NewScheduleController create a new Schedule controller using the provided ScheduleService
+func NewScheduleController(scheduleService *models.ScheduleService) *Schedule {
+	return &Schedule{
+		scheduleService: scheduleService,
+	}
+}
+
+// WithActor return a copy of the controller whose changes are recorded in the audit log as made by the
+// provided actor
+func (sC *Schedule) WithActor(actor string) *Schedule {
+	return &Schedule{
+		scheduleService: sC.scheduleService.WithActor(actor),
+	}
+}
+
+// NewModel create a new models.ScheduledTransaction and then add it to the database using the models.ScheduleService
+func (sC *Schedule) NewModel(input ScheduleInput) (*models.ScheduledTransaction, error) {
+	schedule := &models.ScheduledTransaction{
+		Value:    input.Value,
+		Sender:   input.Sender,
+		Receiver: input.Receiver,
+		StartAt:  input.StartAt,
+		EndAt:    input.EndAt,
+	}
+	if input.Note != nil {
+		schedule.Note = *input.Note
+	}
+	if input.Currency != nil {
+		schedule.Currency = *input.Currency
+	}
+	if input.ReceivedCurrency != nil {
+		schedule.ReceivedCurrency = *input.ReceivedCurrency
+	}
+	if input.Rule != nil {
+		schedule.Rule = *input.Rule
+	}
+	if input.Count != nil {
+		schedule.Count = *input.Count
+	}
+	return schedule, sC.scheduleService.Create(schedule)
+}
+
+// UpdateModel update an existed models.ScheduledTransaction using the models.ScheduleService
+func (sC *Schedule) UpdateModel(id uint, patch SchedulePatch) (*models.ScheduledTransaction, error) {
+	schedule, err := sC.scheduleService.ReadByID(id)
+	if err != nil {
+		return nil, models.ErrNotFound
+	}
+
+	if patch.Value != nil {
+		schedule.Value = *patch.Value
+	}
+	if patch.Note != nil {
+		schedule.Note = *patch.Note
+	}
+	if patch.Sender != nil {
+		schedule.Sender = *patch.Sender
+	}
+	if patch.Receiver != nil {
+		schedule.Receiver = *patch.Receiver
+	}
+	if patch.Currency != nil {
+		schedule.Currency = *patch.Currency
+	}
+	if patch.ReceivedCurrency != nil {
+		schedule.ReceivedCurrency = *patch.ReceivedCurrency
+	}
+	if patch.Rule != nil {
+		schedule.Rule = *patch.Rule
+	}
+	if patch.StartAt != nil {
+		schedule.StartAt = *patch.StartAt
+	}
+	if patch.EndAt != nil {
+		schedule.EndAt = patch.EndAt
+	}
+	if patch.Count != nil {
+		schedule.Count = *patch.Count
+	}
+
+	return schedule, sC.scheduleService.Update(schedule)
+}
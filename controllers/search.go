@@ -0,0 +1,19 @@
+package controllers
+
+import "transaction_project/models"
+
+type Search struct {
+	searchService *models.SearchService
+}
+
+// NewSearchController create a new Search controller using the provided SearchService
+func NewSearchController(searchService *models.SearchService) *Search {
+	return &Search{
+		searchService: searchService,
+	}
+}
+
+// Search find the transactions and users matching the words of the query, best matches first
+func (sC *Search) Search(query string, options models.SearchOptions) ([]models.SearchHit, error) {
+	return sC.searchService.Search(query, options)
+}
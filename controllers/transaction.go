@@ -6,6 +6,50 @@ type Transaction struct {
 	transService *models.TransactionService
 }
 
+// TransactionInput hold the fields needed to create a new models.Transaction
+// Note is optional and is left empty when nil
+// Currency and ReceivedCurrency default to the currency of the sender and receiver accounts when nil
+type TransactionInput struct {
+	Value            float64
+	Note             *string
+	Sender           string
+	Receiver         string
+	Currency         *string
+	ReceivedCurrency *string
+}
+
+// UpdateTransactionInput hold the fields of a models.Transaction that can be updated
+// Every field is optional, a nil field keeps the value already stored in the database
+// Version is the version the caller expects to update, the update fails with a models.ConflictError if
+// the stored transaction has another version
+type UpdateTransactionInput struct {
+	Value            *float64
+	Note             *string
+	Sender           *string
+	Receiver         *string
+	Currency         *string
+	ReceivedCurrency *string
+	Version          *uint
+}
+
+// SplitInput hold the fields needed to create a new models.SplitTransaction
+// Currency defaults to the currency of the sender account when nil, Note is given to every leg that has none
+type SplitInput struct {
+	Sender   string
+	Total    float64
+	Currency *string
+	Note     *string
+	Legs     []SplitLegInput
+}
+
+// SplitLegInput hold the share of a receiver in a split transaction, either Amount or Percentage must be set
+type SplitLegInput struct {
+	Receiver   string
+	Amount     *float64
+	Percentage *float64
+	Note       *string
+}
+
 // NewTransactionController create a new Transaction controller using the provided TransactionService
 func NewTransactionController(transService *models.TransactionService) *Transaction {
 	return &Transaction{
@@ -13,59 +57,106 @@ func NewTransactionController(transService *models.TransactionService) *Transact
 	}
 }
 
+// WithActor return a copy of the controller whose changes are recorded in the audit log under the provided actor
+func (tC *Transaction) WithActor(actor string) *Transaction {
+	return &Transaction{
+		transService: tC.transService.WithActor(actor),
+	}
+}
+
 // NewModel create a new models.Transaction and then add it to the database using the models.TransactionService
-// Argument has type interface{} if it is not required
-func (tC *Transaction) NewModel(value float64, note interface{}, sender, receiver string) (*models.Transaction, error) {
+// When it meets an approval policy it is not created yet, its Approval is set to the approval it awaits
+// Its Risk is set to its risk assessment, it is not created either when it is held for review
+func (tC *Transaction) NewModel(input TransactionInput) (*models.Transaction, error) {
 	var newNote string
-	if note != nil {
-		newNote = note.(string)
+	if input.Note != nil {
+		newNote = *input.Note
 	}
 
 	newTransaction := &models.Transaction{
-		Value:    value,
+		Value:    input.Value,
 		Note:     newNote,
-		Sender:   sender,
-		Receiver: receiver,
+		Sender:   input.Sender,
+		Receiver: input.Receiver,
+	}
+	if input.Currency != nil {
+		newTransaction.Currency = *input.Currency
 	}
-	return newTransaction, tC.transService.Create(newTransaction)
+	if input.ReceivedCurrency != nil {
+		newTransaction.ReceivedCurrency = *input.ReceivedCurrency
+	}
+	approval, err := tC.transService.Submit(newTransaction)
+	newTransaction.Approval = approval
+	return newTransaction, err
 }
 
 // UpdateModel update an existed models.Transaction using the models.TransactionService
-// Argument has type interface{} if it is not required
-func (tC *Transaction) UpdateModel(id uint, value, note, sender, receiver interface{}) (*models.Transaction, error) {
+func (tC *Transaction) UpdateModel(id uint, input UpdateTransactionInput) (*models.Transaction, error) {
 	// Get the exist model
 	transaction, err := tC.transService.ReadByID(id)
 	if err != nil {
 		return nil, models.ErrNotFound
 	}
 
+	// Expect the version the caller read, if any
+	if input.Version != nil {
+		transaction.Version = *input.Version
+	}
+
 	// Update exist model value
-	updateValue := transaction.Value
-	if value != nil {
-		updateValue = value.(float64)
+	if input.Value != nil {
+		transaction.Value = *input.Value
 	}
-	transaction.Value = updateValue
 
 	// Update exist model note
-	updateNote := transaction.Note
-	if note != nil {
-		updateNote = note.(string)
+	if input.Note != nil {
+		transaction.Note = *input.Note
 	}
-	transaction.Note = updateNote
 
 	// Update exist model sender
-	updateSender := transaction.Sender
-	if sender != nil {
-		updateSender = sender.(string)
+	if input.Sender != nil {
+		transaction.Sender = *input.Sender
 	}
-	transaction.Sender = updateSender
 
 	// Update exist model receiver
-	updateReceiver := transaction.Receiver
-	if receiver != nil {
-		updateReceiver = receiver.(string)
+	if input.Receiver != nil {
+		transaction.Receiver = *input.Receiver
+	}
+
+	// Update exist model currencies, the value is converted again when they change
+	if input.Currency != nil {
+		transaction.Currency = *input.Currency
+	}
+	if input.ReceivedCurrency != nil {
+		transaction.ReceivedCurrency = *input.ReceivedCurrency
 	}
-	transaction.Receiver = updateReceiver
 
 	return transaction, tC.transService.Update(transaction)
 }
+
+// NewSplit create a new models.SplitTransaction and the transaction of each of its legs using the
+// models.TransactionService
+func (tC *Transaction) NewSplit(input SplitInput) (*models.SplitTransaction, error) {
+	split := &models.SplitTransaction{
+		Sender: input.Sender,
+		Total:  input.Total,
+	}
+	if input.Currency != nil {
+		split.Currency = *input.Currency
+	}
+	if input.Note != nil {
+		split.Note = *input.Note
+	}
+	legs := make([]models.SplitLeg, len(input.Legs))
+	for i, leg := range input.Legs {
+		legs[i] = models.SplitLeg{
+			Receiver:   leg.Receiver,
+			Amount:     leg.Amount,
+			Percentage: leg.Percentage,
+		}
+		if leg.Note != nil {
+			legs[i].Note = *leg.Note
+		}
+	}
+	return split, tC.transService.CreateSplit(split, legs)
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/handler"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxUploadSize bound the size of a multipart GraphQL request, files included
+const maxUploadSize = 64 << 20
+
+// maxUploadMemory is the part of an upload kept in memory, the rest is written to temporary files
+const maxUploadMemory = 8 << 20
+
+// GraphQL Scalar for a file sent with the GraphQL multipart request specification, the resolver
+// receives a *multipart.FileHeader. It can only be used as a variable.
+var uploadScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Upload",
+	Description: "File sent as a part of a multipart request, see https://github.com/jaydenseric/graphql-multipart-request-spec",
+	Serialize: func(value interface{}) interface{} {
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		if file, isOK := value.(*multipart.FileHeader); isOK {
+			return file
+		}
+		return nil
+	},
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		return nil
+	},
+})
+
+// graphQLHandler serve GraphQL requests, multipart requests carrying files are handled here and every
+// other request is passed to the handler of graphql-go
+type graphQLHandler struct {
+	schema  graphql.Schema
+	handler *handler.Handler
+}
+
+// multipartOperation is the "operations" part of a multipart request
+type multipartOperation struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+func (h *graphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Method != http.MethodPost || mediaType != "multipart/form-data" {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		http.Error(w, "invalid multipart request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	operation, err := parseMultipartOperation(r.MultipartForm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result := graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  operation.Query,
+		VariableValues: operation.Variables,
+		OperationName:  operation.OperationName,
+		Context:        r.Context(),
+	})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	_ = encoder.Encode(result)
+}
+
+// parseMultipartOperation decode the operation of a multipart request and put every file at the
+// paths the "map" part gives for it, like {"0": ["variables.File"]}
+func parseMultipartOperation(form *multipart.Form) (*multipartOperation, error) {
+	if len(form.Value["operations"]) != 1 || len(form.Value["map"]) != 1 {
+		return nil, errors.New("GraphQL: a multipart request needs one operations and one map part")
+	}
+	var operation multipartOperation
+	if err := json.Unmarshal([]byte(form.Value["operations"][0]), &operation); err != nil {
+		return nil, fmt.Errorf("GraphQL: invalid operations, batched operations are not supported: %v", err)
+	}
+	if operation.Variables == nil {
+		operation.Variables = make(map[string]interface{})
+	}
+	var fileMap map[string][]string
+	if err := json.Unmarshal([]byte(form.Value["map"][0]), &fileMap); err != nil {
+		return nil, fmt.Errorf("GraphQL: invalid map: %v", err)
+	}
+
+	for key, paths := range fileMap {
+		files := form.File[key]
+		if len(files) != 1 {
+			return nil, fmt.Errorf("GraphQL: missing file %q", key)
+		}
+		for _, path := range paths {
+			if err := setVariable(operation.Variables, path, files[0]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &operation, nil
+}
+
+// setVariable replace the value at a path like variables.Files.0 with the file
+func setVariable(variables map[string]interface{}, path string, file *multipart.FileHeader) error {
+	parts := strings.Split(path, ".")
+	if len(parts) < 2 || parts[0] != "variables" {
+		return fmt.Errorf("GraphQL: invalid file path %q", path)
+	}
+	var parent interface{} = variables
+	for i, part := range parts[1:] {
+		isLast := i == len(parts)-2
+		switch container := parent.(type) {
+		case map[string]interface{}:
+			if isLast {
+				container[part] = file
+				return nil
+			}
+			parent = container[part]
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(container) {
+				return fmt.Errorf("GraphQL: invalid file path %q", path)
+			}
+			if isLast {
+				container[index] = file
+				return nil
+			}
+			parent = container[index]
+		default:
+			return fmt.Errorf("GraphQL: invalid file path %q", path)
+		}
+	}
+	return nil
+}
@@ -6,81 +6,108 @@ type User struct {
 	userService *models.UserService
 }
 
-func (uC *User) NewModel(email, password, last string, middle, first, phone interface{}) (*models.User, error) {
-	var newMiddle string
-	if middle != nil {
-		newMiddle = middle.(string)
-	}
+// UserInput hold the fields needed to create a new models.User
+// Middle, First and Phone are optional and are left empty when nil
+// Currency is the currency of the account of the user, models.DefaultCurrency when nil
+type UserInput struct {
+	Email    string
+	Password string
+	Last     string
+	Middle   *string
+	First    *string
+	Phone    *string
+	Currency *string
+}
 
-	var newFirst string
-	if first != nil {
-		newFirst = first.(string)
-	}
+// UserPatch hold the fields of a models.User that can be updated
+// Every field is optional, a nil field keeps the value already stored in the database
+// Version is the version the caller expects to update, the update fails with a models.ConflictError if
+// the stored user has another version
+type UserPatch struct {
+	Email    *string
+	Password *string
+	Last     *string
+	Middle   *string
+	First    *string
+	Phone    *string
+	Currency *string
+	Version  *uint
+}
 
-	var newPhone string
-	if phone != nil {
-		newPhone = phone.(string)
+// WithActor return a copy of the controller whose changes are recorded in the audit log under the provided actor
+func (uC *User) WithActor(actor string) *User {
+	return &User{
+		userService: uC.userService.WithActor(actor),
 	}
+}
 
+func (uC *User) NewModel(input UserInput) (*models.User, error) {
 	newUser := &models.User{
-		Email:    email,
-		Password: password,
-		Last:     last,
-		Middle:   newMiddle,
-		First:    newFirst,
-		Phone:    newPhone,
+		Email:    input.Email,
+		Password: input.Password,
+		Last:     input.Last,
+	}
+	if input.Middle != nil {
+		newUser.Middle = *input.Middle
+	}
+	if input.First != nil {
+		newUser.First = *input.First
+	}
+	if input.Phone != nil {
+		newUser.Phone = *input.Phone
+	}
+	if input.Currency != nil {
+		newUser.Currency = *input.Currency
 	}
 	return newUser, uC.userService.Create(newUser)
 }
 
-func (uC *User) UpdateModel(id uint, email, password, last, middle, first, phone interface{}) (*models.User, error) {
+func (uC *User) UpdateModel(id uint, patch UserPatch) (*models.User, error) {
 	// Get the exist model
 	user, err := uC.userService.ReadByID(id)
 	if err != nil {
 		return nil, models.ErrNotFound
 	}
 
+	// Expect the version the caller read, if any
+	if patch.Version != nil {
+		user.Version = *patch.Version
+	}
+
 	// Update exist model email
-	updateEmail := user.Email
-	if email != nil {
-		updateEmail = email.(string)
+	if patch.Email != nil {
+		user.Email = *patch.Email
 	}
-	user.Email = updateEmail
 
 	// Update exist model password
-	updatePassword := user.Password
-	if password != nil {
-		updatePassword = password.(string)
+	if patch.Password != nil {
+		user.Password = *patch.Password
 	}
-	user.Password = updatePassword
 
 	// Update exist model last
-	updateLast := user.Last
-	if last != nil {
-		updateLast = last.(string)
+	if patch.Last != nil {
+		user.Last = *patch.Last
 	}
-	user.Last = updateLast
 
 	// Update exist model middle
-	updateMiddle := user.Middle
-	if middle != nil {
-		updateMiddle = middle.(string)
+	if patch.Middle != nil {
+		user.Middle = *patch.Middle
 	}
-	user.Middle = updateMiddle
 
 	// Update exist model first
-	updateFirst := user.First
-	if first != nil {
-		updateFirst = first.(string)
+	if patch.First != nil {
+		user.First = *patch.First
 	}
-	user.First = updateFirst
 
 	// Update exist model phone
-	updatePhone := user.Phone
-	if phone != nil {
-		updatePhone = phone.(string)
+	if patch.Phone != nil {
+		user.Phone = *patch.Phone
+	}
+
+	// Update exist model currency
+	if patch.Currency != nil {
+		user.Currency = *patch.Currency
 	}
-	user.Phone = updatePhone
 
 	return user, uC.userService.Update(user)
 }
@@ -0,0 +1,596 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"transaction_project/imports"
+	"transaction_project/models"
+	"transaction_project/views"
+)
+
+// Cookies of the web interface
+const (
+	sessionCookie   = "session"
+	loginCSRFCookie = "login_csrf"
+)
+
+const (
+	// webPageSize is the number of transactions listed on a page
+	webPageSize = 50
+
+	// maxFormSize is the largest form accepted, uploaded files included
+	maxFormSize = 32 << 20
+)
+
+// webFlashes are the messages shown after a form redirects, keyed by the flash parameter of the query string
+var webFlashes = map[string]string{
+	"user-created": "User created.",
+	"user-saved":   "User saved.",
+	"logged-out":   "You are logged out.",
+}
+
+type Web struct {
+	transController  *Transaction
+	userController   *User
+	importController *Import
+	auditController  *Audit
+	sessionService   *models.SessionService
+}
+
+// NewWebController create a new Web controller serving the web interface with the provided controllers,
+// users log in with a session of the SessionService
+func NewWebController(transController *Transaction, userController *User, importController *Import,
+	auditController *Audit, sessionService *models.SessionService) *Web {
+	return &Web{
+		transController:  transController,
+		userController:   userController,
+		importController: importController,
+		auditController:  auditController,
+		sessionService:   sessionService,
+	}
+}
+
+// webHandler is a page of the web interface that needs a logged in user, session is the session of the user
+type webHandler func(w http.ResponseWriter, r *http.Request, session *models.Session)
+
+// NewHandler create a new http.Handler serving the web interface under /ui/
+func (wC *Web) NewHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ui/login", wC.login)
+	mux.Handle("/ui/logout", wC.requireLogin(wC.logout))
+	mux.Handle("/ui/transactions", wC.requireLogin(wC.transactions))
+	mux.Handle("/ui/transactions/", wC.requireLogin(wC.transaction))
+	mux.Handle("/ui/users", wC.requireLogin(wC.users))
+	mux.Handle("/ui/users/", wC.requireLogin(wC.user))
+	mux.Handle("/ui/import", wC.requireLogin(wC.imports))
+	mux.HandleFunc("/ui/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ui/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, "/ui/transactions", http.StatusSeeOther)
+	})
+	return securityHeaders(mux)
+}
+
+// securityHeaders is a middleware that forbid framing, sniffing and caching of the pages and restrict
+// what they can load and where their forms can post
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy",
+			"default-src 'self'; style-src 'self' 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "same-origin")
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireLogin is a middleware that identify the user from the session cookie and redirect to the login
+// page when there is none. Every request but GET and HEAD must send the CSRF token of the session in its
+// csrf_token form field.
+func (wC *Web) requireLogin(next webHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, user, err := wC.readSession(r)
+		if err != nil {
+			if !errors.Is(err, models.ErrSessionExpired) {
+				log.Printf("web session: %v", err)
+			}
+			if r.Method == http.MethodGet {
+				http.Redirect(w, r, "/ui/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+				return
+			}
+			http.Redirect(w, r, "/ui/login", http.StatusSeeOther)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), currentUserKey, user))
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+			if !equalTokens(session.CSRFToken, r.FormValue("csrf_token")) {
+				wC.fail(w, r, session, http.StatusForbidden, "The form expired, reload the page and try again.")
+				return
+			}
+		}
+		next(w, r, session)
+	})
+}
+
+// readSession return the session of the session cookie of the request and its user
+func (wC *Web) readSession(r *http.Request) (*models.Session, *models.User, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || cookie.Value == "" {
+		return nil, nil, models.ErrSessionExpired
+	}
+	return wC.sessionService.Authenticate(cookie.Value)
+}
+
+// render write the page of the web interface with the provided name and status, the logged in user and
+// the CSRF token of its session are added to the page
+func (wC *Web) render(w http.ResponseWriter, r *http.Request, session *models.Session, status int, name string, page views.Page) {
+	page.User = currentUser(r.Context())
+	if session != nil {
+		page.CSRFToken = session.CSRFToken
+	}
+	if page.Flash == "" {
+		page.Flash = webFlashes[r.URL.Query().Get("flash")]
+	}
+	var body bytes.Buffer
+	if err := views.RenderPage(&body, name, page); err != nil {
+		log.Printf("web page %s: %v", name, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = body.WriteTo(w)
+}
+
+// fail render the error page with the provided status and message
+func (wC *Web) fail(w http.ResponseWriter, r *http.Request, session *models.Session, status int, message string) {
+	wC.render(w, r, session, status, "error", views.Page{Title: http.StatusText(status), Error: message})
+}
+
+// failWith render the error page for an error returned by a controller, errors the user can not fix are
+// logged and hidden
+func (wC *Web) failWith(w http.ResponseWriter, r *http.Request, session *models.Session, err error) {
+	if errors.Is(err, models.ErrNotFound) {
+		wC.fail(w, r, session, http.StatusNotFound, "Not found.")
+		return
+	}
+	log.Printf("web %s: %v", r.URL.Path, err)
+	wC.fail(w, r, session, http.StatusInternalServerError, "Something went wrong, try again later.")
+}
+
+// allowed check that the logged in user has one of the roles and render the error page if not
+func (wC *Web) allowed(w http.ResponseWriter, r *http.Request, session *models.Session, roles ...string) bool {
+	if err := requireRole(r.Context(), roles...); err != nil {
+		wC.fail(w, r, session, http.StatusForbidden, "You are not allowed to see this page.")
+		return false
+	}
+	return true
+}
+
+// allowMethods check the method of the request and reply 405 if it is not one of the methods
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+// equalTokens compare a secret token with the one a request sent in constant time
+func equalTokens(expected, sent string) bool {
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(sent)) == 1
+}
+
+// setCookie set a cookie of the web interface, marked secure when the request came over TLS
+func setCookie(w http.ResponseWriter, r *http.Request, name, value, path string, maxAge time.Duration, sameSite http.SameSite) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: sameSite,
+		MaxAge:   int(maxAge / time.Second),
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// loginData is the data of the login page
+type loginData struct {
+	Email string
+	Next  string
+}
+
+// login show the login form and open a session when it is submitted. The form is protected from CSRF by
+// a token sent both in a cookie and in the form, there is no session yet to hold it.
+func (wC *Web) login(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		if _, _, err := wC.readSession(r); err == nil {
+			http.Redirect(w, r, "/ui/transactions", http.StatusSeeOther)
+			return
+		}
+		wC.loginForm(w, r, http.StatusOK, loginData{Next: r.URL.Query().Get("next")}, "")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+	data := loginData{Email: r.PostFormValue("email"), Next: r.PostFormValue("next")}
+	cookie, err := r.Cookie(loginCSRFCookie)
+	if err != nil || !equalTokens(cookie.Value, r.PostFormValue("csrf_token")) {
+		wC.loginForm(w, r, http.StatusForbidden, data, "The form expired, try again.")
+		return
+	}
+	user, err := wC.userController.userService.Authenticate(data.Email, r.PostFormValue("password"))
+	if err == models.ErrInvalidCredentials || err == models.ErrNotFound {
+		wC.loginForm(w, r, http.StatusUnauthorized, data, "Invalid email or password.")
+		return
+	}
+	if err != nil {
+		log.Printf("web login: %v", err)
+		wC.loginForm(w, r, http.StatusInternalServerError, data, "Something went wrong, try again later.")
+		return
+	}
+
+	if _, err := wC.sessionService.DeleteExpired(time.Now()); err != nil {
+		log.Printf("web login: %v", err)
+	}
+	token, session, err := wC.sessionService.Create(user.ID, models.DefaultSessionTTL)
+	if err != nil {
+		log.Printf("web login: %v", err)
+		wC.loginForm(w, r, http.StatusInternalServerError, data, "Something went wrong, try again later.")
+		return
+	}
+	setCookie(w, r, sessionCookie, token, "/ui", time.Until(session.ExpiresAt), http.SameSiteLaxMode)
+	setCookie(w, r, loginCSRFCookie, "", "/ui/login", -1, http.SameSiteStrictMode)
+	next := data.Next
+	if !strings.HasPrefix(next, "/ui/") || strings.HasPrefix(next, "//") || strings.Contains(next, "\\") {
+		next = "/ui/transactions"
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// loginForm render the login form with a new CSRF token
+func (wC *Web) loginForm(w http.ResponseWriter, r *http.Request, status int, data loginData, message string) {
+	token, err := models.RandomToken()
+	if err != nil {
+		log.Printf("web login: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	setCookie(w, r, loginCSRFCookie, token, "/ui/login", time.Hour, http.SameSiteStrictMode)
+	page := views.Page{Title: "Log in", CSRFToken: token, Error: message, Data: data}
+	wC.render(w, r, nil, status, "login", page)
+}
+
+// logout close the session
+func (wC *Web) logout(w http.ResponseWriter, r *http.Request, session *models.Session) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err := wC.sessionService.Delete(cookie.Value); err != nil {
+			wC.failWith(w, r, session, err)
+			return
+		}
+	}
+	setCookie(w, r, sessionCookie, "", "/ui", -1, http.SameSiteLaxMode)
+	http.Redirect(w, r, "/ui/login?flash=logged-out", http.StatusSeeOther)
+}
+
+// transactionListData is the data of the transaction list
+type transactionListData struct {
+	Query        url.Values
+	AllAccounts  bool
+	Transactions []models.Transaction
+	Total        int
+	First        int
+	Last         int
+	PrevURL      string
+	NextURL      string
+}
+
+// transactions list the transactions matching the filters of the query string, newest first. Users who
+// are neither auditors nor admins only see their own transactions.
+func (wC *Web) transactions(w http.ResponseWriter, r *http.Request, session *models.Session) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	query := r.URL.Query()
+	data := transactionListData{
+		Query:       query,
+		AllAccounts: requireRole(r.Context(), models.RoleAuditor, models.RoleAdmin) == nil,
+	}
+	filter, err := ParseTransactionFilter(query)
+	if err != nil {
+		wC.render(w, r, session, http.StatusBadRequest, "transactions", views.Page{Title: "Transactions", Error: err.Error(), Data: data})
+		return
+	}
+	if !data.AllAccounts {
+		filter.Sender, filter.Receiver = "", ""
+		filter.Account = currentUser(r.Context()).Email
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	offset := (page - 1) * webPageSize
+	data.Transactions, data.Total, err = wC.transController.transService.ReadPage(filter, offset, webPageSize)
+	if err != nil {
+		wC.failWith(w, r, session, err)
+		return
+	}
+	if len(data.Transactions) > 0 {
+		data.First, data.Last = offset+1, offset+len(data.Transactions)
+	}
+	pageURL := func(page int) string {
+		values := url.Values{}
+		for name, value := range query {
+			values[name] = value
+		}
+		values.Set("page", strconv.Itoa(page))
+		values.Del("flash")
+		return "/ui/transactions?" + values.Encode()
+	}
+	if page > 1 {
+		data.PrevURL = pageURL(page - 1)
+	}
+	if offset+webPageSize < data.Total {
+		data.NextURL = pageURL(page + 1)
+	}
+	wC.render(w, r, session, http.StatusOK, "transactions", views.Page{Title: "Transactions", Data: data})
+}
+
+// transactionData is the data of the page of a transaction
+type transactionData struct {
+	Transaction *models.Transaction
+	ShowHistory bool
+	History     []models.AuditEntry
+}
+
+// transaction show the transaction whose ID ends the path and, to auditors and admins, its history
+func (wC *Web) transaction(w http.ResponseWriter, r *http.Request, session *models.Session) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/ui/transactions/"), 10, 64)
+	if err != nil || id == 0 {
+		wC.fail(w, r, session, http.StatusNotFound, "Not found.")
+		return
+	}
+	transaction, err := wC.transController.transService.ReadByID(uint(id))
+	if err != nil {
+		wC.failWith(w, r, session, err)
+		return
+	}
+	data := transactionData{
+		Transaction: transaction,
+		ShowHistory: requireRole(r.Context(), models.RoleAuditor, models.RoleAdmin) == nil,
+	}
+	if !data.ShowHistory {
+		user := currentUser(r.Context())
+		if transaction.Sender != user.Email && transaction.Receiver != user.Email {
+			wC.fail(w, r, session, http.StatusNotFound, "Not found.")
+			return
+		}
+	} else if data.History, err = wC.auditController.History(models.AuditEntityTransaction, transaction.ID); err != nil {
+		wC.failWith(w, r, session, err)
+		return
+	}
+	title := fmt.Sprintf("Transaction %d", transaction.ID)
+	wC.render(w, r, session, http.StatusOK, "transaction", views.Page{Title: title, Data: data})
+}
+
+// userListData is the data of the user list
+type userListData struct {
+	Users []models.User
+}
+
+// users list every user (admin only)
+func (wC *Web) users(w http.ResponseWriter, r *http.Request, session *models.Session) {
+	if !allowMethods(w, r, http.MethodGet) || !wC.allowed(w, r, session, models.RoleAdmin) {
+		return
+	}
+	users, err := wC.userController.userService.ReadAll()
+	if err != nil {
+		wC.failWith(w, r, session, err)
+		return
+	}
+	wC.render(w, r, session, http.StatusOK, "users", views.Page{Title: "Users", Data: userListData{Users: users}})
+}
+
+// userFormData is the data of the form creating or editing a user, ID is 0 for a new user
+type userFormData struct {
+	ID       uint
+	Email    string
+	First    string
+	Middle   string
+	Last     string
+	Phone    string
+	Currency string
+	Role     string
+	Version  uint
+	Roles    []string
+}
+
+// user show and submit the form creating a user, at /ui/users/new, or editing the user whose ID ends
+// the path (admin only)
+func (wC *Web) user(w http.ResponseWriter, r *http.Request, session *models.Session) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) || !wC.allowed(w, r, session, models.RoleAdmin) {
+		return
+	}
+	data := userFormData{Roles: []string{models.RoleUser, models.RoleAuditor, models.RoleAdmin}}
+	title := "New user"
+	var user *models.User
+	if rawID := strings.TrimPrefix(r.URL.Path, "/ui/users/"); rawID != "new" {
+		id, err := strconv.ParseUint(rawID, 10, 64)
+		if err != nil || id == 0 {
+			wC.fail(w, r, session, http.StatusNotFound, "Not found.")
+			return
+		}
+		if user, err = wC.userController.userService.ReadByID(uint(id)); err != nil {
+			wC.failWith(w, r, session, err)
+			return
+		}
+		data.ID, data.Email, data.First, data.Middle, data.Last = user.ID, user.Email, user.First, user.Middle, user.Last
+		data.Phone, data.Currency, data.Role, data.Version = user.Phone, user.Currency, user.Role, user.Version
+		title = "Edit user"
+	}
+	if r.Method == http.MethodGet {
+		wC.render(w, r, session, http.StatusOK, "user_form", views.Page{Title: title, Data: data})
+		return
+	}
+
+	data.Email, data.Last = strings.TrimSpace(r.PostFormValue("email")), strings.TrimSpace(r.PostFormValue("last"))
+	data.First, data.Middle = strings.TrimSpace(r.PostFormValue("first")), strings.TrimSpace(r.PostFormValue("middle"))
+	data.Phone, data.Currency = strings.TrimSpace(r.PostFormValue("phone")), strings.TrimSpace(r.PostFormValue("currency"))
+	password := r.PostFormValue("password")
+	actor := actorFrom(r.Context())
+
+	if user == nil {
+		input := UserInput{
+			Email:    data.Email,
+			Password: password,
+			Last:     data.Last,
+			Middle:   &data.Middle,
+			First:    &data.First,
+			Phone:    &data.Phone,
+		}
+		if data.Currency != "" {
+			input.Currency = &data.Currency
+		}
+		if _, err := wC.userController.WithActor(actor).NewModel(input); err != nil {
+			wC.render(w, r, session, http.StatusUnprocessableEntity, "user_form", views.Page{Title: title, Error: userFormError(err), Data: data})
+			return
+		}
+		http.Redirect(w, r, "/ui/users?flash=user-created", http.StatusSeeOther)
+		return
+	}
+
+	patch := UserPatch{
+		Email:  &data.Email,
+		Last:   &data.Last,
+		Middle: &data.Middle,
+		First:  &data.First,
+		Phone:  &data.Phone,
+	}
+	if data.Currency != "" {
+		patch.Currency = &data.Currency
+	}
+	if password != "" {
+		patch.Password = &password
+	}
+	if version, err := strconv.ParseUint(r.PostFormValue("version"), 10, 64); err == nil {
+		expected := uint(version)
+		patch.Version = &expected
+	}
+	updated, err := wC.userController.WithActor(actor).UpdateModel(user.ID, patch)
+	if err != nil {
+		wC.render(w, r, session, http.StatusUnprocessableEntity, "user_form", views.Page{Title: title, Error: userFormError(err), Data: data})
+		return
+	}
+	if role := r.PostFormValue("role"); role != "" && role != updated.Role {
+		if _, err := wC.userController.userService.WithActor(actor).UpdateRole(updated.ID, role); err != nil {
+			wC.render(w, r, session, http.StatusUnprocessableEntity, "user_form", views.Page{Title: title, Error: userFormError(err), Data: data})
+			return
+		}
+	}
+	http.Redirect(w, r, "/ui/users?flash=user-saved", http.StatusSeeOther)
+}
+
+// userFormError return the message shown on the user form for an error of the User controller
+func userFormError(err error) string {
+	if errors.Is(err, models.ErrConflict) {
+		return "Someone else changed this user, reload the page to see their changes."
+	}
+	return strings.TrimPrefix(err.Error(), "models: ")
+}
+
+// importData is the data of the import page, the report of the import that was just run if any
+type importData struct {
+	Report          *ImportReport
+	StatementReport *StatementReport
+}
+
+// imports show the import forms and run the import submitted (admin only). kind is transactions for a
+// CSV or JSON Lines file of transactions and statement for a bank statement.
+func (wC *Web) imports(w http.ResponseWriter, r *http.Request, session *models.Session) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) || !wC.allowed(w, r, session, models.RoleAdmin) {
+		return
+	}
+	page := views.Page{Title: "Import", Data: importData{}}
+	if r.Method == http.MethodGet {
+		wC.render(w, r, session, http.StatusOK, "import", page)
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		page.Error = "Choose a file to import."
+		wC.render(w, r, session, http.StatusBadRequest, "import", page)
+		return
+	}
+	defer file.Close()
+	format := r.FormValue("format")
+	dateFormat := r.FormValue("date_format")
+	dryRun := r.FormValue("dry_run") == "true"
+	importController := wC.importController.WithActor(actorFrom(r.Context()))
+
+	var data importData
+	switch r.FormValue("kind") {
+	case "transactions":
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+		data.Report, err = importController.Import(file, ImportOptions{
+			Options:   imports.Options{Format: format, DateFormat: dateFormat},
+			DryRun:    dryRun,
+			BatchSize: defaultImportBatchSize,
+		})
+	case "statement":
+		if format == "" {
+			format = imports.StatementFormat(header.Filename)
+		}
+		data.StatementReport, err = importController.ImportStatement(file, r.FormValue("account"), StatementOptions{
+			Format:     format,
+			DateFormat: dateFormat,
+			DryRun:     dryRun,
+		})
+	default:
+		err = errors.New("unknown kind of import")
+	}
+	if err != nil {
+		page.Error = "Import failed: " + strings.TrimPrefix(err.Error(), "models: ")
+		wC.render(w, r, session, http.StatusUnprocessableEntity, "import", page)
+		return
+	}
+	page.Data = data
+	if dryRun {
+		page.Flash = "Dry run, nothing was created."
+	}
+	wC.render(w, r, session, http.StatusOK, "import", page)
+}
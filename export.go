@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"transaction_project/controllers"
+	"transaction_project/exports"
+	"transaction_project/models"
+)
+
+// exportTransactions implement the export command
+func exportTransactions(services *models.Services, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	path := flags.String("o", "", "file to write, standard output when empty")
+	format := flags.String("format", "", "csv, jsonl or xlsx, inferred from the extension of the file when empty")
+	filters := url.Values{}
+	for _, name := range []string{"sender", "receiver", "account", "currency", "from", "to", "min_value", "max_value"} {
+		name := name
+		flags.Func(name, "only export the transactions whose "+name+" matches, like the "+name+" parameter of /export", func(value string) error {
+			filters.Set(name, value)
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*path)), ".")
+		if *format == "" {
+			*format = exports.FormatCSV
+		}
+	}
+	if !exports.Supported(*format) {
+		return fmt.Errorf("%w: %q", exports.ErrUnknownFormat, *format)
+	}
+	filter, err := controllers.ParseTransactionFilter(filters)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *path != "" {
+		file, err := os.Create(*path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	written, err := controllers.NewExportController(services.Transaction).Export(w, *format, filter)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d transactions\n", written)
+	return nil
+}
@@ -0,0 +1,45 @@
+package exports
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"transaction_project/models"
+)
+
+type csvWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+// newCSVWriter write the header of a CSV file and return a Writer of its rows
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	csvW := &csvWriter{writer: csv.NewWriter(w), record: make([]string, len(Columns))}
+	if err := csvW.writer.Write(Columns); err != nil {
+		return nil, err
+	}
+	return csvW, nil
+}
+
+func (csvW *csvWriter) Write(transaction *models.Transaction) error {
+	for i, c := range row(transaction) {
+		csvW.record[i] = c.String()
+		if c.number == nil && c.date == nil {
+			csvW.record[i] = escapeFormula(csvW.record[i])
+		}
+	}
+	return csvW.writer.Write(csvW.record)
+}
+
+func (csvW *csvWriter) Close() error {
+	csvW.writer.Flush()
+	return csvW.writer.Error()
+}
+
+// escapeFormula prefix text a spreadsheet would run as a formula with a quote so it is shown as is
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
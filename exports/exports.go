@@ -0,0 +1,114 @@
+// Package exports write transactions to files that can be opened by spreadsheets and other systems,
+// one row at a time so an export never holds every transaction in memory.
+package exports
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"transaction_project/models"
+)
+
+// Formats of the files that can be exported
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatXLSX  = "xlsx"
+)
+
+// ErrUnknownFormat is returned when the format of a file is not supported.
+var ErrUnknownFormat = errors.New("exports: unknown file format")
+
+// Columns is the header of the exported files, in the order of the values of a row
+var Columns = []string{
+	"ID", "CreatedAt", "Value", "Currency", "ReceivedValue", "ReceivedCurrency", "Rate",
+	"Sender", "Receiver", "Note", "Version", "Hash",
+}
+
+// Writer write transactions to a file. Close must be called once every transaction was written to
+// complete the file, it does not close the underlying io.Writer.
+type Writer interface {
+	Write(transaction *models.Transaction) error
+	Close() error
+}
+
+// NewWriter return a Writer for the format that writes to w
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatJSONL:
+		return newJSONLWriter(w), nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// Supported report whether files can be exported in the format
+func Supported(format string) bool {
+	switch strings.ToLower(format) {
+	case FormatCSV, FormatJSONL, FormatXLSX:
+		return true
+	default:
+		return false
+	}
+}
+
+// ContentType return the MIME type of the files of the format
+func ContentType(format string) string {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// cell is a value of a row, either text or a number, dates are kept as time.Time so each format can
+// write them its own way
+type cell struct {
+	text   string
+	number *float64
+	date   *time.Time
+}
+
+// row return the cells of the transaction in the order of Columns
+func row(transaction *models.Transaction) []cell {
+	number := func(value float64) cell { return cell{number: &value} }
+	createdAt := transaction.CreatedAt.UTC()
+	return []cell{
+		number(float64(transaction.ID)),
+		{date: &createdAt},
+		number(transaction.Value),
+		{text: transaction.Currency},
+		number(transaction.ReceivedValue),
+		{text: transaction.ReceivedCurrency},
+		number(transaction.Rate),
+		{text: transaction.Sender},
+		{text: transaction.Receiver},
+		{text: transaction.Note},
+		number(float64(transaction.Version)),
+		{text: transaction.Hash},
+	}
+}
+
+// String format the cell as text, dates are RFC 3339
+func (c cell) String() string {
+	switch {
+	case c.number != nil:
+		return strconv.FormatFloat(*c.number, 'f', -1, 64)
+	case c.date != nil:
+		return c.date.Format(time.RFC3339Nano)
+	default:
+		return c.text
+	}
+}
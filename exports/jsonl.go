@@ -0,0 +1,48 @@
+package exports
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"transaction_project/models"
+)
+
+type jsonlWriter struct {
+	writer *bufio.Writer
+}
+
+// newJSONLWriter return a Writer of a JSON Lines file, one object per transaction with the keys in the
+// order of Columns
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	return &jsonlWriter{writer: bufio.NewWriter(w)}
+}
+
+func (jsonlW *jsonlWriter) Write(transaction *models.Transaction) error {
+	jsonlW.writer.WriteByte('{')
+	for i, c := range row(transaction) {
+		if i > 0 {
+			jsonlW.writer.WriteByte(',')
+		}
+		key, _ := json.Marshal(Columns[i])
+		jsonlW.writer.Write(key)
+		jsonlW.writer.WriteByte(':')
+
+		var value []byte
+		var err error
+		if c.number != nil {
+			value, err = json.Marshal(*c.number)
+		} else {
+			value, err = json.Marshal(c.String())
+		}
+		if err != nil {
+			return err
+		}
+		jsonlW.writer.Write(value)
+	}
+	_, err := jsonlW.writer.WriteString("}\n")
+	return err
+}
+
+func (jsonlW *jsonlWriter) Close() error {
+	return jsonlW.writer.Flush()
+}
@@ -0,0 +1,138 @@
+package exports
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"time"
+	"transaction_project/models"
+)
+
+// The parts of a minimal SpreadsheetML workbook with a single sheet. Dates use the built-in number
+// format 22 (m/d/yyyy h:mm) through the second cell format of the styles.
+const (
+	xlsxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`
+	xlsxRootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbook = xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Transactions" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`
+	xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="22" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+		`</styleSheet>`
+	xlsxSheetStart = xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// excelEpoch is the day 0 of the serial dates of spreadsheets, the 1900 leap year bug included
+var excelEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxWriter stream the rows of the only sheet of the workbook, the other parts are written up front
+// so nothing but the current row is kept in memory
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	rows    int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	archive := zip.NewWriter(w)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		partW, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(partW, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheetW, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	xlsxW := &xlsxWriter{archive: archive, sheet: bufio.NewWriter(sheetW)}
+	xlsxW.sheet.WriteString(xlsxSheetStart)
+	header := make([]cell, len(Columns))
+	for i, column := range Columns {
+		header[i] = cell{text: column}
+	}
+	return xlsxW, xlsxW.writeRow(header)
+}
+
+func (xlsxW *xlsxWriter) Write(transaction *models.Transaction) error {
+	return xlsxW.writeRow(row(transaction))
+}
+
+func (xlsxW *xlsxWriter) Close() error {
+	if _, err := xlsxW.sheet.WriteString(xlsxSheetEnd); err != nil {
+		return err
+	}
+	if err := xlsxW.sheet.Flush(); err != nil {
+		return err
+	}
+	return xlsxW.archive.Close()
+}
+
+// writeRow write the cells as the next row of the sheet, text is written inline so the workbook needs
+// no shared strings table
+func (xlsxW *xlsxWriter) writeRow(cells []cell) error {
+	xlsxW.rows++
+	rowNumber := strconv.Itoa(xlsxW.rows)
+	xlsxW.sheet.WriteString(`<row r="` + rowNumber + `">`)
+	for i, c := range cells {
+		reference := columnName(i) + rowNumber
+		switch {
+		case c.number != nil:
+			xlsxW.sheet.WriteString(`<c r="` + reference + `"><v>` + c.String() + `</v></c>`)
+		case c.date != nil:
+			serial := c.date.Sub(excelEpoch).Hours() / 24
+			xlsxW.sheet.WriteString(`<c r="` + reference + `" s="1"><v>` + strconv.FormatFloat(serial, 'f', -1, 64) + `</v></c>`)
+		case c.text != "":
+			xlsxW.sheet.WriteString(`<c r="` + reference + `" t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(xlsxW.sheet, []byte(c.text)); err != nil {
+				return err
+			}
+			xlsxW.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := xlsxW.sheet.WriteString(`</row>`)
+	return err
+}
+
+// columnName return the letters of the column at the zero based index, A to Z then AA and so on
+func columnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
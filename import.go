@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"transaction_project/controllers"
+	"transaction_project/imports"
+	"transaction_project/models"
+)
+
+// importTransactions implement the import command
+func importTransactions(services *models.Services, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	path := flags.String("file", "", "CSV or JSON Lines file of transactions")
+	format := flags.String("format", "", "csv or jsonl, inferred from the extension of the file when empty")
+	mapping := flags.String("map", "", "columns of the fields, like value=Amount,sender=From")
+	dateFormat := flags.String("date-format", "", "Go layout of the date column, RFC 3339 or YYYY-MM-DD when empty")
+	dryRun := flags.Bool("dry-run", false, "validate every row without creating anything")
+	batchSize := flags.Int("batch-size", 500, "number of rows committed in each database transaction")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	options := controllers.ImportOptions{
+		Options: imports.Options{
+			Format:     *format,
+			DateFormat: *dateFormat,
+		},
+		DryRun:    *dryRun,
+		BatchSize: *batchSize,
+	}
+	if options.Format == "" {
+		options.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*path)), ".")
+	}
+	var err error
+	if options.Mapping, err = imports.ParseMapping(*mapping); err != nil {
+		return err
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	importController := controllers.NewImportController(services.Transaction, services.Statement).WithActor("import (" + filepath.Base(*path) + ")")
+	report, err := importController.Import(file, options)
+	if err != nil {
+		return err
+	}
+	for _, rowError := range report.Errors {
+		fmt.Printf("line %d: %s\n", rowError.Line, rowError.Message)
+	}
+	if report.DryRun {
+		fmt.Printf("Dry run: %d rows, %d valid, %d invalid\n", report.Total, report.Valid, report.Invalid)
+		return nil
+	}
+	fmt.Printf("Imported %d of %d rows, %d invalid\n", report.Created, report.Total, report.Invalid)
+	return nil
+}
+
+// importStatement implement the import-statement command
+func importStatement(services *models.Services, args []string) error {
+	flags := flag.NewFlagSet("import-statement", flag.ExitOnError)
+	path := flags.String("file", "", "OFX, QFX, QIF, camt.053 or MT940 bank statement")
+	account := flags.String("account", "", "email of the user the statement belongs to")
+	format := flags.String("format", "", "ofx, qfx, qif, camt053 or mt940, inferred from the extension of the file when empty")
+	dateFormat := flags.String("date-format", "", "Go layout of the dates of a QIF file, US dates when empty")
+	dryRun := flags.Bool("dry-run", false, "report what would be imported without creating anything")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	options := controllers.StatementOptions{
+		Format:     *format,
+		DateFormat: *dateFormat,
+		DryRun:     *dryRun,
+	}
+	if options.Format == "" {
+		options.Format = imports.StatementFormat(*path)
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	importController := controllers.NewImportController(services.Transaction, services.Statement).WithActor("import (" + filepath.Base(*path) + ")")
+	report, err := importController.ImportStatement(file, *account, options)
+	if err != nil {
+		return err
+	}
+	for _, entry := range report.Entries {
+		if entry.Status == models.StatementCreated {
+			continue
+		}
+		fmt.Printf("line %d: %s", entry.Line, entry.Status)
+		if entry.DuplicateOfID != 0 {
+			fmt.Printf(", matches transaction %d", entry.DuplicateOfID)
+		}
+		if entry.Message != "" {
+			fmt.Printf(": %s", entry.Message)
+		}
+		fmt.Println()
+	}
+	if report.DryRun {
+		fmt.Print("Dry run: ")
+	}
+	fmt.Printf("%d entries, %d created, %d skipped, %d conflicted, %d failed\n",
+		report.Total, report.Created, report.Skipped, report.Conflicted, report.Failed)
+	return nil
+}
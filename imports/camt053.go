@@ -0,0 +1,223 @@
+package imports
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// camtDate is a date of camt.053, either a date or a date and time
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+// camtParty is a debtor or a creditor, the name is in Pty from camt.053.001.08 onward
+type camtParty struct {
+	Name      string `xml:"Nm"`
+	PartyName string `xml:"Pty>Nm"`
+}
+
+// camtStatus is the status of an entry, a code from camt.053.001.08 onward and text before
+type camtStatus struct {
+	Value string `xml:",chardata"`
+	Code  string `xml:"Cd"`
+}
+
+// camtAmount is an amount and its currency
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// camtTransaction is a TxDtls of an entry, a batch booked as a single entry has one per transfer
+type camtTransaction struct {
+	Amount         *camtAmount `xml:"Amt"`
+	CreditDebit    string      `xml:"CdtDbtInd"`
+	EndToEndID     string      `xml:"Refs>EndToEndId"`
+	ServicerRef    string      `xml:"Refs>AcctSvcrRef"`
+	TransactionID  string      `xml:"Refs>TxId"`
+	Debtor         camtParty   `xml:"RltdPties>Dbtr"`
+	DebtorIBAN     string      `xml:"RltdPties>DbtrAcct>Id>IBAN"`
+	Creditor       camtParty   `xml:"RltdPties>Cdtr"`
+	CreditorIBAN   string      `xml:"RltdPties>CdtrAcct>Id>IBAN"`
+	Unstructured   []string    `xml:"RmtInf>Ustrd"`
+	CreditorRef    string      `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	AdditionalInfo string      `xml:"AddtlTxInf"`
+}
+
+// camtEntry is an Ntry of a camt.053 statement
+type camtEntry struct {
+	Amount         camtAmount        `xml:"Amt"`
+	CreditDebit    string            `xml:"CdtDbtInd"`
+	Status         camtStatus        `xml:"Sts"`
+	BookingDate    camtDate          `xml:"BookgDt"`
+	ValueDate      camtDate          `xml:"ValDt"`
+	EntryRef       string            `xml:"NtryRef"`
+	ServicerRef    string            `xml:"AcctSvcrRef"`
+	Transactions   []camtTransaction `xml:"NtryDtls>TxDtls"`
+	AdditionalInfo string            `xml:"AddtlNtryInf"`
+}
+
+// parseCAMT053 read the booked entries of the statements of a camt.053 BankToCustomerStatement.
+// Pending and informational entries are left out. An entry holding several transactions, each with
+// its own amount, is read as one entry per transaction.
+func parseCAMT053(r io.Reader) ([]StatementRow, error) {
+	decoder := xml.NewDecoder(r)
+	var rows []StatementRow
+	hasStatement := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("imports: invalid camt.053 file: %w", err)
+		}
+		start, isStart := token.(xml.StartElement)
+		if !isStart {
+			continue
+		}
+		switch start.Name.Local {
+		case "BkToCstmrStmt":
+			hasStatement = true
+		case "Ntry":
+			line, _ := decoder.InputPos()
+			var entry camtEntry
+			if err := decoder.DecodeElement(&entry, &start); err != nil {
+				return nil, fmt.Errorf("imports: invalid camt.053 entry at line %d: %w", line, err)
+			}
+			rows = append(rows, entry.rows(line)...)
+		}
+	}
+	if !hasStatement {
+		return nil, errors.New("imports: not a camt.053 file, it has no BkToCstmrStmt")
+	}
+	return rows, nil
+}
+
+// rows return the statement rows of the entry, none if it is not booked
+func (entry camtEntry) rows(line int) []StatementRow {
+	status := firstNonEmpty(entry.Status.Code, entry.Status.Value)
+	if status != "" && status != "BOOK" {
+		return nil
+	}
+
+	bookedAt, err := entry.BookingDate.parse()
+	if err != nil {
+		return []StatementRow{{Line: line, Err: fmt.Errorf("invalid booking date: %w", err)}}
+	}
+	var valueAt *time.Time
+	if date, err := entry.ValueDate.parse(); err == nil && !date.IsZero() {
+		valueAt = &date
+	}
+
+	transactions := entry.Transactions
+	if len(transactions) == 0 {
+		transactions = []camtTransaction{{}}
+	}
+	isSplit := len(transactions) > 1
+	for _, transaction := range transactions {
+		isSplit = isSplit && transaction.Amount != nil
+	}
+	if !isSplit {
+		// The details describe the entry as a whole
+		transactions = transactions[:1]
+		transactions[0].Amount = &entry.Amount
+		transactions[0].CreditDebit = entry.CreditDebit
+	}
+
+	rows := make([]StatementRow, 0, len(transactions))
+	for _, transaction := range transactions {
+		row := StatementRow{Line: line}
+		amount, err := parseAmount(transaction.Amount.Value)
+		if err != nil {
+			row.Err = err
+			rows = append(rows, row)
+			continue
+		}
+		creditDebit := transaction.CreditDebit
+		if creditDebit == "" {
+			creditDebit = entry.CreditDebit
+		}
+		isCredit := creditDebit == "CRDT"
+		if creditDebit != "CRDT" && creditDebit != "DBIT" {
+			row.Err = fmt.Errorf("invalid credit debit indicator %q", creditDebit)
+			rows = append(rows, row)
+			continue
+		}
+		if !isCredit {
+			amount = -amount
+		}
+
+		// The counterparty is the debtor of a credit and the creditor of a debit
+		counterparty, iban := transaction.Creditor, transaction.CreditorIBAN
+		if isCredit {
+			counterparty, iban = transaction.Debtor, transaction.DebtorIBAN
+		}
+		payee := firstNonEmpty(counterparty.Name, counterparty.PartyName, iban)
+
+		memo := strings.Join(transaction.Unstructured, " ")
+		memo = firstNonEmpty(memo, transaction.AdditionalInfo, entry.AdditionalInfo)
+
+		fitid := firstNonEmpty(transaction.ServicerRef, transaction.TransactionID)
+		if !isSplit {
+			fitid = firstNonEmpty(entry.ServicerRef, fitid, entry.EntryRef)
+		}
+		reference := transaction.EndToEndID
+		if reference == "NOTPROVIDED" {
+			reference = ""
+		}
+		reference = firstNonEmpty(reference, transaction.CreditorRef, entry.EntryRef)
+
+		row.Entry.FITID = fitid
+		row.Entry.Reference = reference
+		row.Entry.PostedAt = bookedAt
+		row.Entry.ValueAt = valueAt
+		row.Entry.Amount = amount
+		row.Entry.Payee = payee
+		row.Entry.Memo = memo
+		row.Entry.Currency = firstNonEmpty(transaction.Amount.Currency, entry.Amount.Currency)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// parse return the date, or the date and time, zero when both are missing
+func (date camtDate) parse() (time.Time, error) {
+	if value := strings.TrimSpace(date.DateTime); value != "" {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+			if parsed, err := time.Parse(layout, value); err == nil {
+				return parsed, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	if value := strings.TrimSpace(date.Date); value != "" {
+		// A date may carry a time zone offset like 2024-01-31+01:00
+		if len(value) > len("2006-01-02") {
+			if parsed, err := time.Parse("2006-01-02Z07:00", value); err == nil {
+				return parsed, nil
+			}
+		}
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", value)
+		}
+		return parsed, nil
+	}
+	return time.Time{}, errors.New("missing date")
+}
+
+// firstNonEmpty return the first of the values that is not blank, trimmed
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
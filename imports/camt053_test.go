@@ -0,0 +1,50 @@
+package imports
+
+import "testing"
+
+func TestParseCAMT053(t *testing.T) {
+	checkStatement(t, "testdata/camt053.xml", FormatCAMT053, []expectedEntry{
+		{
+			FITID:     "2024013100012345",
+			Reference: "INV-2024-0117",
+			PostedAt:  "2024-01-31",
+			ValueAt:   "2024-01-31",
+			Amount:    1500,
+			Payee:     "Muster Handels GmbH",
+			Memo:      "Invoice 2024-0117 Customer 4711",
+			Currency:  "EUR",
+		},
+		{
+			// NOTPROVIDED is no end to end reference, the creditor reference is used
+			FITID:     "2024013100012346",
+			Reference: "RF18539007547034",
+			PostedAt:  "2024-01-31",
+			ValueAt:   "2024-02-01",
+			Amount:    -89.90,
+			Payee:     "Stadtwerke Beispielstadt",
+			Currency:  "EUR",
+		},
+		// The batch entry of 700.00 is split into its TxDtls, each with its own reference
+		{
+			FITID:     "2024013100012347-1",
+			Reference: "SAL-01-ANNA",
+			PostedAt:  "2024-01-31",
+			ValueAt:   "2024-01-31",
+			Amount:    -400,
+			Payee:     "Anna Beispiel",
+			Memo:      "Expenses January",
+			Currency:  "EUR",
+		},
+		{
+			FITID:     "2024013100012347-2",
+			Reference: "SAL-01-BEN",
+			PostedAt:  "2024-01-31",
+			ValueAt:   "2024-01-31",
+			Amount:    -300,
+			Payee:     "Ben Muster",
+			Memo:      "Expenses January",
+			Currency:  "EUR",
+		},
+		// The pending entry is left out
+	})
+}
@@ -0,0 +1,57 @@
+package imports
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// parseCSV read a CSV file whose first record is a header naming the columns
+func parseCSV(r io.Reader, options Options) ([]Row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, field := range requiredFields {
+		if _, isOK := columns[strings.ToLower(options.Mapping.column(field))]; !isOK {
+			return nil, fmt.Errorf("imports: the header has no %q column for %s", options.Mapping.column(field), field)
+		}
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if parseError, isParseError := err.(*csv.ParseError); isParseError {
+				rows = append(rows, Row{Line: parseError.StartLine, Err: parseError.Err})
+				continue
+			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		values := make(map[string]string)
+		for _, field := range Fields {
+			if i, isOK := columns[strings.ToLower(options.Mapping.column(field))]; isOK && i < len(record) {
+				values[field] = record[i]
+			}
+		}
+		rows = append(rows, newRow(line, values, options))
+	}
+	return rows, nil
+}
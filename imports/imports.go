@@ -0,0 +1,159 @@
+// Package imports read transactions from files exported by other systems so they can be created in bulk.
+package imports
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"transaction_project/models"
+)
+
+// Formats of the files that can be imported
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Fields of a models.Transaction that can be read from a file
+const (
+	FieldValue            = "value"
+	FieldNote             = "note"
+	FieldSender           = "sender"
+	FieldReceiver         = "receiver"
+	FieldCurrency         = "currency"
+	FieldReceivedCurrency = "received_currency"
+	FieldDate             = "date"
+)
+
+// Fields is every field that can be read from a file, the required ones first
+var Fields = []string{FieldValue, FieldSender, FieldReceiver, FieldNote, FieldCurrency, FieldReceivedCurrency, FieldDate}
+
+// requiredFields must be present in every row
+var requiredFields = []string{FieldValue, FieldSender, FieldReceiver}
+
+// ErrUnknownFormat is returned when the format of a file is not supported.
+var ErrUnknownFormat = errors.New("imports: unknown file format")
+
+// Mapping map a field of a models.Transaction to the column, or the JSON key, it is read from.
+// Fields that are not mapped are read from the column with the same name as the field.
+type Mapping map[string]string
+
+// Options describe how a file is read
+// DateFormat is a Go time layout, dates are read as RFC 3339 or YYYY-MM-DD when it is empty
+type Options struct {
+	Format     string
+	Mapping    Mapping
+	DateFormat string
+}
+
+// Row is a transaction read from a file. Err is set, and Transaction should be ignored, when the row
+// could not be read or is not a valid transaction.
+type Row struct {
+	Line        int
+	Transaction models.Transaction
+	Err         error
+}
+
+// Parse read every row of the file in the format of the options. An error is only returned when the
+// file as a whole can not be read, errors of a single row are reported in the row.
+func Parse(r io.Reader, options Options) ([]Row, error) {
+	for field := range options.Mapping {
+		if !isField(field) {
+			return nil, fmt.Errorf("imports: unknown field %q in mapping", field)
+		}
+	}
+	switch strings.ToLower(options.Format) {
+	case FormatCSV:
+		return parseCSV(r, options)
+	case FormatJSONL:
+		return parseJSONL(r, options)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, options.Format)
+	}
+}
+
+// ParseMapping parse a mapping written as field=column pairs separated by commas, like
+// value=Amount,sender=From
+func ParseMapping(encoded string) (Mapping, error) {
+	mapping := make(Mapping)
+	if strings.TrimSpace(encoded) == "" {
+		return mapping, nil
+	}
+	for _, pair := range strings.Split(encoded, ",") {
+		field, column, isOK := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !isOK || !isField(field) {
+			return nil, fmt.Errorf("imports: invalid mapping %q, expected field=column", pair)
+		}
+		mapping[field] = strings.TrimSpace(column)
+	}
+	return mapping, nil
+}
+
+// column return the column, or JSON key, the field is read from
+func (mapping Mapping) column(field string) string {
+	if column, isOK := mapping[field]; isOK {
+		return column
+	}
+	return field
+}
+
+// isField report whether the name is one of the Fields
+func isField(name string) bool {
+	for _, field := range Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newRow build a row from the raw values of its fields, keyed by field, and validate it
+func newRow(line int, values map[string]string, options Options) Row {
+	row := Row{Line: line}
+	for _, field := range requiredFields {
+		if strings.TrimSpace(values[field]) == "" {
+			row.Err = fmt.Errorf("missing %s", field)
+			return row
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(values[FieldValue]), 64)
+	if err != nil {
+		row.Err = fmt.Errorf("invalid value %q", values[FieldValue])
+		return row
+	}
+	row.Transaction = models.Transaction{
+		Value:            value,
+		Note:             strings.TrimSpace(values[FieldNote]),
+		Sender:           strings.TrimSpace(values[FieldSender]),
+		Receiver:         strings.TrimSpace(values[FieldReceiver]),
+		Currency:         strings.TrimSpace(values[FieldCurrency]),
+		ReceivedCurrency: strings.TrimSpace(values[FieldReceivedCurrency]),
+	}
+	if date := strings.TrimSpace(values[FieldDate]); date != "" {
+		if row.Transaction.CreatedAt, err = parseDate(date, options.DateFormat); err != nil {
+			row.Err = err
+			return row
+		}
+	}
+	row.Err = row.Transaction.Validate()
+	return row
+}
+
+// parseDate parse the date with the layout, or as RFC 3339 or YYYY-MM-DD when the layout is empty
+func parseDate(value, layout string) (time.Time, error) {
+	layouts := []string{layout}
+	if layout == "" {
+		layouts = []string{time.RFC3339, "2006-01-02"}
+	}
+	for _, layout := range layouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
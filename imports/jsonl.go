@@ -0,0 +1,58 @@
+package imports
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxJSONLineSize bound the size of a single line of a JSON Lines file
+const maxJSONLineSize = 1 << 20
+
+// parseJSONL read a JSON Lines file where every non blank line is an object
+func parseJSONL(r io.Reader, options Options) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLineSize)
+
+	var rows []Row
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var object map[string]interface{}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			rows = append(rows, Row{Line: line, Err: fmt.Errorf("invalid JSON: %v", err)})
+			continue
+		}
+
+		values := make(map[string]string)
+		for _, field := range Fields {
+			raw, isOK := object[options.Mapping.column(field)]
+			if !isOK || raw == nil {
+				continue
+			}
+			switch value := raw.(type) {
+			case string:
+				values[field] = value
+			case json.Number:
+				values[field] = value.String()
+			case bool:
+				values[field] = strconv.FormatBool(value)
+			default:
+				values[field] = fmt.Sprint(value)
+			}
+		}
+		rows = append(rows, newRow(line, values, options))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,191 @@
+package imports
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"transaction_project/models"
+)
+
+// mt940Tag match the start of a field of an MT940 message, like :61: or :60F:
+var mt940Tag = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+
+// mt940StatementLine match a :61: statement line: value date, optional entry date, debit/credit mark,
+// optional funds code, amount, transaction type, reference for the account owner and optional
+// reference of the bank
+var mt940StatementLine = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?([\d,]+)([NFS][A-Z0-9]{3})([^/]*?)(?://(.*))?$`)
+
+// mt940Subfield match the ?NN subfields of the structured :86: of German banks
+var mt940Subfield = regexp.MustCompile(`\?(\d{2})`)
+
+// mt940Field is a field of an MT940 message with the line it starts at
+type mt940Field struct {
+	tag   string
+	value string
+	line  int
+}
+
+// parseMT940 read the statement lines of every statement of an MT940 file. Each :61: statement line
+// is an entry, the :86: that follows it holds the counterparty and the remittance information.
+func parseMT940(r io.Reader) ([]StatementRow, error) {
+	fields, err := readMT940Fields(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []StatementRow
+	currency := ""
+	hasStatement := false
+	for i, field := range fields {
+		switch field.tag {
+		case "20":
+			hasStatement = true
+		case "60F", "60M":
+			// The opening balance is like C240131EUR1234,56
+			if len(field.value) >= 10 {
+				currency = field.value[7:10]
+			}
+		case "61":
+			row := StatementRow{Line: field.line}
+			row.Entry, row.Err = parseMT940StatementLine(field.value)
+			if row.Err == nil {
+				row.Entry.Currency = currency
+				if i+1 < len(fields) && fields[i+1].tag == "86" {
+					row.Entry.Payee, row.Entry.Memo, row.Entry.Reference = parseMT940Information(fields[i+1].value, row.Entry.Reference)
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	if !hasStatement {
+		return nil, errors.New("imports: not an MT940 file, it has no :20: field")
+	}
+	return rows, nil
+}
+
+// readMT940Fields split the messages of the file into fields, joining the lines a field continues
+// on. The SWIFT blocks around the text of a message, like {1:...}{4: and -}, are ignored.
+func readMT940Fields(r io.Reader) ([]mt940Field, error) {
+	scanner := bufio.NewScanner(r)
+	var fields []mt940Field
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \r")
+		if index := strings.Index(text, "{4:"); index >= 0 {
+			text = text[index+3:]
+		}
+		if text == "" || text == "-" || strings.HasPrefix(text, "-}") || strings.HasPrefix(text, "{") {
+			continue
+		}
+		if match := mt940Tag.FindStringSubmatch(text); match != nil {
+			fields = append(fields, mt940Field{tag: match[1], value: text[len(match[0]):], line: line})
+			continue
+		}
+		if len(fields) > 0 {
+			fields[len(fields)-1].value += "\n" + text
+		}
+	}
+	return fields, scanner.Err()
+}
+
+// parseMT940StatementLine parse the value of a :61: field, the supplementary details on its second
+// line are ignored
+func parseMT940StatementLine(value string) (entry models.StatementEntry, err error) {
+	first, _, _ := strings.Cut(value, "\n")
+	match := mt940StatementLine.FindStringSubmatch(first)
+	if match == nil {
+		return entry, fmt.Errorf("invalid statement line %q", first)
+	}
+
+	valueAt, err := time.Parse("060102", match[1])
+	if err != nil {
+		return entry, fmt.Errorf("invalid value date %q", match[1])
+	}
+	entry.ValueAt = &valueAt
+	entry.PostedAt = valueAt
+	if match[2] != "" {
+		// The entry date has no year, it is the closest to the value date
+		bookedAt, err := time.Parse("0102", match[2])
+		if err != nil {
+			return entry, fmt.Errorf("invalid entry date %q", match[2])
+		}
+		entry.PostedAt = time.Date(valueAt.Year(), bookedAt.Month(), bookedAt.Day(), 0, 0, 0, 0, time.UTC)
+		if entry.PostedAt.Sub(valueAt) > 180*24*time.Hour {
+			entry.PostedAt = entry.PostedAt.AddDate(-1, 0, 0)
+		} else if valueAt.Sub(entry.PostedAt) > 180*24*time.Hour {
+			entry.PostedAt = entry.PostedAt.AddDate(1, 0, 0)
+		}
+	}
+
+	if entry.Amount, err = strconv.ParseFloat(strings.Replace(match[5], ",", ".", 1), 64); err != nil {
+		return entry, fmt.Errorf("invalid amount %q", match[5])
+	}
+	// A reversal of a credit is a debit and a reversal of a debit is a credit
+	if match[3] == "D" || match[3] == "RC" {
+		entry.Amount = -entry.Amount
+	}
+
+	reference := strings.TrimSpace(match[7])
+	if reference == "NONREF" {
+		reference = ""
+	}
+	entry.Reference = reference
+	entry.FITID = strings.TrimSpace(match[8])
+	return entry, nil
+}
+
+// parseMT940Information read the counterparty, the remittance information and the end to end reference
+// of a :86: field. It may be structured with ?NN subfields by German banks, with /CODE/ subfields as
+// the SWIFT usage guidelines suggest, or be free text which is then all remittance information.
+func parseMT940Information(value, reference string) (payee, memo, endToEnd string) {
+	value = strings.ReplaceAll(value, "\n", "")
+	endToEnd = reference
+
+	if len(value) > 3 && value[3] == '?' {
+		indexes := mt940Subfield.FindAllStringSubmatchIndex(value, -1)
+		var remittance []string
+		var name string
+		for i, index := range indexes {
+			end := len(value)
+			if i+1 < len(indexes) {
+				end = indexes[i+1][0]
+			}
+			code, _ := strconv.Atoi(value[index[2]:index[3]])
+			text := value[index[1]:end]
+			switch {
+			case code >= 20 && code <= 29, code >= 60 && code <= 63:
+				// SEPA transfers prefix the end to end reference and the remittance information
+				if strings.HasPrefix(text, "EREF+") {
+					endToEnd = firstNonEmpty(strings.TrimPrefix(text, "EREF+"), endToEnd)
+					continue
+				}
+				remittance = append(remittance, strings.TrimPrefix(text, "SVWZ+"))
+			case code == 32 || code == 33:
+				name += text
+			}
+		}
+		return strings.TrimSpace(name), strings.TrimSpace(strings.Join(remittance, "")), endToEnd
+	}
+
+	if strings.HasPrefix(value, "/") {
+		parts := strings.Split(value, "/")
+		for i := 1; i+1 < len(parts); i += 2 {
+			switch parts[i] {
+			case "NAME":
+				payee = strings.TrimSpace(parts[i+1])
+			case "REMI":
+				memo = strings.TrimSpace(parts[i+1])
+			case "EREF":
+				endToEnd = firstNonEmpty(parts[i+1], endToEnd)
+			}
+		}
+		if payee != "" || memo != "" {
+			return payee, memo, endToEnd
+		}
+	}
+	return "", strings.TrimSpace(value), endToEnd
+}
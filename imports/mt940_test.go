@@ -0,0 +1,56 @@
+package imports
+
+import "testing"
+
+func TestParseMT940(t *testing.T) {
+	checkStatement(t, "testdata/mt940.sta", FormatMT940, []expectedEntry{
+		{
+			FITID:     "2024013100012345",
+			Reference: "INV-2024-0117",
+			PostedAt:  "2024-01-31",
+			ValueAt:   "2024-01-31",
+			Amount:    1500,
+			Payee:     "Muster Handels GmbH",
+			Memo:      "Invoice 2024-0117 Customer 4711",
+			Currency:  "EUR",
+		},
+		{
+			// The entry date is before the value date
+			FITID:     "2024013100012346",
+			Reference: "RF18539007547034",
+			PostedAt:  "2024-01-31",
+			ValueAt:   "2024-02-01",
+			Amount:    -89.90,
+			Payee:     "Stadtwerke Beispielstadt",
+			Memo:      "Electricity January",
+			Currency:  "EUR",
+		},
+		{
+			// Without entry date the entry is posted at the value date
+			PostedAt: "2024-01-31",
+			ValueAt:  "2024-01-31",
+			Amount:   -12.50,
+			Memo:     "Account maintenance fee",
+			Currency: "EUR",
+		},
+		{
+			// A reversal of a debit is a credit, the entry date 0102 is in the year after the value date
+			FITID:    "2024010200000001",
+			PostedAt: "2024-01-02",
+			ValueAt:  "2023-12-29",
+			Amount:   12.50,
+			Memo:     "Refund of the account maintenance fee of December",
+			Currency: "EUR",
+		},
+		{
+			// A reversal of a credit is a debit
+			FITID:    "2024013100012348",
+			PostedAt: "2024-01-31",
+			ValueAt:  "2024-01-31",
+			Amount:   -100,
+			Payee:    "Muster Handels GmbH",
+			Memo:     "Return of a duplicate credit",
+			Currency: "EUR",
+		},
+	})
+}
@@ -0,0 +1,163 @@
+package imports
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ofxEntities are the character references that can appear in the text of the XML variant of OFX
+var ofxEntities = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'", "&nbsp;", " ", "&amp;", "&")
+
+// parseOFX read the STMTTRN entries of an OFX or QFX file. Both the SGML variant of OFX 1.x, whose
+// elements holding a value are not closed, and the XML variant of OFX 2.x are read the same way: the
+// file is a sequence of tags, each optionally followed by a value.
+func parseOFX(r io.Reader) ([]StatementRow, error) {
+	reader := bufio.NewReader(r)
+	line := 1
+	var rows []StatementRow
+	var current *StatementRow
+	var path []string
+	currency := ""
+	hasOFX := false
+
+	for {
+		// Skip to the next tag, the SGML header lines before <OFX> are skipped the same way
+		text, err := reader.ReadString('<')
+		line += strings.Count(text, "\n")
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		tagLine := line
+		tag, err := reader.ReadString('>')
+		line += strings.Count(tag, "\n")
+		if err != nil {
+			return nil, errors.New("imports: unterminated OFX tag")
+		}
+		tag = strings.ToUpper(strings.TrimSpace(strings.TrimSuffix(tag, ">")))
+		if tag == "" || tag[0] == '?' || tag[0] == '!' {
+			continue
+		}
+
+		if strings.HasPrefix(tag, "/") {
+			name := tag[1:]
+			// SGML leaves may be closed or not, only aggregates pop the path
+			for i := len(path) - 1; i >= 0; i-- {
+				if path[i] == name {
+					path = path[:i]
+					break
+				}
+			}
+			if name == "STMTTRN" && current != nil {
+				if current.Err == nil && current.Entry.PostedAt.IsZero() {
+					current.Err = errors.New("missing DTPOSTED")
+				}
+				if current.Entry.Currency == "" {
+					current.Entry.Currency = currency
+				}
+				rows = append(rows, *current)
+				current = nil
+			}
+			continue
+		}
+		if tag == "OFX" {
+			hasOFX = true
+		}
+
+		// The value runs until the next tag, which is left to be read by the next iteration
+		value, err := reader.ReadString('<')
+		line += strings.Count(value, "\n")
+		if err == nil {
+			value = strings.TrimSuffix(value, "<")
+			err = reader.UnreadByte()
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		value = strings.TrimSpace(ofxEntities.Replace(value))
+		if value == "" {
+			// An aggregate like <STMTTRN> or <BANKACCTFROM>
+			path = append(path, tag)
+			if tag == "STMTTRN" {
+				current = &StatementRow{Line: tagLine}
+			}
+			continue
+		}
+
+		parent := ""
+		if len(path) > 0 {
+			parent = path[len(path)-1]
+		}
+		if tag == "CURDEF" {
+			currency = value
+		}
+		if current == nil || current.Err != nil {
+			continue
+		}
+		entry := &current.Entry
+		switch {
+		case tag == "FITID":
+			entry.FITID = value
+		case tag == "DTPOSTED":
+			if entry.PostedAt, err = parseOFXDate(value); err != nil {
+				current.Err = err
+			}
+		case tag == "TRNAMT":
+			if entry.Amount, err = parseAmount(value); err != nil {
+				current.Err = err
+			}
+		case tag == "NAME" && (parent == "STMTTRN" || parent == "PAYEE"):
+			if entry.Payee == "" {
+				entry.Payee = value
+			}
+		case tag == "MEMO":
+			entry.Memo = value
+		case tag == "CURSYM" && (parent == "CURRENCY" || parent == "ORIGCURRENCY"):
+			entry.Currency = value
+		}
+	}
+	if !hasOFX {
+		return nil, errors.New("imports: not an OFX file")
+	}
+	return rows, nil
+}
+
+// parseOFXDate parse an OFX date like 20240131, 20240131120000 or 20240131120000.000[-5:EST]
+func parseOFXDate(value string) (time.Time, error) {
+	invalid := fmt.Errorf("invalid date %q", value)
+	location := time.UTC
+	if open := strings.IndexByte(value, '['); open >= 0 {
+		zone := strings.TrimSuffix(value[open+1:], "]")
+		value = value[:open]
+		offset, name, _ := strings.Cut(zone, ":")
+		hours, err := strconv.ParseFloat(offset, 64)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		if name == "" {
+			name = "UTC" + offset
+		}
+		location = time.FixedZone(name, int(hours*3600))
+	}
+	if dot := strings.IndexByte(value, '.'); dot >= 0 {
+		value = value[:dot]
+	}
+
+	layouts := map[int]string{8: "20060102", 12: "200601021504", 14: "20060102150405"}
+	layout, isOK := layouts[len(value)]
+	if !isOK {
+		return time.Time{}, invalid
+	}
+	date, err := time.ParseInLocation(layout, value, location)
+	if err != nil {
+		return time.Time{}, invalid
+	}
+	return date, nil
+}
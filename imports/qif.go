@@ -0,0 +1,108 @@
+package imports
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// qifDateLayouts are the layouts of QIF dates tried when Options.DateFormat is empty, Quicken writes
+// US dates with an apostrophe before two digit years of the 2000s
+var qifDateLayouts = []string{"1/2/2006", "1/2'06", "1/2/06", "1-2-2006", "1-2-06", "2006-01-02", "1.2.2006"}
+
+// parseQIF read the entries of the bank, cash and credit card sections of a QIF file. Every entry is
+// a list of lines starting with a field code and ending with a line holding ^.
+func parseQIF(r io.Reader, options Options) ([]StatementRow, error) {
+	scanner := bufio.NewScanner(r)
+	var rows []StatementRow
+	current := StatementRow{Line: 1}
+	isEmpty := true
+	isTransactions := true
+	hasHeader := false
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(strings.TrimPrefix(scanner.Text(), "\ufeff"), " \r")
+		if text == "" {
+			continue
+		}
+		if text[0] == '!' {
+			hasHeader = true
+			header := strings.ToLower(strings.TrimSpace(text))
+			switch {
+			case strings.HasPrefix(header, "!type:bank"), strings.HasPrefix(header, "!type:cash"),
+				strings.HasPrefix(header, "!type:ccard"), strings.HasPrefix(header, "!type:oth"):
+				isTransactions = true
+			case strings.HasPrefix(header, "!option"), strings.HasPrefix(header, "!clear"):
+			default: // accounts, categories, investments, memorized transactions...
+				isTransactions = false
+			}
+			current, isEmpty = StatementRow{Line: line + 1}, true
+			continue
+		}
+		if !isTransactions {
+			continue
+		}
+		if isEmpty {
+			current.Line = line
+		}
+
+		code, value := text[0], strings.TrimSpace(text[1:])
+		var err error
+		switch code {
+		case '^':
+			if !isEmpty {
+				if current.Err == nil && current.Entry.PostedAt.IsZero() {
+					current.Err = errors.New("missing date")
+				}
+				rows = append(rows, current)
+			}
+			current, isEmpty = StatementRow{Line: line + 1}, true
+			continue
+		case 'D':
+			current.Entry.PostedAt, err = parseQIFDate(value, options.DateFormat)
+		case 'T', 'U':
+			current.Entry.Amount, err = parseAmount(value)
+		case 'P':
+			current.Entry.Payee = value
+		case 'M':
+			current.Entry.Memo = value
+		case 'N':
+			// The check number is kept in the memo since QIF has no identifier for its entries
+			if value != "" && current.Entry.Memo == "" {
+				current.Entry.Memo = "#" + value
+			}
+		}
+		isEmpty = false
+		if err != nil && current.Err == nil {
+			current.Err = err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !hasHeader {
+		return nil, errors.New("imports: not a QIF file, it has no !Type header")
+	}
+	if !isEmpty {
+		rows = append(rows, StatementRow{Line: current.Line, Err: errors.New("entry is not terminated by ^")})
+	}
+	return rows, nil
+}
+
+// parseQIFDate parse the date with the layout, or one of the qifDateLayouts when the layout is empty
+func parseQIFDate(value, layout string) (time.Time, error) {
+	layouts := []string{layout}
+	if layout == "" {
+		layouts = qifDateLayouts
+	}
+	value = strings.ReplaceAll(value, " ", "")
+	for _, layout := range layouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
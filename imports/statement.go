@@ -0,0 +1,70 @@
+package imports
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"transaction_project/models"
+)
+
+// Formats of the bank statements that can be imported, QFX is the OFX of Quicken
+const (
+	FormatOFX     = "ofx"
+	FormatQFX     = "qfx"
+	FormatQIF     = "qif"
+	FormatCAMT053 = "camt053"
+	FormatMT940   = "mt940"
+)
+
+// statementExtensions map the extensions of statement files to their format
+var statementExtensions = map[string]string{
+	".ofx":   FormatOFX,
+	".qfx":   FormatQFX,
+	".qif":   FormatQIF,
+	".xml":   FormatCAMT053,
+	".camt":  FormatCAMT053,
+	".sta":   FormatMT940,
+	".940":   FormatMT940,
+	".mt940": FormatMT940,
+}
+
+// StatementRow is an entry read from a bank statement. Err is set, and Entry should be ignored, when
+// the entry could not be read.
+type StatementRow struct {
+	Line  int
+	Entry models.StatementEntry
+	Err   error
+}
+
+// ParseStatement read every entry of a bank statement in the format of the options, the mapping of
+// the options is not used. An error is only returned when the statement as a whole can not be read.
+func ParseStatement(r io.Reader, options Options) ([]StatementRow, error) {
+	switch strings.ToLower(options.Format) {
+	case FormatOFX, FormatQFX:
+		return parseOFX(r)
+	case FormatQIF:
+		return parseQIF(r, options)
+	case FormatCAMT053:
+		return parseCAMT053(r)
+	case FormatMT940:
+		return parseMT940(r)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, options.Format)
+	}
+}
+
+// StatementFormat return the format of a statement file from its extension, empty if it is unknown
+func StatementFormat(filename string) string {
+	return statementExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// parseAmount parse an amount that may use commas to separate thousands
+func parseAmount(value string) (float64, error) {
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(value), ",", ""), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	return amount, nil
+}
@@ -0,0 +1,78 @@
+package imports
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+// expectedEntry is what a test expects of a models.StatementEntry, ValueAt is empty when the entry has
+// no value date
+type expectedEntry struct {
+	FITID     string
+	Reference string
+	PostedAt  string
+	ValueAt   string
+	Amount    float64
+	Payee     string
+	Memo      string
+	Currency  string
+}
+
+// checkStatement parse the statement file in the format and compare its rows with want, in order
+func checkStatement(t *testing.T, path, format string, want []expectedEntry) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	rows, err := ParseStatement(file, Options{Format: format})
+	if err != nil {
+		t.Fatalf("ParseStatement: %v", err)
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(rows), len(want))
+	}
+	for i, row := range rows {
+		if row.Err != nil {
+			t.Errorf("row %d (line %d): %v", i, row.Line, row.Err)
+			continue
+		}
+		entry, expected := row.Entry, want[i]
+		if entry.FITID != expected.FITID {
+			t.Errorf("row %d: FITID = %q, want %q", i, entry.FITID, expected.FITID)
+		}
+		if entry.Reference != expected.Reference {
+			t.Errorf("row %d: Reference = %q, want %q", i, entry.Reference, expected.Reference)
+		}
+		if date := entry.PostedAt.Format("2006-01-02"); date != expected.PostedAt {
+			t.Errorf("row %d: PostedAt = %s, want %s", i, date, expected.PostedAt)
+		}
+		if date := formatValueDate(entry.ValueAt); date != expected.ValueAt {
+			t.Errorf("row %d: ValueAt = %s, want %s", i, date, expected.ValueAt)
+		}
+		if math.Abs(entry.Amount-expected.Amount) > 0.001 {
+			t.Errorf("row %d: Amount = %.2f, want %.2f", i, entry.Amount, expected.Amount)
+		}
+		if entry.Payee != expected.Payee {
+			t.Errorf("row %d: Payee = %q, want %q", i, entry.Payee, expected.Payee)
+		}
+		if entry.Memo != expected.Memo {
+			t.Errorf("row %d: Memo = %q, want %q", i, entry.Memo, expected.Memo)
+		}
+		if entry.Currency != expected.Currency {
+			t.Errorf("row %d: Currency = %q, want %q", i, entry.Currency, expected.Currency)
+		}
+	}
+}
+
+// formatValueDate return the value date as a day, empty when there is none
+func formatValueDate(valueAt *time.Time) string {
+	if valueAt == nil {
+		return ""
+	}
+	return valueAt.Format("2006-01-02")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"transaction_project/models"
+)
+
+const defaultCheckpointInterval = time.Hour
+
+// ledgerSigningKey read the key used to sign ledger checkpoints from the LEDGER_SIGNING_KEY environment
+// variable, a hex encoded ed25519 seed. nil is returned if the variable is not set.
+func ledgerSigningKey() (ed25519.PrivateKey, error) {
+	encoded := os.Getenv("LEDGER_SIGNING_KEY")
+	if encoded == "" {
+		return nil, nil
+	}
+	seed, err := hex.DecodeString(encoded)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("LEDGER_SIGNING_KEY must be a hex encoded %d bytes seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ledgerPublicKey return the public half of the signing key, nil if there is no signing key
+func ledgerPublicKey(signingKey ed25519.PrivateKey) ed25519.PublicKey {
+	if signingKey == nil {
+		return nil
+	}
+	return signingKey.Public().(ed25519.PublicKey)
+}
+
+// ledgerCheckpointInterval read how often checkpoints are signed from the LEDGER_CHECKPOINT_INTERVAL
+// environment variable
+func ledgerCheckpointInterval() (time.Duration, error) {
+	encoded := os.Getenv("LEDGER_CHECKPOINT_INTERVAL")
+	if encoded == "" {
+		return defaultCheckpointInterval, nil
+	}
+	interval, err := time.ParseDuration(encoded)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("LEDGER_CHECKPOINT_INTERVAL must be a positive duration like 30m")
+	}
+	return interval, nil
+}
+
+// runLedgerCheckpoints sign the head of the ledger every interval, it never returns
+func runLedgerCheckpoints(ledgerService *models.LedgerService, signingKey ed25519.PrivateKey, interval time.Duration) {
+	for range time.Tick(interval) {
+		checkpoint, err := ledgerService.Checkpoint(signingKey)
+		if errors.Is(err, models.ErrNoLedgerEntries) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Ledger checkpoint failed: %v", err)
+			continue
+		}
+		log.Printf("Ledger checkpoint %d signed at entry %d", checkpoint.ID, checkpoint.EntryID)
+	}
+}
+
+// verifyLedger implement the verify-ledger command
+func verifyLedger(services *models.Services, args []string) error {
+	flags := flag.NewFlagSet("verify-ledger", flag.ExitOnError)
+	checkpointsPath := flags.String("checkpoints", "", "also check the checkpoints exported to this file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	signingKey, err := ledgerSigningKey()
+	if err != nil {
+		return err
+	}
+	var exported []models.LedgerCheckpoint
+	if *checkpointsPath != "" {
+		file, err := os.Open(*checkpointsPath)
+		if err != nil {
+			return err
+		}
+		exported, err = models.ReadExportedCheckpoints(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	report, err := services.Ledger.Verify(ledgerPublicKey(signingKey), exported...)
+	if err != nil {
+		return err
+	}
+	if !report.OK {
+		fmt.Printf("Ledger is BROKEN at entry %d (transaction %d): %s\n",
+			report.BrokenEntryID, report.BrokenTransactionID, report.Reason)
+		os.Exit(1)
+	}
+	fmt.Printf("Ledger OK: %d entries, %d transactions, %d checkpoints verified\n",
+		report.Entries, report.Transactions, report.Checkpoints)
+	return nil
+}
+
+// backfillLedger implement the backfill-ledger command
+func backfillLedger(services *models.Services) error {
+	chained, err := services.Ledger.Backfill()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d transactions predating the ledger chained\n", chained)
+	return nil
+}
+
+// checkpointLedger implement the checkpoint-ledger command
+func checkpointLedger(services *models.Services) error {
+	signingKey, err := ledgerSigningKey()
+	if err != nil {
+		return err
+	}
+	if signingKey == nil {
+		return errors.New("LEDGER_SIGNING_KEY must be set to sign checkpoints")
+	}
+	checkpoint, err := services.Ledger.Checkpoint(signingKey)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Checkpoint %d signed at entry %d: %s\n", checkpoint.ID, checkpoint.EntryID, checkpoint.Hash)
+	return nil
+}
+
+// exportCheckpoints implement the export-checkpoints command
+func exportCheckpoints(services *models.Services, args []string) error {
+	flags := flag.NewFlagSet("export-checkpoints", flag.ExitOnError)
+	output := flags.String("o", "ledger-checkpoints.jsonl", "file to write the checkpoints to")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := services.Ledger.ExportCheckpoints(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
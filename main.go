@@ -21,11 +21,30 @@ const (
 
 const defaultPort = "3000"
 
+const usage = `Usage: transaction_project [command] [flags]
+
+Commands:
+  serve                 start the GraphQL server (default)
+  verify-ledger         walk the transaction hash chain and report the first broken link
+  backfill-ledger       chain the transactions committed before the ledger existed, only once
+  checkpoint-ledger     sign the current head of the ledger
+  export-checkpoints    write every signed ledger checkpoint to a file
+  purge-deleted         permanently remove records deleted longer ago than the retention
+  set-role              change the role of a user
+  load-rates            load exchange rates from a CSV or JSON file
+  import                create transactions from a CSV or JSON Lines file
+  import-statement      create the transactions of an OFX, QFX, QIF, camt.053 or MT940 bank statement
+  export                write transactions to a CSV, JSON Lines or XLSX file
+  payment-batch         generate a pain.001 or NACHA file paying pending transactions
+  download-payment-batch
+                        write the file of a payment batch again
+  statements            write the PDF or HTML statement of every user for a month`
+
 func main() {
-	var err error
-	serverPort := os.Getenv("PORT")
-	if serverPort == "" {
-		serverPort = defaultPort
+	command := "serve"
+	args := os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
 	}
 
 	// Initiate database connection
@@ -40,26 +59,121 @@ func main() {
 			panic(err)
 		}
 	}(db)
-	db.LogMode(true)
+	db.LogMode(command == "serve")
 	log.Printf("Database connection established!")
 
 	// Initiate services and AutoMigrate
-	transService, err := models.NewTransactionService(db)
-	if err != nil && transService.AutoMigrate() != nil {
+	services, err := models.NewServices(db)
+	if err != nil {
 		panic(err)
 	}
-	userService, err := models.NewUserService(db)
-	if err != nil && userService.AutoMigrate() != nil {
+	if err := services.AutoMigrate(); err != nil {
 		panic(err)
 	}
 
+	switch command {
+	case "serve":
+		err = serve(services)
+	case "verify-ledger":
+		err = verifyLedger(services, args)
+	case "backfill-ledger":
+		err = backfillLedger(services)
+	case "checkpoint-ledger":
+		err = checkpointLedger(services)
+	case "export-checkpoints":
+		err = exportCheckpoints(services, args)
+	case "purge-deleted":
+		err = purgeDeleted(services, args)
+	case "set-role":
+		err = setRole(services, args)
+	case "load-rates":
+		err = loadRates(services, args)
+	case "import":
+		err = importTransactions(services, args)
+	case "import-statement":
+		err = importStatement(services, args)
+	case "export":
+		err = exportTransactions(services, args)
+	case "payment-batch":
+		err = createPaymentBatch(services, args)
+	case "download-payment-batch":
+		err = downloadPaymentBatch(services, args)
+	case "statements":
+		err = generateStatements(services, args)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// serve start the GraphQL server and the background jobs
+func serve(services *models.Services) error {
+	serverPort := os.Getenv("PORT")
+	if serverPort == "" {
+		serverPort = defaultPort
+	}
+
+	signingKey, err := ledgerSigningKey()
+	if err != nil {
+		return err
+	}
+	if signingKey != nil {
+		interval, err := ledgerCheckpointInterval()
+		if err != nil {
+			return err
+		}
+		go runLedgerCheckpoints(services.Ledger, signingKey, interval)
+	}
+	retention, err := purgeRetention()
+	if err != nil {
+		return err
+	}
+	if retention > 0 {
+		go runPurgeJob(services, retention)
+	}
+	interval, err := schedulerInterval()
+	if err != nil {
+		return err
+	}
+	go runScheduler(services, interval)
+
 	// Initiate controllers
-	transController := controllers.NewTransactionController(transService)
-	userController := controllers.NewUserController(userService)
-	graphController := controllers.NewGraphQL(transController, userController)
+	transController := controllers.NewTransactionController(services.Transaction)
+	userController := controllers.NewUserController(services.User)
+	auditController := controllers.NewAuditController(services.Audit)
+	importController := controllers.NewImportController(services.Transaction, services.Statement)
+	paymentController := controllers.NewPaymentController(services.Payment, paymentOriginator())
+	graphController := controllers.NewGraphQL(controllers.Controllers{
+		Transaction:    transController,
+		User:           userController,
+		Audit:          auditController,
+		Ledger:         controllers.NewLedgerController(services.Ledger, ledgerPublicKey(signingKey)),
+		Rate:           controllers.NewRateController(services.Rate),
+		Report:         controllers.NewReportController(services.Transaction, services.Rate),
+		Schedule:       controllers.NewScheduleController(services.Schedule),
+		Import:         importController,
+		Payment:        paymentController,
+		Reconciliation: controllers.NewReconciliationController(services.Reconciliation),
+		Category:       controllers.NewCategoryController(services.Category),
+		Search:         controllers.NewSearchController(services.Search),
+		Group:          controllers.NewGroupController(services.Group),
+		PaymentRequest: controllers.NewPaymentRequestController(services.PaymentRequest),
+		Hold:           controllers.NewHoldController(services.Hold),
+		Approval:       controllers.NewApprovalController(services.Approval),
+		Limit:          controllers.NewLimitController(services.Limit),
+		Risk:           controllers.NewRiskController(services.Risk),
+	})
 
 	// Add handler and start server
-	http.Handle("/graph", graphController.NewHandler())
+	http.Handle("/graph", userController.Authenticate(graphController.NewHandler()))
+	http.Handle("/export", userController.Authenticate(controllers.NewExportController(services.Transaction).NewHandler()))
+	http.Handle("/payment-batch", userController.Authenticate(paymentController.NewDownloadHandler()))
+	http.Handle("/ui/", controllers.NewWebController(transController, userController, importController, auditController, services.Session).NewHandler())
+	http.Handle("/statement", userController.Authenticate(controllers.NewAccountStatementController(services.Transaction).NewHandler()))
 	log.Printf("Connect to http://localhost:%s/graph for GraphQL playground", serverPort)
-	log.Fatal(http.ListenAndServe(":"+serverPort, nil))
+	log.Printf("Connect to http://localhost:%s/ui/ for the web interface", serverPort)
+	return http.ListenAndServe(":"+serverPort, nil)
 }
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// AccountStatement is the statement of an account for the period from From, inclusive, to To, exclusive.
+// It has a section for every currency the account held money in or moved money in during the period.
+type AccountStatement struct {
+	Account  string
+	Holder   string
+	From     time.Time
+	To       time.Time
+	Sections []StatementSection
+}
+
+// StatementSection is the part of an AccountStatement in a single currency. Closing is Opening plus
+// Incoming minus Outgoing. Currencies without balance nor transaction in the period have no section.
+type StatementSection struct {
+	Currency string
+	Opening  float64
+	Incoming float64
+	Outgoing float64
+	Closing  float64
+	Items    []StatementItem
+}
+
+// StatementItem is a transaction of an AccountStatement. Exactly one of In and Out is set, unless the
+// account sent the transaction to itself, and Balance is the running balance once it is booked.
+type StatementItem struct {
+	TransactionID uint
+	Date          time.Time
+	Counterparty  string
+	Note          string
+	In            float64
+	Out           float64
+	Balance       float64
+}
+
+// Statement build the statement of the account for the period from from, inclusive, to to, exclusive.
+// The opening balance of each currency is computed from every transaction before from and the items
+// are ordered by date, the order their running balance is computed in.
+func (transService *TransactionService) Statement(account string, from, to time.Time) (*AccountStatement, error) {
+	statement := &AccountStatement{
+		Account: account,
+		From:    from,
+		To:      to,
+	}
+	var holder User
+	if err := first(transService.db.Where("email = ?", account), &holder); err != nil && err != ErrNotFound {
+		return nil, err
+	}
+	statement.Holder = strings.Join(strings.Fields(holder.First+" "+holder.Middle+" "+holder.Last), " ")
+
+	openings, err := transService.BalanceByCurrency(account, &from)
+	if err != nil {
+		return nil, err
+	}
+	sections := make(map[string]*StatementSection)
+	sectionOf := func(currency string) *StatementSection {
+		if _, isOK := sections[currency]; !isOK {
+			sections[currency] = &StatementSection{Currency: currency}
+		}
+		return sections[currency]
+	}
+	for _, opening := range openings {
+		sectionOf(opening.Currency).Opening = RoundAmount(opening.Total, opening.Currency)
+	}
+
+	var items []StatementItem
+	var currencies []string
+	filter := TransactionFilter{Account: account, From: &from, To: &to}
+	err = transService.Each(filter, func(transaction *Transaction) error {
+		if transaction.Receiver == account {
+			currency, value := transaction.Currency, transaction.Value
+			if transaction.ReceivedCurrency != "" {
+				currency, value = transaction.ReceivedCurrency, transaction.ReceivedValue
+			}
+			items = append(items, StatementItem{
+				TransactionID: transaction.ID,
+				Date:          transaction.CreatedAt,
+				Counterparty:  transaction.Sender,
+				Note:          transaction.Note,
+				In:            value,
+			})
+			currencies = append(currencies, currency)
+		}
+		if transaction.Sender == account {
+			items = append(items, StatementItem{
+				TransactionID: transaction.ID,
+				Date:          transaction.CreatedAt,
+				Counterparty:  transaction.Receiver,
+				Note:          transaction.Note,
+				Out:           transaction.Value,
+			})
+			currencies = append(currencies, transaction.Currency)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return items[order[i]].Date.Before(items[order[j]].Date) })
+	for _, section := range sections {
+		section.Closing = section.Opening
+	}
+	for _, i := range order {
+		item, current := items[i], sectionOf(currencies[i])
+		current.Incoming += item.In
+		current.Outgoing += item.Out
+		current.Closing = RoundAmount(current.Closing+item.In-item.Out, current.Currency)
+		item.Balance = current.Closing
+		current.Items = append(current.Items, item)
+	}
+
+	for _, section := range sections {
+		if section.Opening == 0 && len(section.Items) == 0 {
+			continue
+		}
+		section.Incoming = RoundAmount(section.Incoming, section.Currency)
+		section.Outgoing = RoundAmount(section.Outgoing, section.Currency)
+		statement.Sections = append(statement.Sections, *section)
+	}
+	sort.Slice(statement.Sections, func(i, j int) bool {
+		return statement.Sections[i].Currency < statement.Sections[j].Currency
+	})
+	return statement, nil
+}
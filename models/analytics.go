@@ -0,0 +1,297 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dimensions transactions are grouped by in Aggregate
+const (
+	GroupByDay      = "day"
+	GroupByWeek     = "week"
+	GroupByMonth    = "month"
+	GroupBySender   = "sender"
+	GroupByReceiver = "receiver"
+	GroupByCurrency = "currency"
+	GroupByCategory = "category"
+)
+
+// DefaultPercentiles is the ranks ValuePercentiles compute when none is requested
+var DefaultPercentiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+var (
+	// ErrUnknownGrouping is returned when transactions are aggregated by an unknown dimension
+	ErrUnknownGrouping = errors.New("models: unknown grouping")
+
+	// ErrInvalidPercentile is returned when a percentile is not between 0 and 1
+	ErrInvalidPercentile = errors.New("models: percentiles must be between 0 and 1")
+)
+
+// groupingKeys is the SQL expression of the key of each dimension of Aggregate. Periods start on their
+// first day, weeks on Monday, and are formatted as YYYY-MM-DD, or YYYY-MM for months. Categories are
+// grouped by ID and named by Aggregate.
+var groupingKeys = map[string]string{
+	GroupByDay:      "to_char(date_trunc('day', created_at), 'YYYY-MM-DD')",
+	GroupByWeek:     "to_char(date_trunc('week', created_at), 'YYYY-MM-DD')",
+	GroupByMonth:    "to_char(date_trunc('month', created_at), 'YYYY-MM')",
+	GroupBySender:   "sender",
+	GroupByReceiver: "receiver",
+	GroupByCurrency: "currency",
+	GroupByCategory: "COALESCE(CAST(category_id AS text), '')",
+}
+
+// AggregateBucket is the sum, the number and the average of the values of the transactions sharing a key
+// and a currency, amounts of different currencies are never added up
+type AggregateBucket struct {
+	Key      string
+	Currency string
+	Total    float64
+	Count    int
+	Average  float64
+}
+
+// CounterpartyTotal is what an account sent to and received from a counterparty in a currency
+type CounterpartyTotal struct {
+	Counterparty string
+	Currency     string
+	Sent         float64
+	Received     float64
+	Count        int
+}
+
+// CashFlow is the money that went in and out of an account in a currency, Net being Incoming minus Outgoing
+type CashFlow struct {
+	Account  string
+	Currency string
+	Incoming float64
+	Outgoing float64
+	Net      float64
+	Count    int
+}
+
+// Percentile is the value below which Rank, between 0 and 1, of the transaction values fall
+type Percentile struct {
+	Rank  float64
+	Value float64
+}
+
+// ValueDistribution describe the values of the transactions of a currency
+type ValueDistribution struct {
+	Currency    string
+	Count       int
+	Min         float64
+	Max         float64
+	Average     float64
+	Percentiles []Percentile
+}
+
+// Aggregate sum the values of the transactions matching the filter grouped by one of the GroupBy dimensions
+// and by currency, ordered by key then currency. The key of uncategorized transactions is empty.
+func (transService *TransactionService) Aggregate(filter TransactionFilter, groupBy string) ([]AggregateBucket, error) {
+	key, isOK := groupingKeys[groupBy]
+	if !isOK {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownGrouping, groupBy)
+	}
+	var buckets []AggregateBucket
+	err := filter.apply(transService.db.Model(&Transaction{})).
+		Select(key + " AS key, currency, SUM(value) AS total, COUNT(*) AS count, AVG(value) AS average").
+		Group(key + ", currency").
+		Order("key, currency").
+		Scan(&buckets).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range buckets {
+		buckets[i].Total = RoundAmount(buckets[i].Total, buckets[i].Currency)
+		buckets[i].Average = RoundAmount(buckets[i].Average, buckets[i].Currency)
+	}
+	if groupBy == GroupByCategory {
+		return buckets, transService.nameCategories(buckets)
+	}
+	return buckets, nil
+}
+
+// nameCategories replace the category IDs keying the buckets with the names of the categories and sort
+// the buckets again
+func (transService *TransactionService) nameCategories(buckets []AggregateBucket) error {
+	var categories []Category
+	if err := transService.db.Find(&categories).Error; err != nil {
+		return err
+	}
+	names := make(map[string]string, len(categories))
+	for _, category := range categories {
+		names[strconv.FormatUint(uint64(category.ID), 10)] = category.Name
+	}
+	for i := range buckets {
+		buckets[i].Key = names[buckets[i].Key]
+	}
+	sort.SliceStable(buckets, func(i, j int) bool {
+		if buckets[i].Key != buckets[j].Key {
+			return buckets[i].Key < buckets[j].Key
+		}
+		return buckets[i].Currency < buckets[j].Currency
+	})
+	return nil
+}
+
+// TopCounterparties return the limit counterparties the account exchanged the most transactions with among
+// those matching the filter, then the largest amounts, with a total per currency. Received amounts are in
+// the currency credited to the account, like BalanceByCurrency.
+func (transService *TransactionService) TopCounterparties(account string, filter TransactionFilter, limit int) ([]CounterpartyTotal, error) {
+	db := filter.apply(transService.db.Model(&Transaction{}))
+	var sent []CounterpartyTotal
+	err := db.Select("receiver AS counterparty, currency, SUM(value) AS sent, COUNT(*) AS count").
+		Where("sender = ?", account).
+		Group("receiver, currency").
+		Scan(&sent).Error
+	if err != nil {
+		return nil, err
+	}
+	var received []CounterpartyTotal
+	err = db.Select("sender AS counterparty, COALESCE(NULLIF(received_currency, ''), currency) AS currency, "+
+		"SUM(CASE WHEN received_currency = '' THEN value ELSE received_value END) AS received, COUNT(*) AS count").
+		Where("receiver = ?", account).
+		Group("sender, COALESCE(NULLIF(received_currency, ''), currency)").
+		Scan(&received).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[[2]string]*CounterpartyTotal)
+	var result []CounterpartyTotal
+	for _, total := range append(sent, received...) {
+		key := [2]string{total.Counterparty, total.Currency}
+		if _, isOK := totals[key]; !isOK {
+			totals[key] = &CounterpartyTotal{Counterparty: total.Counterparty, Currency: total.Currency}
+		}
+		totals[key].Sent += total.Sent
+		totals[key].Received += total.Received
+		totals[key].Count += total.Count
+	}
+	for _, total := range totals {
+		total.Sent = RoundAmount(total.Sent, total.Currency)
+		total.Received = RoundAmount(total.Received, total.Currency)
+		result = append(result, *total)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		if volumeI, volumeJ := result[i].Sent+result[i].Received, result[j].Sent+result[j].Received; volumeI != volumeJ {
+			return volumeI > volumeJ
+		}
+		if result[i].Counterparty != result[j].Counterparty {
+			return result[i].Counterparty < result[j].Counterparty
+		}
+		return result[i].Currency < result[j].Currency
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+// CashFlows compute the money that went in and out of every account, or only of filter.Account, in each
+// currency through the transactions matching the other fields of the filter, ordered by account
+func (transService *TransactionService) CashFlows(filter TransactionFilter) ([]CashFlow, error) {
+	account := filter.Account
+	filter.Account = ""
+	incomingDB := filter.apply(transService.db.Model(&Transaction{}))
+	outgoingDB := incomingDB
+	if account != "" {
+		incomingDB = incomingDB.Where("receiver = ?", account)
+		outgoingDB = outgoingDB.Where("sender = ?", account)
+	}
+
+	var incoming []CashFlow
+	err := incomingDB.Select("receiver AS account, COALESCE(NULLIF(received_currency, ''), currency) AS currency, " +
+		"SUM(CASE WHEN received_currency = '' THEN value ELSE received_value END) AS incoming, COUNT(*) AS count").
+		Group("receiver, COALESCE(NULLIF(received_currency, ''), currency)").
+		Scan(&incoming).Error
+	if err != nil {
+		return nil, err
+	}
+	var outgoing []CashFlow
+	err = outgoingDB.Select("sender AS account, currency, SUM(value) AS outgoing, COUNT(*) AS count").
+		Group("sender, currency").
+		Scan(&outgoing).Error
+	if err != nil {
+		return nil, err
+	}
+
+	flows := make(map[[2]string]*CashFlow)
+	for _, flow := range append(incoming, outgoing...) {
+		key := [2]string{flow.Account, flow.Currency}
+		if _, isOK := flows[key]; !isOK {
+			flows[key] = &CashFlow{Account: flow.Account, Currency: flow.Currency}
+		}
+		flows[key].Incoming += flow.Incoming
+		flows[key].Outgoing += flow.Outgoing
+		flows[key].Count += flow.Count
+	}
+	result := make([]CashFlow, 0, len(flows))
+	for _, flow := range flows {
+		flow.Incoming = RoundAmount(flow.Incoming, flow.Currency)
+		flow.Outgoing = RoundAmount(flow.Outgoing, flow.Currency)
+		flow.Net = RoundAmount(flow.Incoming-flow.Outgoing, flow.Currency)
+		result = append(result, *flow)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Account != result[j].Account {
+			return result[i].Account < result[j].Account
+		}
+		return result[i].Currency < result[j].Currency
+	})
+	return result, nil
+}
+
+// ValuePercentiles describe the distribution of the values of the transactions matching the filter in
+// each currency, with the percentiles of the ranks, DefaultPercentiles when none is provided. Percentiles
+// are interpolated between the closest values.
+func (transService *TransactionService) ValuePercentiles(filter TransactionFilter, ranks []float64) ([]ValueDistribution, error) {
+	if len(ranks) == 0 {
+		ranks = DefaultPercentiles
+	}
+	columns := []string{"currency", "COUNT(*)", "MIN(value)", "MAX(value)", "AVG(value)"}
+	for _, rank := range ranks {
+		if rank < 0 || rank > 1 {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPercentile, rank)
+		}
+		// The ranks are validated numbers, formatting them into the query can not inject anything
+		columns = append(columns, "percentile_cont("+strconv.FormatFloat(rank, 'f', -1, 64)+") WITHIN GROUP (ORDER BY value)")
+	}
+	rows, err := filter.apply(transService.db.Model(&Transaction{})).
+		Select(strings.Join(columns, ", ")).
+		Group("currency").
+		Order("currency").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var distributions []ValueDistribution
+	for rows.Next() {
+		var distribution ValueDistribution
+		values := make([]float64, len(ranks))
+		dest := []interface{}{&distribution.Currency, &distribution.Count, &distribution.Min, &distribution.Max, &distribution.Average}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		distribution.Average = RoundAmount(distribution.Average, distribution.Currency)
+		for i, rank := range ranks {
+			distribution.Percentiles = append(distribution.Percentiles, Percentile{
+				Rank:  rank,
+				Value: RoundAmount(values[i], distribution.Currency),
+			})
+		}
+		distributions = append(distributions, distribution)
+	}
+	return distributions, rows.Err()
+}
@@ -0,0 +1,544 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Statuses of a TransactionApproval
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+)
+
+// Decisions of an approver on a TransactionApproval
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+var (
+	// ErrInvalidPolicy is returned when an approval policy has no name or condition, or an invalid role
+	ErrInvalidPolicy = errors.New("models: invalid approval policy")
+
+	// ErrApprovalResolved is returned when a decision is made on an approval that was already approved or rejected
+	ErrApprovalResolved = errors.New("models: the transaction was already approved or rejected")
+
+	// ErrSelfApproval is returned when the creator of a transaction decides on its own approval
+	ErrSelfApproval = errors.New("models: the creator of a transaction can not approve or reject it")
+
+	// ErrAlreadyDecided is returned when an approver decides twice on the same approval
+	ErrAlreadyDecided = errors.New("models: you already decided on this transaction")
+
+	// ErrNotApprover is returned when the approver does not have the role the approval requires
+	ErrNotApprover = errors.New("models: you do not have the role required to approve this transaction")
+
+	// ErrApprovalRequired is returned when a transaction meeting approval policies is created without being
+	// submitted, or an update would let it skip its approval
+	ErrApprovalRequired = errors.New("models: the transaction needs approval, submit it to queue it for one")
+)
+
+// ApprovalPolicy require transactions meeting every one of its conditions to be approved before they are
+// created, an empty condition is met by every transaction. MinValue is inclusive and in Currency, values in
+// other currencies are converted with the current rate, and compared as they are when Currency is empty.
+// Counterparty matches the sender or the receiver. CreatorRoles is a comma separated list of the roles of
+// the users whose transactions need approval, anonymous creators only meet an empty list.
+// RequiredApprovals approvers having ApproverRole, or any role when it is empty, must approve.
+type ApprovalPolicy struct {
+	ID                uint `gorm:"primaryKey"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Name              string   `json:"name" gorm:"not null"`
+	MinValue          *float64 `json:"minValue,omitempty"`
+	Currency          string   `json:"currency,omitempty" gorm:"not null;default:''"`
+	Counterparty      string   `json:"counterparty,omitempty" gorm:"not null;default:''"`
+	CreatorRoles      string   `json:"creatorRoles,omitempty" gorm:"not null;default:''"`
+	ApproverRole      string   `json:"approverRole,omitempty" gorm:"not null;default:''"`
+	RequiredApprovals int      `json:"requiredApprovals" gorm:"not null;default:1"`
+	Enabled           bool     `json:"enabled" gorm:"not null"`
+}
+
+// TransactionApproval is a transaction awaiting approval because it met the approval Policies, the fields
+// of the transaction are kept until it is approved and created as TransactionID. It is approved once
+// RequiredApprovals approvers other than its creator approved it, and rejected as soon as one rejects it.
+// Approvers must have ApproverRole when it is set, admins can always decide.
+type TransactionApproval struct {
+	ID                uint `gorm:"primaryKey"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	CreatedBy         string     `json:"createdBy" gorm:"not null;index"`
+	Status            string     `json:"status" gorm:"not null;default:'pending';index"`
+	Policies          string     `json:"policies" gorm:"not null"`
+	ApproverRole      string     `json:"approverRole,omitempty" gorm:"not null;default:''"`
+	RequiredApprovals int        `json:"requiredApprovals" gorm:"not null"`
+	Approvals         int        `json:"approvals" gorm:"not null;default:0"`
+	Value             float64    `json:"value" gorm:"not null"`
+	Note              string     `json:"note,omitempty"`
+	Sender            string     `json:"sender" gorm:"not null;index"`
+	Receiver          string     `json:"receiver" gorm:"not null;index"`
+	Currency          string     `json:"currency,omitempty" gorm:"not null;default:''"`
+	ReceivedCurrency  string     `json:"receivedCurrency,omitempty" gorm:"not null;default:''"`
+	ResolvedAt        *time.Time `json:"resolvedAt,omitempty"`
+	TransactionID     *uint      `json:"transactionID,omitempty"`
+
+	Decisions []ApprovalDecision `json:"decisions,omitempty" gorm:"-"`
+}
+
+// ApprovalDecision is the decision of an approver on a TransactionApproval
+type ApprovalDecision struct {
+	ID         uint `gorm:"primaryKey"`
+	CreatedAt  time.Time
+	ApprovalID uint   `json:"approvalID" gorm:"not null;unique_index:idx_approval_decision"`
+	Approver   string `json:"approver" gorm:"not null;unique_index:idx_approval_decision"`
+	Decision   string `json:"decision" gorm:"not null"`
+	Comment    string `json:"comment,omitempty" gorm:"not null;default:''"`
+}
+
+type ApprovalService struct {
+	db    *gorm.DB
+	actor string
+}
+
+// NewApprovalService Create a new ApprovalService with a specified connectionInfo.
+func NewApprovalService(db *gorm.DB) (*ApprovalService, error) {
+	return &ApprovalService{
+		db: db,
+	}, nil
+}
+
+// WithActor return a copy of the service deciding on approvals as the provided actor, who is also recorded
+// in the audit log
+func (approvalService *ApprovalService) WithActor(actor string) *ApprovalService {
+	return &ApprovalService{
+		db:    approvalService.db,
+		actor: actor,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the approval policies and approvals tables
+func (approvalService *ApprovalService) AutoMigrate() error {
+	return approvalService.db.AutoMigrate(&ApprovalPolicy{}, &TransactionApproval{}, &ApprovalDecision{}).Error
+}
+
+// ReadPolicy will look up an approval policy with the provided ID
+func (approvalService *ApprovalService) ReadPolicy(id uint) (*ApprovalPolicy, error) {
+	var policy ApprovalPolicy
+	if err := first(approvalService.db.Where("id = ?", id), &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ReadPolicies return every approval policy ordered by ID
+func (approvalService *ApprovalService) ReadPolicies() ([]ApprovalPolicy, error) {
+	var policies []ApprovalPolicy
+	if err := approvalService.db.Order("id").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// SavePolicy validate and create the approval policy, or update it when it has an ID. Changes only apply
+// to the transactions created afterwards.
+func (approvalService *ApprovalService) SavePolicy(policy *ApprovalPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	if policy.ID != 0 {
+		if _, err := approvalService.ReadPolicy(policy.ID); err != nil {
+			return err
+		}
+	}
+	return approvalService.db.Save(policy).Error
+}
+
+// DeletePolicy delete the approval policy with the provided ID, the transactions awaiting approval because
+// of it still need to be approved
+func (approvalService *ApprovalService) DeletePolicy(id uint) error {
+	if id == 0 { // Go default uint is 0, Gorm will delete all rows if id is not provided
+		return ErrInvalidID
+	}
+	if _, err := approvalService.ReadPolicy(id); err != nil {
+		return err
+	}
+	return approvalService.db.Delete(ApprovalPolicy{ID: id}).Error
+}
+
+// ReadByID will look up an approval with the provided ID along with its decisions
+func (approvalService *ApprovalService) ReadByID(id uint) (*TransactionApproval, error) {
+	var approval TransactionApproval
+	if err := first(approvalService.db.Where("id = ?", id), &approval); err != nil {
+		return nil, err
+	}
+	if err := approvalService.db.Where("approval_id = ?", id).Order("id").Find(&approval.Decisions).Error; err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// ReadApprovals return the approvals with the provided status, or with any status if it is empty, created
+// by creator unless it is empty, oldest first and without their decisions
+func (approvalService *ApprovalService) ReadApprovals(status, creator string) ([]TransactionApproval, error) {
+	db := approvalService.db
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	if creator != "" {
+		db = db.Where("created_by = ?", creator)
+	}
+	var approvals []TransactionApproval
+	if err := db.Order("id").Find(&approvals).Error; err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+// Approve record the approval of the actor of the service, the transaction is created once enough
+// approvers approved it
+func (approvalService *ApprovalService) Approve(id uint, comment string) (*TransactionApproval, error) {
+	return approvalService.decide(id, DecisionApprove, comment)
+}
+
+// Reject record the rejection of the actor of the service, the transaction is never created
+func (approvalService *ApprovalService) Reject(id uint, comment string) (*TransactionApproval, error) {
+	return approvalService.decide(id, DecisionReject, comment)
+}
+
+// decide record the decision of the actor of the service on the approval with the provided ID and record
+// it in the audit log. The transaction is created by its creator when the approval completes.
+func (approvalService *ApprovalService) decide(id uint, decision, comment string) (*TransactionApproval, error) {
+	var approval TransactionApproval
+	err := approvalService.db.Transaction(func(tx *gorm.DB) error {
+		if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id), &approval); err != nil {
+			return err
+		}
+		if approval.Status != ApprovalPending {
+			return ErrApprovalResolved
+		}
+		approver := approvalService.actor
+		if strings.EqualFold(approver, approval.CreatedBy) {
+			return ErrSelfApproval
+		}
+		var user User
+		if err := first(tx.Where("email = ?", approver), &user); err == ErrNotFound {
+			return ErrNotApprover
+		} else if err != nil {
+			return err
+		}
+		if approval.ApproverRole != "" && user.Role != approval.ApproverRole && user.Role != RoleAdmin {
+			return ErrNotApprover
+		}
+		var decided int
+		if err := tx.Model(&ApprovalDecision{}).Where("approval_id = ? AND approver = ?", id, approver).Count(&decided).Error; err != nil {
+			return err
+		}
+		if decided > 0 {
+			return ErrAlreadyDecided
+		}
+		if err := tx.Create(&ApprovalDecision{
+			ApprovalID: id,
+			Approver:   approver,
+			Decision:   decision,
+			Comment:    strings.TrimSpace(comment),
+		}).Error; err != nil {
+			return err
+		}
+
+		before := approval
+		now := gorm.NowFunc()
+		operation := AuditReject
+		if decision == DecisionApprove {
+			operation = AuditUpdate
+			approval.Approvals++
+			if approval.Approvals >= approval.RequiredApprovals {
+				operation = AuditConfirm
+				transaction := approval.transaction()
+				// The transaction was scored when it was submitted and is now approved
+				txService := &TransactionService{db: tx, actor: approval.CreatedBy, submitted: true}
+				if err := txService.Create(&transaction); err != nil {
+					return err
+				}
+				approval.Status = ApprovalApproved
+				approval.TransactionID = &transaction.ID
+				approval.ResolvedAt = &now
+			}
+		} else {
+			approval.Status = ApprovalRejected
+			approval.ResolvedAt = &now
+		}
+		if err := tx.Save(&approval).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("approval_id = ?", id).Order("id").Find(&approval.Decisions).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, approvalService.actor, operation, AuditEntityApproval, approval.ID, before, approval)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// Submit score the risk of the transaction then create it, or hold it for approval when it meets enabled
+// approval policies. The approval is returned in that case and the transaction is only created once it is
+// approved. Risk is set to the assessment of the transaction, which is not created either when it is held
+// for review, and ErrTransactionBlocked is returned when it is blocked.
+func (transService *TransactionService) Submit(transaction *Transaction) (*TransactionApproval, error) {
+	if err := transaction.Validate(); err != nil {
+		return nil, err
+	}
+	var approval *TransactionApproval
+	var assessment *RiskAssessment
+	err := transService.db.Transaction(func(tx *gorm.DB) error {
+		creator := transService.actor
+		if creator == "" {
+			creator = SystemActor
+		}
+		var err error
+		if assessment, err = assessRisk(tx, transaction, creator); err != nil {
+			return err
+		}
+		if assessment.Status == RiskAllowed {
+			txService := &TransactionService{db: tx, actor: transService.actor, submitted: true}
+			if approval, err = txService.submit(transaction, creator); err != nil {
+				return err
+			}
+			assessment.link(transaction, approval)
+		}
+		return assessment.store(tx, transService.actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	transaction.Risk = assessment
+	if assessment.Status == RiskBlocked {
+		return nil, fmt.Errorf("%w: risk assessment %d scored %d (%s)", ErrTransactionBlocked, assessment.ID, assessment.Score, assessment.Rules)
+	}
+	return approval, nil
+}
+
+// submit create the transaction, or hold it for approval when it meets enabled approval policies, the
+// service must run in a database transaction
+func (transService *TransactionService) submit(transaction *Transaction, creator string) (*TransactionApproval, error) {
+	tx := transService.db
+	policies, err := matchPolicies(tx, transaction, creator)
+	if err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, transService.Create(transaction)
+	}
+
+	approval := &TransactionApproval{
+		CreatedBy:        creator,
+		Status:           ApprovalPending,
+		Value:            transaction.Value,
+		Note:             transaction.Note,
+		Sender:           transaction.Sender,
+		Receiver:         transaction.Receiver,
+		Currency:         transaction.Currency,
+		ReceivedCurrency: transaction.ReceivedCurrency,
+	}
+	names := make([]string, len(policies))
+	for i, policy := range policies {
+		names[i] = policy.Name
+		if policy.RequiredApprovals > approval.RequiredApprovals {
+			approval.RequiredApprovals = policy.RequiredApprovals
+		}
+		switch {
+		case policy.ApproverRole == "" || policy.ApproverRole == approval.ApproverRole:
+		case approval.ApproverRole == "":
+			approval.ApproverRole = policy.ApproverRole
+		default:
+			// Policies asking for different roles can only be satisfied by admins
+			approval.ApproverRole = RoleAdmin
+		}
+	}
+	approval.Policies = strings.Join(names, ", ")
+	if err := tx.Create(approval).Error; err != nil {
+		return nil, err
+	}
+	return approval, recordAudit(tx, transService.actor, AuditCreate, AuditEntityApproval, approval.ID, nil, approval)
+}
+
+// checkApprovalPolicies refuse with ErrApprovalRequired an update raising the value of the transaction, or
+// changing its parties or currency, when the updated transaction meets enabled approval policies for the
+// editor. Lowering the value or editing the note never needs approval.
+func checkApprovalPolicies(tx *gorm.DB, transaction, before *Transaction, editor string) error {
+	if transaction.Value <= before.Value && transaction.Sender == before.Sender &&
+		transaction.Receiver == before.Receiver && transaction.Currency == before.Currency {
+		return nil
+	}
+	if editor == "" {
+		editor = SystemActor
+	}
+	policies, err := matchPolicies(tx, transaction, editor)
+	if err != nil || len(policies) == 0 {
+		return err
+	}
+	return approvalRequired(policies)
+}
+
+// requireApproval refuse with ErrApprovalRequired a transaction created by the actor of the service that
+// meets enabled approval policies without going through Submit, so no creation path skips the approval
+func (transService *TransactionService) requireApproval(tx *gorm.DB, transaction *Transaction) error {
+	if transService.submitted {
+		return nil
+	}
+	creator := transService.actor
+	if creator == "" {
+		creator = SystemActor
+	}
+	policies, err := matchPolicies(tx, transaction, creator)
+	if err != nil || len(policies) == 0 {
+		return err
+	}
+	return approvalRequired(policies)
+}
+
+// approvalRequired return ErrApprovalRequired naming the policies the transaction meets
+func approvalRequired(policies []ApprovalPolicy) error {
+	names := make([]string, len(policies))
+	for i, policy := range policies {
+		names[i] = policy.Name
+	}
+	return fmt.Errorf("%w: it meets %s", ErrApprovalRequired, strings.Join(names, ", "))
+}
+
+// transaction return the transaction the approval holds
+func (approval *TransactionApproval) transaction() Transaction {
+	return Transaction{
+		Value:            approval.Value,
+		Note:             approval.Note,
+		Sender:           approval.Sender,
+		Receiver:         approval.Receiver,
+		Currency:         approval.Currency,
+		ReceivedCurrency: approval.ReceivedCurrency,
+	}
+}
+
+// matchPolicies return the enabled approval policies the transaction created by creator meets, ordered by ID
+func matchPolicies(tx *gorm.DB, transaction *Transaction, creator string) ([]ApprovalPolicy, error) {
+	var policies []ApprovalPolicy
+	if err := tx.Where("enabled = ?", true).Order("id").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	role := ""
+	var user User
+	if err := first(tx.Where("email = ?", creator), &user); err == nil {
+		role = user.Role
+	} else if err != ErrNotFound {
+		return nil, err
+	}
+	currency := transaction.Currency
+	if currency == "" {
+		currency = accountCurrency(tx, transaction.Sender)
+	}
+	currency, _ = NormalizeCurrency(currency)
+
+	var matched []ApprovalPolicy
+	for _, policy := range policies {
+		isMatch, err := policy.matches(tx, transaction, currency, role)
+		if err != nil {
+			return nil, err
+		}
+		if isMatch {
+			matched = append(matched, policy)
+		}
+	}
+	return matched, nil
+}
+
+// matches report whether the transaction in currency created by a user with the role meets the policy. A
+// value that can not be converted into the currency of the policy is considered above its threshold.
+func (policy *ApprovalPolicy) matches(tx *gorm.DB, transaction *Transaction, currency, role string) (bool, error) {
+	if policy.Counterparty != "" &&
+		!strings.EqualFold(policy.Counterparty, strings.TrimSpace(transaction.Sender)) &&
+		!strings.EqualFold(policy.Counterparty, strings.TrimSpace(transaction.Receiver)) {
+		return false, nil
+	}
+	if policy.CreatorRoles != "" && !containsRole(policy.CreatorRoles, role) {
+		return false, nil
+	}
+	if policy.MinValue != nil {
+		value := transaction.Value
+		if policy.Currency != "" && policy.Currency != currency {
+			rate, err := lookupRate(tx, currency, policy.Currency, gorm.NowFunc())
+			if err == ErrRateNotFound {
+				return true, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			value *= rate
+		}
+		if value < *policy.MinValue {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// validate check and normalize the fields of the policy
+func (policy *ApprovalPolicy) validate() error {
+	policy.Name = strings.TrimSpace(policy.Name)
+	policy.Counterparty = strings.TrimSpace(policy.Counterparty)
+	policy.ApproverRole = strings.TrimSpace(policy.ApproverRole)
+	if policy.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidPolicy)
+	}
+	if policy.MinValue == nil && policy.Counterparty == "" && strings.TrimSpace(policy.CreatorRoles) == "" {
+		return fmt.Errorf("%w: at least one condition is required", ErrInvalidPolicy)
+	}
+	if policy.MinValue != nil && (math.IsNaN(*policy.MinValue) || *policy.MinValue < 0) {
+		return fmt.Errorf("%w: the minimum value must not be negative", ErrInvalidPolicy)
+	}
+	if policy.Currency != "" {
+		var err error
+		if policy.Currency, err = NormalizeCurrency(policy.Currency); err != nil {
+			return err
+		}
+	}
+	var roles []string
+	for _, role := range strings.Split(policy.CreatorRoles, ",") {
+		if role = strings.TrimSpace(role); role == "" {
+			continue
+		}
+		if !isValidRole(role) {
+			return fmt.Errorf("%w: unknown role %q", ErrInvalidPolicy, role)
+		}
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	policy.CreatorRoles = strings.Join(roles, ",")
+	if policy.ApproverRole != "" && !isValidRole(policy.ApproverRole) {
+		return fmt.Errorf("%w: unknown role %q", ErrInvalidPolicy, policy.ApproverRole)
+	}
+	if policy.RequiredApprovals == 0 {
+		policy.RequiredApprovals = 1
+	}
+	if policy.RequiredApprovals < 0 {
+		return fmt.Errorf("%w: at least one approval is required", ErrInvalidPolicy)
+	}
+	return nil
+}
+
+// containsRole report whether the comma separated list of roles contains the role
+func containsRole(roles, role string) bool {
+	for _, candidate := range strings.Split(roles, ",") {
+		if candidate == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.AutoMigrate(&User{}, &Transaction{}, &ApprovalPolicy{}).Error; err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestCreateBatchRequiresApproval(t *testing.T) {
+	db := openTestDB(t)
+	minValue := 1000.0
+	policy := ApprovalPolicy{Name: "large payments", MinValue: &minValue, Currency: "USD", Enabled: true}
+	if err := db.Create(&policy).Error; err != nil {
+		t.Fatalf("create policy: %v", err)
+	}
+
+	// An imported batch does not go through Submit, the policy must still hold it back
+	transactions := []Transaction{
+		{Value: 5000, Sender: "alice@example.com", Receiver: "bob@example.com", Currency: "USD"},
+	}
+	transService, err := NewTransactionService(db)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	err = transService.WithActor("alice@example.com").CreateBatch(transactions)
+	if !errors.Is(err, ErrApprovalRequired) {
+		t.Fatalf("CreateBatch error = %v, want %v", err, ErrApprovalRequired)
+	}
+	var count int
+	if err := db.Model(&Transaction{}).Count(&count).Error; err != nil {
+		t.Fatalf("count transactions: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("%d transactions created, want none", count)
+	}
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// Operations recorded in the audit log
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+	AuditConfirm = "confirm"
+	AuditReject  = "reject"
+	AuditSettle  = "settle"
+	AuditExpire  = "expire"
+	AuditCapture = "capture"
+	AuditRelease = "release"
+)
+
+// Entity types recorded in the audit log
+const (
+	AuditEntityTransaction    = "transaction"
+	AuditEntityUser           = "user"
+	AuditEntityReconciliation = "reconciliation_match"
+	AuditEntitySplit          = "split_transaction"
+	AuditEntityGroup          = "expense_group"
+	AuditEntityGroupExpense   = "group_expense"
+	AuditEntityPaymentRequest = "payment_request"
+	AuditEntityHold           = "hold"
+	AuditEntityApproval       = "transaction_approval"
+	AuditEntityLimit          = "transaction_limit"
+	AuditEntityRisk           = "risk_assessment"
+	AuditEntityRiskSettings   = "risk_settings"
+	AuditEntitySchedule       = "scheduled_transaction"
+)
+
+// SystemActor is recorded as the actor of changes that were not made on behalf of a user,
+// for example changes made from the command line.
+const SystemActor = "system"
+
+// AuditEntry is a single append-only record of a change made to an entity.
+// Before and After hold JSON snapshots of the entity, Before is empty for a create and After is
+// empty for a delete.
+type AuditEntry struct {
+	ID         uint `gorm:"primaryKey"`
+	CreatedAt  time.Time
+	Actor      string `json:"actor" gorm:"not null"`
+	Operation  string `json:"operation" gorm:"not null"`
+	EntityType string `json:"entityType" gorm:"not null;index:idx_audit_entity"`
+	EntityID   uint   `json:"entityID" gorm:"not null;index:idx_audit_entity"`
+	Before     string `json:"before,omitempty" gorm:"type:text"`
+	After      string `json:"after,omitempty" gorm:"type:text"`
+}
+
+// AuditService only ever reads and appends to the audit log, entries are never updated or deleted.
+type AuditService struct {
+	db *gorm.DB
+}
+
+// NewAuditService Create a new AuditService with a specified connectionInfo.
+func NewAuditService(db *gorm.DB) (*AuditService, error) {
+	return &AuditService{
+		db: db,
+	}, nil
+}
+
+// AutoMigrate will attempt to automatically migrate the audit_entries table.
+// It also installs rules so that rows of the table can not be updated or deleted.
+func (auditService *AuditService) AutoMigrate() error {
+	if err := auditService.db.AutoMigrate(&AuditEntry{}).Error; err != nil {
+		return err
+	}
+	rules := []string{
+		"CREATE OR REPLACE RULE audit_entries_no_update AS ON UPDATE TO audit_entries DO INSTEAD NOTHING",
+		"CREATE OR REPLACE RULE audit_entries_no_delete AS ON DELETE TO audit_entries DO INSTEAD NOTHING",
+	}
+	for _, rule := range rules {
+		if err := auditService.db.Exec(rule).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadByEntity return every audit entry of the entity with the provided type and ID, oldest first.
+func (auditService *AuditService) ReadByEntity(entityType string, id uint) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	err := auditService.db.
+		Where("entity_type = ? AND entity_id = ?", entityType, id).
+		Order("id").
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// recordAudit append an entry to the audit log using the provided gorm.DB, which should be the
+// database transaction the change itself was made in. before and after are nil when they do not apply.
+func recordAudit(db *gorm.DB, actor, operation, entityType string, entityID uint, before, after interface{}) error {
+	if actor == "" {
+		actor = SystemActor
+	}
+	entry := AuditEntry{
+		Actor:      actor,
+		Operation:  operation,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+	if before != nil {
+		snapshot, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		entry.Before = string(snapshot)
+	}
+	if after != nil {
+		snapshot, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		entry.After = string(snapshot)
+	}
+	return db.Create(&entry).Error
+}
@@ -0,0 +1,430 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxTagLength is the longest tag, in characters, that can be put on a transaction
+const maxTagLength = 64
+
+var (
+	// ErrInvalidCategory is returned when a category has no name
+	ErrInvalidCategory = errors.New("models: category name is required")
+
+	// ErrCategoryInUse is returned when deleting a category that rules still assign
+	ErrCategoryInUse = errors.New("models: category is used by a categorization rule")
+
+	// ErrInvalidCategoryRule is returned when a categorization rule has no condition or an invalid one
+	ErrInvalidCategoryRule = errors.New("models: invalid categorization rule")
+
+	// ErrInvalidTag is returned when a tag is empty or too long
+	ErrInvalidTag = errors.New("models: invalid tag")
+)
+
+// Category classify transactions, a transaction has at most one category
+type Category struct {
+	ID          uint `gorm:"primaryKey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string `json:"name" gorm:"not null;unique_index"`
+	Description string `json:"description,omitempty"`
+}
+
+// TransactionTag is a label put on a transaction, a transaction has any number of distinct tags
+type TransactionTag struct {
+	ID            uint   `gorm:"primaryKey"`
+	TransactionID uint   `gorm:"not null;unique_index:idx_transaction_tag"`
+	Tag           string `gorm:"not null;unique_index:idx_transaction_tag;index"`
+}
+
+// CategoryRule assign its category to the transactions meeting every one of its conditions, an empty
+// condition is met by every transaction. Counterparty matches the sender or the receiver, NotePattern is
+// a regular expression searched in the note, MinValue and MaxValue are inclusive. Rules are tried by
+// ascending Priority, then ID, and the first matching one wins.
+type CategoryRule struct {
+	ID           uint `gorm:"primaryKey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         string   `json:"name"`
+	Priority     int      `json:"priority" gorm:"not null;default:0"`
+	CategoryID   uint     `json:"categoryID" gorm:"not null;index"`
+	Counterparty string   `json:"counterparty,omitempty" gorm:"not null;default:''"`
+	NotePattern  string   `json:"notePattern,omitempty" gorm:"not null;default:''"`
+	MinValue     *float64 `json:"minValue,omitempty"`
+	MaxValue     *float64 `json:"maxValue,omitempty"`
+	Enabled      bool     `json:"enabled" gorm:"not null"`
+}
+
+// compiledRule is a CategoryRule with its note pattern compiled
+type compiledRule struct {
+	CategoryRule
+	note *regexp.Regexp
+}
+
+type CategoryService struct {
+	db    *gorm.DB
+	actor string
+}
+
+// NewCategoryService Create a new CategoryService with a specified connectionInfo.
+func NewCategoryService(db *gorm.DB) (*CategoryService, error) {
+	return &CategoryService{
+		db: db,
+	}, nil
+}
+
+// WithActor return a copy of the service whose changes are recorded in the audit log under the provided actor
+func (categoryService *CategoryService) WithActor(actor string) *CategoryService {
+	return &CategoryService{
+		db:    categoryService.db,
+		actor: actor,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the categories, tags and rules tables
+func (categoryService *CategoryService) AutoMigrate() error {
+	return categoryService.db.AutoMigrate(&Category{}, &TransactionTag{}, &CategoryRule{}).Error
+}
+
+// ReadCategory will look up a category with the provided ID, ErrNotFound is returned if there is none
+func (categoryService *CategoryService) ReadCategory(id uint) (*Category, error) {
+	var category Category
+	if err := first(categoryService.db.Where("id = ?", id), &category); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// ReadCategories return every category ordered by name
+func (categoryService *CategoryService) ReadCategories() ([]Category, error) {
+	var categories []Category
+	if err := categoryService.db.Order("name").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// SaveCategory create the category when its ID is 0 and update it otherwise
+func (categoryService *CategoryService) SaveCategory(category *Category) error {
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		return ErrInvalidCategory
+	}
+	if category.ID != 0 {
+		if _, err := categoryService.ReadCategory(category.ID); err != nil {
+			return err
+		}
+	}
+	return categoryService.db.Save(category).Error
+}
+
+// DeleteCategory delete the category with the provided ID and remove it from its transactions. A category
+// still assigned by a rule can not be deleted, ErrCategoryInUse is returned.
+func (categoryService *CategoryService) DeleteCategory(id uint) error {
+	if id == 0 { // Go default uint is 0, Gorm will delete all rows if id is not provided
+		return ErrInvalidID
+	}
+	return categoryService.db.Transaction(func(tx *gorm.DB) error {
+		var category Category
+		if err := first(tx.Where("id = ?", id), &category); err != nil {
+			return err
+		}
+		var rules int
+		if err := tx.Model(&CategoryRule{}).Where("category_id = ?", id).Count(&rules).Error; err != nil {
+			return err
+		}
+		if rules > 0 {
+			return ErrCategoryInUse
+		}
+		err := tx.Unscoped().Model(&Transaction{}).Where("category_id = ?", id).UpdateColumn("category_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+}
+
+// SetCategory assign the category with the provided ID to the transaction, or remove its category when
+// categoryID is nil. The change is recorded in the audit log but not in the ledger, the category is not
+// part of the content of a transaction.
+func (categoryService *CategoryService) SetCategory(transactionID uint, categoryID *uint) (*Transaction, error) {
+	var transaction Transaction
+	err := categoryService.db.Transaction(func(tx *gorm.DB) error {
+		if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", transactionID), &transaction); err != nil {
+			return err
+		}
+		if categoryID != nil {
+			if err := first(tx.Where("id = ?", *categoryID), &Category{}); err != nil {
+				return err
+			}
+		}
+		before := transaction
+		transaction.CategoryID = categoryID
+		if err := tx.Model(&transaction).UpdateColumn("category_id", categoryID).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, categoryService.actor, AuditUpdate, AuditEntityTransaction, transaction.ID, before, transaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// ReadTags return the tags of the transaction in alphabetical order
+func (categoryService *CategoryService) ReadTags(transactionID uint) ([]string, error) {
+	var tags []string
+	err := categoryService.db.Model(&TransactionTag{}).Where("transaction_id = ?", transactionID).Order("tag").Pluck("tag", &tags).Error
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// SetTags replace the tags of the transaction. Tags are trimmed and lowercased, duplicates are dropped.
+// The new tags are returned in alphabetical order.
+func (categoryService *CategoryService) SetTags(transactionID uint, tags []string) ([]string, error) {
+	normalized, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	err = categoryService.db.Transaction(func(tx *gorm.DB) error {
+		var transaction Transaction
+		if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", transactionID), &transaction); err != nil {
+			return err
+		}
+		var before []string
+		if err := tx.Model(&TransactionTag{}).Where("transaction_id = ?", transactionID).Order("tag").Pluck("tag", &before).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("transaction_id = ?", transactionID).Delete(&TransactionTag{}).Error; err != nil {
+			return err
+		}
+		for _, tag := range normalized {
+			if err := tx.Create(&TransactionTag{TransactionID: transactionID, Tag: tag}).Error; err != nil {
+				return err
+			}
+		}
+		type tagged struct {
+			Tags []string `json:"tags"`
+		}
+		return recordAudit(tx, categoryService.actor, AuditUpdate, AuditEntityTransaction, transactionID,
+			tagged{Tags: before}, tagged{Tags: normalized})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// normalizeTags trim, lowercase, deduplicate and sort tags
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("%w: %q, tags must have between 1 and %d characters", ErrInvalidTag, tag, maxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// ReadRule will look up a categorization rule with the provided ID, ErrNotFound is returned if there is none
+func (categoryService *CategoryService) ReadRule(id uint) (*CategoryRule, error) {
+	var rule CategoryRule
+	if err := first(categoryService.db.Where("id = ?", id), &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ReadRules return every categorization rule in the order they are tried
+func (categoryService *CategoryService) ReadRules() ([]CategoryRule, error) {
+	var rules []CategoryRule
+	if err := categoryService.db.Order("priority, id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SaveRule validate the rule, then create it when its ID is 0 and update it otherwise. Saving a rule
+// does not change the transactions already created, see ApplyRules.
+func (categoryService *CategoryService) SaveRule(rule *CategoryRule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	if _, err := categoryService.ReadCategory(rule.CategoryID); err != nil {
+		return err
+	}
+	if rule.ID != 0 {
+		if _, err := categoryService.ReadRule(rule.ID); err != nil {
+			return err
+		}
+	}
+	return categoryService.db.Save(rule).Error
+}
+
+// DeleteRule delete the categorization rule with the provided ID, the transactions it categorized keep
+// their category
+func (categoryService *CategoryService) DeleteRule(id uint) error {
+	if id == 0 { // Go default uint is 0, Gorm will delete all rows if id is not provided
+		return ErrInvalidID
+	}
+	if _, err := categoryService.ReadRule(id); err != nil {
+		return err
+	}
+	return categoryService.db.Delete(CategoryRule{ID: id}).Error
+}
+
+// ApplyRules categorize again the transactions matching the filter with the enabled rules and return how
+// many changed category. Transactions that already have a category are only changed when overwrite is
+// true, and transactions no rule matches keep their category.
+func (categoryService *CategoryService) ApplyRules(filter TransactionFilter, overwrite bool) (int, error) {
+	rules, err := loadRules(categoryService.db)
+	if err != nil {
+		return 0, err
+	}
+	if len(rules) == 0 {
+		return 0, nil
+	}
+	db := filter.apply(categoryService.db.Model(&Transaction{}))
+	if !overwrite {
+		db = db.Where("category_id IS NULL")
+	}
+	var ids []uint
+	if err := db.Order("id").Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	for _, id := range ids {
+		err := categoryService.db.Transaction(func(tx *gorm.DB) error {
+			var transaction Transaction
+			if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id), &transaction); err != nil {
+				return err
+			}
+			rule := matchRule(rules, &transaction)
+			if rule == nil || (transaction.CategoryID != nil && *transaction.CategoryID == rule.CategoryID) {
+				return nil
+			}
+			before := transaction
+			transaction.CategoryID = &rule.CategoryID
+			if err := tx.Model(&transaction).UpdateColumn("category_id", rule.CategoryID).Error; err != nil {
+				return err
+			}
+			changed++
+			return recordAudit(tx, categoryService.actor, AuditUpdate, AuditEntityTransaction, transaction.ID, before, transaction)
+		})
+		if err == ErrNotFound {
+			// Deleted since the IDs were read
+			continue
+		}
+		if err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// categorize set the category of a new transaction that has none from the first enabled rule it matches
+func categorize(tx *gorm.DB, transaction *Transaction) error {
+	if transaction.CategoryID != nil {
+		return nil
+	}
+	rules, err := loadRules(tx)
+	if err != nil {
+		return err
+	}
+	if rule := matchRule(rules, transaction); rule != nil {
+		categoryID := rule.CategoryID
+		transaction.CategoryID = &categoryID
+	}
+	return nil
+}
+
+// loadRules read the enabled rules in the order they are tried and compile their note patterns
+func loadRules(db *gorm.DB) ([]compiledRule, error) {
+	var rules []CategoryRule
+	if err := db.Where("enabled = ?", true).Order("priority, id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		note, err := rule.compile()
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, compiledRule{CategoryRule: rule, note: note})
+	}
+	return compiled, nil
+}
+
+// matchRule return the first rule the transaction meets, nil if it meets none
+func matchRule(rules []compiledRule, transaction *Transaction) *compiledRule {
+	for i := range rules {
+		if rules[i].matches(transaction) {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// matches report whether the transaction meets every condition of the rule
+func (rule *compiledRule) matches(transaction *Transaction) bool {
+	if rule.Counterparty != "" &&
+		!strings.EqualFold(rule.Counterparty, transaction.Sender) && !strings.EqualFold(rule.Counterparty, transaction.Receiver) {
+		return false
+	}
+	if rule.note != nil && !rule.note.MatchString(transaction.Note) {
+		return false
+	}
+	if rule.MinValue != nil && transaction.Value < *rule.MinValue {
+		return false
+	}
+	if rule.MaxValue != nil && transaction.Value > *rule.MaxValue {
+		return false
+	}
+	return true
+}
+
+// validate check that the rule has a category and at least one condition, that its note pattern compiles
+// and that its value range is not empty
+func (rule *CategoryRule) validate() error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Counterparty = strings.TrimSpace(rule.Counterparty)
+	if rule.CategoryID == 0 {
+		return fmt.Errorf("%w: a category is required", ErrInvalidCategoryRule)
+	}
+	if rule.Counterparty == "" && rule.NotePattern == "" && rule.MinValue == nil && rule.MaxValue == nil {
+		return fmt.Errorf("%w: at least one condition is required", ErrInvalidCategoryRule)
+	}
+	if rule.MinValue != nil && rule.MaxValue != nil && *rule.MinValue > *rule.MaxValue {
+		return fmt.Errorf("%w: the minimum value is above the maximum value", ErrInvalidCategoryRule)
+	}
+	_, err := rule.compile()
+	return err
+}
+
+// compile compile the note pattern of the rule, nil is returned when it has none
+func (rule *CategoryRule) compile() (*regexp.Regexp, error) {
+	if rule.NotePattern == "" {
+		return nil, nil
+	}
+	note, err := regexp.Compile(rule.NotePattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCategoryRule, err)
+	}
+	return note, nil
+}
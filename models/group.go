@@ -0,0 +1,642 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Methods splitting a group expense between its participants
+const (
+	SplitEqual       = "equal"
+	SplitExact       = "exact"
+	SplitShares      = "shares"
+	SplitPercentages = "percentages"
+)
+
+// maxExactSettlement is the largest number of members with a balance for which SimplifyDebts finds the
+// fewest transfers, larger groups are settled greedily
+const maxExactSettlement = 16
+
+var (
+	// ErrInvalidGroup is returned when a group has no name or a member is not a user
+	ErrInvalidGroup = errors.New("models: invalid expense group")
+
+	// ErrInvalidExpense is returned when an expense is not positive or its split does not add up
+	ErrInvalidExpense = errors.New("models: invalid group expense")
+
+	// ErrNotGroupMember is returned when someone who is not a member of a group takes part in one of its expenses
+	ErrNotGroupMember = errors.New("models: not a member of the group")
+
+	// ErrUnsettledMember is returned when removing a member who still owes or is owed money
+	ErrUnsettledMember = errors.New("models: member must settle up before leaving the group")
+)
+
+// ExpenseGroup is a set of users sharing bills, all the expenses of a group are in its Currency
+type ExpenseGroup struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string        `json:"name" gorm:"not null"`
+	Currency  string        `json:"currency" gorm:"not null"`
+	CreatedBy string        `json:"createdBy" gorm:"not null"`
+	Members   []GroupMember `json:"members,omitempty" gorm:"-"`
+}
+
+// GroupMember is a user belonging to an expense group, identified by email
+type GroupMember struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	GroupID   uint   `json:"groupID" gorm:"not null;unique_index:idx_group_member"`
+	Email     string `json:"email" gorm:"not null;unique_index:idx_group_member;index"`
+}
+
+// GroupExpense is a bill PaidBy a member and shared between members according to Method, Shares holding
+// what each participant owes
+type GroupExpense struct {
+	ID          uint `gorm:"primaryKey"`
+	CreatedAt   time.Time
+	GroupID     uint           `json:"groupID" gorm:"not null;index"`
+	PaidBy      string         `json:"paidBy" gorm:"not null"`
+	Amount      float64        `json:"amount" gorm:"not null"`
+	Description string         `json:"description,omitempty"`
+	Method      string         `json:"method" gorm:"not null"`
+	Shares      []ExpenseShare `json:"shares,omitempty" gorm:"-"`
+}
+
+// ExpenseShare is the amount a member owes for an expense
+type ExpenseShare struct {
+	ID        uint    `gorm:"primaryKey"`
+	ExpenseID uint    `json:"expenseID" gorm:"not null;index"`
+	GroupID   uint    `json:"groupID" gorm:"not null;index"`
+	Member    string  `json:"member" gorm:"not null"`
+	Amount    float64 `json:"amount" gorm:"not null"`
+}
+
+// ExpenseParticipant is a member taking part in an expense and its Value, whose meaning depends on the
+// split method: ignored for equal, the amount owed for exact, a weight for shares and a percentage of the
+// expense for percentages
+type ExpenseParticipant struct {
+	Member string
+	Value  float64
+}
+
+// GroupSettlement is a transfer recorded by SettleUp, paid with the transaction TransactionID
+type GroupSettlement struct {
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	GroupID       uint    `json:"groupID" gorm:"not null;index"`
+	From          string  `json:"from" gorm:"column:from_member;not null"`
+	To            string  `json:"to" gorm:"column:to_member;not null"`
+	Amount        float64 `json:"amount" gorm:"not null"`
+	TransactionID uint    `json:"transactionID" gorm:"not null"`
+}
+
+// MemberBalance is where a member stands in a group: Paid for expenses, Owed for its shares and Settled by
+// settle-up transfers, sent minus received. Net is positive when the member is owed money.
+type MemberBalance struct {
+	Member  string
+	Paid    float64
+	Owed    float64
+	Settled float64
+	Net     float64
+}
+
+// DebtTransfer is a payment From a member To another that settles debts of a group
+type DebtTransfer struct {
+	From   string
+	To     string
+	Amount float64
+}
+
+type GroupService struct {
+	db    *gorm.DB
+	actor string
+}
+
+// NewGroupService Create a new GroupService with a specified connectionInfo.
+func NewGroupService(db *gorm.DB) (*GroupService, error) {
+	return &GroupService{
+		db: db,
+	}, nil
+}
+
+// WithActor return a copy of the service whose changes are recorded in the audit log under the provided actor
+func (groupService *GroupService) WithActor(actor string) *GroupService {
+	return &GroupService{
+		db:    groupService.db,
+		actor: actor,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the expense groups tables
+func (groupService *GroupService) AutoMigrate() error {
+	return groupService.db.AutoMigrate(&ExpenseGroup{}, &GroupMember{}, &GroupExpense{}, &ExpenseShare{}, &GroupSettlement{}).Error
+}
+
+// Create create the group with its members, the creator being a member too. The currency of the group
+// defaults to the currency of the creator account.
+func (groupService *GroupService) Create(group *ExpenseGroup, members []string) error {
+	group.Name = strings.TrimSpace(group.Name)
+	if group.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidGroup)
+	}
+	return groupService.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if group.Currency == "" {
+			group.Currency = accountCurrency(tx, group.CreatedBy)
+		}
+		if group.Currency, err = NormalizeCurrency(group.Currency); err != nil {
+			return err
+		}
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		seen := make(map[string]bool)
+		for _, email := range append([]string{group.CreatedBy}, members...) {
+			email = strings.TrimSpace(email)
+			if seen[email] {
+				continue
+			}
+			seen[email] = true
+			member, err := addMember(tx, group.ID, email)
+			if err != nil {
+				return err
+			}
+			group.Members = append(group.Members, *member)
+		}
+		return recordAudit(tx, groupService.actor, AuditCreate, AuditEntityGroup, group.ID, nil, group)
+	})
+}
+
+// ReadByID will look up a group with the provided ID along with its members
+func (groupService *GroupService) ReadByID(id uint) (*ExpenseGroup, error) {
+	var group ExpenseGroup
+	if err := first(groupService.db.Where("id = ?", id), &group); err != nil {
+		return nil, err
+	}
+	if err := groupService.db.Where("group_id = ?", id).Order("id").Find(&group.Members).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// ReadForMember return the groups the user with the provided email belongs to, without their members
+func (groupService *GroupService) ReadForMember(email string) ([]ExpenseGroup, error) {
+	var groups []ExpenseGroup
+	err := groupService.db.Where("id IN (SELECT group_id FROM group_members WHERE email = ?)", email).Order("id").Find(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// IsMember report whether the user with the provided email belongs to the group
+func (groupService *GroupService) IsMember(groupID uint, email string) (bool, error) {
+	var count int
+	err := groupService.db.Model(&GroupMember{}).Where("group_id = ? AND email = ?", groupID, email).Count(&count).Error
+	return count > 0, err
+}
+
+// AddMember add the user with the provided email to the group
+func (groupService *GroupService) AddMember(groupID uint, email string) (*GroupMember, error) {
+	var member *GroupMember
+	err := groupService.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockGroup(tx, groupID); err != nil {
+			return err
+		}
+		var err error
+		if member, err = addMember(tx, groupID, strings.TrimSpace(email)); err != nil {
+			return err
+		}
+		return recordAudit(tx, groupService.actor, AuditUpdate, AuditEntityGroup, groupID, nil, member)
+	})
+	return member, err
+}
+
+// RemoveMember remove the user with the provided email from the group, members who still owe or are
+// owed money can not leave, ErrUnsettledMember is returned
+func (groupService *GroupService) RemoveMember(groupID uint, email string) error {
+	return groupService.db.Transaction(func(tx *gorm.DB) error {
+		group, err := lockGroup(tx, groupID)
+		if err != nil {
+			return err
+		}
+		var member GroupMember
+		if err := first(tx.Where("group_id = ? AND email = ?", groupID, email), &member); err != nil {
+			return err
+		}
+		balances, err := groupBalances(tx, group)
+		if err != nil {
+			return err
+		}
+		for _, balance := range balances {
+			if balance.Member == email && toUnits(balance.Net, group.Currency) != 0 {
+				return ErrUnsettledMember
+			}
+		}
+		if err := tx.Delete(&member).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, groupService.actor, AuditUpdate, AuditEntityGroup, groupID, member, nil)
+	})
+}
+
+// addMember add a user to a group, ErrInvalidGroup is returned if there is no such user
+func addMember(tx *gorm.DB, groupID uint, email string) (*GroupMember, error) {
+	if err := first(tx.Where("email = ?", email), &User{}); err == ErrNotFound {
+		return nil, fmt.Errorf("%w: %q is not a user", ErrInvalidGroup, email)
+	} else if err != nil {
+		return nil, err
+	}
+	member := &GroupMember{GroupID: groupID, Email: email}
+	return member, tx.Create(member).Error
+}
+
+// lockGroup read the group with the provided ID and lock it until the end of the database transaction, so
+// its balances do not change while they are used
+func lockGroup(tx *gorm.DB, id uint) (*ExpenseGroup, error) {
+	var group ExpenseGroup
+	if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id), &group); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// AddExpense split the expense between the participants with its method and record it. The payer and
+// every participant must be members of the group, an equal split between every member is used when
+// there is no participant.
+func (groupService *GroupService) AddExpense(expense *GroupExpense, participants []ExpenseParticipant) error {
+	if math.IsNaN(expense.Amount) || math.IsInf(expense.Amount, 0) || expense.Amount <= 0 {
+		return fmt.Errorf("%w: amount must be a positive number", ErrInvalidExpense)
+	}
+	if expense.Method == "" {
+		expense.Method = SplitEqual
+	}
+	return groupService.db.Transaction(func(tx *gorm.DB) error {
+		group, err := lockGroup(tx, expense.GroupID)
+		if err != nil {
+			return err
+		}
+		var members []string
+		if err := tx.Model(&GroupMember{}).Where("group_id = ?", group.ID).Order("id").Pluck("email", &members).Error; err != nil {
+			return err
+		}
+		isMember := make(map[string]bool, len(members))
+		for _, member := range members {
+			isMember[member] = true
+		}
+		if !isMember[expense.PaidBy] {
+			return fmt.Errorf("%w: %s paid the expense", ErrNotGroupMember, expense.PaidBy)
+		}
+		if len(participants) == 0 && expense.Method == SplitEqual {
+			for _, member := range members {
+				participants = append(participants, ExpenseParticipant{Member: member})
+			}
+		}
+		for _, participant := range participants {
+			if !isMember[participant.Member] {
+				return fmt.Errorf("%w: %s takes part in the expense", ErrNotGroupMember, participant.Member)
+			}
+		}
+
+		expense.Amount = RoundAmount(expense.Amount, group.Currency)
+		shares, err := splitExpense(expense.Amount, group.Currency, expense.Method, participants)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(expense).Error; err != nil {
+			return err
+		}
+		expense.Shares = nil
+		for i, participant := range participants {
+			if shares[i] == 0 {
+				continue
+			}
+			share := ExpenseShare{
+				ExpenseID: expense.ID,
+				GroupID:   expense.GroupID,
+				Member:    participant.Member,
+				Amount:    fromUnits(shares[i], group.Currency),
+			}
+			if err := tx.Create(&share).Error; err != nil {
+				return err
+			}
+			expense.Shares = append(expense.Shares, share)
+		}
+		return recordAudit(tx, groupService.actor, AuditCreate, AuditEntityGroupExpense, expense.ID, nil, expense)
+	})
+}
+
+// ReadExpenses return the expenses of the group with their shares, newest first
+func (groupService *GroupService) ReadExpenses(groupID uint) ([]GroupExpense, error) {
+	var expenses []GroupExpense
+	if err := groupService.db.Where("group_id = ?", groupID).Order("id DESC").Find(&expenses).Error; err != nil {
+		return nil, err
+	}
+	var shares []ExpenseShare
+	if err := groupService.db.Where("group_id = ?", groupID).Order("id").Find(&shares).Error; err != nil {
+		return nil, err
+	}
+	byExpense := make(map[uint][]ExpenseShare)
+	for _, share := range shares {
+		byExpense[share.ExpenseID] = append(byExpense[share.ExpenseID], share)
+	}
+	for i := range expenses {
+		expenses[i].Shares = byExpense[expenses[i].ID]
+	}
+	return expenses, nil
+}
+
+// ReadSettlements return the settle-up transfers of the group, newest first
+func (groupService *GroupService) ReadSettlements(groupID uint) ([]GroupSettlement, error) {
+	var settlements []GroupSettlement
+	if err := groupService.db.Where("group_id = ?", groupID).Order("id DESC").Find(&settlements).Error; err != nil {
+		return nil, err
+	}
+	return settlements, nil
+}
+
+// splitExpense compute the minor units each participant owes for an expense of amount in the currency
+func splitExpense(amount float64, currency string, method string, participants []ExpenseParticipant) ([]int64, error) {
+	if len(participants) == 0 {
+		return nil, fmt.Errorf("%w: at least one participant is required", ErrInvalidExpense)
+	}
+	total := toUnits(amount, currency)
+	seen := make(map[string]bool)
+	weights := make([]float64, len(participants))
+	sum := 0.0
+	for i, participant := range participants {
+		if seen[participant.Member] {
+			return nil, fmt.Errorf("%w: %s takes part twice", ErrInvalidExpense, participant.Member)
+		}
+		seen[participant.Member] = true
+		weights[i] = 1
+		if method != SplitEqual {
+			if math.IsNaN(participant.Value) || math.IsInf(participant.Value, 0) || participant.Value < 0 {
+				return nil, fmt.Errorf("%w: the value of %s must not be negative", ErrInvalidExpense, participant.Member)
+			}
+			weights[i] = participant.Value
+		}
+		sum += weights[i]
+	}
+
+	switch method {
+	case SplitEqual:
+		return apportion(total, weights), nil
+	case SplitShares:
+		if sum <= 0 {
+			return nil, fmt.Errorf("%w: the shares add up to 0", ErrInvalidExpense)
+		}
+		return apportion(total, weights), nil
+	case SplitPercentages:
+		if math.Abs(sum-100) > 1e-6 {
+			return nil, fmt.Errorf("%w: the percentages add up to %v instead of 100", ErrInvalidExpense, sum)
+		}
+		return apportion(total, weights), nil
+	case SplitExact:
+		shares := make([]int64, len(participants))
+		var allocated int64
+		for i, weight := range weights {
+			shares[i] = toUnits(weight, currency)
+			allocated += shares[i]
+		}
+		if allocated != total {
+			return nil, fmt.Errorf("%w: the amounts add up to %g instead of %g", ErrInvalidExpense,
+				fromUnits(allocated, currency), fromUnits(total, currency))
+		}
+		return shares, nil
+	}
+	return nil, fmt.Errorf("%w: unknown split method %q", ErrInvalidExpense, method)
+}
+
+// Balances return the balance of every member of the group, and of former members who still have one,
+// ordered by email
+func (groupService *GroupService) Balances(groupID uint) ([]MemberBalance, error) {
+	group, err := groupService.ReadByID(groupID)
+	if err != nil {
+		return nil, err
+	}
+	return groupBalances(groupService.db, group)
+}
+
+// groupBalances add up what every member of the group paid, owes and settled
+func groupBalances(db *gorm.DB, group *ExpenseGroup) ([]MemberBalance, error) {
+	balances := make(map[string]*MemberBalance)
+	balanceOf := func(member string) *MemberBalance {
+		if balances[member] == nil {
+			balances[member] = &MemberBalance{Member: member}
+		}
+		return balances[member]
+	}
+	var members []string
+	if err := db.Model(&GroupMember{}).Where("group_id = ?", group.ID).Pluck("email", &members).Error; err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		balanceOf(member)
+	}
+
+	var totals []struct {
+		Member string
+		Total  float64
+	}
+	if err := db.Model(&GroupExpense{}).Select("paid_by AS member, SUM(amount) AS total").
+		Where("group_id = ?", group.ID).Group("paid_by").Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	for _, total := range totals {
+		balanceOf(total.Member).Paid += total.Total
+	}
+	totals = nil
+	if err := db.Model(&ExpenseShare{}).Select("member, SUM(amount) AS total").
+		Where("group_id = ?", group.ID).Group("member").Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	for _, total := range totals {
+		balanceOf(total.Member).Owed += total.Total
+	}
+	var settlements []GroupSettlement
+	if err := db.Where("group_id = ?", group.ID).Find(&settlements).Error; err != nil {
+		return nil, err
+	}
+	for _, settlement := range settlements {
+		balanceOf(settlement.From).Settled += settlement.Amount
+		balanceOf(settlement.To).Settled -= settlement.Amount
+	}
+
+	result := make([]MemberBalance, 0, len(balances))
+	for _, balance := range balances {
+		balance.Paid = RoundAmount(balance.Paid, group.Currency)
+		balance.Owed = RoundAmount(balance.Owed, group.Currency)
+		balance.Settled = RoundAmount(balance.Settled, group.Currency)
+		balance.Net = fromUnits(toUnits(balance.Paid, group.Currency)-toUnits(balance.Owed, group.Currency)+
+			toUnits(balance.Settled, group.Currency), group.Currency)
+		result = append(result, *balance)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Member < result[j].Member
+	})
+	return result, nil
+}
+
+// SimplifyDebts return the transfers settling every balance of the group. The fewest transfers are found
+// for up to 16 members with a balance, larger groups are settled greedily which takes at most one
+// transfer less than the number of members with a balance.
+func (groupService *GroupService) SimplifyDebts(groupID uint) ([]DebtTransfer, error) {
+	group, err := groupService.ReadByID(groupID)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := groupBalances(groupService.db, group)
+	if err != nil {
+		return nil, err
+	}
+	return simplifyDebts(balances, group.Currency), nil
+}
+
+// simplifyDebts compute the transfers settling the balances. A set of n balances adding up to 0 is always
+// settled with n-1 transfers, so the fewest transfers come from splitting the balances into as many
+// subsets adding up to 0 as possible, which is found by dynamic programming over the subsets.
+func simplifyDebts(balances []MemberBalance, currency string) []DebtTransfer {
+	var members []string
+	var units []int64
+	for _, balance := range balances {
+		if net := toUnits(balance.Net, currency); net != 0 {
+			members = append(members, balance.Member)
+			units = append(units, net)
+		}
+	}
+	n := len(members)
+	if n == 0 {
+		return nil
+	}
+	if n > maxExactSettlement {
+		return settleGreedily(members, units, currency)
+	}
+
+	// groups[mask] is the largest number of subsets adding up to 0 the balances in mask split into,
+	// counting the rest as one more subset when it does not add up to 0
+	full := 1<<n - 1
+	sums := make([]int64, full+1)
+	groups := make([]int, full+1)
+	last := make([]int, full+1)
+	for mask := 1; mask <= full; mask++ {
+		low := mask & -mask
+		i := 0
+		for 1<<i != low {
+			i++
+		}
+		sums[mask] = sums[mask^low] + units[i]
+		for j := 0; j < n; j++ {
+			if mask&(1<<j) == 0 {
+				continue
+			}
+			if g := groups[mask^(1<<j)]; g > groups[mask] || last[mask] == 0 {
+				groups[mask], last[mask] = g, j+1
+			}
+		}
+		if sums[mask] == 0 {
+			groups[mask]++
+		}
+	}
+
+	// Walk back the order the balances were added in, a subset closes whenever the sum returns to 0
+	var transfers []DebtTransfer
+	var subset []int
+	for mask := full; mask != 0; {
+		j := last[mask] - 1
+		subset = append(subset, j)
+		mask ^= 1 << j
+		if sums[mask] == 0 {
+			var subsetMembers []string
+			var subsetUnits []int64
+			for _, k := range subset {
+				subsetMembers = append(subsetMembers, members[k])
+				subsetUnits = append(subsetUnits, units[k])
+			}
+			transfers = append(transfers, settleGreedily(subsetMembers, subsetUnits, currency)...)
+			subset = nil
+		}
+	}
+	return transfers
+}
+
+// settleGreedily settle balances adding up to 0 by repeatedly paying the largest creditor from the
+// largest debtor, each transfer settles at least one of them
+func settleGreedily(members []string, units []int64, currency string) []DebtTransfer {
+	balances := append([]int64(nil), units...)
+	var transfers []DebtTransfer
+	for {
+		debtor, creditor := -1, -1
+		for i, balance := range balances {
+			if balance < 0 && (debtor < 0 || balance < balances[debtor]) {
+				debtor = i
+			}
+			if balance > 0 && (creditor < 0 || balance > balances[creditor]) {
+				creditor = i
+			}
+		}
+		if debtor < 0 || creditor < 0 {
+			return transfers
+		}
+		amount := -balances[debtor]
+		if balances[creditor] < amount {
+			amount = balances[creditor]
+		}
+		balances[debtor] += amount
+		balances[creditor] -= amount
+		transfers = append(transfers, DebtTransfer{
+			From:   members[debtor],
+			To:     members[creditor],
+			Amount: fromUnits(amount, currency),
+		})
+	}
+}
+
+// SettleUp simplify the debts of the group and record a transaction for each transfer, from the member
+// who owes money to the member who is owed, along with the settlements bringing every balance to 0.
+// Either every transfer is recorded or none is.
+func (groupService *GroupService) SettleUp(groupID uint) ([]GroupSettlement, error) {
+	var settlements []GroupSettlement
+	err := groupService.db.Transaction(func(tx *gorm.DB) error {
+		group, err := lockGroup(tx, groupID)
+		if err != nil {
+			return err
+		}
+		balances, err := groupBalances(tx, group)
+		if err != nil {
+			return err
+		}
+		txService := &TransactionService{db: tx, actor: groupService.actor}
+		for _, transfer := range simplifyDebts(balances, group.Currency) {
+			transaction := Transaction{
+				Value:    transfer.Amount,
+				Note:     "Settle up " + group.Name,
+				Sender:   transfer.From,
+				Receiver: transfer.To,
+				Currency: group.Currency,
+			}
+			if err := txService.Create(&transaction); err != nil {
+				return err
+			}
+			settlement := GroupSettlement{
+				GroupID:       group.ID,
+				From:          transfer.From,
+				To:            transfer.To,
+				Amount:        transfer.Amount,
+				TransactionID: transaction.ID,
+			}
+			if err := tx.Create(&settlement).Error; err != nil {
+				return err
+			}
+			settlements = append(settlements, settlement)
+		}
+		return recordAudit(tx, groupService.actor, AuditSettle, AuditEntityGroup, group.ID, nil, settlements)
+	})
+	return settlements, err
+}
@@ -0,0 +1,388 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Statuses of a Hold
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldReleased = "released"
+	HoldExpired  = "expired"
+)
+
+// DefaultHoldExpiry is how long a hold reserves funds when it has no expiry date
+const DefaultHoldExpiry = 7 * 24 * time.Hour
+
+var (
+	// ErrInvalidHold is returned when a hold has no positive amount, no sender or receiver, or expires in the past
+	ErrInvalidHold = errors.New("models: invalid hold")
+
+	// ErrInsufficientFunds is returned when a hold, or a transaction of a sender with active holds, is larger
+	// than the available balance of the sender
+	ErrInsufficientFunds = errors.New("models: insufficient available balance")
+
+	// ErrHoldResolved is returned when a hold that is no longer active is captured or released
+	ErrHoldResolved = errors.New("models: the hold was already captured, released or expired")
+
+	// ErrHoldExpired is returned when a hold is captured after its expiry date
+	ErrHoldExpired = errors.New("models: the hold has expired")
+
+	// ErrInvalidCapture is returned when the captured amount is not positive or larger than the hold
+	ErrInvalidCapture = errors.New("models: the captured amount must be positive and at most the held amount")
+)
+
+// Hold reserve an Amount of the available balance of the Sender for the Receiver until ExpiresAt. Capturing
+// it creates the transaction TransactionID of the CapturedAmount, which may be less than the held amount,
+// and frees the rest. Releasing it or letting it expire frees the whole amount.
+type Hold struct {
+	ID             uint `gorm:"primaryKey"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Sender         string     `json:"sender" gorm:"not null;index"`
+	Receiver       string     `json:"receiver" gorm:"not null;index"`
+	Amount         float64    `json:"amount" gorm:"not null"`
+	Currency       string     `json:"currency" gorm:"not null"`
+	Note           string     `json:"note,omitempty" gorm:"not null;default:''"`
+	Status         string     `json:"status" gorm:"not null;default:'active';index"`
+	ExpiresAt      time.Time  `json:"expiresAt" gorm:"not null;index"`
+	CapturedAmount float64    `json:"capturedAmount" gorm:"not null;default:0"`
+	ResolvedAt     *time.Time `json:"resolvedAt,omitempty"`
+	TransactionID  *uint      `json:"transactionID,omitempty"`
+}
+
+// AccountBalance is the balance of an account in a currency. Ledger is the net amount of its transactions,
+// Held the sum of its active holds and Available what is left to hold or to send while holds are active.
+type AccountBalance struct {
+	Currency  string
+	Ledger    float64
+	Held      float64
+	Available float64
+}
+
+type HoldService struct {
+	db    *gorm.DB
+	actor string
+}
+
+// NewHoldService Create a new HoldService with a specified connectionInfo.
+func NewHoldService(db *gorm.DB) (*HoldService, error) {
+	return &HoldService{
+		db: db,
+	}, nil
+}
+
+// WithActor return a copy of the service whose changes are recorded in the audit log under the provided actor
+func (holdService *HoldService) WithActor(actor string) *HoldService {
+	return &HoldService{
+		db:    holdService.db,
+		actor: actor,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the holds table
+func (holdService *HoldService) AutoMigrate() error {
+	return holdService.db.AutoMigrate(&Hold{}).Error
+}
+
+// Create validate the hold and reserve its amount, ErrInsufficientFunds is returned when it is larger than
+// the available balance of the sender. The currency defaults to the currency of the sender account and the
+// hold expires after DefaultHoldExpiry when it has no expiry date.
+func (holdService *HoldService) Create(hold *Hold) error {
+	hold.Sender = strings.TrimSpace(hold.Sender)
+	hold.Receiver = strings.TrimSpace(hold.Receiver)
+	if hold.Sender == "" || hold.Receiver == "" {
+		return fmt.Errorf("%w: sender and receiver are required", ErrInvalidHold)
+	}
+	if math.IsNaN(hold.Amount) || math.IsInf(hold.Amount, 0) || hold.Amount <= 0 {
+		return fmt.Errorf("%w: amount must be a positive number", ErrInvalidHold)
+	}
+	now := gorm.NowFunc()
+	if hold.ExpiresAt.IsZero() {
+		hold.ExpiresAt = now.Add(DefaultHoldExpiry)
+	} else if !hold.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: the expiry date is in the past", ErrInvalidHold)
+	}
+	hold.Status = HoldActive
+	hold.CapturedAmount = 0
+	hold.Note = strings.TrimSpace(hold.Note)
+
+	return holdService.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockAccount(tx, hold.Sender); err != nil {
+			return err
+		}
+		var err error
+		if hold.Currency == "" {
+			hold.Currency = accountCurrency(tx, hold.Sender)
+		}
+		if hold.Currency, err = NormalizeCurrency(hold.Currency); err != nil {
+			return err
+		}
+		hold.Amount = RoundAmount(hold.Amount, hold.Currency)
+		balance, err := accountBalance(tx, hold.Sender, hold.Currency, now)
+		if err != nil {
+			return err
+		}
+		if toUnits(hold.Amount, hold.Currency) > toUnits(balance.Available, hold.Currency) {
+			return fmt.Errorf("%w: %g %s is available", ErrInsufficientFunds, balance.Available, hold.Currency)
+		}
+		if err := tx.Create(hold).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, holdService.actor, AuditCreate, AuditEntityHold, hold.ID, nil, hold)
+	})
+}
+
+// ReadByID will look up a hold with the provided ID
+func (holdService *HoldService) ReadByID(id uint) (*Hold, error) {
+	var hold Hold
+	if err := first(holdService.db.Where("id = ?", id), &hold); err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// ReadHolds return the holds the account placed or receives with the provided status, or with any status
+// if it is empty, newest first. Active holds past their expiry date that were not expired yet by ExpireDue
+// are reported as expired.
+func (holdService *HoldService) ReadHolds(account, status string) ([]Hold, error) {
+	now := gorm.NowFunc()
+	db := holdService.db.Where("sender = ? OR receiver = ?", account, account)
+	switch status {
+	case "":
+	case HoldActive:
+		db = db.Where("status = ? AND expires_at > ?", HoldActive, now)
+	case HoldExpired:
+		db = db.Where("status = ? OR (status = ? AND expires_at <= ?)", HoldExpired, HoldActive, now)
+	default:
+		db = db.Where("status = ?", status)
+	}
+	var holds []Hold
+	if err := db.Order("id DESC").Find(&holds).Error; err != nil {
+		return nil, err
+	}
+	for i := range holds {
+		if holds[i].Status == HoldActive && !holds[i].ExpiresAt.After(now) {
+			holds[i].Status = HoldExpired
+		}
+	}
+	return holds, nil
+}
+
+// Capture create the transaction paying amount of the hold with the provided ID from the sender to the
+// receiver, the whole hold if amount is nil. The rest of the hold is freed.
+func (holdService *HoldService) Capture(id uint, amount *float64) (*Hold, error) {
+	return holdService.resolve(id, HoldCaptured, amount)
+}
+
+// Release free the whole amount of the hold with the provided ID without creating a transaction
+func (holdService *HoldService) Release(id uint) (*Hold, error) {
+	return holdService.resolve(id, HoldReleased, nil)
+}
+
+// resolve capture or release the hold with the provided ID and record it in the audit log. A hold past its
+// expiry date is expired instead and ErrHoldExpired is returned.
+func (holdService *HoldService) resolve(id uint, status string, amount *float64) (*Hold, error) {
+	var hold Hold
+	expired := false
+	err := holdService.db.Transaction(func(tx *gorm.DB) error {
+		if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id), &hold); err != nil {
+			return err
+		}
+		if hold.Status != HoldActive {
+			return ErrHoldResolved
+		}
+		now := gorm.NowFunc()
+		if !hold.ExpiresAt.After(now) {
+			expired = true
+			return expireHold(tx, holdService.actor, &hold, now)
+		}
+
+		before := hold
+		operation := AuditRelease
+		if status == HoldCaptured {
+			operation = AuditCapture
+			captured := hold.Amount
+			if amount != nil {
+				captured = RoundAmount(*amount, hold.Currency)
+			}
+			if math.IsNaN(captured) || captured <= 0 || toUnits(captured, hold.Currency) > toUnits(hold.Amount, hold.Currency) {
+				return ErrInvalidCapture
+			}
+			// The hold stops reserving funds before the transaction spends them, see checkAvailable
+			if err := tx.Model(&Hold{}).Where("id = ?", hold.ID).UpdateColumn("status", status).Error; err != nil {
+				return err
+			}
+			transaction := Transaction{
+				Value:    captured,
+				Note:     hold.Note,
+				Sender:   hold.Sender,
+				Receiver: hold.Receiver,
+				Currency: hold.Currency,
+			}
+			txService := &TransactionService{db: tx, actor: holdService.actor}
+			if err := txService.Create(&transaction); err != nil {
+				return err
+			}
+			hold.CapturedAmount = captured
+			hold.TransactionID = &transaction.ID
+		}
+		hold.Status = status
+		hold.ResolvedAt = &now
+		if err := tx.Save(&hold).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, holdService.actor, operation, AuditEntityHold, hold.ID, before, hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return &hold, ErrHoldExpired
+	}
+	return &hold, nil
+}
+
+// ExpireDue release every active hold whose expiry date is not after now and return how many were expired
+func (holdService *HoldService) ExpireDue(now time.Time) (int, error) {
+	expired := 0
+	err := holdService.db.Transaction(func(tx *gorm.DB) error {
+		var holds []Hold
+		err := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("status = ? AND expires_at <= ?", HoldActive, now).Find(&holds).Error
+		if err != nil {
+			return err
+		}
+		for i := range holds {
+			if err := expireHold(tx, holdService.actor, &holds[i], now); err != nil {
+				return err
+			}
+		}
+		expired = len(holds)
+		return nil
+	})
+	return expired, err
+}
+
+// expireHold mark the active hold as expired and record it in the audit log
+func expireHold(tx *gorm.DB, actor string, hold *Hold, now time.Time) error {
+	before := *hold
+	hold.Status = HoldExpired
+	hold.ResolvedAt = &now
+	if err := tx.Save(hold).Error; err != nil {
+		return err
+	}
+	return recordAudit(tx, actor, AuditExpire, AuditEntityHold, hold.ID, before, *hold)
+}
+
+// Balances return the ledger, held and available balances of the account in every currency it holds money
+// in or has active holds in, ordered by currency
+func (holdService *HoldService) Balances(account string) ([]AccountBalance, error) {
+	ledger, err := (&TransactionService{db: holdService.db}).BalanceByCurrency(account, nil)
+	if err != nil {
+		return nil, err
+	}
+	held, err := heldByCurrency(holdService.db, account, gorm.NowFunc())
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]*AccountBalance)
+	for _, total := range ledger {
+		balances[total.Currency] = &AccountBalance{Currency: total.Currency, Ledger: total.Total}
+	}
+	for currency, amount := range held {
+		if balances[currency] == nil {
+			balances[currency] = &AccountBalance{Currency: currency}
+		}
+		balances[currency].Held = amount
+	}
+	result := make([]AccountBalance, 0, len(balances))
+	for currency, balance := range balances {
+		balance.Available = fromUnits(toUnits(balance.Ledger, currency)-toUnits(balance.Held, currency), currency)
+		result = append(result, *balance)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Currency < result[j].Currency
+	})
+	return result, nil
+}
+
+// accountBalance return the ledger, held and available balances of the account in the currency
+func accountBalance(tx *gorm.DB, account, currency string, now time.Time) (AccountBalance, error) {
+	balance := AccountBalance{Currency: currency}
+	ledger, err := (&TransactionService{db: tx}).BalanceByCurrency(account, nil)
+	if err != nil {
+		return balance, err
+	}
+	for _, total := range ledger {
+		if total.Currency == currency {
+			balance.Ledger = total.Total
+		}
+	}
+	held, err := heldByCurrency(tx, account, now)
+	if err != nil {
+		return balance, err
+	}
+	balance.Held = held[currency]
+	balance.Available = fromUnits(toUnits(balance.Ledger, currency)-toUnits(balance.Held, currency), currency)
+	return balance, nil
+}
+
+// heldByCurrency sum the holds of the sender that are active and not expired at now, for each currency
+func heldByCurrency(db *gorm.DB, sender string, now time.Time) (map[string]float64, error) {
+	var totals []CurrencyTotal
+	err := db.Model(&Hold{}).Select("currency, SUM(amount) AS total, COUNT(*) AS count").
+		Where("sender = ? AND status = ? AND expires_at > ?", sender, HoldActive, now).
+		Group("currency").Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+	held := make(map[string]float64, len(totals))
+	for _, total := range totals {
+		held[total.Currency] = RoundAmount(total.Total, total.Currency)
+	}
+	return held, nil
+}
+
+// checkAvailable lock the sender of the transaction and refuse it with ErrInsufficientFunds when it would
+// spend funds reserved by the active holds of the sender. A sender without active holds in the currency
+// may still overdraw its account. before is the stored transaction when it is updated, its value is
+// already counted in the balance.
+func checkAvailable(tx *gorm.DB, transaction, before *Transaction) error {
+	if err := lockLedger(tx); err != nil {
+		return err
+	}
+	if err := lockAccount(tx, transaction.Sender); err != nil {
+		return err
+	}
+	now := gorm.NowFunc()
+	held, err := heldByCurrency(tx, transaction.Sender, now)
+	if err != nil {
+		return err
+	}
+	currency := transaction.Currency
+	if held[currency] == 0 {
+		return nil
+	}
+	balance, err := accountBalance(tx, transaction.Sender, currency, now)
+	if err != nil {
+		return err
+	}
+	available := toUnits(balance.Available, currency)
+	if before != nil && before.Sender == transaction.Sender && before.Currency == currency {
+		available += toUnits(before.Value, currency)
+	}
+	if toUnits(transaction.Value, currency) > available {
+		return fmt.Errorf("%w: %g %s is available, %g %s is held", ErrInsufficientFunds,
+			fromUnits(available, currency), currency, balance.Held, currency)
+	}
+	return nil
+}
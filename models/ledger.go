@@ -0,0 +1,425 @@
+package models
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ErrNoLedgerEntries is returned when a checkpoint is requested but the ledger is still empty.
+var ErrNoLedgerEntries = errors.New("models: the ledger has no entries")
+
+// ErrLedgerBackfilled is returned when the transactions predating the ledger are chained a second time.
+var ErrLedgerBackfilled = errors.New("models: the transactions predating the ledger were already chained")
+
+// LedgerEntry is a link of the hash chain over the transaction log. An entry is appended every time a
+// transaction is committed, ContentHash is the hash of the canonical contents of the transaction at
+// that point and Hash is the hash of ContentHash together with the Hash of the previous entry.
+type LedgerEntry struct {
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	TransactionID uint   `json:"transactionID" gorm:"not null;index"`
+	Operation     string `json:"operation" gorm:"not null"`
+	ContentHash   string `json:"contentHash" gorm:"not null"`
+	PrevHash      string `json:"prevHash" gorm:"not null"`
+	Hash          string `json:"hash" gorm:"not null;unique_index"`
+}
+
+// LedgerCheckpoint is a signed statement of the head of the ledger at some point in time. Exported
+// checkpoints let the ledger be verified against a copy that is kept outside the database.
+type LedgerCheckpoint struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"createdAt"`
+	EntryID   uint      `json:"entryID" gorm:"not null"`
+	Hash      string    `json:"hash" gorm:"not null"`
+	PublicKey string    `json:"publicKey" gorm:"not null"`
+	Signature string    `json:"signature" gorm:"not null"`
+}
+
+// LedgerBackfill record that the transactions committed before the ledger existed were chained, which
+// can only happen once
+type LedgerBackfill struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt    time.Time `json:"createdAt"`
+	Transactions int       `json:"transactions" gorm:"not null"`
+}
+
+// LedgerReport is the result of walking the ledger. When OK is false, BrokenEntryID and/or
+// BrokenTransactionID identify the first broken link and Reason describe what is wrong with it.
+type LedgerReport struct {
+	OK                  bool
+	Entries             int
+	Transactions        int
+	Checkpoints         int
+	BrokenEntryID       uint
+	BrokenTransactionID uint
+	Reason              string
+}
+
+type LedgerService struct {
+	db *gorm.DB
+}
+
+// NewLedgerService Create a new LedgerService with a specified connectionInfo.
+func NewLedgerService(db *gorm.DB) (*LedgerService, error) {
+	return &LedgerService{
+		db: db,
+	}, nil
+}
+
+// AutoMigrate will attempt to automatically migrate the ledger tables and protect them from updates and
+// deletes. It never chains transactions, see Backfill.
+func (ledgerService *LedgerService) AutoMigrate() error {
+	if err := ledgerService.db.AutoMigrate(&LedgerEntry{}, &LedgerCheckpoint{}, &LedgerBackfill{}).Error; err != nil {
+		return err
+	}
+	rules := []string{
+		"CREATE OR REPLACE RULE ledger_entries_no_update AS ON UPDATE TO ledger_entries DO INSTEAD NOTHING",
+		"CREATE OR REPLACE RULE ledger_entries_no_delete AS ON DELETE TO ledger_entries DO INSTEAD NOTHING",
+		"CREATE OR REPLACE RULE ledger_checkpoints_no_update AS ON UPDATE TO ledger_checkpoints DO INSTEAD NOTHING",
+		"CREATE OR REPLACE RULE ledger_checkpoints_no_delete AS ON DELETE TO ledger_checkpoints DO INSTEAD NOTHING",
+		"CREATE OR REPLACE RULE ledger_backfills_no_update AS ON UPDATE TO ledger_backfills DO INSTEAD NOTHING",
+		"CREATE OR REPLACE RULE ledger_backfills_no_delete AS ON DELETE TO ledger_backfills DO INSTEAD NOTHING",
+	}
+	for _, rule := range rules {
+		if err := ledgerService.db.Exec(rule).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Backfill append a create entry for every transaction committed before the ledger existed, oldest first,
+// and return how many were chained. It is a one-time migration: transactions that already have an entry
+// are never chained again, so clearing the hash of an altered transaction does not seal the change, and
+// ErrLedgerBackfilled is returned once it ran.
+func (ledgerService *LedgerService) Backfill() (int, error) {
+	var chained int
+	err := ledgerService.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockLedger(tx); err != nil {
+			return err
+		}
+		var backfills int
+		if err := tx.Model(&LedgerBackfill{}).Count(&backfills).Error; err != nil {
+			return err
+		}
+		if backfills > 0 {
+			return ErrLedgerBackfilled
+		}
+		var transactions []Transaction
+		err := tx.Unscoped().
+			Where("hash = '' AND NOT EXISTS (SELECT 1 FROM ledger_entries WHERE ledger_entries.transaction_id = transactions.id)").
+			Order("id").Find(&transactions).Error
+		if err != nil {
+			return err
+		}
+		for i := range transactions {
+			if err := appendLedger(tx, AuditCreate, &transactions[i]); err != nil {
+				return err
+			}
+		}
+		chained = len(transactions)
+		return tx.Create(&LedgerBackfill{Transactions: chained}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return chained, nil
+}
+
+// Checkpoint sign the current head of the ledger with the provided key and store the checkpoint.
+// If the head has not moved since the last checkpoint, the last checkpoint is returned instead.
+func (ledgerService *LedgerService) Checkpoint(key ed25519.PrivateKey) (*LedgerCheckpoint, error) {
+	var head LedgerEntry
+	if err := first(ledgerService.db.Order("id desc"), &head); err != nil {
+		if err == ErrNotFound {
+			return nil, ErrNoLedgerEntries
+		}
+		return nil, err
+	}
+
+	var last LedgerCheckpoint
+	err := first(ledgerService.db.Order("id desc"), &last)
+	if err == nil && last.EntryID == head.ID {
+		return &last, nil
+	}
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+
+	checkpoint := &LedgerCheckpoint{
+		EntryID:   head.ID,
+		Hash:      head.Hash,
+		PublicKey: hex.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Signature: hex.EncodeToString(ed25519.Sign(key, checkpointMessage(head.ID, head.Hash))),
+	}
+	return checkpoint, ledgerService.db.Create(checkpoint).Error
+}
+
+// ReadCheckpoints return every stored checkpoint, oldest first.
+func (ledgerService *LedgerService) ReadCheckpoints() ([]LedgerCheckpoint, error) {
+	var checkpoints []LedgerCheckpoint
+	if err := ledgerService.db.Order("id").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
+
+// ExportCheckpoints write every stored checkpoint to w as JSON Lines.
+func (ledgerService *LedgerService) ExportCheckpoints(w io.Writer) error {
+	checkpoints, err := ledgerService.ReadCheckpoints()
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	for _, checkpoint := range checkpoints {
+		if err := encoder.Encode(checkpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadExportedCheckpoints read checkpoints written by ExportCheckpoints.
+func ReadExportedCheckpoints(r io.Reader) ([]LedgerCheckpoint, error) {
+	var checkpoints []LedgerCheckpoint
+	decoder := json.NewDecoder(r)
+	for decoder.More() {
+		var checkpoint LedgerCheckpoint
+		if err := decoder.Decode(&checkpoint); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, nil
+}
+
+// Verify walk the whole ledger and report the first broken link. Every entry must hash to its stored
+// Hash and point at the Hash of the entry before it, every transaction must be chained and match the last
+// entry that was appended for it, and every stored checkpoint must match the ledger and carry a valid signature.
+// When publicKey is not nil, checkpoints must also have been signed by that key. Checkpoints exported
+// to a file can be checked with the extra argument.
+func (ledgerService *LedgerService) Verify(publicKey ed25519.PublicKey, extra ...LedgerCheckpoint) (*LedgerReport, error) {
+	report := &LedgerReport{}
+
+	// Walk the chain of entries
+	rows, err := ledgerService.db.Model(&LedgerEntry{}).Order("id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[uint]LedgerEntry)
+	hashes := make(map[uint]string)
+	prevHash := ""
+	for rows.Next() {
+		var entry LedgerEntry
+		if err := ledgerService.db.ScanRows(rows, &entry); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.Entries++
+		if entry.PrevHash != prevHash {
+			rows.Close()
+			return report.broken(entry.ID, entry.TransactionID, "entry does not point at the previous entry"), nil
+		}
+		if entry.Hash != chainHash(entry.PrevHash, entry.Operation, entry.TransactionID, entry.ContentHash) {
+			rows.Close()
+			return report.broken(entry.ID, entry.TransactionID, "entry hash does not match its contents"), nil
+		}
+		prevHash = entry.Hash
+		latest[entry.TransactionID] = entry
+		hashes[entry.ID] = entry.Hash
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Compare every transaction with the last entry appended for it
+	rows, err = ledgerService.db.Unscoped().Model(&Transaction{}).Order("id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var transaction Transaction
+		if err := ledgerService.db.ScanRows(rows, &transaction); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		report.Transactions++
+		if transaction.Hash == "" {
+			rows.Close()
+			return report.broken(0, transaction.ID, "transaction is not chained, its hash is empty"), nil
+		}
+		entry, isChained := latest[transaction.ID]
+		if !isChained {
+			rows.Close()
+			return report.broken(0, transaction.ID, "transaction is not in the ledger"), nil
+		}
+		delete(latest, transaction.ID)
+		if entry.Operation == AuditPurge {
+			rows.Close()
+			return report.broken(entry.ID, transaction.ID, "purged transaction is back in the database"), nil
+		}
+		if entry.ContentHash != transaction.contentHash() {
+			rows.Close()
+			return report.broken(entry.ID, transaction.ID, "transaction contents were altered"), nil
+		}
+		if transaction.Hash != entry.Hash || transaction.PrevHash != entry.PrevHash {
+			rows.Close()
+			return report.broken(entry.ID, transaction.ID, "transaction hash does not match the ledger"), nil
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var missing *LedgerEntry
+	for _, entry := range latest {
+		if entry.Operation != AuditPurge && (missing == nil || entry.ID < missing.ID) {
+			entry := entry
+			missing = &entry
+		}
+	}
+	if missing != nil {
+		return report.broken(missing.ID, missing.TransactionID, "transaction was removed from the database"), nil
+	}
+
+	// Check the signed checkpoints
+	checkpoints, err := ledgerService.ReadCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+	for _, checkpoint := range append(checkpoints, extra...) {
+		report.Checkpoints++
+		if reason := checkpoint.verify(publicKey, hashes); reason != "" {
+			return report.broken(checkpoint.EntryID, 0, reason), nil
+		}
+	}
+
+	report.OK = true
+	return report, nil
+}
+
+// broken mark the report as failed at the provided link
+func (report *LedgerReport) broken(entryID, transactionID uint, reason string) *LedgerReport {
+	report.OK = false
+	report.BrokenEntryID = entryID
+	report.BrokenTransactionID = transactionID
+	report.Reason = reason
+	return report
+}
+
+// verify check the checkpoint against the hashes of the ledger entries, an empty string means the
+// checkpoint is valid
+func (checkpoint LedgerCheckpoint) verify(publicKey ed25519.PublicKey, hashes map[uint]string) string {
+	signer, err := hex.DecodeString(checkpoint.PublicKey)
+	if err != nil || len(signer) != ed25519.PublicKeySize {
+		return fmt.Sprintf("checkpoint %d has an invalid public key", checkpoint.ID)
+	}
+	if publicKey != nil && !publicKey.Equal(ed25519.PublicKey(signer)) {
+		return fmt.Sprintf("checkpoint %d was signed by an unknown key", checkpoint.ID)
+	}
+	signature, err := hex.DecodeString(checkpoint.Signature)
+	if err != nil || !ed25519.Verify(signer, checkpointMessage(checkpoint.EntryID, checkpoint.Hash), signature) {
+		return fmt.Sprintf("checkpoint %d has an invalid signature", checkpoint.ID)
+	}
+	if hash, isOK := hashes[checkpoint.EntryID]; !isOK || hash != checkpoint.Hash {
+		return fmt.Sprintf("checkpoint %d does not match the ledger", checkpoint.ID)
+	}
+	return ""
+}
+
+// appendLedger chain the current contents of the transaction at the head of the ledger and store the
+// resulting hashes on the transaction. It must run inside the database transaction the change was made in.
+func appendLedger(tx *gorm.DB, operation string, transaction *Transaction) error {
+	if err := lockLedger(tx); err != nil {
+		return err
+	}
+
+	var head LedgerEntry
+	err := first(tx.Order("id desc"), &head)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	entry := LedgerEntry{
+		TransactionID: transaction.ID,
+		Operation:     operation,
+		ContentHash:   transaction.contentHash(),
+		PrevHash:      head.Hash,
+	}
+	entry.Hash = chainHash(entry.PrevHash, entry.Operation, entry.TransactionID, entry.ContentHash)
+	if err := tx.Create(&entry).Error; err != nil {
+		return err
+	}
+	if operation == AuditPurge {
+		return nil
+	}
+
+	transaction.Hash = entry.Hash
+	transaction.PrevHash = entry.PrevHash
+	return tx.Unscoped().Model(&Transaction{}).Where("id = ?", transaction.ID).
+		UpdateColumns(map[string]interface{}{"hash": entry.Hash, "prev_hash": entry.PrevHash}).Error
+}
+
+// chainHash compute the Hash of a ledger entry
+func chainHash(prevHash, operation string, transactionID uint, contentHash string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%s", prevHash, operation, transactionID, contentHash)))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkpointMessage is the message signed by a checkpoint
+func checkpointMessage(entryID uint, hash string) []byte {
+	return []byte(fmt.Sprintf("%d|%s", entryID, hash))
+}
+
+// contentHash compute the hash of the canonical contents of the transaction. Timestamps are reduced to
+// the precision stored by the database so the hash is the same before and after a round trip.
+// The currency fields are left out of transactions created before they existed, which are all in the
+// DefaultCurrency without conversion, so their hashes stay valid.
+func (transaction *Transaction) contentHash() string {
+	type currencyContent struct {
+		Currency         string `json:"currency"`
+		ReceivedValue    string `json:"receivedValue"`
+		ReceivedCurrency string `json:"receivedCurrency"`
+		Rate             string `json:"rate"`
+	}
+	var currencies *currencyContent
+	if transaction.ReceivedCurrency != "" || transaction.Currency != DefaultCurrency {
+		currencies = &currencyContent{
+			Currency:         transaction.Currency,
+			ReceivedValue:    strconv.FormatFloat(transaction.ReceivedValue, 'f', -1, 64),
+			ReceivedCurrency: transaction.ReceivedCurrency,
+			Rate:             strconv.FormatFloat(transaction.Rate, 'f', -1, 64),
+		}
+	}
+
+	canonical, _ := json.Marshal(struct {
+		ID         uint             `json:"id"`
+		CreatedAt  string           `json:"createdAt"`
+		Value      string           `json:"value"`
+		Note       string           `json:"note"`
+		Sender     string           `json:"sender"`
+		Receiver   string           `json:"receiver"`
+		Deleted    bool             `json:"deleted"`
+		Currencies *currencyContent `json:"currencies,omitempty"`
+	}{
+		ID:         transaction.ID,
+		CreatedAt:  transaction.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+		Value:      strconv.FormatFloat(transaction.Value, 'f', -1, 64),
+		Note:       transaction.Note,
+		Sender:     transaction.Sender,
+		Receiver:   transaction.Receiver,
+		Deleted:    transaction.DeletedAt != nil,
+		Currencies: currencies,
+	})
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
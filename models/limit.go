@@ -0,0 +1,361 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"math"
+	"strings"
+	"time"
+)
+
+// Kinds of limit a LimitExceededError reports
+const (
+	LimitPerTransaction = "per_transaction"
+	LimitDaily          = "daily"
+	LimitMonthly        = "monthly"
+	LimitHourlyCount    = "hourly_count"
+)
+
+// limitTolerance absorb the rounding of converted amounts when they are compared to a limit
+const limitTolerance = 1e-9
+
+var (
+	// ErrLimitExceeded is returned when a transaction would exceed a limit of its sender, see LimitExceededError
+	ErrLimitExceeded = errors.New("models: transaction limit exceeded")
+
+	// ErrInvalidLimit is returned when a limit is set for neither or both of a user and a role, or has a negative maximum
+	ErrInvalidLimit = errors.New("models: invalid transaction limit")
+)
+
+// LimitExceededError is returned when a transaction would exceed the Limit of its sender. Max is the limit
+// and Remaining what is left of it before the transaction, amounts are in Currency and counts for
+// LimitHourlyCount.
+type LimitExceededError struct {
+	Limit     string
+	Max       float64
+	Remaining float64
+	Currency  string
+}
+
+func (err *LimitExceededError) Error() string {
+	switch err.Limit {
+	case LimitPerTransaction:
+		return fmt.Sprintf("%v: at most %g %s per transaction", ErrLimitExceeded, err.Max, err.Currency)
+	case LimitHourlyCount:
+		return fmt.Sprintf("%v: at most %g transactions per hour, %g remaining", ErrLimitExceeded, err.Max, err.Remaining)
+	}
+	return fmt.Sprintf("%v: %s limit of %g %s, %g %s remaining", ErrLimitExceeded, err.Limit, err.Max, err.Currency,
+		err.Remaining, err.Currency)
+}
+
+func (err *LimitExceededError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// TransactionLimit restrict the transactions sent by the user with the provided Email, or by every user with
+// the provided Role. A limit of a user replaces the limit of its role entirely. A nil maximum is not
+// limited. Amounts are in Currency, the values of transactions in other currencies are converted with the
+// current rate, and are added as they are when Currency is empty. Daily and monthly totals reset at
+// midnight UTC, the hourly count covers the last 60 minutes.
+type TransactionLimit struct {
+	ID                uint `gorm:"primaryKey"`
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Email             string   `json:"email,omitempty" gorm:"not null;default:'';unique_index:idx_limit_owner"`
+	Role              string   `json:"role,omitempty" gorm:"not null;default:'';unique_index:idx_limit_owner"`
+	Currency          string   `json:"currency,omitempty" gorm:"not null;default:''"`
+	MaxPerTransaction *float64 `json:"maxPerTransaction,omitempty"`
+	MaxDaily          *float64 `json:"maxDaily,omitempty"`
+	MaxMonthly        *float64 `json:"maxMonthly,omitempty"`
+	MaxPerHour        *int     `json:"maxPerHour,omitempty"`
+}
+
+// LimitUsage is the limit applying to a user and how much of it is used, Limit is nil when the user has
+// no limit. Remaining fields are nil when the matching maximum is not limited.
+type LimitUsage struct {
+	Email            string
+	Limit            *TransactionLimit
+	SentToday        float64
+	SentThisMonth    float64
+	CountLastHour    int
+	RemainingDaily   *float64
+	RemainingMonthly *float64
+	RemainingPerHour *int
+}
+
+type LimitService struct {
+	db    *gorm.DB
+	actor string
+}
+
+// NewLimitService Create a new LimitService with a specified connectionInfo.
+func NewLimitService(db *gorm.DB) (*LimitService, error) {
+	return &LimitService{
+		db: db,
+	}, nil
+}
+
+// WithActor return a copy of the service whose changes are recorded in the audit log under the provided actor
+func (limitService *LimitService) WithActor(actor string) *LimitService {
+	return &LimitService{
+		db:    limitService.db,
+		actor: actor,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the transaction limits table
+func (limitService *LimitService) AutoMigrate() error {
+	return limitService.db.AutoMigrate(&TransactionLimit{}).Error
+}
+
+// ReadLimits return every limit, the limits of roles first
+func (limitService *LimitService) ReadLimits() ([]TransactionLimit, error) {
+	var limits []TransactionLimit
+	if err := limitService.db.Order("email, role").Find(&limits).Error; err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+// SetLimit validate the limit and store it, replacing the limit already set for the same user or role
+func (limitService *LimitService) SetLimit(limit *TransactionLimit) error {
+	if err := limit.validate(); err != nil {
+		return err
+	}
+	return limitService.db.Transaction(func(tx *gorm.DB) error {
+		var existing TransactionLimit
+		err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("email = ? AND role = ?", limit.Email, limit.Role), &existing)
+		if err == ErrNotFound {
+			limit.ID = 0
+			if err := tx.Create(limit).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, limitService.actor, AuditCreate, AuditEntityLimit, limit.ID, nil, limit)
+		}
+		if err != nil {
+			return err
+		}
+		limit.ID = existing.ID
+		limit.CreatedAt = existing.CreatedAt
+		if err := tx.Save(limit).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, limitService.actor, AuditUpdate, AuditEntityLimit, limit.ID, existing, limit)
+	})
+}
+
+// DeleteLimit delete the limit with the provided ID, the user or role is no longer limited
+func (limitService *LimitService) DeleteLimit(id uint) error {
+	if id == 0 { // Go default uint is 0, Gorm will delete all rows if id is not provided
+		return ErrInvalidID
+	}
+	return limitService.db.Transaction(func(tx *gorm.DB) error {
+		var limit TransactionLimit
+		if err := first(tx.Where("id = ?", id), &limit); err != nil {
+			return err
+		}
+		if err := tx.Delete(&limit).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, limitService.actor, AuditDelete, AuditEntityLimit, id, limit, nil)
+	})
+}
+
+// Usage return the limit applying to the user with the provided email and how much of it is used now
+func (limitService *LimitService) Usage(email string) (*LimitUsage, error) {
+	limit, err := effectiveLimit(limitService.db, email)
+	if err != nil {
+		return nil, err
+	}
+	usage := &LimitUsage{Email: email, Limit: limit}
+	if limit == nil {
+		return usage, nil
+	}
+	if err := usage.measure(limitService.db, gorm.NowFunc(), 0); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// checkLimits return a *LimitExceededError if the transaction would exceed the limit of its sender. It must
+// run inside the database transaction creating or updating it, after its currencies are set, and locks the
+// sender account so concurrent transactions of the sender are counted. An updated transaction is checked
+// as if it was sent now, the totals leave out its stored version.
+func checkLimits(tx *gorm.DB, transaction *Transaction) error {
+	limit, err := effectiveLimit(tx, transaction.Sender)
+	if err != nil || limit == nil {
+		return err
+	}
+	// The transaction appends to the ledger, whose lock comes first, see lockAccount
+	if err := lockLedger(tx); err != nil {
+		return err
+	}
+	if err := lockAccount(tx, transaction.Sender); err != nil {
+		return err
+	}
+	now := gorm.NowFunc()
+	value, err := convertAmount(tx, transaction.Value, transaction.Currency, limit.Currency, now)
+	if err != nil {
+		return err
+	}
+	if limit.MaxPerTransaction != nil && value > *limit.MaxPerTransaction+limitTolerance {
+		return &LimitExceededError{
+			Limit:     LimitPerTransaction,
+			Max:       *limit.MaxPerTransaction,
+			Remaining: *limit.MaxPerTransaction,
+			Currency:  limit.Currency,
+		}
+	}
+
+	usage := LimitUsage{Email: transaction.Sender, Limit: limit}
+	if err := usage.measure(tx, now, transaction.ID); err != nil {
+		return err
+	}
+	if usage.RemainingPerHour != nil && *usage.RemainingPerHour < 1 {
+		return &LimitExceededError{Limit: LimitHourlyCount, Max: float64(*limit.MaxPerHour), Remaining: float64(*usage.RemainingPerHour)}
+	}
+	if usage.RemainingDaily != nil && value > *usage.RemainingDaily+limitTolerance {
+		return &LimitExceededError{Limit: LimitDaily, Max: *limit.MaxDaily, Remaining: *usage.RemainingDaily, Currency: limit.Currency}
+	}
+	if usage.RemainingMonthly != nil && value > *usage.RemainingMonthly+limitTolerance {
+		return &LimitExceededError{Limit: LimitMonthly, Max: *limit.MaxMonthly, Remaining: *usage.RemainingMonthly, Currency: limit.Currency}
+	}
+	return nil
+}
+
+// measure add up the transactions the user sent today, this month and in the last hour, except the
+// transaction with the ID exclude. Deleted transactions still count, so deleting a transaction does not free
+// up the limit again. Today is always within the month, so a single query over the month gives both totals.
+func (usage *LimitUsage) measure(db *gorm.DB, now time.Time, exclude uint) error {
+	limit := usage.Limit
+	sent := db.Unscoped().Model(&Transaction{})
+	utc := now.UTC()
+	dayStart := time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(utc.Year(), utc.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var totals []struct {
+		Currency string
+		Today    bool
+		Total    float64
+	}
+	err := sent.
+		Select("currency, created_at >= ? AS today, SUM(value) AS total", dayStart).
+		Where("sender = ? AND created_at >= ? AND id <> ?", usage.Email, monthStart, exclude).
+		Group("currency, today").
+		Scan(&totals).Error
+	if err != nil {
+		return err
+	}
+	for _, total := range totals {
+		amount, err := convertAmount(db, total.Total, total.Currency, limit.Currency, now)
+		if err != nil {
+			return err
+		}
+		usage.SentThisMonth += amount
+		if total.Today {
+			usage.SentToday += amount
+		}
+	}
+	if limit.Currency != "" {
+		usage.SentToday = RoundAmount(usage.SentToday, limit.Currency)
+		usage.SentThisMonth = RoundAmount(usage.SentThisMonth, limit.Currency)
+	}
+	if err := sent.Where("sender = ? AND created_at >= ? AND id <> ?", usage.Email, now.Add(-time.Hour), exclude).
+		Count(&usage.CountLastHour).Error; err != nil {
+		return err
+	}
+
+	if limit.MaxDaily != nil {
+		remaining := limit.remaining(*limit.MaxDaily, usage.SentToday)
+		usage.RemainingDaily = &remaining
+	}
+	if limit.MaxMonthly != nil {
+		remaining := limit.remaining(*limit.MaxMonthly, usage.SentThisMonth)
+		usage.RemainingMonthly = &remaining
+	}
+	if limit.MaxPerHour != nil {
+		remaining := *limit.MaxPerHour - usage.CountLastHour
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.RemainingPerHour = &remaining
+	}
+	return nil
+}
+
+// remaining return what is left of max once used is spent, rounded to the currency of the limit
+func (limit *TransactionLimit) remaining(max, used float64) float64 {
+	remaining := math.Max(0, max-used)
+	if limit.Currency != "" {
+		remaining = RoundAmount(remaining, limit.Currency)
+	}
+	return remaining
+}
+
+// effectiveLimit return the limit of the user with the provided email, or else the limit of its role, nil
+// when neither is limited
+func effectiveLimit(db *gorm.DB, email string) (*TransactionLimit, error) {
+	var limit TransactionLimit
+	err := first(db.Where("email = ? AND role = ''", email), &limit)
+	if err == nil {
+		return &limit, nil
+	}
+	if err != ErrNotFound {
+		return nil, err
+	}
+	var user User
+	if err := first(db.Where("email = ?", email), &user); err == ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = first(db.Where("email = '' AND role = ?", user.Role), &limit)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// convertAmount convert an amount from a currency into another with the rate effective at, the amount is
+// returned as it is when to is empty
+func convertAmount(db *gorm.DB, amount float64, from, to string, at time.Time) (float64, error) {
+	if to == "" || from == to {
+		return amount, nil
+	}
+	rate, err := lookupRate(db, from, to, at)
+	if err != nil {
+		return 0, err
+	}
+	return amount * rate, nil
+}
+
+// validate check and normalize the fields of the limit
+func (limit *TransactionLimit) validate() error {
+	limit.Email = strings.TrimSpace(limit.Email)
+	limit.Role = strings.TrimSpace(limit.Role)
+	if (limit.Email == "") == (limit.Role == "") {
+		return fmt.Errorf("%w: set either a user or a role", ErrInvalidLimit)
+	}
+	if limit.Role != "" && !isValidRole(limit.Role) {
+		return fmt.Errorf("%w: unknown role %q", ErrInvalidLimit, limit.Role)
+	}
+	if limit.Currency != "" {
+		var err error
+		if limit.Currency, err = NormalizeCurrency(limit.Currency); err != nil {
+			return err
+		}
+	}
+	for _, max := range []*float64{limit.MaxPerTransaction, limit.MaxDaily, limit.MaxMonthly} {
+		if max != nil && (math.IsNaN(*max) || *max < 0) {
+			return fmt.Errorf("%w: maximums must not be negative", ErrInvalidLimit)
+		}
+	}
+	if limit.MaxPerHour != nil && *limit.MaxPerHour < 0 {
+		return fmt.Errorf("%w: maximums must not be negative", ErrInvalidLimit)
+	}
+	return nil
+}
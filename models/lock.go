@@ -0,0 +1,28 @@
+package models
+
+import "github.com/jinzhu/gorm"
+
+// Keys of the Postgres advisory locks taken by the models. Every advisory lock of the application is keyed
+// from this block so two features never take the same lock by accident: a new lock gets the next unused
+// key here. The keys are arbitrary but must never change, processes of different versions may run at once.
+const (
+	// ledgerAppendLockKey is the single lock serializing the appends to the ledger hash chain
+	ledgerAppendLockKey = 28028
+
+	// accountBalanceLockKey is the first key of the locks serializing the changes to the available balance
+	// of an account, the second key is the hash of the account
+	accountBalanceLockKey = 28029
+)
+
+// lockLedger serialize the appends to the ledger until the end of the database transaction
+func lockLedger(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", ledgerAppendLockKey).Error
+}
+
+// lockAccount serialize the changes to the available balance of the account until the end of the database
+// transaction. Accounts are not rows of their own, so a Postgres advisory lock keyed by the account is used.
+// A transaction that also appends to the ledger takes the ledger lock first, so the locks are always taken
+// in the same order.
+func lockAccount(tx *gorm.DB, account string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?, hashtext(?))", accountBalanceLockKey, account).Error
+}
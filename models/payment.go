@@ -0,0 +1,332 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Types of the bank accounts paid through ACH
+const (
+	AccountChecking = "checking"
+	AccountSavings  = "savings"
+)
+
+var (
+	// ErrInvalidBankAccount is returned when the details of a bank account are missing or malformed.
+	ErrInvalidBankAccount = errors.New("models: invalid bank account")
+
+	// ErrMissingBankAccount is returned when a payment is made to an account with no bank details.
+	ErrMissingBankAccount = errors.New("models: the account has no bank details")
+
+	// ErrNoPendingPayments is returned when a payment batch would contain no transaction.
+	ErrNoPendingPayments = errors.New("models: no pending transaction to pay")
+
+	// ErrNotPending is returned when a payment batch is asked to include a transaction that does not
+	// exist, is in another currency or was already submitted in another batch.
+	ErrNotPending = errors.New("models: transaction is not pending in this currency")
+)
+
+var (
+	bicPattern           = regexp.MustCompile(`^[A-Z]{6}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+	ibanPattern          = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	accountNumberPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,17}$`)
+)
+
+// BankAccount is where the money received by an account is paid out: an IBAN, and optionally a BIC,
+// for ISO 20022 credit transfers or an ABA routing number and an account number for ACH.
+type BankAccount struct {
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Account       string `json:"account" gorm:"not null;unique_index"`
+	HolderName    string `json:"holderName" gorm:"not null"`
+	IBAN          string `json:"iban,omitempty" gorm:"column:iban;not null;default:''"`
+	BIC           string `json:"bic,omitempty" gorm:"column:bic;not null;default:''"`
+	RoutingNumber string `json:"routingNumber,omitempty" gorm:"not null;default:''"`
+	AccountNumber string `json:"accountNumber,omitempty" gorm:"not null;default:''"`
+	AccountType   string `json:"accountType,omitempty" gorm:"not null;default:'checking'"`
+}
+
+// PaymentBatch is a payment file sent to the bank, Content is the file as generated so it can be
+// downloaded again. Every transaction of the batch has a PaymentSubmission.
+type PaymentBatch struct {
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	Format        string    `gorm:"not null"`
+	Currency      string    `gorm:"not null"`
+	ExecutionDate time.Time `gorm:"not null"`
+	MessageID     string    `gorm:"not null;default:''"`
+	Filename      string    `gorm:"not null;default:''"`
+	Count         int       `gorm:"not null"`
+	Total         float64   `gorm:"not null"`
+	Content       string    `gorm:"type:text;not null;default:''"`
+}
+
+// PaymentSubmission mark a transaction as submitted to the bank in a batch, the unique index
+// guarantees a transaction is never paid twice.
+type PaymentSubmission struct {
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	BatchID       uint `gorm:"not null;index"`
+	TransactionID uint `gorm:"not null;unique_index"`
+}
+
+// PendingPayment is a transaction to pay in a batch with the bank account of its receiver. Amount is
+// what the receiver is credited, in the currency of the batch.
+type PendingPayment struct {
+	Transaction Transaction
+	BankAccount BankAccount
+	Amount      float64
+}
+
+type PaymentService struct {
+	db *gorm.DB
+}
+
+// NewPaymentService Create a new PaymentService with a specified connectionInfo.
+func NewPaymentService(db *gorm.DB) (*PaymentService, error) {
+	return &PaymentService{
+		db: db,
+	}, nil
+}
+
+// AutoMigrate will attempt to automatically migrate the bank accounts and payment batches tables
+func (paymentService *PaymentService) AutoMigrate() error {
+	if err := paymentService.db.AutoMigrate(&BankAccount{}, &PaymentBatch{}, &PaymentSubmission{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReadBankAccount will look up the bank account of the account, ErrNotFound if it has none.
+func (paymentService *PaymentService) ReadBankAccount(account string) (*BankAccount, error) {
+	var bankAccount BankAccount
+	err := first(paymentService.db.Where("account = ?", account), &bankAccount)
+	if err != nil {
+		return nil, err
+	}
+	return &bankAccount, nil
+}
+
+// SetBankAccount validate the provided bank account and store it as the bank account of its account,
+// replacing the previous one.
+func (paymentService *PaymentService) SetBankAccount(bankAccount *BankAccount) error {
+	if err := bankAccount.normalize(); err != nil {
+		return err
+	}
+	return paymentService.db.Transaction(func(tx *gorm.DB) error {
+		if err := first(tx.Where("email = ?", bankAccount.Account), &User{}); err != nil {
+			if err == ErrNotFound {
+				return ErrUnknownAccount
+			}
+			return err
+		}
+		var existing BankAccount
+		err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("account = ?", bankAccount.Account), &existing)
+		if err == ErrNotFound {
+			return tx.Create(bankAccount).Error
+		}
+		if err != nil {
+			return err
+		}
+		bankAccount.ID = existing.ID
+		bankAccount.CreatedAt = existing.CreatedAt
+		return tx.Save(bankAccount).Error
+	})
+}
+
+// ReadBatch will look up a payment batch with the provided ID, it behaves like TransactionService.ReadByID.
+func (paymentService *PaymentService) ReadBatch(id uint) (*PaymentBatch, error) {
+	var batch PaymentBatch
+	err := first(paymentService.db.Where("id = ?", id), &batch)
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// ReadBatches return every payment batch, newest first, without their content
+func (paymentService *PaymentService) ReadBatches() ([]PaymentBatch, error) {
+	var batches []PaymentBatch
+	err := paymentService.db.Select("id, created_at, format, currency, execution_date, message_id, filename, count, total").
+		Order("id DESC").Find(&batches).Error
+	if err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+// CreateBatch select the transactions to pay in the currency of the batch, call render to generate the
+// payment file of the batch and store it, marking every transaction of the batch as submitted. It is
+// all done in a single database transaction so a transaction is only submitted if its file is stored.
+// When ids is empty, the oldest pending transactions whose receiver has a bank account are selected, up
+// to limit if it is positive. Otherwise exactly the transactions with the provided IDs are selected,
+// and ErrNotPending is returned if one of them can not be paid.
+func (paymentService *PaymentService) CreateBatch(batch *PaymentBatch, ids []uint, limit int, render func(*PaymentBatch, []PendingPayment) error) error {
+	currency, err := NormalizeCurrency(batch.Currency)
+	if err != nil {
+		return err
+	}
+	batch.Currency = currency
+
+	return paymentService.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("COALESCE(NULLIF(received_currency, ''), currency) = ?", currency).
+			Where("id NOT IN (SELECT transaction_id FROM payment_submissions)").
+			Order("id")
+		if len(ids) > 0 {
+			query = query.Where("id IN (?)", ids)
+		} else {
+			query = query.Where("receiver IN (SELECT account FROM bank_accounts)")
+			if limit > 0 {
+				query = query.Limit(limit)
+			}
+		}
+		var transactions []Transaction
+		if err := query.Find(&transactions).Error; err != nil {
+			return err
+		}
+		if len(ids) > 0 && len(transactions) != len(uniqueIDs(ids)) {
+			found := make(map[uint]bool, len(transactions))
+			for _, transaction := range transactions {
+				found[transaction.ID] = true
+			}
+			for _, id := range ids {
+				if !found[id] {
+					return fmt.Errorf("%w: transaction %d", ErrNotPending, id)
+				}
+			}
+		}
+		if len(transactions) == 0 {
+			return ErrNoPendingPayments
+		}
+
+		payments := make([]PendingPayment, 0, len(transactions))
+		batch.Count, batch.Total = 0, 0
+		for _, transaction := range transactions {
+			var bankAccount BankAccount
+			if err := first(tx.Where("account = ?", transaction.Receiver), &bankAccount); err != nil {
+				if err == ErrNotFound {
+					return fmt.Errorf("%w: %s", ErrMissingBankAccount, transaction.Receiver)
+				}
+				return err
+			}
+			amount := transaction.ReceivedValue
+			if amount <= 0 {
+				amount = transaction.Value
+			}
+			amount = RoundAmount(amount, currency)
+			payments = append(payments, PendingPayment{Transaction: transaction, BankAccount: bankAccount, Amount: amount})
+			batch.Count++
+			batch.Total = RoundAmount(batch.Total+amount, currency)
+		}
+
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+		if err := render(batch, payments); err != nil {
+			return err
+		}
+		err := tx.Model(batch).UpdateColumns(map[string]interface{}{
+			"message_id": batch.MessageID,
+			"filename":   batch.Filename,
+			"content":    batch.Content,
+		}).Error
+		if err != nil {
+			return err
+		}
+		for _, payment := range payments {
+			submission := &PaymentSubmission{BatchID: batch.ID, TransactionID: payment.Transaction.ID}
+			if err := tx.Create(submission).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// normalize remove the spaces of the bank details, upper case them and check that they are valid
+func (bankAccount *BankAccount) normalize() error {
+	compact := func(value string) string {
+		return strings.ToUpper(strings.Join(strings.Fields(value), ""))
+	}
+	bankAccount.Account = strings.TrimSpace(bankAccount.Account)
+	bankAccount.HolderName = strings.TrimSpace(bankAccount.HolderName)
+	bankAccount.IBAN = compact(bankAccount.IBAN)
+	bankAccount.BIC = compact(bankAccount.BIC)
+	bankAccount.RoutingNumber = compact(bankAccount.RoutingNumber)
+	bankAccount.AccountNumber = compact(bankAccount.AccountNumber)
+	bankAccount.AccountType = strings.ToLower(strings.TrimSpace(bankAccount.AccountType))
+	if bankAccount.AccountType == "" {
+		bankAccount.AccountType = AccountChecking
+	}
+
+	switch {
+	case bankAccount.Account == "" || bankAccount.HolderName == "":
+		return fmt.Errorf("%w: account and holder name are required", ErrInvalidBankAccount)
+	case bankAccount.IBAN == "" && bankAccount.RoutingNumber == "":
+		return fmt.Errorf("%w: an IBAN or a routing number is required", ErrInvalidBankAccount)
+	case bankAccount.IBAN != "" && !isValidIBAN(bankAccount.IBAN):
+		return fmt.Errorf("%w: invalid IBAN", ErrInvalidBankAccount)
+	case bankAccount.BIC != "" && !bicPattern.MatchString(bankAccount.BIC):
+		return fmt.Errorf("%w: invalid BIC", ErrInvalidBankAccount)
+	case bankAccount.RoutingNumber != "" && !isValidRoutingNumber(bankAccount.RoutingNumber):
+		return fmt.Errorf("%w: invalid routing number", ErrInvalidBankAccount)
+	case bankAccount.RoutingNumber != "" && !accountNumberPattern.MatchString(bankAccount.AccountNumber):
+		return fmt.Errorf("%w: an account number of at most 17 characters is required with a routing number", ErrInvalidBankAccount)
+	case bankAccount.AccountType != AccountChecking && bankAccount.AccountType != AccountSavings:
+		return fmt.Errorf("%w: account type must be %s or %s", ErrInvalidBankAccount, AccountChecking, AccountSavings)
+	}
+	return nil
+}
+
+// isValidIBAN check the format and the ISO 7064 mod 97-10 check digits of an IBAN
+func isValidIBAN(iban string) bool {
+	if !ibanPattern.MatchString(iban) {
+		return false
+	}
+	var digits strings.Builder
+	for _, char := range iban[4:] + iban[:4] {
+		if char >= 'A' && char <= 'Z' {
+			fmt.Fprintf(&digits, "%d", char-'A'+10)
+		} else {
+			digits.WriteRune(char)
+		}
+	}
+	number, isOK := new(big.Int).SetString(digits.String(), 10)
+	return isOK && new(big.Int).Mod(number, big.NewInt(97)).Int64() == 1
+}
+
+// isValidRoutingNumber check the checksum of an ABA routing number
+func isValidRoutingNumber(routing string) bool {
+	if len(routing) != 9 {
+		return false
+	}
+	weights := []int{3, 7, 1, 3, 7, 1, 3, 7, 1}
+	sum := 0
+	for i, char := range routing {
+		if char < '0' || char > '9' {
+			return false
+		}
+		sum += int(char-'0') * weights[i]
+	}
+	return sum%10 == 0
+}
+
+// uniqueIDs return the IDs without duplicates
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := ids[:0:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
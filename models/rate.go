@@ -0,0 +1,323 @@
+package models
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCurrency is the currency of transactions and accounts that do not specify one.
+const DefaultCurrency = "USD"
+
+var (
+	// ErrInvalidCurrency is returned when a currency is not a three letters ISO 4217 code.
+	ErrInvalidCurrency = errors.New("models: currency must be a three letters ISO 4217 code")
+
+	// ErrInvalidRate is returned when an exchange rate is not a positive number.
+	ErrInvalidRate = errors.New("models: exchange rate must be positive")
+
+	// ErrRateNotFound is returned when no exchange rate is effective for a pair of currencies at a date.
+	ErrRateNotFound = errors.New("models: no exchange rate for this currency pair at this date")
+)
+
+// zeroDecimalCurrencies have no minor unit, amounts in them are rounded to whole units
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "ISK": true, "JPY": true, "KMF": true, "KRW": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// NormalizeCurrency upper case the provided currency code and check that it looks like an ISO 4217 code.
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if len(currency) != 3 {
+		return "", ErrInvalidCurrency
+	}
+	for _, letter := range currency {
+		if letter < 'A' || letter > 'Z' {
+			return "", ErrInvalidCurrency
+		}
+	}
+	return currency, nil
+}
+
+// RoundAmount round the amount to the minor unit of the currency.
+func RoundAmount(amount float64, currency string) float64 {
+	if zeroDecimalCurrencies[currency] {
+		return math.Round(amount)
+	}
+	return math.Round(amount*100) / 100
+}
+
+// CurrencyDecimals return the number of decimals of the minor unit of the currency.
+func CurrencyDecimals(currency string) int {
+	if zeroDecimalCurrencies[currency] {
+		return 0
+	}
+	return 2
+}
+
+// ExchangeRate is the price of one unit of Base in Quote, effective from EffectiveAt until the next
+// rate of the same pair.
+type ExchangeRate struct {
+	ID          uint `gorm:"primaryKey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Base        string    `json:"base" gorm:"not null;unique_index:idx_rate_pair_date"`
+	Quote       string    `json:"quote" gorm:"not null;unique_index:idx_rate_pair_date"`
+	Rate        float64   `json:"rate" gorm:"not null"`
+	EffectiveAt time.Time `json:"effectiveAt" gorm:"not null;unique_index:idx_rate_pair_date"`
+}
+
+type RateService struct {
+	db *gorm.DB
+}
+
+// NewRateService Create a new RateService with a specified connectionInfo.
+func NewRateService(db *gorm.DB) (*RateService, error) {
+	return &RateService{
+		db: db,
+	}, nil
+}
+
+// AutoMigrate will attempt to automatically migrate the exchange_rates table
+func (rateService *RateService) AutoMigrate() error {
+	if err := rateService.db.AutoMigrate(&ExchangeRate{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Set store the rate of a currency pair from its effective date, replacing the rate already stored
+// for the same pair and date.
+func (rateService *RateService) Set(rate *ExchangeRate) error {
+	var err error
+	if rate.Base, err = NormalizeCurrency(rate.Base); err != nil {
+		return err
+	}
+	if rate.Quote, err = NormalizeCurrency(rate.Quote); err != nil {
+		return err
+	}
+	if rate.Rate <= 0 || math.IsInf(rate.Rate, 0) || math.IsNaN(rate.Rate) {
+		return ErrInvalidRate
+	}
+	return rateService.db.Transaction(func(tx *gorm.DB) error {
+		var existing ExchangeRate
+		err := first(tx.Where("base = ? AND quote = ? AND effective_at = ?", rate.Base, rate.Quote, rate.EffectiveAt), &existing)
+		if err == ErrNotFound {
+			return tx.Create(rate).Error
+		}
+		if err != nil {
+			return err
+		}
+		rate.ID = existing.ID
+		rate.CreatedAt = existing.CreatedAt
+		return tx.Save(rate).Error
+	})
+}
+
+// ReadAll return every stored rate, optionally only those of a currency, ordered by pair and date.
+func (rateService *RateService) ReadAll(currency string) ([]ExchangeRate, error) {
+	var rates []ExchangeRate
+	db := rateService.db.Order("base, quote, effective_at")
+	if currency != "" {
+		db = db.Where("base = ? OR quote = ?", currency, currency)
+	}
+	if err := db.Find(&rates).Error; err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// Lookup return the rate converting from one currency to another effective at the provided date.
+// A rate stored for the inverse pair is inverted if there is none for the pair itself.
+func (rateService *RateService) Lookup(from, to string, at time.Time) (float64, error) {
+	return lookupRate(rateService.db, from, to, at)
+}
+
+// Convert convert the amount from one currency to another with the rate effective at the provided date.
+func (rateService *RateService) Convert(amount float64, from, to string, at time.Time) (float64, error) {
+	rate, err := rateService.Lookup(from, to, at)
+	if err != nil {
+		return 0, err
+	}
+	return RoundAmount(amount*rate, to), nil
+}
+
+// lookupRate implement RateService.Lookup with the provided gorm.DB so it can run inside a database transaction
+func lookupRate(db *gorm.DB, from, to string, at time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	var direct ExchangeRate
+	err := first(db.Where("base = ? AND quote = ? AND effective_at <= ?", from, to, at).Order("effective_at desc"), &direct)
+	if err == nil {
+		return direct.Rate, nil
+	}
+	if err != ErrNotFound {
+		return 0, err
+	}
+	var inverse ExchangeRate
+	err = first(db.Where("base = ? AND quote = ? AND effective_at <= ?", to, from, at).Order("effective_at desc"), &inverse)
+	if err == ErrNotFound {
+		return 0, ErrRateNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 1 / inverse.Rate, nil
+}
+
+// LoadCSV store every rate of a CSV file with a header of base,quote,rate,effective_at and return how
+// many were stored. Dates are either YYYY-MM-DD or RFC 3339.
+func (rateService *RateService) LoadCSV(r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return 0, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"base", "quote", "rate", "effective_at"} {
+		if _, isOK := columns[name]; !isOK {
+			return 0, fmt.Errorf("models: rates file is missing the %s column", name)
+		}
+	}
+
+	var rates []ExchangeRate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[columns["rate"]]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("models: line %d: invalid rate: %w", line, err)
+		}
+		effectiveAt, err := parseRateDate(record[columns["effective_at"]])
+		if err != nil {
+			return 0, fmt.Errorf("models: line %d: %w", line, err)
+		}
+		rates = append(rates, ExchangeRate{
+			Base:        record[columns["base"]],
+			Quote:       record[columns["quote"]],
+			Rate:        value,
+			EffectiveAt: effectiveAt,
+		})
+	}
+	return rateService.setAll(rates)
+}
+
+// LoadJSON store every rate of a JSON array of objects with base, quote, rate and effectiveAt keys and
+// return how many were stored. Dates are either YYYY-MM-DD or RFC 3339.
+func (rateService *RateService) LoadJSON(r io.Reader) (int, error) {
+	var records []struct {
+		Base        string  `json:"base"`
+		Quote       string  `json:"quote"`
+		Rate        float64 `json:"rate"`
+		EffectiveAt string  `json:"effectiveAt"`
+	}
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return 0, err
+	}
+
+	rates := make([]ExchangeRate, 0, len(records))
+	for i, record := range records {
+		effectiveAt, err := parseRateDate(record.EffectiveAt)
+		if err != nil {
+			return 0, fmt.Errorf("models: rate %d: %w", i+1, err)
+		}
+		rates = append(rates, ExchangeRate{
+			Base:        record.Base,
+			Quote:       record.Quote,
+			Rate:        record.Rate,
+			EffectiveAt: effectiveAt,
+		})
+	}
+	return rateService.setAll(rates)
+}
+
+// setAll store every rate in a single database transaction
+func (rateService *RateService) setAll(rates []ExchangeRate) (int, error) {
+	err := rateService.db.Transaction(func(tx *gorm.DB) error {
+		txService := &RateService{db: tx}
+		for i := range rates {
+			if err := txService.Set(&rates[i]); err != nil {
+				return fmt.Errorf("models: rate %s/%s: %w", rates[i].Base, rates[i].Quote, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// parseRateDate parse the effective date of a rate in a rates file
+func parseRateDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid effective date %q", value)
+	}
+	return date, nil
+}
+
+// ConvertedTotal is a CurrencyTotal together with its conversion into a base currency
+type ConvertedTotal struct {
+	CurrencyTotal
+	Rate      float64
+	Converted float64
+}
+
+// ConvertedTotals is the sum of amounts in several currencies converted into a single base currency
+type ConvertedTotals struct {
+	Base       string
+	Total      float64
+	Count      int
+	ByCurrency []ConvertedTotal
+}
+
+// ConvertTotals convert every total into the base currency with the rates effective at the provided
+// date and sum them.
+func (rateService *RateService) ConvertTotals(totals []CurrencyTotal, base string, at time.Time) (*ConvertedTotals, error) {
+	base, err := NormalizeCurrency(base)
+	if err != nil {
+		return nil, err
+	}
+	result := &ConvertedTotals{
+		Base:       base,
+		ByCurrency: make([]ConvertedTotal, 0, len(totals)),
+	}
+	for _, total := range totals {
+		rate, err := rateService.Lookup(total.Currency, base, at)
+		if err != nil {
+			return nil, fmt.Errorf("models: converting %s into %s: %w", total.Currency, base, err)
+		}
+		converted := ConvertedTotal{
+			CurrencyTotal: total,
+			Rate:          rate,
+			Converted:     RoundAmount(total.Total*rate, base),
+		}
+		result.Total += converted.Converted
+		result.Count += total.Count
+		result.ByCurrency = append(result.ByCurrency, converted)
+	}
+	result.Total = RoundAmount(result.Total, base)
+	return result, nil
+}
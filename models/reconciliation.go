@@ -0,0 +1,607 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Statuses of a StatementLine
+const (
+	LineUnmatched = "unmatched"
+	LineMatched   = "matched"
+)
+
+// Statuses of a ReconciliationMatch
+const (
+	MatchProposed  = "proposed"
+	MatchConfirmed = "confirmed"
+	MatchRejected  = "rejected"
+)
+
+// Weights of the criteria of the confidence score of a proposed match, they add up to 1
+const (
+	amountWeight       = 0.4
+	dateWeight         = 0.3
+	referenceWeight    = 0.2
+	counterpartyWeight = 0.1
+)
+
+// Defaults of MatchOptions
+const (
+	DefaultMatchDateWindow      = 3
+	DefaultMatchAmountTolerance = 0.005
+	DefaultMatchMinScore        = 0.4
+)
+
+// minReferenceLength is the shortest note or reference looked for in the other side of a match
+const minReferenceLength = 4
+
+var (
+	// ErrLineMatched is returned when a statement line that is already matched is paired again.
+	ErrLineMatched = errors.New("models: the statement line is already matched")
+
+	// ErrTransactionMatched is returned when a transaction already matched to a statement line is paired again.
+	ErrTransactionMatched = errors.New("models: the transaction is already matched to a statement line")
+
+	// ErrMatchResolved is returned when a match that was already confirmed or rejected is resolved again.
+	ErrMatchResolved = errors.New("models: the match was already confirmed or rejected")
+
+	// ErrNotAccountTransaction is returned when a statement line is paired with a transaction of another account.
+	ErrNotAccountTransaction = errors.New("models: the transaction does not belong to the account of the statement line")
+)
+
+// StatementLine is an entry of a bank statement loaded for reconciliation. Unlike an ImportedEntry it
+// does not create a transaction, it is matched against the transactions already recorded for the
+// account. TransactionID is set once the line is matched.
+type StatementLine struct {
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	Account       string     `json:"account" gorm:"not null;index"`
+	FITID         string     `json:"fitID,omitempty" gorm:"column:fit_id;not null;default:''"`
+	Reference     string     `json:"reference,omitempty" gorm:"not null;default:''"`
+	BookedAt      time.Time  `json:"bookedAt" gorm:"not null"`
+	ValueAt       *time.Time `json:"valueAt,omitempty"`
+	Amount        float64    `json:"amount" gorm:"not null"`
+	Currency      string     `json:"currency,omitempty" gorm:"not null;default:''"`
+	Payee         string     `json:"payee,omitempty" gorm:"not null;default:''"`
+	Memo          string     `json:"memo,omitempty" gorm:"not null;default:''"`
+	Status        string     `json:"status" gorm:"not null;default:'unmatched'"`
+	TransactionID *uint      `json:"transactionID,omitempty" gorm:"index"`
+}
+
+// ReconciliationMatch pair a StatementLine with a Transaction. Matches are proposed with a confidence
+// Score between 0 and 1 by Match, or created confirmed by Pair when an operator pairs them by hand.
+// Reasons lists the criteria that contributed to the score.
+type ReconciliationMatch struct {
+	ID              uint `gorm:"primaryKey"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	StatementLineID uint       `json:"statementLineID" gorm:"not null;index"`
+	TransactionID   uint       `json:"transactionID" gorm:"not null;index"`
+	Score           float64    `json:"score" gorm:"not null;default:0"`
+	Reasons         string     `json:"reasons,omitempty" gorm:"not null;default:''"`
+	Status          string     `json:"status" gorm:"not null;default:'proposed'"`
+	Manual          bool       `json:"manual" gorm:"not null;default:false"`
+	Note            string     `json:"note,omitempty" gorm:"not null;default:''"`
+	ResolvedBy      string     `json:"resolvedBy,omitempty" gorm:"not null;default:''"`
+	ResolvedAt      *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// MatchOptions tune how Match looks for the transaction of a statement line. A transaction is a
+// candidate when it was created at most DateWindow days from the booking or value date of the line and
+// its amount is within AmountTolerance of the line. Candidates scoring less than MinScore are not proposed.
+type MatchOptions struct {
+	DateWindow      int
+	AmountTolerance float64
+	MinScore        float64
+}
+
+type ReconciliationService struct {
+	db    *gorm.DB
+	actor string
+}
+
+// NewReconciliationService Create a new ReconciliationService with a specified connectionInfo.
+func NewReconciliationService(db *gorm.DB) (*ReconciliationService, error) {
+	return &ReconciliationService{
+		db: db,
+	}, nil
+}
+
+// WithActor return a copy of the ReconciliationService that records the provided actor in the audit log
+// for every match it confirms, rejects or pairs.
+func (reconService *ReconciliationService) WithActor(actor string) *ReconciliationService {
+	return &ReconciliationService{
+		db:    reconService.db,
+		actor: actor,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the statement lines and reconciliation matches tables
+func (reconService *ReconciliationService) AutoMigrate() error {
+	if err := reconService.db.AutoMigrate(&StatementLine{}, &ReconciliationMatch{}).Error; err != nil {
+		return err
+	}
+	// FITIDs are unique per account and a transaction is confirmed for a single statement line
+	indexes := []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_statement_line_fit_id ON statement_lines (account, fit_id) WHERE fit_id <> ''`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_reconciliation_match_confirmed ON reconciliation_matches (transaction_id) WHERE status = 'confirmed'`,
+	}
+	for _, index := range indexes {
+		if err := reconService.db.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddLines load the entries of a statement of the account as unmatched statement lines and return the
+// lines created. Entries with a FITID already loaded for the account are skipped and counted.
+func (reconService *ReconciliationService) AddLines(account string, entries []StatementEntry) ([]StatementLine, int, error) {
+	var owner User
+	if err := first(reconService.db.Where("email = ?", account), &owner); err != nil {
+		if err == ErrNotFound {
+			return nil, 0, ErrUnknownAccount
+		}
+		return nil, 0, err
+	}
+
+	var lines []StatementLine
+	skipped := 0
+	err := reconService.db.Transaction(func(tx *gorm.DB) error {
+		for _, entry := range entries {
+			if entry.FITID != "" {
+				var count int
+				if err := tx.Model(&StatementLine{}).Where("account = ? AND fit_id = ?", account, entry.FITID).Count(&count).Error; err != nil {
+					return err
+				}
+				if count > 0 {
+					skipped++
+					continue
+				}
+			}
+			line := StatementLine{
+				Account:   account,
+				FITID:     entry.FITID,
+				Reference: strings.TrimSpace(entry.Reference),
+				BookedAt:  entry.PostedAt,
+				ValueAt:   entry.ValueAt,
+				Amount:    entry.Amount,
+				Currency:  entry.Currency,
+				Payee:     strings.TrimSpace(entry.Payee),
+				Memo:      strings.TrimSpace(entry.Memo),
+				Status:    LineUnmatched,
+			}
+			if err := tx.Create(&line).Error; err != nil {
+				return err
+			}
+			lines = append(lines, line)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return lines, skipped, nil
+}
+
+// ReadLine will look up the statement line with the provided ID
+func (reconService *ReconciliationService) ReadLine(id uint) (*StatementLine, error) {
+	var line StatementLine
+	if err := first(reconService.db.Where("id = ?", id), &line); err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
+
+// ReadMatch will look up the reconciliation match with the provided ID
+func (reconService *ReconciliationService) ReadMatch(id uint) (*ReconciliationMatch, error) {
+	var match ReconciliationMatch
+	if err := first(reconService.db.Where("id = ?", id), &match); err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// ReadMatches return the matches of the statement lines of the account with the provided status, every
+// match when status is empty, highest score first.
+func (reconService *ReconciliationService) ReadMatches(account, status string) ([]ReconciliationMatch, error) {
+	db := reconService.db.
+		Where("statement_line_id IN (SELECT id FROM statement_lines WHERE account = ?)", account)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	var matches []ReconciliationMatch
+	if err := db.Order("score DESC, id").Find(&matches).Error; err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// UnmatchedLines return the statement lines of the account that are not matched to a transaction yet,
+// oldest first.
+func (reconService *ReconciliationService) UnmatchedLines(account string) ([]StatementLine, error) {
+	var lines []StatementLine
+	err := reconService.db.
+		Where("account = ? AND status = ?", account, LineUnmatched).
+		Order("booked_at, id").
+		Find(&lines).Error
+	if err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// UnmatchedTransactions return the transactions of the account created between from and to that no
+// statement line is matched to, oldest first. Transactions imported from a statement come from the bank
+// and are never returned. When from or to is nil the period covered by the statement lines of the
+// account is used.
+func (reconService *ReconciliationService) UnmatchedTransactions(account string, from, to *time.Time) ([]Transaction, error) {
+	if from == nil || to == nil {
+		var span struct {
+			First *time.Time
+			Last  *time.Time
+		}
+		err := reconService.db.Model(&StatementLine{}).
+			Select("MIN(booked_at) AS first, MAX(booked_at) AS last").
+			Where("account = ?", account).
+			Scan(&span).Error
+		if err != nil {
+			return nil, err
+		}
+		if span.First == nil {
+			return nil, nil
+		}
+		if from == nil {
+			from = span.First
+		}
+		if to == nil {
+			last := span.Last.AddDate(0, 0, 1)
+			to = &last
+		}
+	}
+
+	var transactions []Transaction
+	err := reconService.unmatchedTransactions(reconService.db).
+		Where("sender = ? OR receiver = ?", account, account).
+		Where("created_at >= ? AND created_at < ?", *from, *to).
+		Order("created_at, id").
+		Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// unmatchedTransactions scope the provided gorm.DB to the transactions that are neither imported from a
+// statement nor confirmed for a statement line
+func (reconService *ReconciliationService) unmatchedTransactions(db *gorm.DB) *gorm.DB {
+	return db.
+		Where("id NOT IN (SELECT transaction_id FROM imported_entries)").
+		Where("id NOT IN (SELECT transaction_id FROM reconciliation_matches WHERE status = ?)", MatchConfirmed)
+}
+
+// candidate is a transaction that could be the transaction of a statement line
+type candidate struct {
+	line        StatementLine
+	transaction Transaction
+	score       float64
+	reasons     []string
+}
+
+// Match propose a transaction for every unmatched statement line of the account and return the proposed
+// matches, highest score first. Every candidate of every line is scored on how close its amount and date
+// are to the line, whether the reference of the line is found in the transaction and whether the payee
+// is the other party of the transaction. The best scoring pairs are proposed first so a transaction is
+// proposed for a single line. Matches proposed by an earlier run are replaced, rejected pairs are never
+// proposed again.
+func (reconService *ReconciliationService) Match(account string, options MatchOptions) ([]ReconciliationMatch, error) {
+	if options.DateWindow <= 0 {
+		options.DateWindow = DefaultMatchDateWindow
+	}
+	if options.AmountTolerance <= 0 {
+		options.AmountTolerance = DefaultMatchAmountTolerance
+	}
+	if options.MinScore <= 0 {
+		options.MinScore = DefaultMatchMinScore
+	}
+
+	var matches []ReconciliationMatch
+	err := reconService.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("status = ? AND statement_line_id IN (SELECT id FROM statement_lines WHERE account = ?)",
+			MatchProposed, account).Delete(&ReconciliationMatch{}).Error
+		if err != nil {
+			return err
+		}
+		var lines []StatementLine
+		err = tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("account = ? AND status = ?", account, LineUnmatched).
+			Order("id").
+			Find(&lines).Error
+		if err != nil {
+			return err
+		}
+
+		var candidates []candidate
+		for _, line := range lines {
+			found, err := reconService.candidates(tx, line, options)
+			if err != nil {
+				return err
+			}
+			candidates = append(candidates, found...)
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].score != candidates[j].score {
+				return candidates[i].score > candidates[j].score
+			}
+			if candidates[i].line.ID != candidates[j].line.ID {
+				return candidates[i].line.ID < candidates[j].line.ID
+			}
+			return candidates[i].transaction.ID < candidates[j].transaction.ID
+		})
+
+		matchedLines := make(map[uint]bool)
+		matchedTransactions := make(map[uint]bool)
+		for _, found := range candidates {
+			if found.score < options.MinScore || matchedLines[found.line.ID] || matchedTransactions[found.transaction.ID] {
+				continue
+			}
+			match := ReconciliationMatch{
+				StatementLineID: found.line.ID,
+				TransactionID:   found.transaction.ID,
+				Score:           math.Round(found.score*1000) / 1000,
+				Reasons:         strings.Join(found.reasons, ", "),
+				Status:          MatchProposed,
+			}
+			if err := tx.Create(&match).Error; err != nil {
+				return err
+			}
+			matchedLines[found.line.ID] = true
+			matchedTransactions[found.transaction.ID] = true
+			matches = append(matches, match)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// candidates return the scored transactions that could be the transaction of the line. A positive line
+// is credited to the account so its transactions are received by the account, a negative one is sent.
+func (reconService *ReconciliationService) candidates(db *gorm.DB, line StatementLine, options MatchOptions) ([]candidate, error) {
+	amount := math.Abs(line.Amount)
+	start, end := line.BookedAt, line.BookedAt
+	if line.ValueAt != nil {
+		if line.ValueAt.Before(start) {
+			start = *line.ValueAt
+		} else {
+			end = *line.ValueAt
+		}
+	}
+	window := time.Duration(options.DateWindow) * 24 * time.Hour
+
+	db = reconService.unmatchedTransactions(db).
+		Where("created_at >= ? AND created_at <= ?", start.Add(-window), end.Add(window)).
+		Where("id NOT IN (SELECT transaction_id FROM reconciliation_matches WHERE statement_line_id = ? AND status = ?)",
+			line.ID, MatchRejected)
+	if line.Amount >= 0 {
+		db = db.Where("receiver = ? AND ABS(CASE WHEN received_value > 0 THEN received_value ELSE value END - ?) <= ?",
+			line.Account, amount, options.AmountTolerance)
+		if line.Currency != "" {
+			db = db.Where("COALESCE(NULLIF(received_currency, ''), currency) = ?", line.Currency)
+		}
+	} else {
+		db = db.Where("sender = ? AND ABS(value - ?) <= ?", line.Account, amount, options.AmountTolerance)
+		if line.Currency != "" {
+			db = db.Where("currency = ?", line.Currency)
+		}
+	}
+	var transactions []Transaction
+	if err := db.Order("id").Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := make([]candidate, 0, len(transactions))
+	for _, transaction := range transactions {
+		candidates = append(candidates, line.score(transaction, options))
+	}
+	return candidates, nil
+}
+
+// score rate how likely the transaction is the transaction of the line
+func (line StatementLine) score(transaction Transaction, options MatchOptions) candidate {
+	found := candidate{line: line, transaction: transaction}
+
+	value, counterparty := transaction.Value, transaction.Receiver
+	if line.Amount >= 0 {
+		counterparty = transaction.Sender
+		if transaction.ReceivedValue > 0 {
+			value = transaction.ReceivedValue
+		}
+	}
+	difference := math.Abs(math.Abs(line.Amount) - value)
+	if difference < DefaultMatchAmountTolerance {
+		found.score += amountWeight
+		found.reasons = append(found.reasons, "exact amount")
+	} else {
+		found.score += amountWeight * (1 - difference/(options.AmountTolerance+DefaultMatchAmountTolerance))
+		found.reasons = append(found.reasons, fmt.Sprintf("amount within %.2f", difference))
+	}
+
+	days := math.Abs(transaction.CreatedAt.Sub(line.BookedAt).Hours()) / 24
+	if line.ValueAt != nil {
+		days = math.Min(days, math.Abs(transaction.CreatedAt.Sub(*line.ValueAt).Hours())/24)
+	}
+	days = math.Floor(days)
+	found.score += dateWeight * (1 - days/float64(options.DateWindow+1))
+	if days == 0 {
+		found.reasons = append(found.reasons, "same day")
+	} else {
+		found.reasons = append(found.reasons, fmt.Sprintf("%.0f days apart", days))
+	}
+
+	if line.matchesReference(transaction) {
+		found.score += referenceWeight
+		found.reasons = append(found.reasons, "reference")
+	}
+	if counterparty != "" && line.Payee != "" && strings.EqualFold(strings.TrimSpace(counterparty), line.Payee) {
+		found.score += counterpartyWeight
+		found.reasons = append(found.reasons, "counterparty")
+	}
+	return found
+}
+
+// matchesReference return true if the line refers to the transaction, its reference or memo holds the
+// end to end identifier payment files give the transaction or the note of the transaction, or the note
+// holds the reference of the line.
+func (line StatementLine) matchesReference(transaction Transaction) bool {
+	text := strings.ToUpper(line.Reference + " " + line.Memo)
+	endToEndID := fmt.Sprintf("TX%d", transaction.ID)
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return r == ' ' || r == '/' || r == ',' || r == ':' }) {
+		if word == endToEndID {
+			return true
+		}
+	}
+	note := strings.ToUpper(strings.TrimSpace(transaction.Note))
+	if len(note) >= minReferenceLength && strings.Contains(text, note) {
+		return true
+	}
+	reference := strings.ToUpper(line.Reference)
+	return len(reference) >= minReferenceLength && strings.Contains(note, reference)
+}
+
+// Confirm accept a proposed match, the statement line is matched to the transaction.
+func (reconService *ReconciliationService) Confirm(id uint) (*ReconciliationMatch, error) {
+	return reconService.resolve(id, MatchConfirmed, "")
+}
+
+// Reject refuse a proposed match, the pair is not proposed again. note records why.
+func (reconService *ReconciliationService) Reject(id uint, note string) (*ReconciliationMatch, error) {
+	return reconService.resolve(id, MatchRejected, note)
+}
+
+// resolve confirm or reject the proposed match with the provided ID and record it in the audit log
+func (reconService *ReconciliationService) resolve(id uint, status, note string) (*ReconciliationMatch, error) {
+	var match ReconciliationMatch
+	err := reconService.db.Transaction(func(tx *gorm.DB) error {
+		if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id), &match); err != nil {
+			return err
+		}
+		if match.Status != MatchProposed {
+			return ErrMatchResolved
+		}
+		before := match
+		if status == MatchConfirmed {
+			if err := reconService.matchLine(tx, match.StatementLineID, match.TransactionID); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		match.Status = status
+		match.Note = strings.TrimSpace(note)
+		match.ResolvedBy = reconService.actorName()
+		match.ResolvedAt = &now
+		if err := tx.Save(&match).Error; err != nil {
+			return err
+		}
+		operation := AuditConfirm
+		if status == MatchRejected {
+			operation = AuditReject
+		}
+		return recordAudit(tx, reconService.actor, operation, AuditEntityReconciliation, match.ID, before, match)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// Pair match the statement line with the transaction by hand, the match is created confirmed and recorded
+// in the audit log. The matches proposed for the line or the transaction are rejected.
+func (reconService *ReconciliationService) Pair(lineID, transactionID uint, note string) (*ReconciliationMatch, error) {
+	var match ReconciliationMatch
+	err := reconService.db.Transaction(func(tx *gorm.DB) error {
+		if err := reconService.matchLine(tx, lineID, transactionID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		err := tx.Model(&ReconciliationMatch{}).
+			Where("status = ? AND (statement_line_id = ? OR transaction_id = ?)", MatchProposed, lineID, transactionID).
+			Updates(map[string]interface{}{
+				"status":      MatchRejected,
+				"note":        "superseded by a manual match",
+				"resolved_by": reconService.actorName(),
+				"resolved_at": now,
+			}).Error
+		if err != nil {
+			return err
+		}
+		match = ReconciliationMatch{
+			StatementLineID: lineID,
+			TransactionID:   transactionID,
+			Score:           1,
+			Reasons:         "manual",
+			Status:          MatchConfirmed,
+			Manual:          true,
+			Note:            strings.TrimSpace(note),
+			ResolvedBy:      reconService.actorName(),
+			ResolvedAt:      &now,
+		}
+		if err := tx.Create(&match).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, reconService.actor, AuditCreate, AuditEntityReconciliation, match.ID, nil, match)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &match, nil
+}
+
+// matchLine mark the statement line as matched to the transaction using the provided gorm.DB, after
+// checking that neither is matched yet and that the transaction belongs to the account of the line
+func (reconService *ReconciliationService) matchLine(tx *gorm.DB, lineID, transactionID uint) error {
+	var line StatementLine
+	if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", lineID), &line); err != nil {
+		return err
+	}
+	if line.Status != LineUnmatched {
+		return ErrLineMatched
+	}
+	var transaction Transaction
+	if err := first(tx.Where("id = ?", transactionID), &transaction); err != nil {
+		return err
+	}
+	if transaction.Sender != line.Account && transaction.Receiver != line.Account {
+		return ErrNotAccountTransaction
+	}
+	var count int
+	err := reconService.unmatchedTransactions(tx.Model(&Transaction{})).Where("id = ?", transactionID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTransactionMatched
+	}
+	return tx.Model(&line).UpdateColumns(map[string]interface{}{
+		"status":         LineMatched,
+		"transaction_id": transactionID,
+	}).Error
+}
+
+// actorName return the actor recorded as the operator who resolved a match
+func (reconService *ReconciliationService) actorName() string {
+	if reconService.actor == "" {
+		return SystemActor
+	}
+	return reconService.actor
+}
@@ -0,0 +1,220 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies of a Recurrence, an empty frequency means the schedule happens only once
+const (
+	FrequencyDaily   = "DAILY"
+	FrequencyWeekly  = "WEEKLY"
+	FrequencyMonthly = "MONTHLY"
+	FrequencyYearly  = "YEARLY"
+)
+
+// ErrInvalidRule is returned when a recurrence rule can not be parsed.
+var ErrInvalidRule = errors.New("models: invalid recurrence rule")
+
+// maxEmptyPeriods stop the walk over a rule that never produces an occurrence, like the 31st of
+// every February
+const maxEmptyPeriods = 1000
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Recurrence is a subset of the RFC 5545 RRULE: a frequency, an interval and, for weekly and monthly
+// rules, the days of the week or of the month. Count and end date are kept on the schedule.
+type Recurrence struct {
+	Frequency  string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+}
+
+// ParseRecurrence parse a rule like "FREQ=MONTHLY;INTERVAL=1;BYMONTHDAY=1" or one of the shorthands
+// daily, weekly, monthly and yearly. An empty rule is a one-off.
+func ParseRecurrence(rule string) (Recurrence, error) {
+	rule = strings.ToUpper(strings.TrimSpace(rule))
+	recurrence := Recurrence{Interval: 1}
+	switch rule {
+	case "", "ONCE":
+		recurrence.Frequency = ""
+		return recurrence, nil
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyYearly:
+		recurrence.Frequency = rule
+		return recurrence, nil
+	}
+
+	for _, part := range strings.Split(strings.TrimPrefix(rule, "RRULE:"), ";") {
+		name, value, isOK := strings.Cut(part, "=")
+		if !isOK {
+			return recurrence, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+		switch name {
+		case "FREQ":
+			switch value {
+			case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyYearly:
+				recurrence.Frequency = value
+			default:
+				return recurrence, fmt.Errorf("%w: unsupported frequency %q", ErrInvalidRule, value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return recurrence, fmt.Errorf("%w: invalid interval %q", ErrInvalidRule, value)
+			}
+			recurrence.Interval = interval
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				weekday, isOK := weekdayCodes[code]
+				if !isOK {
+					return recurrence, fmt.Errorf("%w: invalid day %q", ErrInvalidRule, code)
+				}
+				recurrence.ByDay = append(recurrence.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, err := strconv.Atoi(code)
+				if err != nil || day == 0 || day < -31 || day > 31 {
+					return recurrence, fmt.Errorf("%w: invalid day of month %q", ErrInvalidRule, code)
+				}
+				recurrence.ByMonthDay = append(recurrence.ByMonthDay, day)
+			}
+		default:
+			return recurrence, fmt.Errorf("%w: unsupported part %q, use the schedule end date and count instead of COUNT and UNTIL", ErrInvalidRule, name)
+		}
+	}
+	if recurrence.Frequency == "" {
+		return recurrence, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if len(recurrence.ByDay) > 0 && recurrence.Frequency != FrequencyWeekly {
+		return recurrence, fmt.Errorf("%w: BYDAY is only supported with FREQ=WEEKLY", ErrInvalidRule)
+	}
+	if len(recurrence.ByMonthDay) > 0 && recurrence.Frequency != FrequencyMonthly {
+		return recurrence, fmt.Errorf("%w: BYMONTHDAY is only supported with FREQ=MONTHLY", ErrInvalidRule)
+	}
+	return recurrence, nil
+}
+
+// String format the recurrence as a rule ParseRecurrence accepts, empty for a one-off
+func (recurrence Recurrence) String() string {
+	if recurrence.Frequency == "" {
+		return ""
+	}
+	parts := []string{"FREQ=" + recurrence.Frequency}
+	if recurrence.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(recurrence.Interval))
+	}
+	if len(recurrence.ByDay) > 0 {
+		codes := make([]string, 0, len(recurrence.ByDay))
+		for _, weekday := range recurrence.ByDay {
+			for code, day := range weekdayCodes {
+				if day == weekday {
+					codes = append(codes, code)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if len(recurrence.ByMonthDay) > 0 {
+		days := make([]string, 0, len(recurrence.ByMonthDay))
+		for _, day := range recurrence.ByMonthDay {
+			days = append(days, strconv.Itoa(day))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// each call yield with every occurrence of the recurrence from start, in order, until yield returns
+// false. A one-off only occurs at start.
+func (recurrence Recurrence) each(start time.Time, yield func(time.Time) bool) {
+	if recurrence.Frequency == "" {
+		yield(start)
+		return
+	}
+	interval := recurrence.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	emptyPeriods := 0
+	for period := 0; emptyPeriods < maxEmptyPeriods; period++ {
+		candidates := recurrence.period(start, period*interval)
+		yielded := false
+		for _, candidate := range candidates {
+			if candidate.Before(start) {
+				continue
+			}
+			yielded = true
+			if !yield(candidate) {
+				return
+			}
+		}
+		if yielded {
+			emptyPeriods = 0
+		} else {
+			emptyPeriods++
+		}
+	}
+}
+
+// period return the sorted candidate occurrences of the period that is offset frequency units after
+// the period of start
+func (recurrence Recurrence) period(start time.Time, offset int) []time.Time {
+	hour, min, sec := start.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, start.Nanosecond(), start.Location())
+	}
+
+	var candidates []time.Time
+	switch recurrence.Frequency {
+	case FrequencyDaily:
+		candidates = append(candidates, start.AddDate(0, 0, offset))
+	case FrequencyWeekly:
+		// Weeks start on Monday like the RRULE default
+		monday := start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+7*offset)
+		weekdays := recurrence.ByDay
+		if len(weekdays) == 0 {
+			weekdays = []time.Weekday{start.Weekday()}
+		}
+		for _, weekday := range weekdays {
+			candidates = append(candidates, monday.AddDate(0, 0, (int(weekday)+6)%7))
+		}
+	case FrequencyMonthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(offset), 1, 0, 0, 0, 0, start.Location())
+		daysInMonth := first.AddDate(0, 1, -1).Day()
+		days := recurrence.ByMonthDay
+		if len(days) == 0 {
+			days = []int{start.Day()}
+		}
+		for _, day := range days {
+			if day < 0 {
+				day = daysInMonth + day + 1
+			}
+			if day >= 1 && day <= daysInMonth {
+				candidates = append(candidates, at(first.Year(), first.Month(), day))
+			}
+		}
+	case FrequencyYearly:
+		candidate := at(start.Year()+offset, start.Month(), start.Day())
+		if candidate.Day() == start.Day() { // February 29 only occurs on leap years
+			candidates = append(candidates, candidate)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	unique := candidates[:0]
+	for i, candidate := range candidates {
+		if i == 0 || !candidate.Equal(candidates[i-1]) {
+			unique = append(unique, candidate)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"math"
+	"strings"
+	"time"
+)
+
+// Statuses of a PaymentRequest
+const (
+	RequestPending  = "pending"
+	RequestAccepted = "accepted"
+	RequestDeclined = "declined"
+	RequestExpired  = "expired"
+)
+
+// DefaultRequestExpiry is how long a payment request stays pending when it has no expiry date
+const DefaultRequestExpiry = 7 * 24 * time.Hour
+
+var (
+	// ErrInvalidRequest is returned when a payment request has no positive amount, is sent to its requester or
+	// to someone who is not a user, or expires in the past
+	ErrInvalidRequest = errors.New("models: invalid payment request")
+
+	// ErrRequestResolved is returned when a payment request that is no longer pending is accepted or declined
+	ErrRequestResolved = errors.New("models: the payment request was already accepted, declined or expired")
+
+	// ErrRequestExpired is returned when a payment request is accepted or declined after its expiry date
+	ErrRequestExpired = errors.New("models: the payment request has expired")
+)
+
+// PaymentRequest is an Amount the Requester asks the Payer to pay. Accepting it creates the transaction
+// TransactionID from the payer to the requester, declining it or letting it expire closes it without one.
+type PaymentRequest struct {
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Requester     string     `json:"requester" gorm:"not null;index"`
+	Payer         string     `json:"payer" gorm:"not null;index"`
+	Amount        float64    `json:"amount" gorm:"not null"`
+	Currency      string     `json:"currency" gorm:"not null"`
+	Note          string     `json:"note,omitempty" gorm:"not null;default:''"`
+	Status        string     `json:"status" gorm:"not null;default:'pending';index"`
+	ExpiresAt     time.Time  `json:"expiresAt" gorm:"not null;index"`
+	Reason        string     `json:"reason,omitempty" gorm:"not null;default:''"`
+	ResolvedAt    *time.Time `json:"resolvedAt,omitempty"`
+	TransactionID *uint      `json:"transactionID,omitempty"`
+}
+
+type PaymentRequestService struct {
+	db    *gorm.DB
+	actor string
+}
+
+// NewPaymentRequestService Create a new PaymentRequestService with a specified connectionInfo.
+func NewPaymentRequestService(db *gorm.DB) (*PaymentRequestService, error) {
+	return &PaymentRequestService{
+		db: db,
+	}, nil
+}
+
+// WithActor return a copy of the service whose changes are recorded in the audit log under the provided actor
+func (requestService *PaymentRequestService) WithActor(actor string) *PaymentRequestService {
+	return &PaymentRequestService{
+		db:    requestService.db,
+		actor: actor,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the payment requests table
+func (requestService *PaymentRequestService) AutoMigrate() error {
+	return requestService.db.AutoMigrate(&PaymentRequest{}).Error
+}
+
+// Create validate and create the pending payment request. The currency defaults to the currency of the
+// requester account and the request expires after DefaultRequestExpiry when it has no expiry date.
+func (requestService *PaymentRequestService) Create(request *PaymentRequest) error {
+	request.Requester = strings.TrimSpace(request.Requester)
+	request.Payer = strings.TrimSpace(request.Payer)
+	if request.Requester == "" || request.Payer == "" {
+		return fmt.Errorf("%w: requester and payer are required", ErrInvalidRequest)
+	}
+	if strings.EqualFold(request.Requester, request.Payer) {
+		return fmt.Errorf("%w: you can not request a payment from yourself", ErrInvalidRequest)
+	}
+	if math.IsNaN(request.Amount) || math.IsInf(request.Amount, 0) || request.Amount <= 0 {
+		return fmt.Errorf("%w: amount must be a positive number", ErrInvalidRequest)
+	}
+	now := gorm.NowFunc()
+	if request.ExpiresAt.IsZero() {
+		request.ExpiresAt = now.Add(DefaultRequestExpiry)
+	} else if !request.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: the expiry date is in the past", ErrInvalidRequest)
+	}
+	request.Status = RequestPending
+	request.Note = strings.TrimSpace(request.Note)
+
+	return requestService.db.Transaction(func(tx *gorm.DB) error {
+		if err := first(tx.Where("email = ?", request.Payer), &User{}); err == ErrNotFound {
+			return fmt.Errorf("%w: %q is not a user", ErrInvalidRequest, request.Payer)
+		} else if err != nil {
+			return err
+		}
+		var err error
+		if request.Currency == "" {
+			request.Currency = accountCurrency(tx, request.Requester)
+		}
+		if request.Currency, err = NormalizeCurrency(request.Currency); err != nil {
+			return err
+		}
+		request.Amount = RoundAmount(request.Amount, request.Currency)
+		if err := tx.Create(request).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, requestService.actor, AuditCreate, AuditEntityPaymentRequest, request.ID, nil, request)
+	})
+}
+
+// ReadByID will look up a payment request with the provided ID
+func (requestService *PaymentRequestService) ReadByID(id uint) (*PaymentRequest, error) {
+	var request PaymentRequest
+	if err := first(requestService.db.Where("id = ?", id), &request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// ReadIncoming return the payment requests the payer is asked to pay with the provided status, or with any
+// status if it is empty, newest first
+func (requestService *PaymentRequestService) ReadIncoming(payer, status string) ([]PaymentRequest, error) {
+	return requestService.read("payer = ?", payer, status)
+}
+
+// ReadOutgoing return the payment requests made by the requester with the provided status, or with any
+// status if it is empty, newest first
+func (requestService *PaymentRequestService) ReadOutgoing(requester, status string) ([]PaymentRequest, error) {
+	return requestService.read("requester = ?", requester, status)
+}
+
+// read return the payment requests of an account with the provided status, newest first. Pending requests
+// past their expiry date that were not expired yet by ExpireDue are reported as expired.
+func (requestService *PaymentRequestService) read(query, account, status string) ([]PaymentRequest, error) {
+	now := gorm.NowFunc()
+	db := requestService.db.Where(query, account)
+	switch status {
+	case "":
+	case RequestPending:
+		db = db.Where("status = ? AND expires_at > ?", RequestPending, now)
+	case RequestExpired:
+		db = db.Where("status = ? OR (status = ? AND expires_at <= ?)", RequestExpired, RequestPending, now)
+	default:
+		db = db.Where("status = ?", status)
+	}
+	var requests []PaymentRequest
+	if err := db.Order("id DESC").Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	for i := range requests {
+		if requests[i].Status == RequestPending && !requests[i].ExpiresAt.After(now) {
+			requests[i].Status = RequestExpired
+		}
+	}
+	return requests, nil
+}
+
+// Accept pay the pending payment request with the provided ID with a transaction from the payer to the
+// requester, the request and the transaction are created together or not at all
+func (requestService *PaymentRequestService) Accept(id uint) (*PaymentRequest, error) {
+	return requestService.resolve(id, RequestAccepted, "")
+}
+
+// Decline refuse the pending payment request with the provided ID, reason records why
+func (requestService *PaymentRequestService) Decline(id uint, reason string) (*PaymentRequest, error) {
+	return requestService.resolve(id, RequestDeclined, reason)
+}
+
+// resolve accept or decline the payment request with the provided ID and record it in the audit log.
+// A request past its expiry date is expired instead and ErrRequestExpired is returned.
+func (requestService *PaymentRequestService) resolve(id uint, status, reason string) (*PaymentRequest, error) {
+	var request PaymentRequest
+	expired := false
+	err := requestService.db.Transaction(func(tx *gorm.DB) error {
+		if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id), &request); err != nil {
+			return err
+		}
+		if request.Status != RequestPending {
+			return ErrRequestResolved
+		}
+		now := gorm.NowFunc()
+		if !request.ExpiresAt.After(now) {
+			expired = true
+			return expireRequest(tx, requestService.actor, &request, now)
+		}
+
+		before := request
+		if status == RequestAccepted {
+			transaction := Transaction{
+				Value:    request.Amount,
+				Note:     request.Note,
+				Sender:   request.Payer,
+				Receiver: request.Requester,
+				Currency: request.Currency,
+			}
+			txService := &TransactionService{db: tx, actor: requestService.actor}
+			if err := txService.Create(&transaction); err != nil {
+				return err
+			}
+			request.TransactionID = &transaction.ID
+		}
+		request.Status = status
+		request.Reason = strings.TrimSpace(reason)
+		request.ResolvedAt = &now
+		if err := tx.Save(&request).Error; err != nil {
+			return err
+		}
+		operation := AuditConfirm
+		if status == RequestDeclined {
+			operation = AuditReject
+		}
+		return recordAudit(tx, requestService.actor, operation, AuditEntityPaymentRequest, request.ID, before, request)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return &request, ErrRequestExpired
+	}
+	return &request, nil
+}
+
+// ExpireDue expire every pending payment request whose expiry date is not after now and return how many
+// were expired
+func (requestService *PaymentRequestService) ExpireDue(now time.Time) (int, error) {
+	expired := 0
+	err := requestService.db.Transaction(func(tx *gorm.DB) error {
+		var requests []PaymentRequest
+		err := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("status = ? AND expires_at <= ?", RequestPending, now).Find(&requests).Error
+		if err != nil {
+			return err
+		}
+		for i := range requests {
+			if err := expireRequest(tx, requestService.actor, &requests[i], now); err != nil {
+				return err
+			}
+		}
+		expired = len(requests)
+		return nil
+	})
+	return expired, err
+}
+
+// expireRequest mark the pending payment request as expired and record it in the audit log
+func expireRequest(tx *gorm.DB, actor string, request *PaymentRequest, now time.Time) error {
+	before := *request
+	request.Status = RequestExpired
+	request.ResolvedAt = &now
+	if err := tx.Save(request).Error; err != nil {
+		return err
+	}
+	return recordAudit(tx, actor, AuditExpire, AuditEntityPaymentRequest, request.ID, before, *request)
+}
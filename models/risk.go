@@ -0,0 +1,658 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Statuses of a RiskAssessment. Allowed transactions are created right away, held ones wait until a
+// reviewer releases or rejects them and blocked ones are never created.
+const (
+	RiskAllowed  = "allowed"
+	RiskHeld     = "held"
+	RiskBlocked  = "blocked"
+	RiskReleased = "released"
+	RiskRejected = "rejected"
+)
+
+// Thresholds of the risk engine until an admin saves the RiskSettings
+const (
+	DefaultRiskHoldScore  = 50
+	DefaultRiskBlockScore = 80
+)
+
+// MaxRiskScore is the highest risk score, the points of the triggered rules are capped to it
+const MaxRiskScore = 100
+
+// StructuringThreshold is a reporting threshold that payments are split to stay under, like the 10,000 of
+// cash transaction reports. It is compared with the value of a transaction in its own currency.
+const StructuringThreshold = 10000
+
+// Parameters of the built-in risk rules
+const (
+	riskHistoryWindow    = 90 * 24 * time.Hour
+	riskMinHistory       = 5
+	rapidRepeatWindow    = 10 * time.Minute
+	rapidRepeatCount     = 2
+	roundAmountUnit      = 1000
+	structuringMargin    = 0.1
+	structuringWindow    = 24 * time.Hour
+	unusualAmountScore   = 35
+	newCounterpartyScore = 15
+	rapidRepeatScore     = 30
+	roundAmountScore     = 10
+	structuringScore     = 25
+	structuringRepeat    = 40
+)
+
+var (
+	// ErrTransactionBlocked is returned when the risk score of a transaction reaches the block threshold
+	ErrTransactionBlocked = errors.New("models: the transaction was blocked as suspicious")
+
+	// ErrTransactionHeld is returned when the risk score of a transaction reaches the hold threshold but it
+	// can not wait for review, only transactions submitted with TransactionService.Submit can
+	ErrTransactionHeld = errors.New("models: the transaction needs a risk review, submit it to queue it for one")
+
+	// ErrReviewResolved is returned when reviewing a risk assessment that is not held
+	ErrReviewResolved = errors.New("models: the transaction is not awaiting review")
+
+	// ErrSelfReview is returned when the creator of a held transaction reviews it
+	ErrSelfReview = errors.New("models: the creator of a transaction can not review it")
+
+	// ErrInvalidRiskSettings is returned when a threshold is out of range or a disabled rule does not exist
+	ErrInvalidRiskSettings = errors.New("models: invalid risk settings")
+)
+
+// RiskCheck is what a RiskRule scores: the Transaction about to be created, or updated, by Creator, whose
+// value is in Currency. An updated Transaction has an ID, rules leave it out of the history they compare
+// it with. DB is the database transaction the check runs in, rules must only read from it.
+type RiskCheck struct {
+	DB          *gorm.DB
+	Transaction *Transaction
+	Creator     string
+	Currency    string
+	Now         time.Time
+}
+
+// RiskRule score one sign of fraud. Score returns 0 when the transaction does not trigger the rule,
+// otherwise the points the rule adds to the risk score and why it was triggered.
+type RiskRule interface {
+	Name() string
+	Score(check *RiskCheck) (int, string, error)
+}
+
+var (
+	riskRulesMutex sync.RWMutex
+	riskRules      = []RiskRule{
+		unusualAmountRule{},
+		newCounterpartyRule{},
+		rapidRepeatRule{},
+		roundAmountRule{},
+		structuringRule{},
+	}
+)
+
+// RegisterRiskRule add a rule evaluated on every transaction scored afterwards, after the rules already
+// registered. A rule with the name of a registered rule replaces it.
+func RegisterRiskRule(rule RiskRule) {
+	riskRulesMutex.Lock()
+	defer riskRulesMutex.Unlock()
+	for i, registered := range riskRules {
+		if registered.Name() == rule.Name() {
+			riskRules[i] = rule
+			return
+		}
+	}
+	riskRules = append(riskRules, rule)
+}
+
+// RiskRuleNames return the names of the registered rules in the order they are evaluated
+func RiskRuleNames() []string {
+	riskRulesMutex.RLock()
+	defer riskRulesMutex.RUnlock()
+	names := make([]string, len(riskRules))
+	for i, rule := range riskRules {
+		names[i] = rule.Name()
+	}
+	return names
+}
+
+// RiskSettings configure the risk engine and are stored in a single row. Transactions scoring at least
+// HoldScore are held for review and those scoring at least BlockScore are blocked, a nil threshold never
+// applies. DisabledRules is a comma separated list of the names of the rules that are not evaluated.
+type RiskSettings struct {
+	ID            uint `gorm:"primaryKey"`
+	UpdatedAt     time.Time
+	HoldScore     *int   `json:"holdScore,omitempty"`
+	BlockScore    *int   `json:"blockScore,omitempty"`
+	DisabledRules string `json:"disabledRules,omitempty" gorm:"not null;default:''"`
+}
+
+// RiskAssessment is the risk score of a transaction when it was submitted, created or updated and the rules
+// it triggered. The fields of the transaction are kept so a held transaction can be created as TransactionID once it is released, or
+// submitted for approval as ApprovalID when it meets approval policies.
+type RiskAssessment struct {
+	ID               uint `gorm:"primaryKey"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	CreatedBy        string     `json:"createdBy" gorm:"not null;index"`
+	Score            int        `json:"score" gorm:"not null;index"`
+	Rules            string     `json:"rules,omitempty" gorm:"not null;default:''"`
+	Status           string     `json:"status" gorm:"not null;index"`
+	Value            float64    `json:"value" gorm:"not null"`
+	Note             string     `json:"note,omitempty"`
+	Sender           string     `json:"sender" gorm:"not null;index"`
+	Receiver         string     `json:"receiver" gorm:"not null"`
+	Currency         string     `json:"currency" gorm:"not null"`
+	ReceivedCurrency string     `json:"receivedCurrency,omitempty" gorm:"not null;default:''"`
+	TransactionID    *uint      `json:"transactionID,omitempty" gorm:"index"`
+	ApprovalID       *uint      `json:"approvalID,omitempty"`
+	ReviewedBy       string     `json:"reviewedBy,omitempty" gorm:"not null;default:''"`
+	ReviewComment    string     `json:"reviewComment,omitempty" gorm:"not null;default:''"`
+	ReviewedAt       *time.Time `json:"reviewedAt,omitempty"`
+
+	Signals []RiskSignal `json:"signals,omitempty" gorm:"-"`
+}
+
+// RiskSignal is a rule triggered by the transaction of a RiskAssessment
+type RiskSignal struct {
+	ID           uint   `gorm:"primaryKey"`
+	AssessmentID uint   `json:"assessmentID" gorm:"not null;index"`
+	Rule         string `json:"rule" gorm:"not null"`
+	Score        int    `json:"score" gorm:"not null"`
+	Reason       string `json:"reason" gorm:"not null;default:''"`
+}
+
+type RiskService struct {
+	db    *gorm.DB
+	actor string
+}
+
+// NewRiskService Create a new RiskService with a specified connectionInfo.
+func NewRiskService(db *gorm.DB) (*RiskService, error) {
+	return &RiskService{
+		db: db,
+	}, nil
+}
+
+// WithActor return a copy of the service reviewing transactions as the provided actor, who is also
+// recorded in the audit log
+func (riskService *RiskService) WithActor(actor string) *RiskService {
+	return &RiskService{
+		db:    riskService.db,
+		actor: actor,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the risk settings, assessments and signals tables
+func (riskService *RiskService) AutoMigrate() error {
+	return riskService.db.AutoMigrate(&RiskSettings{}, &RiskAssessment{}, &RiskSignal{}).Error
+}
+
+// ReadSettings return the risk settings, the defaults when they were never saved
+func (riskService *RiskService) ReadSettings() (*RiskSettings, error) {
+	return readRiskSettings(riskService.db)
+}
+
+// SaveSettings validate and store the risk settings, they apply to the transactions scored afterwards
+func (riskService *RiskService) SaveSettings(settings *RiskSettings) error {
+	if err := settings.validate(); err != nil {
+		return err
+	}
+	return riskService.db.Transaction(func(tx *gorm.DB) error {
+		before, err := readRiskSettings(tx.Set("gorm:query_option", "FOR UPDATE"))
+		if err != nil {
+			return err
+		}
+		settings.ID = 1
+		if err := tx.Save(settings).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, riskService.actor, AuditUpdate, AuditEntityRiskSettings, settings.ID, before, settings)
+	})
+}
+
+// ReadByID will look up a risk assessment with the provided ID along with its signals
+func (riskService *RiskService) ReadByID(id uint) (*RiskAssessment, error) {
+	var assessment RiskAssessment
+	if err := first(riskService.db.Where("id = ?", id), &assessment); err != nil {
+		return nil, err
+	}
+	if err := assessment.loadSignals(riskService.db); err != nil {
+		return nil, err
+	}
+	return &assessment, nil
+}
+
+// ReadByTransaction will look up the latest risk assessment of the transaction with the provided ID, the
+// one of its last scored update if any, along with its signals
+func (riskService *RiskService) ReadByTransaction(transactionID uint) (*RiskAssessment, error) {
+	var assessment RiskAssessment
+	if err := first(riskService.db.Where("transaction_id = ?", transactionID).Order("id DESC"), &assessment); err != nil {
+		return nil, err
+	}
+	if err := assessment.loadSignals(riskService.db); err != nil {
+		return nil, err
+	}
+	return &assessment, nil
+}
+
+// ReadAssessments return the assessments with the provided status, or with any status if it is empty,
+// scoring at least minScore, riskiest first and along with their signals
+func (riskService *RiskService) ReadAssessments(status string, minScore int) ([]RiskAssessment, error) {
+	db := riskService.db.Where("score >= ?", minScore)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+	var assessments []RiskAssessment
+	if err := db.Order("score DESC, id").Find(&assessments).Error; err != nil {
+		return nil, err
+	}
+	if len(assessments) == 0 {
+		return assessments, nil
+	}
+	ids := make([]uint, len(assessments))
+	for i := range assessments {
+		ids[i] = assessments[i].ID
+	}
+	var signals []RiskSignal
+	if err := riskService.db.Where("assessment_id IN (?)", ids).Order("id").Find(&signals).Error; err != nil {
+		return nil, err
+	}
+	byAssessment := make(map[uint][]RiskSignal)
+	for _, signal := range signals {
+		byAssessment[signal.AssessmentID] = append(byAssessment[signal.AssessmentID], signal)
+	}
+	for i := range assessments {
+		assessments[i].Signals = byAssessment[assessments[i].ID]
+	}
+	return assessments, nil
+}
+
+// Release clear a held transaction as the actor of the service, it is submitted again without being
+// scored: created, or held for approval when it meets approval policies
+func (riskService *RiskService) Release(id uint, comment string) (*RiskAssessment, error) {
+	return riskService.review(id, RiskReleased, comment)
+}
+
+// Reject refuse a held transaction as the actor of the service, it is never created
+func (riskService *RiskService) Reject(id uint, comment string) (*RiskAssessment, error) {
+	return riskService.review(id, RiskRejected, comment)
+}
+
+// review resolve the held assessment with the provided ID with the status and record it in the audit log.
+// A released transaction is submitted by its creator.
+func (riskService *RiskService) review(id uint, status, comment string) (*RiskAssessment, error) {
+	var assessment RiskAssessment
+	err := riskService.db.Transaction(func(tx *gorm.DB) error {
+		if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id), &assessment); err != nil {
+			return err
+		}
+		if assessment.Status != RiskHeld {
+			return ErrReviewResolved
+		}
+		if strings.EqualFold(riskService.actor, assessment.CreatedBy) {
+			return ErrSelfReview
+		}
+
+		before := assessment
+		operation := AuditReject
+		if status == RiskReleased {
+			operation = AuditRelease
+			transaction := assessment.transaction()
+			txService := &TransactionService{db: tx, actor: assessment.CreatedBy, submitted: true}
+			approval, err := txService.submit(&transaction, assessment.CreatedBy)
+			if err != nil {
+				return err
+			}
+			assessment.link(&transaction, approval)
+		}
+		now := gorm.NowFunc()
+		assessment.Status = status
+		assessment.ReviewedBy = riskService.actor
+		assessment.ReviewComment = strings.TrimSpace(comment)
+		assessment.ReviewedAt = &now
+		if err := tx.Save(&assessment).Error; err != nil {
+			return err
+		}
+		if err := assessment.loadSignals(tx); err != nil {
+			return err
+		}
+		return recordAudit(tx, riskService.actor, operation, AuditEntityRisk, assessment.ID, before, assessment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &assessment, nil
+}
+
+// assessRisk score the transaction created by creator with the enabled rules and decide from the
+// thresholds whether it is allowed, held or blocked. The assessment is not stored.
+func assessRisk(tx *gorm.DB, transaction *Transaction, creator string) (*RiskAssessment, error) {
+	settings, err := readRiskSettings(tx)
+	if err != nil {
+		return nil, err
+	}
+	currency := transaction.Currency
+	if currency == "" {
+		currency = accountCurrency(tx, transaction.Sender)
+	}
+	if currency, err = NormalizeCurrency(currency); err != nil {
+		return nil, err
+	}
+	check := &RiskCheck{
+		DB:          tx,
+		Transaction: transaction,
+		Creator:     creator,
+		Currency:    currency,
+		Now:         gorm.NowFunc(),
+	}
+
+	assessment := &RiskAssessment{
+		CreatedBy:        creator,
+		Value:            transaction.Value,
+		Note:             transaction.Note,
+		Sender:           transaction.Sender,
+		Receiver:         transaction.Receiver,
+		Currency:         currency,
+		ReceivedCurrency: transaction.ReceivedCurrency,
+	}
+	riskRulesMutex.RLock()
+	rules := append([]RiskRule(nil), riskRules...)
+	riskRulesMutex.RUnlock()
+	disabled := settings.disabled()
+	var names []string
+	for _, rule := range rules {
+		if disabled[rule.Name()] {
+			continue
+		}
+		score, reason, err := rule.Score(check)
+		if err != nil {
+			return nil, fmt.Errorf("models: risk rule %s: %w", rule.Name(), err)
+		}
+		if score <= 0 {
+			continue
+		}
+		assessment.Score += score
+		assessment.Signals = append(assessment.Signals, RiskSignal{Rule: rule.Name(), Score: score, Reason: reason})
+		names = append(names, rule.Name())
+	}
+	if assessment.Score > MaxRiskScore {
+		assessment.Score = MaxRiskScore
+	}
+	assessment.Rules = strings.Join(names, ",")
+
+	switch {
+	case settings.BlockScore != nil && assessment.Score >= *settings.BlockScore:
+		assessment.Status = RiskBlocked
+	case settings.HoldScore != nil && assessment.Score >= *settings.HoldScore:
+		assessment.Status = RiskHeld
+	default:
+		assessment.Status = RiskAllowed
+	}
+	return assessment, nil
+}
+
+// scoreRisk assess the transaction created or updated by the actor of the service, unless it went through
+// Submit and was already scored, and return the assessment to store once the transaction is saved. ErrTransactionHeld
+// or ErrTransactionBlocked is returned when it is not allowed.
+func (transService *TransactionService) scoreRisk(tx *gorm.DB, transaction *Transaction) (*RiskAssessment, error) {
+	if transService.submitted {
+		return nil, nil
+	}
+	creator := transService.actor
+	if creator == "" {
+		creator = SystemActor
+	}
+	assessment, err := assessRisk(tx, transaction, creator)
+	if err != nil {
+		return nil, err
+	}
+	switch assessment.Status {
+	case RiskBlocked:
+		return nil, fmt.Errorf("%w: it scored %d (%s)", ErrTransactionBlocked, assessment.Score, assessment.Rules)
+	case RiskHeld:
+		return nil, fmt.Errorf("%w: it scored %d (%s)", ErrTransactionHeld, assessment.Score, assessment.Rules)
+	}
+	return assessment, nil
+}
+
+// storeRisk store the assessment returned by scoreRisk, if any, linked to the saved transaction
+func (transService *TransactionService) storeRisk(tx *gorm.DB, transaction *Transaction, assessment *RiskAssessment) error {
+	if assessment == nil {
+		return nil
+	}
+	assessment.link(transaction, nil)
+	if err := assessment.store(tx, transService.actor); err != nil {
+		return err
+	}
+	transaction.Risk = assessment
+	return nil
+}
+
+// store create the assessment and its signals, held and blocked assessments are recorded in the audit log
+// since no transaction records them
+func (assessment *RiskAssessment) store(tx *gorm.DB, actor string) error {
+	if err := tx.Create(assessment).Error; err != nil {
+		return err
+	}
+	for i := range assessment.Signals {
+		assessment.Signals[i].AssessmentID = assessment.ID
+		if err := tx.Create(&assessment.Signals[i]).Error; err != nil {
+			return err
+		}
+	}
+	if assessment.Status == RiskAllowed {
+		return nil
+	}
+	return recordAudit(tx, actor, AuditCreate, AuditEntityRisk, assessment.ID, nil, assessment)
+}
+
+// link record what became of the scored transaction, the approval it awaits or else its ID
+func (assessment *RiskAssessment) link(transaction *Transaction, approval *TransactionApproval) {
+	if approval != nil {
+		assessment.ApprovalID = &approval.ID
+	} else {
+		assessment.TransactionID = &transaction.ID
+	}
+}
+
+// loadSignals read the signals of the assessment
+func (assessment *RiskAssessment) loadSignals(db *gorm.DB) error {
+	return db.Where("assessment_id = ?", assessment.ID).Order("id").Find(&assessment.Signals).Error
+}
+
+// transaction return the transaction the assessment holds
+func (assessment *RiskAssessment) transaction() Transaction {
+	return Transaction{
+		Value:            assessment.Value,
+		Note:             assessment.Note,
+		Sender:           assessment.Sender,
+		Receiver:         assessment.Receiver,
+		Currency:         assessment.Currency,
+		ReceivedCurrency: assessment.ReceivedCurrency,
+	}
+}
+
+// readRiskSettings return the stored risk settings, or the default thresholds when there are none
+func readRiskSettings(db *gorm.DB) (*RiskSettings, error) {
+	var settings RiskSettings
+	err := first(db.Where("id = ?", 1), &settings)
+	if err == ErrNotFound {
+		hold, block := DefaultRiskHoldScore, DefaultRiskBlockScore
+		return &RiskSettings{HoldScore: &hold, BlockScore: &block}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// validate check the thresholds and normalize the disabled rules of the settings
+func (settings *RiskSettings) validate() error {
+	for _, threshold := range []*int{settings.HoldScore, settings.BlockScore} {
+		if threshold != nil && (*threshold < 1 || *threshold > MaxRiskScore) {
+			return fmt.Errorf("%w: thresholds must be between 1 and %d", ErrInvalidRiskSettings, MaxRiskScore)
+		}
+	}
+	if settings.HoldScore != nil && settings.BlockScore != nil && *settings.HoldScore >= *settings.BlockScore {
+		return fmt.Errorf("%w: the hold threshold must be below the block threshold", ErrInvalidRiskSettings)
+	}
+	known := make(map[string]bool)
+	for _, name := range RiskRuleNames() {
+		known[name] = true
+	}
+	var disabled []string
+	for name := range settings.disabled() {
+		if !known[name] {
+			return fmt.Errorf("%w: unknown rule %q", ErrInvalidRiskSettings, name)
+		}
+		disabled = append(disabled, name)
+	}
+	sort.Strings(disabled)
+	settings.DisabledRules = strings.Join(disabled, ",")
+	return nil
+}
+
+// disabled return the set of the names of the disabled rules
+func (settings *RiskSettings) disabled() map[string]bool {
+	disabled := make(map[string]bool)
+	for _, name := range strings.Split(settings.DisabledRules, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			disabled[name] = true
+		}
+	}
+	return disabled
+}
+
+// unusualAmountRule is triggered by a value far above the usual values the sender sends in the currency
+type unusualAmountRule struct{}
+
+func (unusualAmountRule) Name() string { return "unusual_amount" }
+
+func (unusualAmountRule) Score(check *RiskCheck) (int, string, error) {
+	var history struct {
+		Count     int
+		Mean      float64
+		Deviation float64
+	}
+	err := check.DB.Model(&Transaction{}).
+		Select("COUNT(*) AS count, COALESCE(AVG(value), 0) AS mean, COALESCE(STDDEV_POP(value), 0) AS deviation").
+		Where("sender = ? AND currency = ? AND created_at >= ? AND id <> ?", check.Transaction.Sender, check.Currency,
+			check.Now.Add(-riskHistoryWindow), check.Transaction.ID).
+		Scan(&history).Error
+	if err != nil {
+		return 0, "", err
+	}
+	if history.Count < riskMinHistory {
+		return 0, "", nil
+	}
+	// Three standard deviations above the mean, and at least twice the mean when the values barely vary
+	threshold := math.Max(history.Mean+3*history.Deviation, 2*history.Mean)
+	if check.Transaction.Value <= threshold {
+		return 0, "", nil
+	}
+	return unusualAmountScore, fmt.Sprintf("%.2f %s is above %.2f, the usual maximum of the %d transactions the sender made in the last 90 days",
+		check.Transaction.Value, check.Currency, threshold, history.Count), nil
+}
+
+// newCounterpartyRule is triggered when the sender never paid the receiver before
+type newCounterpartyRule struct{}
+
+func (newCounterpartyRule) Name() string { return "new_counterparty" }
+
+func (newCounterpartyRule) Score(check *RiskCheck) (int, string, error) {
+	var count int
+	err := check.DB.Model(&Transaction{}).
+		Where("sender = ? AND receiver = ? AND id <> ?", check.Transaction.Sender, check.Transaction.Receiver, check.Transaction.ID).
+		Count(&count).Error
+	if err != nil || count > 0 {
+		return 0, "", err
+	}
+	return newCounterpartyScore, "the sender never paid the receiver before", nil
+}
+
+// rapidRepeatRule is triggered when the sender already paid the receiver several times in the last minutes
+type rapidRepeatRule struct{}
+
+func (rapidRepeatRule) Name() string { return "rapid_repeats" }
+
+func (rapidRepeatRule) Score(check *RiskCheck) (int, string, error) {
+	var count int
+	err := check.DB.Model(&Transaction{}).
+		Where("sender = ? AND receiver = ? AND created_at >= ? AND id <> ?", check.Transaction.Sender, check.Transaction.Receiver,
+			check.Now.Add(-rapidRepeatWindow), check.Transaction.ID).
+		Count(&count).Error
+	if err != nil || count < rapidRepeatCount {
+		return 0, "", err
+	}
+	return rapidRepeatScore, fmt.Sprintf("the sender already paid the receiver %d times in the last %d minutes", count, int(rapidRepeatWindow.Minutes())), nil
+}
+
+// roundAmountRule is triggered by a value that is a multiple of 1000
+type roundAmountRule struct{}
+
+func (roundAmountRule) Name() string { return "round_amount" }
+
+func (roundAmountRule) Score(check *RiskCheck) (int, string, error) {
+	value := check.Transaction.Value
+	if value < roundAmountUnit || math.Mod(value, roundAmountUnit) != 0 {
+		return 0, "", nil
+	}
+	return roundAmountScore, fmt.Sprintf("%.2f %s is a round amount", value, check.Currency), nil
+}
+
+// structuringRule is triggered by a value just under the StructuringThreshold or the per-transaction
+// maximum of the sender, and scores more when the sender already sent such values in the last 24 hours
+type structuringRule struct{}
+
+func (structuringRule) Name() string { return "structuring" }
+
+func (structuringRule) Score(check *RiskCheck) (int, string, error) {
+	thresholds := []float64{StructuringThreshold}
+	limit, err := effectiveLimit(check.DB, check.Transaction.Sender)
+	if err != nil {
+		return 0, "", err
+	}
+	if limit != nil && limit.MaxPerTransaction != nil {
+		if limit.Currency == "" { // the limit applies to the value in whatever currency it is sent
+			thresholds = append(thresholds, *limit.MaxPerTransaction)
+		} else {
+			max, err := convertAmount(check.DB, *limit.MaxPerTransaction, limit.Currency, check.Currency, check.Now)
+			if err != nil && err != ErrRateNotFound {
+				return 0, "", err
+			}
+			if err == nil {
+				thresholds = append(thresholds, max)
+			}
+		}
+	}
+
+	value := check.Transaction.Value
+	for _, threshold := range thresholds {
+		floor := threshold * (1 - structuringMargin)
+		if value < floor || value >= threshold {
+			continue
+		}
+		var count int
+		err := check.DB.Model(&Transaction{}).
+			Where("sender = ? AND currency = ? AND value >= ? AND value < ? AND created_at >= ? AND id <> ?",
+				check.Transaction.Sender, check.Currency, floor, threshold, check.Now.Add(-structuringWindow), check.Transaction.ID).
+			Count(&count).Error
+		if err != nil {
+			return 0, "", err
+		}
+		if count > 0 {
+			return structuringRepeat, fmt.Sprintf("%.2f %s is just under %.2f, like %d other transactions the sender made in the last 24 hours",
+				value, check.Currency, threshold, count), nil
+		}
+		return structuringScore, fmt.Sprintf("%.2f %s is just under %.2f", value, check.Currency, threshold), nil
+	}
+	return 0, "", nil
+}
@@ -0,0 +1,404 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"sort"
+	"time"
+)
+
+// ErrInvalidSchedule is returned when a scheduled transaction ends before it starts or has a negative count.
+var ErrInvalidSchedule = errors.New("models: schedule must end after it starts and have a positive count")
+
+// maxUpcoming bound the number of occurrences previewed for a single schedule
+const maxUpcoming = 1000
+
+// MaxScheduleFailures is the number of occurrences in a row that can fail before a schedule is paused
+const MaxScheduleFailures = 3
+
+// ScheduledTransaction is a transaction that is created once at StartAt, or repeatedly following Rule
+// from StartAt until EndAt or until Count transactions were created, whichever comes first.
+// NextRunAt is the next occurrence that is due, it is nil once the schedule is finished or paused.
+// An occurrence whose transaction can not be created is skipped and counted in Failures, the schedule is
+// paused once MaxScheduleFailures occurrences failed in a row and resumes when it is updated.
+type ScheduledTransaction struct {
+	ID               uint `gorm:"primaryKey"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	DeletedAt        *time.Time `sql:"index" gorm:"index"`
+	Value            float64    `json:"value" gorm:"not null"`
+	Note             string     `json:"note,omitempty"`
+	Sender           string     `json:"sender" gorm:"not null"`
+	Receiver         string     `json:"receiver" gorm:"not null"`
+	Currency         string     `json:"currency,omitempty"`
+	ReceivedCurrency string     `json:"receivedCurrency,omitempty"`
+	Rule             string     `json:"rule,omitempty"`
+	StartAt          time.Time  `json:"startAt" gorm:"not null"`
+	EndAt            *time.Time `json:"endAt,omitempty"`
+	Count            int        `json:"count,omitempty" gorm:"not null;default:0"`
+	Occurrences      int        `json:"occurrences" gorm:"not null;default:0"`
+	LastRunAt        *time.Time `json:"lastRunAt,omitempty"`
+	NextRunAt        *time.Time `json:"nextRunAt,omitempty" gorm:"index"`
+	Failures         int        `json:"failures" gorm:"not null;default:0"`
+	LastError        string     `json:"lastError,omitempty"`
+	LastFailedAt     *time.Time `json:"lastFailedAt,omitempty"`
+	PausedAt         *time.Time `json:"pausedAt,omitempty"`
+}
+
+// ScheduledOccurrence record that the occurrence of a schedule at OccursAt created a transaction. The
+// unique index guarantees an occurrence is only ever materialized once.
+type ScheduledOccurrence struct {
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	ScheduleID    uint      `gorm:"not null;unique_index:idx_schedule_occurrence"`
+	OccursAt      time.Time `gorm:"not null;unique_index:idx_schedule_occurrence"`
+	TransactionID uint      `gorm:"not null"`
+}
+
+// UpcomingTransaction is a future occurrence of a ScheduledTransaction
+type UpcomingTransaction struct {
+	ScheduleID uint
+	OccursAt   time.Time
+	Value      float64
+	Currency   string
+	Note       string
+	Sender     string
+	Receiver   string
+}
+
+type ScheduleService struct {
+	db    *gorm.DB
+	actor string
+}
+
+// NewScheduleService Create a new ScheduleService with a specified connectionInfo.
+func NewScheduleService(db *gorm.DB) (*ScheduleService, error) {
+	return &ScheduleService{
+		db: db,
+	}, nil
+}
+
+// WithActor return a copy of the service whose changes are recorded in the audit log under the provided actor
+func (scheduleService *ScheduleService) WithActor(actor string) *ScheduleService {
+	return &ScheduleService{
+		db:    scheduleService.db,
+		actor: actor,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the scheduled transactions tables
+func (scheduleService *ScheduleService) AutoMigrate() error {
+	if err := scheduleService.db.AutoMigrate(&ScheduledTransaction{}, &ScheduledOccurrence{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReadByID will look up a scheduled transaction with the provided ID, it behaves like
+// TransactionService.ReadByID.
+func (scheduleService *ScheduleService) ReadByID(id uint) (*ScheduledTransaction, error) {
+	var schedule ScheduledTransaction
+	db := scheduleService.db.Where("id = ?", id)
+	err := first(db, &schedule)
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ReadAll return every scheduled transaction, or only those the account sends or receives when it is not
+// empty.
+func (scheduleService *ScheduleService) ReadAll(account string) ([]ScheduledTransaction, error) {
+	var schedules []ScheduledTransaction
+	db := scheduleService.db
+	if account != "" {
+		db = db.Where("sender = ? OR receiver = ?", account, account)
+	}
+	if err := db.Order("id").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// Create will validate the rule of the provided scheduled transaction, compute its first occurrence
+// and store it.
+func (scheduleService *ScheduleService) Create(schedule *ScheduledTransaction) error {
+	if err := schedule.prepare(); err != nil {
+		return err
+	}
+	return scheduleService.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(schedule).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, scheduleService.actor, AuditCreate, AuditEntitySchedule, schedule.ID, nil, schedule)
+	})
+}
+
+// Update will store every field of the provided scheduled transaction and compute its next occurrence
+// again, occurrences that were already materialized or skipped are never repeated. A paused schedule
+// resumes and its failures are cleared.
+func (scheduleService *ScheduleService) Update(schedule *ScheduledTransaction) error {
+	schedule.Failures = 0
+	schedule.PausedAt = nil
+	if err := schedule.prepare(); err != nil {
+		return err
+	}
+	return scheduleService.db.Transaction(func(tx *gorm.DB) error {
+		var before ScheduledTransaction
+		if err := first(tx.Where("id = ?", schedule.ID), &before); err != nil {
+			return err
+		}
+		if err := tx.Save(schedule).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, scheduleService.actor, AuditUpdate, AuditEntitySchedule, schedule.ID, before, schedule)
+	})
+}
+
+// Delete will delete the scheduled transaction with the provided ID, no more occurrences are materialized.
+func (scheduleService *ScheduleService) Delete(id uint) error {
+	if id == 0 { // Go default uint is 0, Gorm will delete all rows if id is not provided
+		return ErrInvalidID
+	}
+	return scheduleService.db.Transaction(func(tx *gorm.DB) error {
+		var schedule ScheduledTransaction
+		if err := first(tx.Where("id = ?", id), &schedule); err != nil {
+			return err
+		}
+		if err := tx.Delete(&schedule).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, scheduleService.actor, AuditDelete, AuditEntitySchedule, id, schedule, nil)
+	})
+}
+
+// Upcoming return every occurrence of every active schedule between from and to, both included,
+// ordered by date, or only of the schedules the account sends or receives when it is not empty. Nothing
+// is created.
+func (scheduleService *ScheduleService) Upcoming(account string, from, to time.Time) ([]UpcomingTransaction, error) {
+	var schedules []ScheduledTransaction
+	db := scheduleService.db.Where("next_run_at IS NOT NULL AND next_run_at <= ?", to)
+	if account != "" {
+		db = db.Where("sender = ? OR receiver = ?", account, account)
+	}
+	err := db.Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var upcoming []UpcomingTransaction
+	for _, schedule := range schedules {
+		recurrence, err := ParseRecurrence(schedule.Rule)
+		if err != nil {
+			return nil, err
+		}
+		found := 0
+		schedule.each(recurrence, func(occursAt time.Time) bool {
+			if occursAt.After(to) || found >= maxUpcoming {
+				return false
+			}
+			if occursAt.Before(from) || occursAt.Before(*schedule.NextRunAt) {
+				return true
+			}
+			found++
+			upcoming = append(upcoming, UpcomingTransaction{
+				ScheduleID: schedule.ID,
+				OccursAt:   occursAt,
+				Value:      schedule.Value,
+				Currency:   schedule.Currency,
+				Note:       schedule.Note,
+				Sender:     schedule.Sender,
+				Receiver:   schedule.Receiver,
+			})
+			return true
+		})
+	}
+	sort.SliceStable(upcoming, func(i, j int) bool { return upcoming[i].OccursAt.Before(upcoming[j].OccursAt) })
+	return upcoming, nil
+}
+
+// MaterializeDue create a transaction through the TransactionService for every occurrence that is due
+// at now and return how many were created. Each occurrence is created in its own database transaction
+// together with its ScheduledOccurrence and the advance of the schedule, so an occurrence is created
+// exactly once even if the process stops half way or several schedulers run at the same time.
+// An occurrence that fails is recorded on its schedule and skipped, see recordFailure, the schedule is
+// left alone until the next call and the first error is returned.
+func (scheduleService *ScheduleService) MaterializeDue(now time.Time) (int, error) {
+	var ids []uint
+	err := scheduleService.db.Model(&ScheduledTransaction{}).
+		Where("next_run_at IS NOT NULL AND next_run_at <= ?", now).
+		Order("next_run_at").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	var firstErr error
+	for _, id := range ids {
+		for {
+			isDue, err := scheduleService.materializeNext(id, now)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("models: scheduled transaction %d: %w", id, err)
+				}
+				break
+			}
+			if !isDue {
+				break
+			}
+			created++
+		}
+	}
+	return created, firstErr
+}
+
+// materializeNext create the transaction of the next occurrence of the schedule if it is due at now,
+// and report whether it was due. When the transaction can not be created the failure is recorded with
+// recordFailure and returned.
+func (scheduleService *ScheduleService) materializeNext(id uint, now time.Time) (bool, error) {
+	isDue := false
+	var createErr error
+	err := scheduleService.db.Transaction(func(tx *gorm.DB) error {
+		var schedule ScheduledTransaction
+		err := first(tx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Where("id = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", id, now), &schedule)
+		if err == ErrNotFound { // finished, not due anymore or being materialized by another scheduler
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		isDue = true
+
+		transaction := &Transaction{
+			Value:            schedule.Value,
+			Note:             schedule.Note,
+			Sender:           schedule.Sender,
+			Receiver:         schedule.Receiver,
+			Currency:         schedule.Currency,
+			ReceivedCurrency: schedule.ReceivedCurrency,
+		}
+		transService := &TransactionService{db: tx, actor: fmt.Sprintf("scheduler (schedule %d)", schedule.ID)}
+		if err := transService.Create(transaction); err != nil {
+			createErr = err
+			return err
+		}
+		occurrence := &ScheduledOccurrence{
+			ScheduleID:    schedule.ID,
+			OccursAt:      *schedule.NextRunAt,
+			TransactionID: transaction.ID,
+		}
+		if err := tx.Create(occurrence).Error; err != nil {
+			return err
+		}
+
+		schedule.Occurrences++
+		schedule.LastRunAt = schedule.NextRunAt
+		schedule.Failures = 0
+		if err := schedule.prepare(); err != nil {
+			return err
+		}
+		return tx.Model(&schedule).UpdateColumns(map[string]interface{}{
+			"occurrences": schedule.Occurrences,
+			"last_run_at": schedule.LastRunAt,
+			"next_run_at": schedule.NextRunAt,
+			"failures":    schedule.Failures,
+		}).Error
+	})
+	if createErr != nil {
+		if err := scheduleService.recordFailure(id, now, createErr); err != nil {
+			return isDue, err
+		}
+	}
+	return isDue, err
+}
+
+// recordFailure skip the due occurrence of the schedule whose transaction could not be created with
+// cause, so it is not retried forever and later occurrences are not held back, and pause the schedule
+// once MaxScheduleFailures occurrences failed in a row
+func (scheduleService *ScheduleService) recordFailure(id uint, now time.Time, cause error) error {
+	return scheduleService.db.Transaction(func(tx *gorm.DB) error {
+		var schedule ScheduledTransaction
+		err := first(tx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Where("id = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", id, now), &schedule)
+		if err == ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		schedule.Failures++
+		schedule.LastError = fmt.Sprintf("occurrence of %s: %v", schedule.NextRunAt.Format(time.RFC3339), cause)
+		schedule.LastFailedAt = &now
+		schedule.LastRunAt = schedule.NextRunAt
+		if err := schedule.prepare(); err != nil {
+			return err
+		}
+		if schedule.Failures >= MaxScheduleFailures && schedule.NextRunAt != nil {
+			schedule.PausedAt = &now
+			schedule.NextRunAt = nil
+		}
+		return tx.Model(&schedule).UpdateColumns(map[string]interface{}{
+			"failures":       schedule.Failures,
+			"last_error":     schedule.LastError,
+			"last_failed_at": schedule.LastFailedAt,
+			"last_run_at":    schedule.LastRunAt,
+			"next_run_at":    schedule.NextRunAt,
+			"paused_at":      schedule.PausedAt,
+		}).Error
+	})
+}
+
+// prepare validate and normalize the rule of the schedule and compute its next occurrence after the
+// last one that was materialized
+func (schedule *ScheduledTransaction) prepare() error {
+	recurrence, err := ParseRecurrence(schedule.Rule)
+	if err != nil {
+		return err
+	}
+	schedule.Rule = recurrence.String()
+	if schedule.Count < 0 || (schedule.EndAt != nil && schedule.EndAt.Before(schedule.StartAt)) {
+		return ErrInvalidSchedule
+	}
+	if schedule.Currency != "" {
+		if schedule.Currency, err = NormalizeCurrency(schedule.Currency); err != nil {
+			return err
+		}
+	}
+	if schedule.ReceivedCurrency != "" {
+		if schedule.ReceivedCurrency, err = NormalizeCurrency(schedule.ReceivedCurrency); err != nil {
+			return err
+		}
+	}
+	// Keep only the precision stored by the database so occurrences are the same after a round trip
+	schedule.StartAt = schedule.StartAt.Truncate(time.Microsecond)
+
+	schedule.NextRunAt = nil
+	schedule.each(recurrence, func(occursAt time.Time) bool {
+		if schedule.LastRunAt != nil && !occursAt.After(*schedule.LastRunAt) {
+			return true
+		}
+		schedule.NextRunAt = &occursAt
+		return false
+	})
+	return nil
+}
+
+// each call yield with every occurrence of the schedule, in order, until yield returns false or the
+// schedule reaches its end date or count
+func (schedule *ScheduledTransaction) each(recurrence Recurrence, yield func(time.Time) bool) {
+	index := 0
+	recurrence.each(schedule.StartAt, func(occursAt time.Time) bool {
+		if schedule.EndAt != nil && occursAt.After(*schedule.EndAt) {
+			return false
+		}
+		if schedule.Count > 0 && index >= schedule.Count {
+			return false
+		}
+		index++
+		return yield(occursAt)
+	})
+}
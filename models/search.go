@@ -0,0 +1,314 @@
+package models
+
+import (
+	"errors"
+	"github.com/jinzhu/gorm"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Kinds of the results of a search
+const (
+	SearchTransaction = "transaction"
+	SearchUser        = "user"
+)
+
+const (
+	// DefaultSearchLimit is the number of results of a search when no limit is provided
+	DefaultSearchLimit = 20
+
+	// maxSearchTerms is the number of words of a query that are searched, the others are ignored
+	maxSearchTerms = 8
+)
+
+// Weights of the fields of the fallback search, they mirror the A and B weights of the Postgres documents
+const (
+	searchWeightA = 1.0
+	searchWeightB = 0.4
+)
+
+// ErrEmptySearch is returned when a search query has no word to search
+var ErrEmptySearch = errors.New("models: search query has no word to search")
+
+// Documents searched in Postgres. The expressions are also the expressions of the full-text indexes, they
+// must stay the same for the indexes to be used. The simple configuration is used because most searches
+// are for names and emails, which must not be stemmed.
+const (
+	transactionDocument = `setweight(to_tsvector('simple', sender || ' ' || receiver), 'A') || ` +
+		`setweight(to_tsvector('simple', coalesce(note, '')), 'B')`
+	userDocument = `setweight(to_tsvector('simple', email || ' ' || coalesce(first, '') || ' ' || last), 'A') || ` +
+		`setweight(to_tsvector('simple', coalesce(middle, '')), 'B')`
+)
+
+// SearchOptions restrict a search. Account limits the transactions to those it sent or received, and
+// Kinds to the kinds of results listed, every kind when empty.
+type SearchOptions struct {
+	Account string
+	Kinds   []string
+	Limit   int
+}
+
+// SearchHit is a result of a search, either a transaction or a user, Rank being higher for better matches
+type SearchHit struct {
+	Kind        string
+	Rank        float64
+	Transaction *Transaction
+	User        *User
+}
+
+type SearchService struct {
+	db *gorm.DB
+}
+
+// NewSearchService Create a new SearchService with a specified connectionInfo.
+func NewSearchService(db *gorm.DB) (*SearchService, error) {
+	return &SearchService{
+		db: db,
+	}, nil
+}
+
+// AutoMigrate create the full-text indexes of transactions and users. Other databases than Postgres have
+// no index, they are searched by the fallback implementation.
+func (searchService *SearchService) AutoMigrate() error {
+	if !searchService.fullText() {
+		return nil
+	}
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_transactions_search ON transactions USING GIN ((` + transactionDocument + `))`,
+		`CREATE INDEX IF NOT EXISTS idx_users_search ON users USING GIN ((` + userDocument + `))`,
+	}
+	for _, index := range indexes {
+		if err := searchService.db.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fullText report whether the database supports the Postgres full-text search
+func (searchService *SearchService) fullText() bool {
+	return searchService.db.Dialect().GetName() == "postgres"
+}
+
+// Search find the transactions and users whose every word starts with one of the words of the query,
+// best matches first. Transactions are matched on their sender, receiver and note, users on their email
+// and names. Deleted transactions and users are never returned.
+func (searchService *SearchService) Search(query string, options SearchOptions) ([]SearchHit, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+	if options.Limit <= 0 {
+		options.Limit = DefaultSearchLimit
+	}
+
+	var hits []SearchHit
+	if searchKind(options.Kinds, SearchTransaction) {
+		transactions, err := searchService.searchTransactions(terms, options)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, transactions...)
+	}
+	if searchKind(options.Kinds, SearchUser) {
+		users, err := searchService.searchUsers(terms, options)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, users...)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Rank > hits[j].Rank
+	})
+	if len(hits) > options.Limit {
+		hits = hits[:options.Limit]
+	}
+	return hits, nil
+}
+
+// searchTransactions return the limit transactions best matching the terms
+func (searchService *SearchService) searchTransactions(terms []string, options SearchOptions) ([]SearchHit, error) {
+	db := searchService.db.Model(&Transaction{})
+	if options.Account != "" {
+		db = db.Where("sender = ? OR receiver = ?", options.Account, options.Account)
+	}
+
+	if !searchService.fullText() {
+		var transactions []Transaction
+		if err := likeTerms(db, terms, "note", "sender", "receiver").Order("id DESC").Find(&transactions).Error; err != nil {
+			return nil, err
+		}
+		hits := make([]SearchHit, 0, len(transactions))
+		for i := range transactions {
+			transaction := &transactions[i]
+			rank := fallbackRank(terms,
+				weightedText{searchWeightA, transaction.Sender + " " + transaction.Receiver},
+				weightedText{searchWeightB, transaction.Note})
+			hits = append(hits, SearchHit{Kind: SearchTransaction, Rank: rank, Transaction: transaction})
+		}
+		return topHits(hits, options.Limit), nil
+	}
+
+	var ranked []struct {
+		Transaction
+		Rank float64
+	}
+	tsQuery := toTSQuery(terms)
+	err := db.Select("transactions.*, ts_rank("+transactionDocument+", to_tsquery('simple', ?)) AS rank", tsQuery).
+		Where(transactionDocument+" @@ to_tsquery('simple', ?)", tsQuery).
+		Order("rank DESC, id DESC").
+		Limit(options.Limit).
+		Scan(&ranked).Error
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]SearchHit, 0, len(ranked))
+	for i := range ranked {
+		hits = append(hits, SearchHit{Kind: SearchTransaction, Rank: ranked[i].Rank, Transaction: &ranked[i].Transaction})
+	}
+	return hits, nil
+}
+
+// searchUsers return the limit users best matching the terms
+func (searchService *SearchService) searchUsers(terms []string, options SearchOptions) ([]SearchHit, error) {
+	db := searchService.db.Model(&User{})
+
+	if !searchService.fullText() {
+		var users []User
+		if err := likeTerms(db, terms, "email", "first", "middle", "last").Order("id").Find(&users).Error; err != nil {
+			return nil, err
+		}
+		hits := make([]SearchHit, 0, len(users))
+		for i := range users {
+			user := &users[i]
+			rank := fallbackRank(terms,
+				weightedText{searchWeightA, user.Email + " " + user.First + " " + user.Last},
+				weightedText{searchWeightB, user.Middle})
+			hits = append(hits, SearchHit{Kind: SearchUser, Rank: rank, User: user})
+		}
+		return topHits(hits, options.Limit), nil
+	}
+
+	var ranked []struct {
+		User
+		Rank float64
+	}
+	tsQuery := toTSQuery(terms)
+	err := db.Select("users.*, ts_rank("+userDocument+", to_tsquery('simple', ?)) AS rank", tsQuery).
+		Where(userDocument+" @@ to_tsquery('simple', ?)", tsQuery).
+		Order("rank DESC, id").
+		Limit(options.Limit).
+		Scan(&ranked).Error
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]SearchHit, 0, len(ranked))
+	for i := range ranked {
+		hits = append(hits, SearchHit{Kind: SearchUser, Rank: ranked[i].Rank, User: &ranked[i].User})
+	}
+	return hits, nil
+}
+
+// searchKind report whether results of the kind are searched, every kind is when kinds is empty
+func searchKind(kinds []string, kind string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+	for _, searched := range kinds {
+		if searched == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// searchTerms split a query into lowercase words, keeping the characters of emails inside words and
+// dropping everything else, in particular the operators of tsquery
+func searchTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("@._-", r)
+	})
+	var terms []string
+	for _, word := range words {
+		word = strings.Trim(word, "@._-")
+		if word == "" {
+			continue
+		}
+		terms = append(terms, word)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// toTSQuery build a tsquery matching the documents having a word starting with each term
+func toTSQuery(terms []string) string {
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		prefixes[i] = term + ":*"
+	}
+	return strings.Join(prefixes, " & ")
+}
+
+// likeTerms restrict a query to the rows where each term is found in one of the columns, it is how the
+// fallback search selects its candidates
+func likeTerms(db *gorm.DB, terms []string, columns ...string) *gorm.DB {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	for _, term := range terms {
+		pattern := "%" + escaper.Replace(term) + "%"
+		conditions := make([]string, len(columns))
+		args := make([]interface{}, len(columns))
+		for i, column := range columns {
+			conditions[i] = "LOWER(COALESCE(" + column + `, '')) LIKE ? ESCAPE '\'`
+			args[i] = pattern
+		}
+		db = db.Where(strings.Join(conditions, " OR "), args...)
+	}
+	return db
+}
+
+// weightedText is a field of a result of the fallback search and the weight of the terms found in it
+type weightedText struct {
+	weight float64
+	text   string
+}
+
+// fallbackRank rank a result of the fallback search, each term adding the weight of the best field it
+// is found in, doubled when a word of the field starts with it, divided by the number of terms
+func fallbackRank(terms []string, fields ...weightedText) float64 {
+	rank := 0.0
+	for _, term := range terms {
+		best := 0.0
+		for _, field := range fields {
+			text := strings.ToLower(field.text)
+			if !strings.Contains(text, term) {
+				continue
+			}
+			weight := field.weight
+			for _, word := range strings.FieldsFunc(text, unicode.IsSpace) {
+				if strings.HasPrefix(word, term) {
+					weight *= 2
+					break
+				}
+			}
+			if weight > best {
+				best = weight
+			}
+		}
+		rank += best
+	}
+	return rank / float64(len(terms))
+}
+
+// topHits sort the hits of the fallback search by rank and keep the limit best ones
+func topHits(hits []SearchHit, limit int) []SearchHit {
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Rank > hits[j].Rank
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
@@ -0,0 +1,180 @@
+package models
+
+import "github.com/jinzhu/gorm"
+
+// Services hold every service of the models package, all sharing the same database connection.
+type Services struct {
+	Transaction    *TransactionService
+	User           *UserService
+	Audit          *AuditService
+	Ledger         *LedgerService
+	Rate           *RateService
+	Schedule       *ScheduleService
+	Statement      *StatementService
+	Payment        *PaymentService
+	Reconciliation *ReconciliationService
+	Session        *SessionService
+	Category       *CategoryService
+	Search         *SearchService
+	Group          *GroupService
+	PaymentRequest *PaymentRequestService
+	Hold           *HoldService
+	Approval       *ApprovalService
+	Limit          *LimitService
+	Risk           *RiskService
+	db             *gorm.DB
+}
+
+// NewServices create every service using the provided database connection.
+func NewServices(db *gorm.DB) (*Services, error) {
+	transService, err := NewTransactionService(db)
+	if err != nil {
+		return nil, err
+	}
+	userService, err := NewUserService(db)
+	if err != nil {
+		return nil, err
+	}
+	auditService, err := NewAuditService(db)
+	if err != nil {
+		return nil, err
+	}
+	ledgerService, err := NewLedgerService(db)
+	if err != nil {
+		return nil, err
+	}
+	rateService, err := NewRateService(db)
+	if err != nil {
+		return nil, err
+	}
+	scheduleService, err := NewScheduleService(db)
+	if err != nil {
+		return nil, err
+	}
+	statementService, err := NewStatementService(db)
+	if err != nil {
+		return nil, err
+	}
+	paymentService, err := NewPaymentService(db)
+	if err != nil {
+		return nil, err
+	}
+	reconService, err := NewReconciliationService(db)
+	if err != nil {
+		return nil, err
+	}
+	sessionService, err := NewSessionService(db)
+	if err != nil {
+		return nil, err
+	}
+	categoryService, err := NewCategoryService(db)
+	if err != nil {
+		return nil, err
+	}
+	searchService, err := NewSearchService(db)
+	if err != nil {
+		return nil, err
+	}
+	groupService, err := NewGroupService(db)
+	if err != nil {
+		return nil, err
+	}
+	requestService, err := NewPaymentRequestService(db)
+	if err != nil {
+		return nil, err
+	}
+	holdService, err := NewHoldService(db)
+	if err != nil {
+		return nil, err
+	}
+	approvalService, err := NewApprovalService(db)
+	if err != nil {
+		return nil, err
+	}
+	limitService, err := NewLimitService(db)
+	if err != nil {
+		return nil, err
+	}
+	riskService, err := NewRiskService(db)
+	if err != nil {
+		return nil, err
+	}
+	return &Services{
+		Transaction:    transService,
+		User:           userService,
+		Audit:          auditService,
+		Ledger:         ledgerService,
+		Rate:           rateService,
+		Schedule:       scheduleService,
+		Statement:      statementService,
+		Payment:        paymentService,
+		Reconciliation: reconService,
+		Session:        sessionService,
+		Category:       categoryService,
+		Search:         searchService,
+		Group:          groupService,
+		PaymentRequest: requestService,
+		Hold:           holdService,
+		Approval:       approvalService,
+		Limit:          limitService,
+		Risk:           riskService,
+		db:             db,
+	}, nil
+}
+
+// AutoMigrate will attempt to automatically migrate every table. The ledger is migrated last, after the
+// transactions table it protects.
+func (services *Services) AutoMigrate() error {
+	if err := services.Transaction.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.User.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Audit.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Rate.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Schedule.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Statement.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Payment.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Reconciliation.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Session.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Category.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Search.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Group.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.PaymentRequest.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Hold.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Approval.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Limit.AutoMigrate(); err != nil {
+		return err
+	}
+	if err := services.Risk.AutoMigrate(); err != nil {
+		return err
+	}
+	return services.Ledger.AutoMigrate()
+}
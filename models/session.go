@@ -0,0 +1,116 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// DefaultSessionTTL is how long a session of the web interface lasts after the user logs in
+const DefaultSessionTTL = 12 * time.Hour
+
+// ErrSessionExpired is returned when a session token is unknown or its session has expired.
+var ErrSessionExpired = errors.New("models: session expired")
+
+// Session is a login to the web interface. Only the SHA-256 hash of the token handed to the browser is
+// stored, so the table can not be used to impersonate users. CSRFToken must be sent back with every form
+// submitted during the session.
+type Session struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	TokenHash string    `gorm:"not null;unique_index"`
+	UserID    uint      `gorm:"not null;index"`
+	CSRFToken string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+type SessionService struct {
+	db *gorm.DB
+}
+
+// NewSessionService Create a new SessionService with a specified connectionInfo.
+func NewSessionService(db *gorm.DB) (*SessionService, error) {
+	return &SessionService{
+		db: db,
+	}, nil
+}
+
+// AutoMigrate will attempt to automatically migrate the sessions table
+func (sessionService *SessionService) AutoMigrate() error {
+	return sessionService.db.AutoMigrate(&Session{}).Error
+}
+
+// Create open a session of the user lasting ttl and return it with the token identifying it, the token
+// is not stored and can not be read again.
+func (sessionService *SessionService) Create(userID uint, ttl time.Duration) (string, *Session, error) {
+	token, err := RandomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	csrfToken, err := RandomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	session := &Session{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		CSRFToken: csrfToken,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := sessionService.db.Create(session).Error; err != nil {
+		return "", nil, err
+	}
+	return token, session, nil
+}
+
+// Authenticate return the session identified by the token and its user, ErrSessionExpired if the session
+// does not exist, has expired or its user was deleted.
+func (sessionService *SessionService) Authenticate(token string) (*Session, *User, error) {
+	var session Session
+	err := first(sessionService.db.Where("token_hash = ? AND expires_at > ?", hashToken(token), time.Now()), &session)
+	if err == ErrNotFound {
+		return nil, nil, ErrSessionExpired
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var user User
+	err = first(sessionService.db.Where("id = ?", session.UserID), &user)
+	if err == ErrNotFound {
+		return nil, nil, ErrSessionExpired
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &session, &user, nil
+}
+
+// Delete close the session identified by the token, closing a session that does not exist is not an error.
+func (sessionService *SessionService) Delete(token string) error {
+	return sessionService.db.Where("token_hash = ?", hashToken(token)).Delete(&Session{}).Error
+}
+
+// DeleteExpired remove every session that expired before now and return how many were removed.
+func (sessionService *SessionService) DeleteExpired(now time.Time) (int, error) {
+	result := sessionService.db.Where("expires_at <= ?", now).Delete(&Session{})
+	return int(result.RowsAffected), result.Error
+}
+
+// RandomToken return 32 random bytes encoded as URL safe base64, suitable as a secret token
+func RandomToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// hashToken return the hex encoded SHA-256 hash of a session token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSplitLegs is the largest number of legs of a split transaction
+const maxSplitLegs = 100
+
+// ErrInvalidSplit is returned when the legs of a split transaction are invalid or do not add up to its total
+var ErrInvalidSplit = errors.New("models: invalid split transaction")
+
+// SplitTransaction is a single payment of Total from the sender fanned out to several receivers. Every
+// leg is a Transaction whose SplitID is the ID of the split, the legs always add up to Total.
+type SplitTransaction struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Sender    string        `json:"sender" gorm:"not null;index"`
+	Total     float64       `json:"total" gorm:"not null"`
+	Currency  string        `json:"currency" gorm:"not null"`
+	Note      string        `json:"note,omitempty"`
+	Legs      []Transaction `json:"legs,omitempty" gorm:"-"`
+}
+
+// SplitLeg describe the share of a receiver in a split transaction, either an Amount in the currency of
+// the split or a Percentage of its total. Note replaces the note of the split for this leg when it is set.
+type SplitLeg struct {
+	Receiver   string
+	Amount     *float64
+	Percentage *float64
+	Note       string
+}
+
+// CreateSplit validate the legs and create the split transaction with a transaction for each leg, either
+// all of them are created or none is. The currency of the split defaults to the currency of the sender
+// account. Percentages are rounded to the minor unit of the currency, the cents left over by rounding go
+// to the legs with the largest remainders so the legs add up to the total exactly.
+func (transService *TransactionService) CreateSplit(split *SplitTransaction, legs []SplitLeg) error {
+	split.Sender = strings.TrimSpace(split.Sender)
+	if split.Sender == "" {
+		return fmt.Errorf("%w: sender is required", ErrInvalidSplit)
+	}
+	if math.IsNaN(split.Total) || math.IsInf(split.Total, 0) || split.Total <= 0 {
+		return fmt.Errorf("%w: total must be a positive number", ErrInvalidSplit)
+	}
+	if len(legs) < 2 || len(legs) > maxSplitLegs {
+		return fmt.Errorf("%w: between 2 and %d legs are needed", ErrInvalidSplit, maxSplitLegs)
+	}
+	if split.CreatedAt.IsZero() {
+		split.CreatedAt = gorm.NowFunc()
+	}
+	split.CreatedAt = split.CreatedAt.Truncate(time.Microsecond)
+
+	return transService.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if split.Currency == "" {
+			split.Currency = accountCurrency(tx, split.Sender)
+		}
+		if split.Currency, err = NormalizeCurrency(split.Currency); err != nil {
+			return err
+		}
+		split.Total = RoundAmount(split.Total, split.Currency)
+		amounts, err := allocateSplit(split.Total, split.Currency, legs)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(split).Error; err != nil {
+			return err
+		}
+
+		txService := &TransactionService{db: tx, actor: transService.actor}
+		split.Legs = make([]Transaction, len(legs))
+		for i, leg := range legs {
+			note := split.Note
+			if leg.Note != "" {
+				note = leg.Note
+			}
+			splitID := split.ID
+			split.Legs[i] = Transaction{
+				CreatedAt: split.CreatedAt,
+				Value:     amounts[i],
+				Note:      note,
+				Sender:    split.Sender,
+				Receiver:  strings.TrimSpace(leg.Receiver),
+				Currency:  split.Currency,
+				SplitID:   &splitID,
+			}
+			if err := split.Legs[i].Validate(); err != nil {
+				return fmt.Errorf("leg %d: %w", i+1, err)
+			}
+			if err := txService.Create(&split.Legs[i]); err != nil {
+				return fmt.Errorf("leg %d: %w", i+1, err)
+			}
+		}
+		return recordAudit(tx, transService.actor, AuditCreate, AuditEntitySplit, split.ID, nil, split)
+	})
+}
+
+// ReadSplit will look up a split transaction with the provided ID along with its legs ordered by ID.
+// Legs that were deleted are left out.
+func (transService *TransactionService) ReadSplit(id uint) (*SplitTransaction, error) {
+	var split SplitTransaction
+	if err := first(transService.db.Where("id = ?", id), &split); err != nil {
+		return nil, err
+	}
+	if err := transService.db.Where("split_id = ?", id).Order("id").Find(&split.Legs).Error; err != nil {
+		return nil, err
+	}
+	return &split, nil
+}
+
+// ReadSplits return the split transactions sent by the sender, or every split transaction if it is
+// empty, newest first, without their legs
+func (transService *TransactionService) ReadSplits(sender string) ([]SplitTransaction, error) {
+	db := transService.db
+	if sender != "" {
+		db = db.Where("sender = ?", sender)
+	}
+	var splits []SplitTransaction
+	if err := db.Order("id DESC").Find(&splits).Error; err != nil {
+		return nil, err
+	}
+	return splits, nil
+}
+
+// allocateSplit compute the amount of each leg of a split of total. Legs with an Amount receive it, the
+// legs with a Percentage share what is left in proportion of their percentages, which must add up to
+// what is left within a minor unit. Amounts are computed in minor units so they add up to total exactly.
+func allocateSplit(total float64, currency string, legs []SplitLeg) ([]float64, error) {
+	totalUnits := toUnits(total, currency)
+	units := make([]int64, len(legs))
+	receivers := make(map[string]bool)
+	var fixedUnits int64
+	var percentLegs []int
+	var percentSum float64
+	for i, leg := range legs {
+		receiver := strings.ToLower(strings.TrimSpace(leg.Receiver))
+		if receivers[receiver] {
+			return nil, fmt.Errorf("%w: leg %d repeats receiver %s", ErrInvalidSplit, i+1, leg.Receiver)
+		}
+		receivers[receiver] = true
+		switch {
+		case (leg.Amount == nil) == (leg.Percentage == nil):
+			return nil, fmt.Errorf("%w: leg %d needs either an amount or a percentage", ErrInvalidSplit, i+1)
+		case leg.Amount != nil:
+			if math.IsNaN(*leg.Amount) || *leg.Amount <= 0 {
+				return nil, fmt.Errorf("%w: the amount of leg %d must be positive", ErrInvalidSplit, i+1)
+			}
+			units[i] = toUnits(*leg.Amount, currency)
+			fixedUnits += units[i]
+		default:
+			if math.IsNaN(*leg.Percentage) || *leg.Percentage <= 0 || *leg.Percentage > 100 {
+				return nil, fmt.Errorf("%w: the percentage of leg %d must be above 0 and at most 100", ErrInvalidSplit, i+1)
+			}
+			percentLegs = append(percentLegs, i)
+			percentSum += *leg.Percentage
+		}
+	}
+
+	left := totalUnits - fixedUnits
+	if len(percentLegs) == 0 {
+		if left != 0 {
+			return nil, fmt.Errorf("%w: the legs add up to %g instead of %g", ErrInvalidSplit,
+				fromUnits(fixedUnits, currency), fromUnits(totalUnits, currency))
+		}
+	} else {
+		if left <= 0 || math.Abs(percentSum/100*float64(totalUnits)-float64(left)) >= 1 {
+			return nil, fmt.Errorf("%w: the percentages add up to %v%% of the total, %v%% is left after the amounts",
+				ErrInvalidSplit, percentSum, 100*float64(left)/float64(totalUnits))
+		}
+		percentages := make([]float64, len(percentLegs))
+		for j, i := range percentLegs {
+			percentages[j] = *legs[i].Percentage
+		}
+		for j, share := range apportion(left, percentages) {
+			units[percentLegs[j]] = share
+		}
+	}
+
+	amounts := make([]float64, len(legs))
+	for i := range units {
+		if units[i] <= 0 {
+			return nil, fmt.Errorf("%w: leg %d would receive nothing", ErrInvalidSplit, i+1)
+		}
+		amounts[i] = fromUnits(units[i], currency)
+	}
+	return amounts, nil
+}
+
+// apportion divide total minor units in proportion of the weights with the largest remainder method:
+// every share is rounded down, then the units left go one by one to the largest fractions
+func apportion(total int64, weights []float64) []int64 {
+	sum := 0.0
+	for _, weight := range weights {
+		sum += weight
+	}
+	shares := make([]int64, len(weights))
+	if sum <= 0 {
+		return shares
+	}
+	remainders := make([]float64, len(weights))
+	allocated := int64(0)
+	for i, weight := range weights {
+		share := float64(total) * weight / sum
+		shares[i] = int64(math.Floor(share))
+		remainders[i] = share - float64(shares[i])
+		allocated += shares[i]
+	}
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for k := int64(0); k < total-allocated; k++ {
+		shares[order[int(k)%len(order)]]++
+	}
+	return shares
+}
+
+// toUnits convert an amount of the currency into minor units, cents for most currencies
+func toUnits(amount float64, currency string) int64 {
+	return int64(math.Round(amount * math.Pow10(CurrencyDecimals(currency))))
+}
+
+// fromUnits convert minor units of the currency back into an amount
+func fromUnits(units int64, currency string) float64 {
+	return float64(units) / math.Pow10(CurrencyDecimals(currency))
+}
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"math"
+	"strings"
+	"time"
+)
+
+// Outcomes of a StatementEntry imported by StatementService.Import
+const (
+	StatementCreated    = "created"
+	StatementSkipped    = "skipped"
+	StatementConflicted = "conflicted"
+	StatementFailed     = "failed"
+)
+
+// ErrUnknownAccount is returned when a statement is imported for an account no user owns.
+var ErrUnknownAccount = errors.New("models: no user owns the account")
+
+// StatementEntry is an entry of a bank statement of an account. A positive Amount is credited to the
+// account by Payee and a negative one is debited from the account to Payee. FITID is the identifier
+// the bank gives the entry, it is empty for formats that have none. PostedAt is the booking date and
+// ValueAt, when the statement has it, the value date.
+type StatementEntry struct {
+	FITID     string
+	Reference string
+	PostedAt  time.Time
+	ValueAt   *time.Time
+	Amount    float64
+	Payee     string
+	Memo      string
+	Currency  string
+}
+
+// StatementOutcome is what became of a StatementEntry. TransactionID is the created transaction and
+// DuplicateOfID the existing transaction the entry matched.
+type StatementOutcome struct {
+	Status        string
+	TransactionID uint
+	DuplicateOfID uint
+	Err           error
+}
+
+// ImportedEntry record that an entry of a statement created a transaction and keep what the statement
+// says of it beside the transaction. An entry with a FITID is skipped when the statement, or an
+// overlapping one, is imported again.
+type ImportedEntry struct {
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	Account       string     `gorm:"not null"`
+	FITID         string     `gorm:"column:fit_id;not null;default:''"`
+	TransactionID uint       `gorm:"not null;index"`
+	Reference     string     `gorm:"not null;default:''"`
+	Counterparty  string     `gorm:"not null;default:''"`
+	BookedAt      *time.Time `gorm:"column:booked_at"`
+	ValueAt       *time.Time `gorm:"column:value_at"`
+}
+
+type StatementService struct {
+	db    *gorm.DB
+	actor string
+}
+
+// NewStatementService Create a new StatementService with a specified connectionInfo.
+func NewStatementService(db *gorm.DB) (*StatementService, error) {
+	return &StatementService{
+		db: db,
+	}, nil
+}
+
+// WithActor return a copy of the StatementService that records the provided actor in the audit log
+// for every transaction it creates.
+func (statementService *StatementService) WithActor(actor string) *StatementService {
+	return &StatementService{
+		db:    statementService.db,
+		actor: actor,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the imported entries table
+func (statementService *StatementService) AutoMigrate() error {
+	if err := statementService.db.AutoMigrate(&ImportedEntry{}).Error; err != nil {
+		return err
+	}
+	// FITIDs are unique per account, entries without one are all recorded
+	index := `CREATE UNIQUE INDEX IF NOT EXISTS idx_imported_entry ON imported_entries (account, fit_id) WHERE fit_id <> ''`
+	return statementService.db.Exec(index).Error
+}
+
+// ReadByTransaction return the statement entry the transaction with the provided ID was imported from,
+// ErrNotFound if it was not imported from a statement.
+func (statementService *StatementService) ReadByTransaction(id uint) (*ImportedEntry, error) {
+	var entry ImportedEntry
+	err := first(statementService.db.Where("transaction_id = ?", id), &entry)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Import create a transaction of the account for every entry of its statement and return the outcome
+// of each entry, in the order of the entries. An entry is skipped when an entry with the same FITID
+// was already imported for the account, or, when it has no FITID, when a transaction of the same day,
+// amount and payee already exists. An entry with a FITID that matches such a transaction is not
+// created either but reported as conflicted, it may be the same transfer entered by hand.
+// Only transactions that existed before the import are matched, so identical entries of the same
+// statement are all created. Nothing is created when dryRun is true.
+func (statementService *StatementService) Import(account string, entries []StatementEntry, dryRun bool) ([]StatementOutcome, error) {
+	var owner User
+	if err := first(statementService.db.Where("email = ?", account), &owner); err != nil {
+		if err == ErrNotFound {
+			return nil, ErrUnknownAccount
+		}
+		return nil, err
+	}
+	currency := owner.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	var lastID struct{ ID uint }
+	if err := statementService.db.Unscoped().Model(&Transaction{}).Select("COALESCE(MAX(id), 0) AS id").Scan(&lastID).Error; err != nil {
+		return nil, err
+	}
+
+	outcomes := make([]StatementOutcome, len(entries))
+	for i, entry := range entries {
+		outcomes[i] = statementService.importEntry(account, currency, lastID.ID, entry, dryRun)
+	}
+	return outcomes, nil
+}
+
+// importEntry import a single entry of a statement, see Import. The entry and its ImportedEntry are
+// created in the same database transaction.
+func (statementService *StatementService) importEntry(account, currency string, lastID uint, entry StatementEntry, dryRun bool) StatementOutcome {
+	transaction, err := entry.transaction(account, currency)
+	if err != nil {
+		return StatementOutcome{Status: StatementFailed, Err: err}
+	}
+
+	var outcome StatementOutcome
+	err = statementService.db.Transaction(func(tx *gorm.DB) error {
+		if entry.FITID != "" {
+			var imported ImportedEntry
+			err := first(tx.Where("account = ? AND fit_id = ?", account, entry.FITID), &imported)
+			if err == nil {
+				outcome = StatementOutcome{Status: StatementSkipped, DuplicateOfID: imported.TransactionID}
+				return nil
+			}
+			if err != ErrNotFound {
+				return err
+			}
+		}
+
+		var duplicate Transaction
+		day := time.Date(entry.PostedAt.Year(), entry.PostedAt.Month(), entry.PostedAt.Day(), 0, 0, 0, 0, entry.PostedAt.Location())
+		err := first(tx.Where("id <= ? AND sender = ? AND receiver = ? AND ABS(value - ?) < 0.005 AND created_at >= ? AND created_at < ?",
+			lastID, transaction.Sender, transaction.Receiver, transaction.Value, day, day.AddDate(0, 0, 1)).Order("id"), &duplicate)
+		if err == nil {
+			outcome = StatementOutcome{Status: StatementSkipped, DuplicateOfID: duplicate.ID}
+			if entry.FITID != "" {
+				outcome.Status = StatementConflicted
+			}
+			return nil
+		}
+		if err != ErrNotFound {
+			return err
+		}
+
+		outcome = StatementOutcome{Status: StatementCreated}
+		if dryRun {
+			return nil
+		}
+		transService := &TransactionService{db: tx, actor: statementService.actor}
+		if err := transService.Create(transaction); err != nil {
+			return err
+		}
+		outcome.TransactionID = transaction.ID
+		postedAt := entry.PostedAt
+		return tx.Create(&ImportedEntry{
+			Account:       account,
+			FITID:         entry.FITID,
+			TransactionID: transaction.ID,
+			Reference:     entry.Reference,
+			Counterparty:  strings.TrimSpace(entry.Payee),
+			BookedAt:      &postedAt,
+			ValueAt:       entry.ValueAt,
+		}).Error
+	})
+	if err != nil {
+		return StatementOutcome{Status: StatementFailed, Err: err}
+	}
+	return outcome
+}
+
+// transaction map the entry onto a transaction of the account, the entry and the transaction are both
+// in the currency of the statement, or of the account when the statement has none
+func (entry StatementEntry) transaction(account, currency string) (*Transaction, error) {
+	payee := strings.TrimSpace(entry.Payee)
+	if payee == "" {
+		payee = strings.TrimSpace(entry.Memo)
+	}
+	if payee == "" {
+		return nil, fmt.Errorf("%w: the entry has no payee", ErrInvalidTransaction)
+	}
+	if entry.Currency != "" {
+		currency = entry.Currency
+	}
+
+	transaction := &Transaction{
+		CreatedAt:        entry.PostedAt,
+		Value:            math.Abs(entry.Amount),
+		Note:             strings.TrimSpace(entry.Memo),
+		Sender:           payee,
+		Receiver:         account,
+		Currency:         currency,
+		ReceivedCurrency: currency,
+	}
+	if entry.Amount < 0 {
+		transaction.Sender, transaction.Receiver = account, payee
+	}
+	if transaction.Note == payee {
+		transaction.Note = ""
+	}
+	if err := transaction.Validate(); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
@@ -2,8 +2,12 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"math"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -14,8 +18,37 @@ var (
 	// ErrInvalidID is returned when an invalid ID is provided to a method like
 	// Delete.
 	ErrInvalidID = errors.New("models: ID provided was invalid")
+
+	// ErrInvalidCredentials is returned when an email and password do not match any user.
+	ErrInvalidCredentials = errors.New("models: invalid email or password")
+
+	// ErrInvalidPassword is returned when a user is given an empty password, or one too long to be hashed.
+	ErrInvalidPassword = errors.New("models: password provided was invalid")
+
+	// ErrInvalidRole is returned when a user is given a role that does not exist.
+	ErrInvalidRole = errors.New("models: role provided was invalid")
+
+	// ErrInvalidTransaction is returned when a transaction is missing a field or has an invalid value.
+	ErrInvalidTransaction = errors.New("models: invalid transaction")
+
+	// ErrConflict is returned when a resource was changed by someone else since it was read.
+	ErrConflict = errors.New("models: resource was changed by someone else")
 )
 
+// ConflictError is returned by Update when the version of the resource being saved is not the version
+// stored in the database. Current hold the stored resource so the caller can retry from it.
+type ConflictError struct {
+	Current interface{}
+}
+
+func (err *ConflictError) Error() string {
+	return ErrConflict.Error()
+}
+
+func (err *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
 type Transaction struct {
 	ID        uint `gorm:"primaryKey"`
 	CreatedAt time.Time
@@ -25,10 +58,44 @@ type Transaction struct {
 	Note      string     `json:"note,omitempty"`
 	Sender    string     `json:"sender,omitempty" gorm:"not null"`
 	Receiver  string     `json:"receiver,omitempty" gorm:"not null"`
+	Hash      string     `json:"hash,omitempty" gorm:"not null;default:''"`
+	PrevHash  string     `json:"prevHash,omitempty" gorm:"not null;default:''"`
+	Version   uint       `json:"version" gorm:"not null;default:1"`
+
+	// Value is in Currency and the receiver is credited ReceivedValue in ReceivedCurrency, converted
+	// with Rate. Rate is 1 when both currencies are the same.
+	Currency         string  `json:"currency,omitempty" gorm:"not null;default:'USD'"`
+	ReceivedValue    float64 `json:"receivedValue,omitempty" gorm:"not null;default:0"`
+	ReceivedCurrency string  `json:"receivedCurrency,omitempty" gorm:"not null;default:''"`
+	Rate             float64 `json:"rate,omitempty" gorm:"not null;default:0"`
+
+	// CategoryID is set by hand or by the first CategoryRule the transaction matches when it is created
+	CategoryID *uint `json:"categoryID,omitempty" gorm:"index"`
+
+	// SplitID is the SplitTransaction the transaction is a leg of, nil for a standalone transaction
+	SplitID *uint `json:"splitID,omitempty" gorm:"index"`
+
+	// Approval is set instead of the ID when Submit held the transaction for approval, it is not stored
+	Approval *TransactionApproval `json:"approval,omitempty" gorm:"-"`
+
+	// Risk is set by Submit to the risk assessment of the transaction, it is not stored with it
+	Risk *RiskAssessment `json:"risk,omitempty" gorm:"-"`
 }
 
+// CurrencyTotal is the sum and the number of amounts in a single currency
+type CurrencyTotal struct {
+	Currency string
+	Total    float64
+	Count    int
+}
+
+// TransactionService creates and updates transactions. submitted is only set for transactions that went
+// through Submit, like a submitted transaction once it is approved or released by a reviewer: their risk
+// was scored and they were checked against the approval policies, Create does neither again.
 type TransactionService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	actor     string
+	submitted bool
 }
 
 // NewTransactionService Create a new TransactionService with a specified connectionInfo.
@@ -38,9 +105,19 @@ func NewTransactionService(db *gorm.DB) (*TransactionService, error) {
 	}, nil
 }
 
-// AutoMigrate will attempt to automatically migrate the transactions table
+// WithActor return a copy of the TransactionService that records the provided actor in the audit
+// log for every change it makes.
+func (transService *TransactionService) WithActor(actor string) *TransactionService {
+	return &TransactionService{
+		db:        transService.db,
+		actor:     actor,
+		submitted: transService.submitted,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the transactions and split transactions tables
 func (transService *TransactionService) AutoMigrate() error {
-	if err := transService.db.AutoMigrate(&Transaction{}).Error; err != nil {
+	if err := transService.db.AutoMigrate(&Transaction{}, &SplitTransaction{}).Error; err != nil {
 		return err
 	}
 	return nil
@@ -94,23 +171,448 @@ func (transService *TransactionService) ReadAll() ([]Transaction, error) {
 	return transactions, nil
 }
 
+// TransactionFilter select the transactions returned by ReadFiltered and Each, a zero field matches
+// every transaction. Account matches either the sender or the receiver, From is inclusive and To is
+// exclusive.
+type TransactionFilter struct {
+	Sender     string
+	Receiver   string
+	Account    string
+	Currency   string
+	From       *time.Time
+	To         *time.Time
+	MinValue   *float64
+	MaxValue   *float64
+	CategoryID *uint
+	Tag        string
+	SplitID    *uint
+}
+
+// apply add the conditions of the filter to the query
+func (filter TransactionFilter) apply(db *gorm.DB) *gorm.DB {
+	if filter.Sender != "" {
+		db = db.Where("sender = ?", filter.Sender)
+	}
+	if filter.Receiver != "" {
+		db = db.Where("receiver = ?", filter.Receiver)
+	}
+	if filter.Account != "" {
+		db = db.Where("sender = ? OR receiver = ?", filter.Account, filter.Account)
+	}
+	if filter.Currency != "" {
+		db = db.Where("currency = ?", filter.Currency)
+	}
+	if filter.From != nil {
+		db = db.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		db = db.Where("created_at < ?", *filter.To)
+	}
+	if filter.MinValue != nil {
+		db = db.Where("value >= ?", *filter.MinValue)
+	}
+	if filter.MaxValue != nil {
+		db = db.Where("value <= ?", *filter.MaxValue)
+	}
+	if filter.CategoryID != nil {
+		db = db.Where("category_id = ?", *filter.CategoryID)
+	}
+	if filter.SplitID != nil {
+		db = db.Where("split_id = ?", *filter.SplitID)
+	}
+	if filter.Tag != "" {
+		db = db.Where("id IN (SELECT transaction_id FROM transaction_tags WHERE tag = ?)", strings.ToLower(strings.TrimSpace(filter.Tag)))
+	}
+	return db
+}
+
+// ReadFiltered return the transactions matching the filter ordered by ID
+func (transService *TransactionService) ReadFiltered(filter TransactionFilter) ([]Transaction, error) {
+	var transactions []Transaction
+	if err := filter.apply(transService.db).Order("id").Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// ReadPage return at most limit transactions matching the filter, newest first, after skipping the
+// first offset ones, and the number of transactions matching the filter
+func (transService *TransactionService) ReadPage(filter TransactionFilter, offset, limit int) ([]Transaction, int, error) {
+	var total int
+	if err := filter.apply(transService.db.Model(&Transaction{})).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var transactions []Transaction
+	err := filter.apply(transService.db).Order("id DESC").Offset(offset).Limit(limit).Find(&transactions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return transactions, total, nil
+}
+
+// Each call fn with every transaction matching the filter, ordered by ID, reading them one row at a
+// time from the database instead of loading them all. It stops at the first error fn returns.
+func (transService *TransactionService) Each(filter TransactionFilter, fn func(*Transaction) error) error {
+	rows, err := filter.apply(transService.db.Model(&Transaction{})).Order("id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction Transaction
+		if err := transService.db.ScanRows(rows, &transaction); err != nil {
+			return err
+		}
+		if err := fn(&transaction); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // Create will create the provided transaction and back-fill data like
-// the ID, CreatedAt, and UpdatedAt fields.
+// the ID, CreatedAt, and UpdatedAt fields. A *LimitExceededError is returned when
+// the transaction would exceed a limit of its sender, and ErrInsufficientFunds when
+// it would spend funds reserved by the active holds of its sender. Unless it went
+// through Submit, a transaction meeting enabled approval policies is refused with
+// ErrApprovalRequired, and the risk of the transaction is scored and Risk is set to
+// its assessment. A transaction that would be held for review is refused with
+// ErrTransactionHeld, only Submit can queue it for review or approval, and a blocked
+// one with ErrTransactionBlocked.
 func (transService *TransactionService) Create(transaction *Transaction) error {
-	return transService.db.Create(transaction).Error
+	transaction.Version = 1
+	if transaction.CreatedAt.IsZero() {
+		transaction.CreatedAt = gorm.NowFunc()
+	}
+	// Keep only the precision stored by the database so the ledger hash survives a round trip
+	transaction.CreatedAt = transaction.CreatedAt.Truncate(time.Microsecond)
+	return transService.db.Transaction(func(tx *gorm.DB) error {
+		if err := transaction.applyCurrencies(tx, nil); err != nil {
+			return err
+		}
+		if err := transService.requireApproval(tx, transaction); err != nil {
+			return err
+		}
+		assessment, err := transService.scoreRisk(tx, transaction)
+		if err != nil {
+			return err
+		}
+		if err := checkLimits(tx, transaction); err != nil {
+			return err
+		}
+		if err := checkAvailable(tx, transaction, nil); err != nil {
+			return err
+		}
+		if err := categorize(tx, transaction); err != nil {
+			return err
+		}
+		if err := tx.Create(transaction).Error; err != nil {
+			return err
+		}
+		if err := appendLedger(tx, AuditCreate, transaction); err != nil {
+			return err
+		}
+		if err := recordAudit(tx, transService.actor, AuditCreate, AuditEntityTransaction, transaction.ID, nil, transaction); err != nil {
+			return err
+		}
+		return transService.storeRisk(tx, transaction, assessment)
+	})
+}
+
+// CreateBatch will create every provided transaction in a single database transaction, either all of
+// them are created or none is.
+func (transService *TransactionService) CreateBatch(transactions []Transaction) error {
+	return transService.db.Transaction(func(tx *gorm.DB) error {
+		txService := &TransactionService{db: tx, actor: transService.actor, submitted: transService.submitted}
+		for i := range transactions {
+			if err := txService.Create(&transactions[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Validate check that the transaction has a positive value, a sender and a receiver, and that its
+// currencies, when they are set, are ISO 4217 codes.
+func (transaction *Transaction) Validate() error {
+	if math.IsNaN(transaction.Value) || math.IsInf(transaction.Value, 0) || transaction.Value <= 0 {
+		return fmt.Errorf("%w: value must be a positive number", ErrInvalidTransaction)
+	}
+	if strings.TrimSpace(transaction.Sender) == "" {
+		return fmt.Errorf("%w: sender is required", ErrInvalidTransaction)
+	}
+	if strings.TrimSpace(transaction.Receiver) == "" {
+		return fmt.Errorf("%w: receiver is required", ErrInvalidTransaction)
+	}
+	for _, currency := range []string{transaction.Currency, transaction.ReceivedCurrency} {
+		if currency == "" {
+			continue
+		}
+		if _, err := NormalizeCurrency(currency); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Update will update the provided trasaction with all the data in the provided
-// user object.
+// user object. The Version of the provided transaction must be the version stored in the
+// database, otherwise a *ConflictError holding the stored transaction is returned.
+// Like Create, a change of value, sender or currency is checked against the limits of the sender and can not
+// spend funds reserved by its active holds. ErrApprovalRequired is returned when the change would need
+// approval, see checkApprovalPolicies. A change of value or parties is scored again like in Create, an
+// update that would be held for review or blocked is refused.
 func (transService *TransactionService) Update(transaction *Transaction) error {
-	return transService.db.Save(transaction).Error
+	return transService.db.Transaction(func(tx *gorm.DB) error {
+		var before Transaction
+		if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", transaction.ID), &before); err != nil {
+			return err
+		}
+		if before.Version != transaction.Version {
+			return &ConflictError{Current: before}
+		}
+		if err := transaction.applyCurrencies(tx, &before); err != nil {
+			return err
+		}
+		if err := checkApprovalPolicies(tx, transaction, &before, transService.actor); err != nil {
+			return err
+		}
+		var assessment *RiskAssessment
+		if transaction.Value != before.Value || transaction.Sender != before.Sender || transaction.Receiver != before.Receiver {
+			var err error
+			if assessment, err = transService.scoreRisk(tx, transaction); err != nil {
+				return err
+			}
+		}
+		if transaction.Value != before.Value || transaction.Sender != before.Sender || transaction.Currency != before.Currency {
+			if err := checkLimits(tx, transaction); err != nil {
+				return err
+			}
+			if err := checkAvailable(tx, transaction, &before); err != nil {
+				return err
+			}
+		}
+		transaction.Version++
+		if err := tx.Save(transaction).Error; err != nil {
+			return err
+		}
+		if err := appendLedger(tx, AuditUpdate, transaction); err != nil {
+			return err
+		}
+		if err := recordAudit(tx, transService.actor, AuditUpdate, AuditEntityTransaction, transaction.ID, before, transaction); err != nil {
+			return err
+		}
+		return transService.storeRisk(tx, transaction, assessment)
+	})
 }
 
-// Delete will delete the transaction with the provided ID
+// Delete will delete the transaction with the provided ID. The row is only soft deleted, it can be
+// restored with Restore until it is purged. ErrNotFound is returned if there is no such transaction.
 func (transService *TransactionService) Delete(id uint) error {
 	if id == 0 { // Go default uint is 0, Gorm will delete all rows if id is not provided
 		return ErrInvalidID
 	}
-	trasaction := Transaction{ID: id}
-	return transService.db.Delete(trasaction).Error
+	return transService.db.Transaction(func(tx *gorm.DB) error {
+		var before Transaction
+		if err := first(tx.Where("id = ?", id), &before); err != nil {
+			return err
+		}
+		if err := tx.Delete(Transaction{ID: id}).Error; err != nil {
+			return err
+		}
+		var deleted Transaction
+		if err := first(tx.Unscoped().Where("id = ?", id), &deleted); err != nil {
+			return err
+		}
+		if err := appendLedger(tx, AuditDelete, &deleted); err != nil {
+			return err
+		}
+		return recordAudit(tx, transService.actor, AuditDelete, AuditEntityTransaction, id, before, nil)
+	})
+}
+
+// ReadDeleted return every soft deleted transaction that has not been purged yet.
+func (transService *TransactionService) ReadDeleted() ([]Transaction, error) {
+	var transactions []Transaction
+	err := transService.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at").Find(&transactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// Restore will undo the soft delete of the transaction with the provided ID.
+// ErrNotFound is returned if there is no soft deleted transaction with this ID.
+func (transService *TransactionService) Restore(id uint) (*Transaction, error) {
+	var transaction Transaction
+	err := transService.db.Transaction(func(tx *gorm.DB) error {
+		if err := first(tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id), &transaction); err != nil {
+			return err
+		}
+		err := tx.Unscoped().Model(&Transaction{}).Where("id = ?", id).UpdateColumn("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+		transaction.DeletedAt = nil
+		if err := appendLedger(tx, AuditRestore, &transaction); err != nil {
+			return err
+		}
+		return recordAudit(tx, transService.actor, AuditRestore, AuditEntityTransaction, id, nil, transaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// Purge will permanently remove the soft deleted transaction with the provided ID.
+// ErrNotFound is returned if there is no soft deleted transaction with this ID.
+func (transService *TransactionService) Purge(id uint) error {
+	return transService.db.Transaction(func(tx *gorm.DB) error {
+		var transaction Transaction
+		if err := first(tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id), &transaction); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(Transaction{ID: id}).Error; err != nil {
+			return err
+		}
+		if err := appendLedger(tx, AuditPurge, &transaction); err != nil {
+			return err
+		}
+		return recordAudit(tx, transService.actor, AuditPurge, AuditEntityTransaction, id, transaction, nil)
+	})
+}
+
+// PurgeDeletedBefore will permanently remove every transaction that was soft deleted before cutoff and
+// return how many were removed.
+func (transService *TransactionService) PurgeDeletedBefore(cutoff time.Time) (int, error) {
+	var ids []uint
+	err := transService.db.Unscoped().Model(&Transaction{}).Where("deleted_at < ?", cutoff).Order("id").Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if err := transService.Purge(id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// TotalsByCurrency sum the value of the transactions created between from and to, either of which may
+// be nil, for each currency.
+func (transService *TransactionService) TotalsByCurrency(from, to *time.Time) ([]CurrencyTotal, error) {
+	db := transService.db.Model(&Transaction{}).
+		Select("currency, SUM(value) AS total, COUNT(*) AS count").
+		Group("currency").
+		Order("currency")
+	if from != nil {
+		db = db.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		db = db.Where("created_at < ?", *to)
+	}
+	var totals []CurrencyTotal
+	if err := db.Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// BalanceByCurrency compute the net amount credited to the account, in each currency it holds money in,
+// from the transactions created before at, or every transaction if at is nil.
+func (transService *TransactionService) BalanceByCurrency(account string, at *time.Time) ([]CurrencyTotal, error) {
+	db := transService.db.Model(&Transaction{})
+	if at != nil {
+		db = db.Where("created_at < ?", *at)
+	}
+
+	var incoming []CurrencyTotal
+	err := db.Select("COALESCE(NULLIF(received_currency, ''), currency) AS currency, "+
+		"SUM(CASE WHEN received_currency = '' THEN value ELSE received_value END) AS total, COUNT(*) AS count").
+		Where("receiver = ?", account).
+		Group("COALESCE(NULLIF(received_currency, ''), currency)").
+		Scan(&incoming).Error
+	if err != nil {
+		return nil, err
+	}
+	var outgoing []CurrencyTotal
+	err = db.Select("currency, SUM(value) AS total, COUNT(*) AS count").
+		Where("sender = ?", account).
+		Group("currency").
+		Scan(&outgoing).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]*CurrencyTotal)
+	var currencies []string
+	for _, total := range append(incoming, outgoing...) {
+		if _, isOK := balances[total.Currency]; !isOK {
+			balances[total.Currency] = &CurrencyTotal{Currency: total.Currency}
+			currencies = append(currencies, total.Currency)
+		}
+	}
+	for _, total := range incoming {
+		balances[total.Currency].Total += total.Total
+		balances[total.Currency].Count += total.Count
+	}
+	for _, total := range outgoing {
+		balances[total.Currency].Total -= total.Total
+		balances[total.Currency].Count += total.Count
+	}
+	sort.Strings(currencies)
+	result := make([]CurrencyTotal, 0, len(currencies))
+	for _, currency := range currencies {
+		balance := *balances[currency]
+		balance.Total = RoundAmount(balance.Total, currency)
+		result = append(result, balance)
+	}
+	return result, nil
+}
+
+// applyCurrencies fill the currencies of the transaction that are not set from the accounts of the
+// sender and receiver, and convert the value into the received currency. The rate effective when the
+// transaction was created is applied, it is only looked up again when the currencies change.
+// before is the stored transaction when updating, nil when creating.
+func (transaction *Transaction) applyCurrencies(tx *gorm.DB, before *Transaction) error {
+	var err error
+	if transaction.Currency == "" {
+		transaction.Currency = accountCurrency(tx, transaction.Sender)
+	}
+	if transaction.Currency, err = NormalizeCurrency(transaction.Currency); err != nil {
+		return err
+	}
+	if transaction.ReceivedCurrency == "" {
+		if before != nil {
+			transaction.ReceivedCurrency = transaction.Currency
+		} else {
+			transaction.ReceivedCurrency = accountCurrency(tx, transaction.Receiver)
+		}
+	}
+	if transaction.ReceivedCurrency, err = NormalizeCurrency(transaction.ReceivedCurrency); err != nil {
+		return err
+	}
+
+	if transaction.Rate == 0 || before == nil ||
+		before.Currency != transaction.Currency || before.ReceivedCurrency != transaction.ReceivedCurrency {
+		transaction.Rate, err = lookupRate(tx, transaction.Currency, transaction.ReceivedCurrency, transaction.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	transaction.ReceivedValue = RoundAmount(transaction.Value*transaction.Rate, transaction.ReceivedCurrency)
+	return nil
+}
+
+// accountCurrency return the currency of the account of the user with the provided email, or the
+// DefaultCurrency if there is no such user
+func accountCurrency(tx *gorm.DB, email string) string {
+	var user User
+	if err := first(tx.Where("email = ?", email), &user); err != nil || user.Currency == "" {
+		return DefaultCurrency
+	}
+	return user.Currency
 }
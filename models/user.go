@@ -1,25 +1,43 @@
 package models
 
 import (
+	"fmt"
 	"github.com/jinzhu/gorm"
+	"golang.org/x/crypto/bcrypt"
 	"time"
 )
 
+// Roles a user can have. Auditors can read the audit log and verify the ledger, admins can also
+// manage deleted records and roles.
+const (
+	RoleUser    = "user"
+	RoleAuditor = "auditor"
+	RoleAdmin   = "admin"
+)
+
 type User struct {
 	ID        uint `gorm:"primaryKey"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt *time.Time `sql:"index" gorm:"index"`
 	Email     string     `json:"email,omitempty" gorm:"not null;unique_index"`
-	Password  string     `json:"password" gorm:"not null"`
+	Password  string     `json:"-" gorm:"not null"`
 	Last      string     `json:"last" gorm:"not null"`
 	Middle    string     `json:"middle,omitempty"`
 	First     string     `json:"first,omitempty"`
 	Phone     string     `json:"phone,omitempty"`
+	Role      string     `json:"role,omitempty" gorm:"not null;default:'user'"`
+	Version   uint       `json:"version" gorm:"not null;default:1"`
+	Currency  string     `json:"currency,omitempty" gorm:"not null;default:'USD'"`
 }
 
+// dummyPasswordHash is compared with the password of unknown emails so Authenticate takes as long for them
+// as for known users
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
 type UserService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	actor string
 }
 
 func NewUserService(db *gorm.DB) (*UserService, error) {
@@ -28,11 +46,42 @@ func NewUserService(db *gorm.DB) (*UserService, error) {
 	}, nil
 }
 
-// AutoMigrate will attempt to automatically migrate the users table
+// WithActor return a copy of the UserService that records the provided actor in the audit log for
+// every change it makes.
+func (userService *UserService) WithActor(actor string) *UserService {
+	return &UserService{
+		db:    userService.db,
+		actor: actor,
+	}
+}
+
+// AutoMigrate will attempt to automatically migrate the users table and hash the passwords still stored
+// in plaintext
 func (userService *UserService) AutoMigrate() error {
 	if err := userService.db.AutoMigrate(&User{}).Error; err != nil {
 		return err
 	}
+	return userService.hashPlaintextPasswords()
+}
+
+// hashPlaintextPasswords replace every password that is not a bcrypt hash yet by its hash. Empty passwords
+// are kept, they never match since they are not hashes.
+func (userService *UserService) hashPlaintextPasswords() error {
+	var users []User
+	err := userService.db.Unscoped().Select("id, password").Where("password <> '' AND password NOT LIKE ?", "$2%").Find(&users).Error
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		hash, err := hashPassword(user.Password)
+		if err != nil {
+			return err
+		}
+		err = userService.db.Unscoped().Model(&User{}).Where("id = ?", user.ID).UpdateColumn("password", hash).Error
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -64,6 +113,34 @@ func (userService *UserService) ReadByID(id uint) (*User, error) {
 	return &user, nil
 }
 
+// ReadByEmail will look up a user with the provided email, it behaves like ReadByID.
+func (userService *UserService) ReadByEmail(email string) (*User, error) {
+	var user User
+	db := userService.db.Where("email = ?", email)
+	err := first(db, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Authenticate look up the user with the provided email and check the provided password against it.
+// ErrInvalidCredentials is returned if there is no such user or the password does not match.
+func (userService *UserService) Authenticate(email, password string) (*User, error) {
+	user, err := userService.ReadByEmail(email)
+	if err == ErrNotFound {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
 func (userService *UserService) ReadAll() ([]User, error) {
 	var users []User
 	userService.db.Find(&users)
@@ -74,22 +151,170 @@ func (userService *UserService) ReadAll() ([]User, error) {
 }
 
 // Create will create the provided user and back-fill data like
-// the ID, CreatedAt, and UpdatedAt fields.
+// the ID, CreatedAt, and UpdatedAt fields. The password is replaced by its bcrypt hash.
 func (userService *UserService) Create(user *User) error {
-	return userService.db.Create(user).Error
+	user.Version = 1
+	if user.Currency == "" {
+		user.Currency = DefaultCurrency
+	}
+	var err error
+	if user.Currency, err = NormalizeCurrency(user.Currency); err != nil {
+		return err
+	}
+	if user.Password, err = hashPassword(user.Password); err != nil {
+		return err
+	}
+	return userService.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, userService.actor, AuditCreate, AuditEntityUser, user.ID, nil, user.auditSnapshot())
+	})
 }
 
 // Update will update the provided user with all the data in the provided
-// user object.
+// user object. The Version of the provided user must be the version stored in the
+// database, otherwise a *ConflictError holding the stored user is returned. A password other than the
+// stored hash is a new password and is replaced by its bcrypt hash.
 func (userService *UserService) Update(user *User) error {
-	return userService.db.Save(user).Error
+	return userService.db.Transaction(func(tx *gorm.DB) error {
+		var before User
+		if err := first(tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", user.ID), &before); err != nil {
+			return err
+		}
+		if before.Version != user.Version {
+			return &ConflictError{Current: before.auditSnapshot()}
+		}
+		var err error
+		if user.Currency, err = NormalizeCurrency(user.Currency); err != nil {
+			return err
+		}
+		if user.Password != before.Password {
+			if user.Password, err = hashPassword(user.Password); err != nil {
+				return err
+			}
+		}
+		user.Version++
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, userService.actor, AuditUpdate, AuditEntityUser, user.ID, before.auditSnapshot(), user.auditSnapshot())
+	})
 }
 
-// Delete will delete the user with the provided ID
+// Delete will delete the user with the provided ID. The row is only soft deleted, it can be restored
+// with Restore until it is purged. ErrNotFound is returned if there is no such user.
 func (userService *UserService) Delete(id uint) error {
 	if id == 0 { // Go default uint is 0, Gorm will delete all rows if id is not provided
 		return ErrInvalidID
 	}
-	user := User{ID: id}
-	return userService.db.Delete(user).Error
+	return userService.db.Transaction(func(tx *gorm.DB) error {
+		var before User
+		if err := first(tx.Where("id = ?", id), &before); err != nil {
+			return err
+		}
+		if err := tx.Delete(User{ID: id}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, userService.actor, AuditDelete, AuditEntityUser, id, before.auditSnapshot(), nil)
+	})
+}
+
+// ReadDeleted return every soft deleted user that has not been purged yet.
+func (userService *UserService) ReadDeleted() ([]User, error) {
+	var users []User
+	err := userService.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at").Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// Restore will undo the soft delete of the user with the provided ID.
+// ErrNotFound is returned if there is no soft deleted user with this ID.
+func (userService *UserService) Restore(id uint) (*User, error) {
+	var user User
+	err := userService.db.Transaction(func(tx *gorm.DB) error {
+		if err := first(tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id), &user); err != nil {
+			return err
+		}
+		err := tx.Unscoped().Model(&User{}).Where("id = ?", id).UpdateColumn("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+		user.DeletedAt = nil
+		return recordAudit(tx, userService.actor, AuditRestore, AuditEntityUser, id, nil, user.auditSnapshot())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Purge will permanently remove the soft deleted user with the provided ID.
+// ErrNotFound is returned if there is no soft deleted user with this ID.
+func (userService *UserService) Purge(id uint) error {
+	return userService.db.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := first(tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id), &user); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(User{ID: id}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, userService.actor, AuditPurge, AuditEntityUser, id, user.auditSnapshot(), nil)
+	})
+}
+
+// PurgeDeletedBefore will permanently remove every user that was soft deleted before cutoff and return
+// how many were removed.
+func (userService *UserService) PurgeDeletedBefore(cutoff time.Time) (int, error) {
+	var ids []uint
+	err := userService.db.Unscoped().Model(&User{}).Where("deleted_at < ?", cutoff).Order("id").Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if err := userService.Purge(id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// UpdateRole give the user with the provided ID a new role.
+func (userService *UserService) UpdateRole(id uint, role string) (*User, error) {
+	if !isValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	user, err := userService.ReadByID(id)
+	if err != nil {
+		return nil, err
+	}
+	user.Role = role
+	return user, userService.Update(user)
+}
+
+// auditSnapshot return a copy of the user that is safe to store in the audit log, the password is
+// never recorded.
+func (user User) auditSnapshot() User {
+	user.Password = ""
+	return user
+}
+
+// hashPassword return the bcrypt hash of a new password, ErrInvalidPassword when it is empty
+func hashPassword(password string) (string, error) {
+	if password == "" {
+		return "", ErrInvalidPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPassword, err)
+	}
+	return string(hash), nil
+}
+
+// isValidRole report whether the role is one of the roles a user can have
+func isValidRole(role string) bool {
+	return role == RoleUser || role == RoleAuditor || role == RoleAdmin
 }
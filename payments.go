@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"transaction_project/controllers"
+	"transaction_project/models"
+	"transaction_project/payments"
+)
+
+// paymentOriginator read the company paying out the payment batches and its bank account from the
+// PAYMENT_ORIGINATOR_* environment variables
+func paymentOriginator() payments.Originator {
+	return payments.Originator{
+		Name:            os.Getenv("PAYMENT_ORIGINATOR_NAME"),
+		IBAN:            strings.ReplaceAll(os.Getenv("PAYMENT_ORIGINATOR_IBAN"), " ", ""),
+		BIC:             os.Getenv("PAYMENT_ORIGINATOR_BIC"),
+		RoutingNumber:   os.Getenv("PAYMENT_ORIGINATOR_ROUTING_NUMBER"),
+		CompanyID:       os.Getenv("PAYMENT_ORIGINATOR_COMPANY_ID"),
+		ImmediateOrigin: os.Getenv("PAYMENT_ORIGINATOR_IMMEDIATE_ORIGIN"),
+		DestinationName: os.Getenv("PAYMENT_ORIGINATOR_BANK_NAME"),
+	}
+}
+
+// createPaymentBatch implement the payment-batch command
+func createPaymentBatch(services *models.Services, args []string) error {
+	flags := flag.NewFlagSet("payment-batch", flag.ExitOnError)
+	format := flags.String("format", payments.FormatPain001, "pain.001 or nacha")
+	currency := flags.String("currency", "", "currency of the transactions to pay")
+	ids := flags.String("ids", "", "comma separated IDs of the transactions to pay, the oldest pending ones when empty")
+	limit := flags.Int("limit", 0, "maximum number of transactions to pay when -ids is empty, 0 for no limit")
+	executionDate := flags.String("execution-date", "", "date the bank should pay, YYYY-MM-DD, today when empty")
+	path := flags.String("o", "", "file to write, the name of the batch file when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	input := controllers.PaymentBatchInput{
+		Format:   *format,
+		Currency: *currency,
+		Limit:    *limit,
+	}
+	if *ids != "" {
+		for _, encoded := range strings.Split(*ids, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(encoded), 10, 64)
+			if err != nil || id == 0 {
+				return fmt.Errorf("invalid transaction ID %q", encoded)
+			}
+			input.TransactionIDs = append(input.TransactionIDs, uint(id))
+		}
+	}
+	if *executionDate != "" {
+		date, err := time.Parse("2006-01-02", *executionDate)
+		if err != nil {
+			return fmt.Errorf("-execution-date must be a date like 2024-01-31")
+		}
+		input.ExecutionDate = &date
+	}
+
+	batch, err := controllers.NewPaymentController(services.Payment, paymentOriginator()).NewBatch(input)
+	if err != nil {
+		return err
+	}
+	if *path == "" {
+		*path = batch.Filename
+	}
+	if err := os.WriteFile(*path, []byte(batch.Content), 0o600); err != nil {
+		return err
+	}
+	fmt.Printf("Batch %d: %d transactions, %.2f %s, written to %s\n", batch.ID, batch.Count, batch.Total, batch.Currency, *path)
+	return nil
+}
+
+// downloadPaymentBatch implement the download-payment-batch command
+func downloadPaymentBatch(services *models.Services, args []string) error {
+	flags := flag.NewFlagSet("download-payment-batch", flag.ExitOnError)
+	id := flags.Uint("id", 0, "ID of the payment batch")
+	path := flags.String("o", "", "file to write, the name of the batch file when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	batch, err := services.Payment.ReadBatch(*id)
+	if err != nil {
+		return err
+	}
+	if *path == "" {
+		*path = batch.Filename
+	}
+	if err := os.WriteFile(*path, []byte(batch.Content), 0o600); err != nil {
+		return err
+	}
+	fmt.Printf("Batch %d written to %s\n", batch.ID, *path)
+	return nil
+}
@@ -0,0 +1,155 @@
+package payments
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NACHA record layout constants
+const (
+	nachaRecordSize     = 94
+	nachaBlockingFactor = 10
+	// nachaCreditsOnly is the service class code of a batch that only credits accounts
+	nachaCreditsOnly = "220"
+	// Transaction codes of credits to checking and savings accounts
+	nachaCheckingCredit = "22"
+	nachaSavingsCredit  = "32"
+)
+
+// alphanumeric format a field of a NACHA record: upper case, left justified and padded with spaces
+func alphanumeric(value string, size int) string {
+	value = strings.Map(func(char rune) rune {
+		if char < ' ' || char > '~' {
+			return ' '
+		}
+		return char
+	}, strings.ToUpper(value))
+	value = truncate(value, size)
+	return value + strings.Repeat(" ", size-len(value))
+}
+
+// numeric format a field of a NACHA record: right justified and padded with zeros, keeping the lowest
+// digits when the number is too large like the entry hash requires
+func numeric(value int64, size int) string {
+	digits := strconv.FormatInt(value, 10)
+	if len(digits) > size {
+		return digits[len(digits)-size:]
+	}
+	return strings.Repeat("0", size-len(digits)) + digits
+}
+
+// nacha write the batch as a NACHA file with a single PPD batch crediting every payment from the
+// account of the originator. The file is padded with 9 filled records to a multiple of 10 records.
+func nacha(batch Batch) ([]byte, error) {
+	originator := batch.Originator
+	if batch.Currency != "USD" {
+		return nil, fmt.Errorf("%w: ACH payments must be in USD", ErrInvalidBatch)
+	}
+	if len(originator.RoutingNumber) != 9 || originator.CompanyID == "" || originator.Name == "" {
+		return nil, fmt.Errorf("%w: NACHA needs the routing number, the company ID and the name of the originator", ErrInvalidBatch)
+	}
+	immediateOrigin := originator.ImmediateOrigin
+	if immediateOrigin == "" {
+		immediateOrigin = originator.CompanyID
+	}
+	odfi := originator.RoutingNumber[:8]
+	batchNumber := int64(batch.ID % 10000000)
+
+	var records []string
+	records = append(records, "1"+
+		"01"+
+		" "+originator.RoutingNumber+
+		fmt.Sprintf("%10s", truncate(immediateOrigin, 10))+
+		batch.CreatedAt.UTC().Format("060102")+
+		batch.CreatedAt.UTC().Format("1504")+
+		"A"+
+		"094"+
+		"10"+
+		"1"+
+		alphanumeric(originator.DestinationName, 23)+
+		alphanumeric(originator.Name, 23)+
+		alphanumeric(numeric(batchNumber, 8), 8))
+
+	records = append(records, "5"+
+		nachaCreditsOnly+
+		alphanumeric(originator.Name, 16)+
+		alphanumeric("", 20)+
+		alphanumeric(originator.CompanyID, 10)+
+		"PPD"+
+		alphanumeric("PAYOUT", 10)+
+		alphanumeric(batch.CreatedAt.UTC().Format("Jan 06"), 6)+
+		batch.ExecutionDate.Format("060102")+
+		"   "+
+		"1"+
+		odfi+
+		numeric(batchNumber, 7))
+
+	var entryHash, totalCredit int64
+	for i, payment := range batch.Payments {
+		if len(payment.RoutingNumber) != 9 || payment.AccountNumber == "" {
+			return nil, fmt.Errorf("%w: %s has no routing and account number", ErrInvalidBatch, payment.Name)
+		}
+		amount := cents(payment.Amount)
+		if amount <= 0 || amount > 9999999999 {
+			return nil, fmt.Errorf("%w: the amount paid to %s must be positive and fit in 10 digits", ErrInvalidBatch, payment.Name)
+		}
+		receivingDFI, err := strconv.ParseInt(payment.RoutingNumber[:8], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid routing number of %s", ErrInvalidBatch, payment.Name)
+		}
+		entryHash += receivingDFI
+		totalCredit += amount
+
+		transactionCode := nachaCheckingCredit
+		if payment.Savings {
+			transactionCode = nachaSavingsCredit
+		}
+		records = append(records, "6"+
+			transactionCode+
+			payment.RoutingNumber+
+			alphanumeric(payment.AccountNumber, 17)+
+			numeric(amount, 10)+
+			alphanumeric(payment.EndToEndID, 15)+
+			alphanumeric(payment.Name, 22)+
+			"  "+
+			"0"+
+			odfi+numeric(int64(i+1), 7))
+	}
+
+	entries := int64(len(batch.Payments))
+	records = append(records, "8"+
+		nachaCreditsOnly+
+		numeric(entries, 6)+
+		numeric(entryHash, 10)+
+		numeric(0, 12)+
+		numeric(totalCredit, 12)+
+		alphanumeric(originator.CompanyID, 10)+
+		strings.Repeat(" ", 19)+
+		strings.Repeat(" ", 6)+
+		odfi+
+		numeric(batchNumber, 7))
+
+	blocks := (len(records) + 1 + nachaBlockingFactor - 1) / nachaBlockingFactor
+	records = append(records, "9"+
+		numeric(1, 6)+
+		numeric(int64(blocks), 6)+
+		numeric(entries, 8)+
+		numeric(entryHash, 10)+
+		numeric(0, 12)+
+		numeric(totalCredit, 12)+
+		strings.Repeat(" ", 39))
+	for len(records)%nachaBlockingFactor != 0 {
+		records = append(records, strings.Repeat("9", nachaRecordSize))
+	}
+
+	var content strings.Builder
+	for _, record := range records {
+		if len(record) != nachaRecordSize {
+			return nil, fmt.Errorf("payments: NACHA record of %d characters: %q", len(record), record)
+		}
+		content.WriteString(record)
+		content.WriteString("\n")
+	}
+	return []byte(content.String()), nil
+}
@@ -0,0 +1,183 @@
+package payments
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// pain001Namespace is the namespace of the Customer Credit Transfer Initiation V03
+const pain001Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"
+
+// The elements of a pain.001.001.03 document, optional elements are omitted when empty
+type (
+	pain001Document struct {
+		XMLName    xml.Name          `xml:"Document"`
+		Namespace  string            `xml:"xmlns,attr"`
+		Initiation pain001Initiation `xml:"CstmrCdtTrfInitn"`
+	}
+	pain001Initiation struct {
+		GroupHeader pain001GroupHeader `xml:"GrpHdr"`
+		PaymentInfo pain001PaymentInfo `xml:"PmtInf"`
+	}
+	pain001GroupHeader struct {
+		MessageID       string       `xml:"MsgId"`
+		CreatedAt       string       `xml:"CreDtTm"`
+		NumberOfTxs     int          `xml:"NbOfTxs"`
+		ControlSum      string       `xml:"CtrlSum"`
+		InitiatingParty pain001Party `xml:"InitgPty"`
+	}
+	pain001Party struct {
+		Name string `xml:"Nm"`
+	}
+	pain001Account struct {
+		IBAN string `xml:"Id>IBAN"`
+	}
+	pain001Agent struct {
+		Institution pain001Institution `xml:"FinInstnId"`
+	}
+	pain001Institution struct {
+		BIC   string        `xml:"BIC,omitempty"`
+		Other *pain001Other `xml:"Othr,omitempty"`
+	}
+	pain001Other struct {
+		ID string `xml:"Id"`
+	}
+	pain001PaymentType struct {
+		ServiceLevel string `xml:"SvcLvl>Cd"`
+	}
+	pain001Remittance struct {
+		Unstructured string `xml:"Ustrd"`
+	}
+	pain001PaymentInfo struct {
+		ID            string                  `xml:"PmtInfId"`
+		Method        string                  `xml:"PmtMtd"`
+		BatchBooking  bool                    `xml:"BtchBookg"`
+		NumberOfTxs   int                     `xml:"NbOfTxs"`
+		ControlSum    string                  `xml:"CtrlSum"`
+		PaymentType   *pain001PaymentType     `xml:"PmtTpInf,omitempty"`
+		ExecutionDate string                  `xml:"ReqdExctnDt"`
+		Debtor        pain001Party            `xml:"Dbtr"`
+		DebtorAccount pain001Account          `xml:"DbtrAcct"`
+		DebtorAgent   pain001Agent            `xml:"DbtrAgt"`
+		ChargeBearer  string                  `xml:"ChrgBr"`
+		Transfers     []pain001CreditTransfer `xml:"CdtTrfTxInf"`
+	}
+	pain001CreditTransfer struct {
+		EndToEndID      string             `xml:"PmtId>EndToEndId"`
+		Amount          pain001Amount      `xml:"Amt>InstdAmt"`
+		CreditorAgent   *pain001Agent      `xml:"CdtrAgt,omitempty"`
+		Creditor        pain001Party       `xml:"Cdtr"`
+		CreditorAccount pain001Account     `xml:"CdtrAcct"`
+		Remittance      *pain001Remittance `xml:"RmtInf,omitempty"`
+	}
+	pain001Amount struct {
+		Currency string `xml:"Ccy,attr"`
+		Value    string `xml:",chardata"`
+	}
+)
+
+// sepaText replace the characters outside of the Latin character set of SEPA with spaces
+func sepaText(text string, size int) string {
+	text = strings.Map(func(char rune) rune {
+		switch {
+		case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+			return char
+		case strings.ContainsRune("/-?:().,'+ ", char):
+			return char
+		default:
+			return ' '
+		}
+	}, text)
+	return truncate(strings.Join(strings.Fields(text), " "), size)
+}
+
+// formatAmount format the amount with two decimals as ISO 20022 amounts are written
+func formatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// pain001 write the batch as a pain.001.001.03 credit transfer initiation with a single payment
+// information block debiting the account of the originator. Batches in euro use the SEPA service level.
+func pain001(batch Batch) ([]byte, error) {
+	originator := batch.Originator
+	if originator.Name == "" || originator.IBAN == "" {
+		return nil, fmt.Errorf("%w: pain.001 needs the name and the IBAN of the originator", ErrInvalidBatch)
+	}
+
+	var total int64
+	transfers := make([]pain001CreditTransfer, 0, len(batch.Payments))
+	for _, payment := range batch.Payments {
+		if payment.IBAN == "" {
+			return nil, fmt.Errorf("%w: %s has no IBAN", ErrInvalidBatch, payment.Name)
+		}
+		amount := cents(payment.Amount)
+		if amount <= 0 {
+			return nil, fmt.Errorf("%w: the amount paid to %s must be positive", ErrInvalidBatch, payment.Name)
+		}
+		total += amount
+		transfer := pain001CreditTransfer{
+			EndToEndID:      sepaText(payment.EndToEndID, 35),
+			Amount:          pain001Amount{Currency: batch.Currency, Value: formatAmount(amount)},
+			Creditor:        pain001Party{Name: sepaText(payment.Name, 70)},
+			CreditorAccount: pain001Account{IBAN: payment.IBAN},
+		}
+		if payment.BIC != "" {
+			transfer.CreditorAgent = &pain001Agent{Institution: pain001Institution{BIC: payment.BIC}}
+		}
+		if remittance := sepaText(payment.Remittance, 140); remittance != "" {
+			transfer.Remittance = &pain001Remittance{Unstructured: remittance}
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	debtorAgent := pain001Agent{Institution: pain001Institution{BIC: originator.BIC}}
+	if originator.BIC == "" {
+		debtorAgent.Institution.Other = &pain001Other{ID: "NOTPROVIDED"}
+	}
+	var paymentType *pain001PaymentType
+	if batch.Currency == "EUR" {
+		paymentType = &pain001PaymentType{ServiceLevel: "SEPA"}
+	}
+	controlSum := formatAmount(total)
+	document := pain001Document{
+		Namespace: pain001Namespace,
+		Initiation: pain001Initiation{
+			GroupHeader: pain001GroupHeader{
+				MessageID:       sepaText(batch.MessageID, 35),
+				CreatedAt:       batch.CreatedAt.UTC().Format("2006-01-02T15:04:05"),
+				NumberOfTxs:     len(transfers),
+				ControlSum:      controlSum,
+				InitiatingParty: pain001Party{Name: sepaText(originator.Name, 70)},
+			},
+			PaymentInfo: pain001PaymentInfo{
+				ID:            sepaText(batch.MessageID, 35),
+				Method:        "TRF",
+				BatchBooking:  true,
+				NumberOfTxs:   len(transfers),
+				ControlSum:    controlSum,
+				PaymentType:   paymentType,
+				ExecutionDate: batch.ExecutionDate.Format("2006-01-02"),
+				Debtor:        pain001Party{Name: sepaText(originator.Name, 70)},
+				DebtorAccount: pain001Account{IBAN: originator.IBAN},
+				DebtorAgent:   debtorAgent,
+				ChargeBearer:  "SLEV",
+				Transfers:     transfers,
+			},
+		},
+	}
+	if paymentType == nil {
+		// SLEV, following the service level, is only meaningful for SEPA
+		document.Initiation.PaymentInfo.ChargeBearer = "SHAR"
+	}
+
+	content, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(content, '\n')...), nil
+}
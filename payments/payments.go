@@ -0,0 +1,114 @@
+// Package payments generate the files that instruct a bank to pay out a batch of transactions.
+package payments
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Formats of the payment files that can be generated
+const (
+	FormatPain001 = "pain.001"
+	FormatNACHA   = "nacha"
+)
+
+// ErrUnknownFormat is returned when the format of a payment file is not supported.
+var ErrUnknownFormat = errors.New("payments: unknown file format")
+
+// ErrInvalidBatch is returned when a batch can not be written in the format, like an ACH batch in a
+// currency other than USD or a payment to an account without the details the format needs.
+var ErrInvalidBatch = errors.New("payments: invalid batch")
+
+// Originator is the company paying out the batches and its bank account. Name, IBAN and BIC are used
+// by pain.001 and the other fields by NACHA.
+type Originator struct {
+	Name            string
+	IBAN            string
+	BIC             string
+	RoutingNumber   string
+	CompanyID       string
+	ImmediateOrigin string
+	DestinationName string
+}
+
+// Payment is a credit transfer to a bank account
+type Payment struct {
+	EndToEndID    string
+	Amount        float64
+	Name          string
+	IBAN          string
+	BIC           string
+	RoutingNumber string
+	AccountNumber string
+	Savings       bool
+	Remittance    string
+}
+
+// Batch is the payments written to a single file. ID is unique to the batch and numbers it in the file.
+type Batch struct {
+	ID            uint
+	MessageID     string
+	CreatedAt     time.Time
+	ExecutionDate time.Time
+	Currency      string
+	Originator    Originator
+	Payments      []Payment
+}
+
+// Generate write the batch in the format and return the file and its name
+func Generate(format string, batch Batch) (filename string, content []byte, err error) {
+	if len(batch.Payments) == 0 {
+		return "", nil, fmt.Errorf("%w: no payment", ErrInvalidBatch)
+	}
+	switch strings.ToLower(format) {
+	case FormatPain001:
+		content, err = pain001(batch)
+		filename = fmt.Sprintf("pain001-%s.xml", batch.MessageID)
+	case FormatNACHA:
+		content, err = nacha(batch)
+		filename = fmt.Sprintf("nacha-%s.ach", batch.MessageID)
+	default:
+		return "", nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return filename, content, nil
+}
+
+// Supported report whether payment files can be generated in the format
+func Supported(format string) bool {
+	switch strings.ToLower(format) {
+	case FormatPain001, FormatNACHA:
+		return true
+	default:
+		return false
+	}
+}
+
+// ContentType return the MIME type of the files of the format
+func ContentType(format string) string {
+	if strings.ToLower(format) == FormatPain001 {
+		return "application/xml"
+	}
+	return "text/plain; charset=us-ascii"
+}
+
+// cents return the amount in minor units
+func cents(amount float64) int64 {
+	if amount < 0 {
+		return int64(amount*100 - 0.5)
+	}
+	return int64(amount*100 + 0.5)
+}
+
+// truncate cut the text to at most size characters
+func truncate(text string, size int) string {
+	runes := []rune(text)
+	if len(runes) > size {
+		return string(runes[:size])
+	}
+	return text
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"transaction_project/models"
+)
+
+const purgeInterval = 24 * time.Hour
+
+// purgeRetention read how long soft deleted records are kept from the PURGE_RETENTION environment
+// variable. Zero is returned if the variable is not set, which disable the purge job.
+func purgeRetention() (time.Duration, error) {
+	encoded := os.Getenv("PURGE_RETENTION")
+	if encoded == "" {
+		return 0, nil
+	}
+	retention, err := time.ParseDuration(encoded)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("PURGE_RETENTION must be a positive duration like 720h")
+	}
+	return retention, nil
+}
+
+// purgeDeletedRecords permanently remove every transaction and user soft deleted more than retention ago
+func purgeDeletedRecords(services *models.Services, retention time.Duration) (transactions, users int, err error) {
+	cutoff := time.Now().Add(-retention)
+	transactions, err = services.Transaction.PurgeDeletedBefore(cutoff)
+	if err != nil {
+		return transactions, 0, err
+	}
+	users, err = services.User.PurgeDeletedBefore(cutoff)
+	return transactions, users, err
+}
+
+// runPurgeJob purge the expired soft deleted records once per purgeInterval, it never returns
+func runPurgeJob(services *models.Services, retention time.Duration) {
+	for {
+		transactions, users, err := purgeDeletedRecords(services, retention)
+		if err != nil {
+			log.Printf("Purge of deleted records failed: %v", err)
+		}
+		if transactions > 0 || users > 0 {
+			log.Printf("Purged %d deleted transactions and %d deleted users", transactions, users)
+		}
+		time.Sleep(purgeInterval)
+	}
+}
+
+// purgeDeleted implement the purge-deleted command
+func purgeDeleted(services *models.Services, args []string) error {
+	flags := flag.NewFlagSet("purge-deleted", flag.ExitOnError)
+	olderThan := flags.Duration("older-than", 0, "purge records deleted longer ago than this, defaults to PURGE_RETENTION")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	retention := *olderThan
+	if retention == 0 {
+		var err error
+		if retention, err = purgeRetention(); err != nil {
+			return err
+		}
+	}
+	if retention <= 0 {
+		return fmt.Errorf("-older-than or PURGE_RETENTION must be set to a positive duration")
+	}
+
+	transactions, users, err := purgeDeletedRecords(services, retention)
+	fmt.Printf("Purged %d deleted transactions and %d deleted users\n", transactions, users)
+	return err
+}
+
+// setRole implement the set-role command
+func setRole(services *models.Services, args []string) error {
+	flags := flag.NewFlagSet("set-role", flag.ExitOnError)
+	email := flags.String("email", "", "email of the user")
+	role := flags.String("role", models.RoleUser, "new role: user, auditor or admin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	user, err := services.User.ReadByEmail(*email)
+	if err != nil {
+		return err
+	}
+	user, err = services.User.UpdateRole(user.ID, *role)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s\n", user.Email, user.Role)
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"transaction_project/models"
+)
+
+// loadRates implement the load-rates command
+func loadRates(services *models.Services, args []string) error {
+	flags := flag.NewFlagSet("load-rates", flag.ExitOnError)
+	path := flags.String("file", "", "CSV or JSON file of exchange rates")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var loaded int
+	switch strings.ToLower(filepath.Ext(*path)) {
+	case ".csv":
+		loaded, err = services.Rate.LoadCSV(file)
+	case ".json":
+		loaded, err = services.Rate.LoadJSON(file)
+	default:
+		return fmt.Errorf("%s: rates file must be .csv or .json", *path)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Loaded %d exchange rates\n", loaded)
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"transaction_project/models"
+)
+
+const defaultSchedulerInterval = time.Minute
+
+// schedulerInterval read how often due scheduled transactions are materialized and overdue payment
+// requests and holds are expired from the SCHEDULER_INTERVAL environment variable
+func schedulerInterval() (time.Duration, error) {
+	encoded := os.Getenv("SCHEDULER_INTERVAL")
+	if encoded == "" {
+		return defaultSchedulerInterval, nil
+	}
+	interval, err := time.ParseDuration(encoded)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("SCHEDULER_INTERVAL must be a positive duration like 1m")
+	}
+	return interval, nil
+}
+
+// runScheduler create the transactions of the due scheduled transactions, expire the overdue payment
+// requests and release the expired holds every interval, it never returns
+func runScheduler(services *models.Services, interval time.Duration) {
+	for {
+		created, err := services.Schedule.MaterializeDue(time.Now())
+		if err != nil {
+			log.Printf("Scheduler failed: %v", err)
+		}
+		if created > 0 {
+			log.Printf("Scheduler created %d transactions", created)
+		}
+		expired, err := services.PaymentRequest.ExpireDue(time.Now())
+		if err != nil {
+			log.Printf("Expiry of payment requests failed: %v", err)
+		}
+		if expired > 0 {
+			log.Printf("Scheduler expired %d payment requests", expired)
+		}
+		released, err := services.Hold.ExpireDue(time.Now())
+		if err != nil {
+			log.Printf("Release of expired holds failed: %v", err)
+		}
+		if released > 0 {
+			log.Printf("Scheduler released %d expired holds", released)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+	"transaction_project/controllers"
+	"transaction_project/models"
+	"transaction_project/views"
+)
+
+// generateStatements implement the statements command
+func generateStatements(services *models.Services, args []string) error {
+	flags := flag.NewFlagSet("statements", flag.ExitOnError)
+	dir := flags.String("dir", "statements", "directory to write the statements to")
+	format := flags.String("format", views.FormatPDF, "pdf or html")
+	account := flags.String("account", "", "only generate the statement of this account, every user when empty")
+	period := url.Values{}
+	for name, usage := range map[string]string{
+		"month": "month of the statements, YYYY-MM, the previous month when no period is given",
+		"from":  "first day of the statements, YYYY-MM-DD",
+		"to":    "day after the last day of the statements, YYYY-MM-DD",
+	} {
+		name := name
+		flags.Func(name, usage, func(value string) error {
+			period.Set(name, value)
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if !views.Supported(*format) {
+		return fmt.Errorf("%w: %q", views.ErrUnknownFormat, *format)
+	}
+	from, to, err := controllers.ParseStatementPeriod(period, time.Now())
+	if err != nil {
+		return err
+	}
+	accounts := []string{*account}
+	if *account == "" {
+		users, err := services.User.ReadAll()
+		if err != nil {
+			return err
+		}
+		accounts = accounts[:0]
+		for _, user := range users {
+			accounts = append(accounts, user.Email)
+		}
+	}
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		return err
+	}
+
+	statementController := controllers.NewAccountStatementController(services.Transaction)
+	failed := 0
+	for _, account := range accounts {
+		if err := writeStatement(statementController, *dir, *format, account, from, to); err != nil {
+			log.Printf("Statement of %s failed: %v", account, err)
+			failed++
+		}
+	}
+	fmt.Printf("Generated %d statements from %s to %s in %s\n",
+		len(accounts)-failed, from.Format("2006-01-02"), to.Format("2006-01-02"), *dir)
+	if failed > 0 {
+		return fmt.Errorf("%d statements failed", failed)
+	}
+	return nil
+}
+
+// writeStatement render the statement of the account to a new file of the directory
+func writeStatement(statementController *controllers.AccountStatement, dir, format, account string, from, to time.Time) error {
+	file, err := os.CreateTemp(dir, ".statement-*")
+	if err != nil {
+		return err
+	}
+	statement, err := statementController.Render(file, format, account, from, to)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), filepath.Join(dir, views.StatementFilename(statement, format)))
+}
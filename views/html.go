@@ -0,0 +1,74 @@
+package views
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+	"transaction_project/models"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+// funcs are the functions the HTML templates can call
+var funcs = template.FuncMap{
+	"amount":   func(amount float64, currency string) string { return formatAmount(amount, currency, false) },
+	"credit":   func(amount float64, currency string) string { return formatAmount(amount, currency, true) },
+	"date":     func(date time.Time) string { return date.Format(dateLayout) },
+	"datetime": func(date time.Time) string { return date.Format("2006-01-02 15:04:05 MST") },
+	"period":   period,
+}
+
+// templates hold the HTML templates of the documents of the templates directory
+var templates = template.Must(template.New("").Funcs(funcs).ParseFS(templateFiles, "templates/*.html"))
+
+// pages hold a template for every page of the web interface, made of the layout and of the page, which
+// defines the content of the layout
+var pages = parsePages()
+
+// Page is what a page of the web interface is rendered from. User is the logged in user, nil on the login
+// page, and CSRFToken the token its forms must send back. Data is specific to every page.
+type Page struct {
+	Title     string
+	User      *models.User
+	CSRFToken string
+	Flash     string
+	Error     string
+	Data      interface{}
+}
+
+// parsePages parse the layout with every page of the templates/ui directory
+func parsePages() map[string]*template.Template {
+	names, err := fs.Glob(templateFiles, "templates/ui/*.html")
+	if err != nil {
+		panic(err)
+	}
+	parsed := make(map[string]*template.Template)
+	for _, name := range names {
+		if path.Base(name) == "layout.html" {
+			continue
+		}
+		page := strings.TrimSuffix(path.Base(name), ".html")
+		parsed[page] = template.Must(template.New("layout.html").Funcs(funcs).ParseFS(templateFiles, "templates/ui/layout.html", name))
+	}
+	return parsed
+}
+
+// RenderPage write the page of the web interface with the provided name, like "transactions", to w
+func RenderPage(w io.Writer, name string, page Page) error {
+	tmpl, isOK := pages[name]
+	if !isOK {
+		return fmt.Errorf("%w: %q", ErrUnknownPage, name)
+	}
+	return tmpl.Execute(w, page)
+}
+
+// statementHTML write the statement to w as an HTML page
+func statementHTML(w io.Writer, statement *models.AccountStatement) error {
+	return templates.ExecuteTemplate(w, "statement.html", statement)
+}
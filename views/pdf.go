@@ -0,0 +1,222 @@
+package views
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Size of an A4 page in points
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+)
+
+// Widths of the characters 32 to 126 of the standard Helvetica and Helvetica-Bold fonts, in thousandths
+// of the font size, from their Adobe font metrics. Other characters are measured as defaultGlyphWidth.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+const defaultGlyphWidth = 556
+
+// winAnsi map the characters outside of ASCII and Latin-1 that WinAnsiEncoding has a code for
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+	'•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99, 'Š': 0x8A, 'š': 0x9A, 'Œ': 0x8C, 'œ': 0x9C,
+	'Ž': 0x8E, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// pdfDocument build a PDF file of A4 pages of text and lines. It only uses the standard Helvetica fonts,
+// which every reader provides, so no font is embedded and text is limited to WinAnsiEncoding. Coordinates
+// are in points from the bottom left corner of the page.
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	page  int
+}
+
+// newPage add a blank page to the document, the following drawings are made on it
+func (doc *pdfDocument) newPage() {
+	doc.pages = append(doc.pages, &bytes.Buffer{})
+	doc.page = len(doc.pages) - 1
+}
+
+// selectPage make the following drawings on the page with the provided index, counted from 0
+func (doc *pdfDocument) selectPage(index int) {
+	doc.page = index
+}
+
+// current return the content stream of the page drawings are made on
+func (doc *pdfDocument) current() *bytes.Buffer {
+	if len(doc.pages) == 0 {
+		doc.newPage()
+	}
+	return doc.pages[doc.page]
+}
+
+// text draw s with its baseline starting at x, y
+func (doc *pdfDocument) text(x, y, size float64, bold bool, s string) {
+	if s == "" {
+		return
+	}
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(doc.current(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+// textRight draw s with its baseline ending at x, y
+func (doc *pdfDocument) textRight(x, y, size float64, bold bool, s string) {
+	doc.text(x-textWidth(s, size, bold), y, size, bold, s)
+}
+
+// line draw a line of the provided width from x1, y1 to x2, y2
+func (doc *pdfDocument) line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(doc.current(), "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, y1, x2, y2)
+}
+
+// fill paint a gray rectangle, gray going from 0 for black to 1 for white
+func (doc *pdfDocument) fill(x, y, width, height, gray float64) {
+	fmt.Fprintf(doc.current(), "q %.2f g %.2f %.2f %.2f %.2f re f Q\n", gray, x, y, width, height)
+}
+
+// write the document to w
+func (doc *pdfDocument) write(w io.Writer) error {
+	if len(doc.pages) == 0 {
+		doc.newPage()
+	}
+	out := &pdfWriter{w: bufio.NewWriter(w)}
+	out.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 to 4 are the catalog, the page tree and the fonts, then every page is followed by its content
+	pageIDs := make([]string, len(doc.pages))
+	for i := range doc.pages {
+		pageIDs[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	out.object("<< /Type /Catalog /Pages 2 0 R >>")
+	out.object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(pageIDs, " "), len(doc.pages)))
+	out.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	out.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range doc.pages {
+		out.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, 6+2*i))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(page.Bytes()); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		out.object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+	}
+
+	xref := out.offset
+	out.printf("xref\n0 %d\n0000000000 65535 f \n", len(out.offsets)+1)
+	for _, offset := range out.offsets {
+		out.printf("%010d 00000 n \n", offset)
+	}
+	out.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(out.offsets)+1, xref)
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+// pdfWriter write the objects of a PDF file and remember their offsets for the cross-reference table
+type pdfWriter struct {
+	w       *bufio.Writer
+	offset  int
+	offsets []int
+	err     error
+}
+
+func (out *pdfWriter) printf(format string, args ...interface{}) {
+	if out.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(out.w, format, args...)
+	out.offset += n
+	out.err = err
+}
+
+// object write the next indirect object, objects are numbered from 1 in the order they are written
+func (out *pdfWriter) object(body string) {
+	out.offsets = append(out.offsets, out.offset)
+	out.printf("%d 0 obj\n%s\nendobj\n", len(out.offsets), body)
+}
+
+// pdfString encode s in WinAnsiEncoding and escape it for a PDF literal string, characters the encoding
+// does not have are replaced by a question mark
+func pdfString(s string) string {
+	var encoded strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			encoded.WriteByte('\\')
+			encoded.WriteRune(r)
+		case r == '\t' || r == '\n' || r == '\r':
+			encoded.WriteByte(' ')
+		case r >= ' ' && r < 0x7f:
+			encoded.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			encoded.WriteByte(byte(r))
+		default:
+			if code, isOK := winAnsi[r]; isOK {
+				encoded.WriteByte(code)
+			} else {
+				encoded.WriteByte('?')
+			}
+		}
+	}
+	return encoded.String()
+}
+
+// textWidth measure s drawn in Helvetica, or Helvetica-Bold, of the provided size
+func textWidth(s string, size float64, bold bool) float64 {
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, r := range s {
+		if r >= ' ' && r < 0x7f {
+			total += widths[r-' ']
+		} else {
+			total += defaultGlyphWidth
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// fitText shorten s with an ellipsis so that it is at most width wide
+func fitText(s string, width, size float64, bold bool) string {
+	if textWidth(s, size, bold) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(string(runes)+"...", size, bold) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "..."
+}
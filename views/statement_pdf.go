@@ -0,0 +1,160 @@
+package views
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"transaction_project/models"
+)
+
+// Layout of the statement pages, in points
+const (
+	pdfMargin     = 50
+	pdfRowHeight  = 14
+	pdfFontSize   = 9
+	pdfFooterSize = 8
+)
+
+// Columns of the transaction table of the statement, amounts are aligned on the right edge of their column
+const (
+	columnDate        = pdfMargin
+	columnTransaction = pdfMargin + 62
+	columnDescription = pdfMargin + 110
+	columnIn          = pageWidth - pdfMargin - 150
+	columnOut         = pageWidth - pdfMargin - 75
+	columnBalance     = pageWidth - pdfMargin
+	descriptionWidth  = columnIn - 70 - columnDescription
+)
+
+// statementLayout lay the statement out on the pages of a pdfDocument, starting a new page when the
+// next row does not fit
+type statementLayout struct {
+	doc       *pdfDocument
+	statement *models.AccountStatement
+	y         float64
+}
+
+// statementPDF write the statement to w as a PDF file
+func statementPDF(w io.Writer, statement *models.AccountStatement) error {
+	layout := &statementLayout{doc: &pdfDocument{}, statement: statement}
+	layout.header()
+	if len(statement.Sections) == 0 {
+		layout.doc.text(pdfMargin, layout.y, pdfFontSize+1, false, "No balance and no transaction in this period.")
+	}
+	for _, section := range statement.Sections {
+		layout.section(section)
+	}
+	layout.footers()
+	return layout.doc.write(w)
+}
+
+// header start the first page with the title, the holder, the account and the period of the statement
+func (layout *statementLayout) header() {
+	layout.doc.newPage()
+	layout.y = pageHeight - pdfMargin - 18
+	layout.doc.text(pdfMargin, layout.y, 18, true, "Account statement")
+	layout.y -= 24
+	for _, line := range []string{layout.statement.Holder, layout.statement.Account, period(layout.statement)} {
+		if line == "" {
+			continue
+		}
+		layout.doc.text(pdfMargin, layout.y, pdfFontSize+1, false, line)
+		layout.y -= pdfRowHeight
+	}
+	layout.y -= pdfRowHeight
+}
+
+// section draw the summary and the transactions of a currency
+func (layout *statementLayout) section(section models.StatementSection) {
+	currency := section.Currency
+	// Keep the title, the summary, the table header and the first row together
+	layout.ensure(9 * pdfRowHeight)
+	layout.doc.text(pdfMargin, layout.y, 13, true, currency)
+	layout.y -= 4
+	layout.doc.line(pdfMargin, layout.y, pageWidth-pdfMargin, layout.y, 0.5)
+	layout.y -= pdfRowHeight + 2
+
+	summary := []struct {
+		label  string
+		amount float64
+	}{
+		{"Opening balance on " + layout.statement.From.Format(dateLayout), section.Opening},
+		{"Money in", section.Incoming},
+		{"Money out", section.Outgoing},
+		{"Closing balance", section.Closing},
+	}
+	for _, line := range summary {
+		layout.doc.text(pdfMargin, layout.y, pdfFontSize+1, false, line.label)
+		layout.doc.textRight(columnIn, layout.y, pdfFontSize+1, false, formatAmount(line.amount, currency, false))
+		layout.y -= pdfRowHeight
+	}
+	layout.y -= pdfRowHeight / 2
+
+	layout.tableHeader(currency, false)
+	layout.row(layout.statement.From.Format(dateLayout), "", "Opening balance", "", "", formatAmount(section.Opening, currency, false), false)
+	for i, item := range section.Items {
+		if layout.y < pdfMargin+2*pdfRowHeight {
+			layout.doc.newPage()
+			layout.y = pageHeight - pdfMargin - pdfFontSize
+			layout.tableHeader(currency, true)
+		}
+		if i%2 == 0 {
+			layout.doc.fill(pdfMargin-4, layout.y-4, pageWidth-2*pdfMargin+8, pdfRowHeight, 0.95)
+		}
+		description := "To " + item.Counterparty
+		if item.In != 0 {
+			description = "From " + item.Counterparty
+		}
+		if item.Note != "" {
+			description += " - " + item.Note
+		}
+		layout.row(item.Date.Format(dateLayout), strconv.FormatUint(uint64(item.TransactionID), 10), description,
+			formatAmount(item.In, currency, true), formatAmount(item.Out, currency, true),
+			formatAmount(item.Balance, currency, false), false)
+	}
+	layout.ensure(pdfRowHeight)
+	layout.doc.line(pdfMargin, layout.y+pdfRowHeight-3, pageWidth-pdfMargin, layout.y+pdfRowHeight-3, 0.5)
+	layout.row("", "", "Closing balance", formatAmount(section.Incoming, currency, false),
+		formatAmount(section.Outgoing, currency, false), formatAmount(section.Closing, currency, false), true)
+	layout.y -= 2 * pdfRowHeight
+}
+
+// tableHeader draw the column titles of the transaction table
+func (layout *statementLayout) tableHeader(currency string, continued bool) {
+	title := "Description"
+	if continued {
+		title = fmt.Sprintf("Description (%s, continued)", currency)
+	}
+	layout.row("Date", "Transaction", title, "In", "Out", "Balance", true)
+	layout.doc.line(pdfMargin, layout.y+pdfRowHeight-3, pageWidth-pdfMargin, layout.y+pdfRowHeight-3, 0.5)
+}
+
+// row draw a row of the transaction table and move down to the next one
+func (layout *statementLayout) row(date, transaction, description, in, out, balance string, bold bool) {
+	doc, y := layout.doc, layout.y
+	doc.text(columnDate, y, pdfFontSize, bold, date)
+	doc.text(columnTransaction, y, pdfFontSize, bold, transaction)
+	doc.text(columnDescription, y, pdfFontSize, bold, fitText(description, descriptionWidth, pdfFontSize, bold))
+	doc.textRight(columnIn, y, pdfFontSize, bold, in)
+	doc.textRight(columnOut, y, pdfFontSize, bold, out)
+	doc.textRight(columnBalance, y, pdfFontSize, bold, balance)
+	layout.y -= pdfRowHeight
+}
+
+// ensure start a new page when less than height is left on the current one
+func (layout *statementLayout) ensure(height float64) {
+	if layout.y-height < pdfMargin {
+		layout.doc.newPage()
+		layout.y = pageHeight - pdfMargin - pdfFontSize
+	}
+}
+
+// footers number every page and repeat the account at its bottom
+func (layout *statementLayout) footers() {
+	doc := layout.doc
+	for i := range doc.pages {
+		doc.selectPage(i)
+		doc.text(pdfMargin, pdfMargin/2, pdfFooterSize, false, layout.statement.Account+", "+period(layout.statement))
+		doc.textRight(pageWidth-pdfMargin, pdfMargin/2, pdfFooterSize, false, fmt.Sprintf("Page %d of %d", i+1, len(doc.pages)))
+	}
+}
@@ -1 +0,0 @@
-package views
\ No newline at end of file
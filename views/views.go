@@ -0,0 +1,96 @@
+// Package views render the documents the application hands to its users, like the periodic statement
+// of an account, as HTML pages or PDF files, and the pages of the web interface.
+package views
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+	"transaction_project/models"
+)
+
+// Formats the documents can be rendered to
+const (
+	FormatHTML = "html"
+	FormatPDF  = "pdf"
+)
+
+var (
+	// ErrUnknownFormat is returned when a document is rendered to a format that is not supported.
+	ErrUnknownFormat = errors.New("views: unknown format")
+
+	// ErrUnknownPage is returned when a page of the web interface that does not exist is rendered.
+	ErrUnknownPage = errors.New("views: unknown page")
+)
+
+// dateLayout is the layout of the dates printed on the documents
+const dateLayout = "2006-01-02"
+
+// Supported return true if documents can be rendered to the format
+func Supported(format string) bool {
+	return format == FormatHTML || format == FormatPDF
+}
+
+// ContentType return the MIME type of the documents rendered to the format
+func ContentType(format string) string {
+	if format == FormatPDF {
+		return "application/pdf"
+	}
+	return "text/html; charset=utf-8"
+}
+
+// RenderStatement write the statement to w in the format
+func RenderStatement(w io.Writer, format string, statement *models.AccountStatement) error {
+	switch format {
+	case FormatHTML:
+		return statementHTML(w, statement)
+	case FormatPDF:
+		return statementPDF(w, statement)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// StatementFilename return the name of the file the statement is saved to in the format, the account and
+// the first day of the period
+func StatementFilename(statement *models.AccountStatement, format string) string {
+	account := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ' ' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, statement.Account)
+	return fmt.Sprintf("statement-%s-%s.%s", account, statement.From.Format(dateLayout), format)
+}
+
+// formatAmount format the amount with the decimals of the currency and thousands separators, empty for
+// a zero amount when blankZero is true
+func formatAmount(amount float64, currency string, blankZero bool) string {
+	decimals := models.CurrencyDecimals(currency)
+	if blankZero && math.Abs(amount) < math.Pow10(-decimals)/2 {
+		return ""
+	}
+	formatted := fmt.Sprintf("%.*f", decimals, math.Abs(amount))
+	integer, fraction := formatted, ""
+	if i := strings.IndexByte(formatted, '.'); i >= 0 {
+		integer, fraction = formatted[:i], formatted[i:]
+	}
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	if amount < 0 && formatted != fmt.Sprintf("%.*f", decimals, 0.0) {
+		return "-" + grouped.String() + fraction
+	}
+	return grouped.String() + fraction
+}
+
+// period return the period of the statement as printed on it, the last day is inclusive
+func period(statement *models.AccountStatement) string {
+	return statement.From.Format(dateLayout) + " to " + statement.To.Add(-time.Nanosecond).Format(dateLayout)
+}